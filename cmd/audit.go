@@ -0,0 +1,99 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/alertavert/gpt4-go/pkg/audit"
+	"github.com/alertavert/gpt4-go/pkg/config"
+)
+
+// runAudit implements `majordomo audit`, which prints the entries of the
+// audit log (as JSON lines) selected by the flags, or verifies its hash chain.
+func runAudit(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("audit", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var configPath, location, since, until string
+	var filter audit.Filter
+	var verify bool
+	fs.StringVar(&configPath, "config", "", "Path to the configuration file, where the audit log is configured")
+	fs.StringVar(&location, "log", "", "Path to the audit log; overrides the configured one")
+	fs.StringVar(&since, "since", "", "Only the entries from this date (YYYY-MM-DD, or RFC 3339) onwards")
+	fs.StringVar(&until, "until", "", "Only the entries before this date (YYYY-MM-DD, or RFC 3339)")
+	fs.StringVar(&filter.Project, "project", "", "Only the entries of this project")
+	fs.StringVar(&filter.File, "file", "", "Only the entries which sent, or saved, this file (relative to the project)")
+	fs.BoolVar(&verify, "verify", false, "Verify the hash chain of the audit log, instead of printing it")
+	fs.Usage = func() {
+		_, _ = fmt.Fprintln(stderr, "Usage: majordomo audit [flags]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if location == "" {
+		cfg, err := config.LoadConfig(configPath)
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "error loading config: %v\n", err)
+			return 1
+		}
+		location = cfg.Audit.Location
+	}
+	if location == "" {
+		_, _ = fmt.Fprintln(stderr, "the audit log is not configured")
+		return 1
+	}
+	location = os.ExpandEnv(location)
+
+	if verify {
+		n, err := audit.Verify(location)
+		if err != nil {
+			_, _ = fmt.Fprintln(stderr, err)
+			return 1
+		}
+		_, _ = fmt.Fprintf(stdout, "%d entries verified\n", n)
+		return 0
+	}
+
+	var err error
+	if filter.Since, err = parseDate(since); err != nil {
+		_, _ = fmt.Fprintf(stderr, "invalid -since: %v\n", err)
+		return 2
+	}
+	if filter.Until, err = parseDate(until); err != nil {
+		_, _ = fmt.Fprintf(stderr, "invalid -until: %v\n", err)
+		return 2
+	}
+	entries, err := audit.Query(location, filter)
+	if err != nil {
+		_, _ = fmt.Fprintln(stderr, err)
+		return 1
+	}
+	encoder := json.NewEncoder(stdout)
+	for _, e := range entries {
+		if err = encoder.Encode(e); err != nil {
+			_, _ = fmt.Fprintln(stderr, err)
+			return 1
+		}
+	}
+	return 0
+}
+
+// parseDate parses either a date (midnight, UTC) or an RFC 3339 timestamp.
+func parseDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -5,19 +5,27 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"github.com/alertavert/gpt4-go/pkg/completions"
 	"github.com/alertavert/gpt4-go/pkg/config"
 	"github.com/alertavert/gpt4-go/pkg/server"
+	"github.com/alertavert/gpt4-go/pkg/tracing"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"os"
+	"os/signal"
+	"syscall"
 )
 
 // Release version of the server. It is expected to be set during build.
 var Release = "UNKNOWN"
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "audit" {
+		os.Exit(runAudit(os.Args[2:], os.Stdout, os.Stderr))
+	}
 	var port int
 	var debug bool
 	var configPath string
@@ -47,6 +55,15 @@ func main() {
 		Str("instructions", cfg.AssistantsLocation).
 		Msg("Loaded config")
 
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing, Release)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Error setting up tracing")
+	}
+	if cfg.Tracing.Enabled {
+		log.Info().
+			Str("endpoint", cfg.Tracing.Endpoint).
+			Msg("Exporting traces")
+	}
 
 	majordomo, err := completions.NewMajordomo(cfg)
 	if err != nil {
@@ -73,6 +90,16 @@ func main() {
 		svr.SetDebugMode()
 	}
 	log.Info().Msgf("Server configured & running on port %d", port)
-	log.Fatal().Err(svr.Run()).
-		Msg("Majordomo server exited")
+	// Kubernetes sends SIGTERM, and waits for the grace period, before killing the pod.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err = svr.Run(ctx)
+	if err := shutdownTracing(context.Background()); err != nil {
+		log.Err(err).Msg("Error flushing the traces")
+	}
+	if err != nil {
+		log.Fatal().Err(err).
+			Msg("Majordomo server exited")
+	}
+	log.Info().Msg("Majordomo server stopped")
 }
@@ -0,0 +1,271 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+// Package api defines the types exchanged over the Majordomo REST API, which
+// are shared by the server and its clients; it only depends on the standard
+// library, so that the clients do not depend on the server.
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Prefix is the path under which the current version of the API is served.
+const Prefix = "/api/v1"
+
+// UserHeader names the user sending the prompt (e.g., set by an
+// authenticating proxy); it is recorded in the audit log.
+const UserHeader = "X-Majordomo-User"
+
+// The codes of the errors, in the ErrorResponse; they mirror the HTTP status
+// of the response, and are meant for the clients to branch on.
+const (
+	CodeInvalidRequest       = "invalid_request"
+	CodeForbidden            = "forbidden"
+	CodeNotFound             = "not_found"
+	CodeConflict             = "conflict"
+	CodeTooLarge             = "too_large"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeUnprocessable        = "unprocessable"
+	CodeInternal             = "internal"
+	CodeNotImplemented       = "not_implemented"
+	CodeUpstream             = "upstream_error"
+	CodeUnavailable          = "unavailable"
+)
+
+// CodeForStatus returns the error code for the HTTP status.
+func CodeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeInvalidRequest
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound, http.StatusMethodNotAllowed:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusRequestEntityTooLarge:
+		return CodeTooLarge
+	case http.StatusUnsupportedMediaType:
+		return CodeUnsupportedMediaType
+	case http.StatusUnprocessableEntity:
+		return CodeUnprocessable
+	case http.StatusNotImplemented:
+		return CodeNotImplemented
+	case http.StatusBadGateway:
+		return CodeUpstream
+	case http.StatusServiceUnavailable:
+		return CodeUnavailable
+	}
+	if status >= 400 && status < 500 {
+		return CodeInvalidRequest
+	}
+	return CodeInternal
+}
+
+// Error describes why a request failed.
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Details are specific to the error, e.g. the `rejected` paths of a
+	// forbidden request.
+	Details map[string]any `json:"details,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// ErrorResponse is the body of all the error responses.
+type ErrorResponse struct {
+	Error *Error `json:"error"`
+}
+
+// MessageResponse is the body of the responses which only confirm the request.
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+// PromptResponse is the body of the response to `POST /prompt`.
+type PromptResponse struct {
+	BotResponse
+	ThreadId   string `json:"thread_id"`
+	ThreadName string `json:"thread_name"`
+	// AudioURL is where the spoken response can be downloaded from, if
+	// `speak` was requested; SpeechError is why it could not be created.
+	AudioURL    string `json:"audio_url,omitempty"`
+	SpeechError string `json:"speech_error,omitempty"`
+}
+
+// ParseResponse is the body of the response to `POST /parse`.
+type ParseResponse struct {
+	// Message is the prompt, with the code snippets in it and the secrets redacted.
+	Message    string      `json:"message"`
+	Redactions []Redaction `json:"redactions"`
+}
+
+// CommandResponse is the body of the response to `POST /command`.
+type CommandResponse struct {
+	Message       string  `json:"message"`
+	Transcript    string  `json:"transcript"`
+	Intent        *Intent `json:"intent"`
+	ActiveProject string  `json:"active_project"`
+	Assistant     string  `json:"assistant,omitempty"`
+	ThreadId      string  `json:"thread_id,omitempty"`
+	ThreadName    string  `json:"thread_name,omitempty"`
+	// Result is the assistant's answer, for the `ask` and `read_back` intents.
+	Result *BotResponse `json:"result,omitempty"`
+	// Transcription is missing if the command was typed.
+	Transcription *Transcription `json:"transcription,omitempty"`
+}
+
+// SpeechResponse is the body of the response to `POST /speak?url=true`.
+type SpeechResponse struct {
+	// URL is where the audio file can be downloaded from.
+	URL         string `json:"url"`
+	ContentType string `json:"content_type"`
+	// Truncated is true if the text was too long, and only its beginning was converted.
+	Truncated bool `json:"truncated"`
+}
+
+// ProjectsResponse is the body of the response to `GET /projects`.
+type ProjectsResponse struct {
+	ActiveProject string    `json:"active_project"`
+	Projects      []Project `json:"projects"`
+}
+
+// ActiveProjectRequest is the body of `PUT /projects`.
+type ActiveProjectRequest struct {
+	ActiveProject string `json:"active_project"`
+}
+
+// ProjectDeletionResponse is the body of the response to
+// `DELETE /projects/:project_name`.
+type ProjectDeletionResponse struct {
+	Message string `json:"message"`
+	ProjectDeletion
+}
+
+// ConversationsResponse is the body of the response to
+// `GET /projects/:project_name/conversations`.
+type ConversationsResponse struct {
+	Project string   `json:"project"`
+	Threads []Thread `json:"threads"`
+}
+
+// ThreadMessagesResponse is the body of the response to
+// `GET /conversations/:thread_id/messages`.
+type ThreadMessagesResponse struct {
+	ThreadId string          `json:"thread_id"`
+	Messages []ThreadMessage `json:"messages"`
+}
+
+// FilesResponse is the body of the response to `GET /projects/:project_name/files`.
+type FilesResponse struct {
+	Project string `json:"project"`
+	// Files are the paths, relative to the project's location, of the files
+	// which can be included in the prompts.
+	Files []string `json:"files"`
+	// Truncated is true if there were more than `limit` files.
+	Truncated bool `json:"truncated"`
+}
+
+// RefreshResponse is the body of the response to `POST /assistants/refresh`.
+type RefreshResponse struct {
+	// Assistants is how many assistants were listed.
+	Assistants int `json:"assistants"`
+	// Duplicates are the IDs of the assistants sharing the same name.
+	Duplicates  map[string][]string `json:"duplicates"`
+	RefreshedAt time.Time           `json:"refreshed_at"`
+}
+
+// Snippet is a stored snippet, with its versions if the store keeps them.
+type Snippet struct {
+	SnippetInfo
+	Current  string           `json:"current,omitempty"`
+	Versions []SnippetVersion `json:"versions,omitempty"`
+}
+
+// SnippetsResponse is the body of the response to `GET /projects/:project_name/snippets`.
+type SnippetsResponse struct {
+	Project  string    `json:"project"`
+	Snippets []Snippet `json:"snippets"`
+}
+
+// SnippetResponse is the body of the response to
+// `GET /projects/:project_name/snippets/*path`.
+type SnippetResponse struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+	// Diff compares the snippet against the project's file, if requested.
+	Diff string `json:"diff,omitempty"`
+}
+
+// VersionResponse is the body of the response to
+// `GET /projects/:project_name/versions/:version_id`.
+type VersionResponse struct {
+	Version *SnippetVersion `json:"version"`
+	Content string          `json:"content"`
+}
+
+// VersionDiffResponse is the body of the response to
+// `GET /projects/:project_name/versions/:version_id/diff`.
+type VersionDiffResponse struct {
+	From *SnippetVersion `json:"from"`
+	To   *SnippetVersion `json:"to"`
+	Diff string          `json:"diff"`
+}
+
+// The types of the ChatMessage, sent by the clients over the `/ws` WebSocket.
+const (
+	// ChatPrompt sends the prompt on the session's Thread.
+	ChatPrompt = "prompt"
+	// ChatParse returns the prompt as it would be sent, without sending it.
+	ChatParse = "parse"
+	// ChatCancel cancels the prompt running.
+	ChatCancel = "cancel"
+	// ChatAssistant sends the next prompts to another assistant.
+	ChatAssistant = "assistant"
+)
+
+// The types of the ChatEvent, sent by the server over the `/ws` WebSocket.
+const (
+	// EventSession carries the session's state, when opened and whenever it changes.
+	EventSession = "session"
+	// EventProgress carries the progress of the prompt running, including
+	// its output and the snippets saved.
+	EventProgress  = "progress"
+	EventResponse  = "response"
+	EventParsed    = "parsed"
+	EventCancelled = "cancelled"
+	EventError     = "error"
+)
+
+// ChatMessage is sent by the client over the `/ws` WebSocket.
+type ChatMessage struct {
+	Type string `json:"type"`
+	// ID, if set, is returned in the events replying to the message.
+	ID string `json:"id,omitempty"`
+	// Prompt is the text of the `prompt` and `parse` messages.
+	Prompt string `json:"prompt,omitempty"`
+	// Save, if false, only previews the code snippets in the response.
+	Save *bool `json:"save,omitempty"`
+	// Assistant is the one switched to, by the `assistant` messages.
+	Assistant string `json:"assistant,omitempty"`
+}
+
+// ChatEvent is sent by the server over the `/ws` WebSocket; only the field
+// relevant to the Type is set.
+type ChatEvent struct {
+	Type string `json:"type"`
+	// ID is that of the ChatMessage the event replies to.
+	ID       string         `json:"id,omitempty"`
+	Session  *ChatState     `json:"session,omitempty"`
+	Progress *Progress      `json:"progress,omitempty"`
+	Response *BotResponse   `json:"response,omitempty"`
+	Parsed   *ParseResponse `json:"parsed,omitempty"`
+	Error    *Error         `json:"error,omitempty"`
+}
@@ -0,0 +1,39 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package api
+
+import "time"
+
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobCompleted JobStatus = "completed"
+	JobFailed    JobStatus = "failed"
+	JobCancelled JobStatus = "cancelled"
+)
+
+// Job is a prompt run in the background.
+type Job struct {
+	ID      string    `json:"id"`
+	Status  JobStatus `json:"status"`
+	Project string    `json:"project"`
+	// Request is the prompt, as submitted; the ID and name of the Thread are
+	// filled in when it is created.
+	Request PromptRequest `json:"request"`
+	// RunId is the ID of the OpenAI Run, once the prompt has been sent.
+	RunId      string       `json:"run_id,omitempty"`
+	Response   *BotResponse `json:"response,omitempty"`
+	Error      string       `json:"error,omitempty"`
+	CreatedAt  time.Time    `json:"created_at"`
+	StartedAt  *time.Time   `json:"started_at,omitempty"`
+	FinishedAt *time.Time   `json:"finished_at,omitempty"`
+}
+
+// Finished is true if the job is completed, failed or cancelled.
+func (j *Job) Finished() bool {
+	return j.Status == JobCompleted || j.Status == JobFailed || j.Status == JobCancelled
+}
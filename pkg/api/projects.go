@@ -0,0 +1,137 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package api
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Project is a project, as returned by (and sent to) `/projects`.
+type Project struct {
+	// ID stays the same when the project is renamed; the name, unless taken
+	// when created. It is ignored in the requests.
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Location    string `json:"location"`
+	// CodeSnippets is where the code snippets are saved; under the
+	// configured location, if missing.
+	CodeSnippets string `json:"code_snippets,omitempty"`
+	// Include and Exclude are globs (relative to the project's location) which
+	// restrict the files that can be sent to, or saved from, the LLM.
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// Thread models simply the ID and name of the Thread.
+// The ID is used to retrieve the Thread from the OpenAI API; while the name is
+// used to display the Thread in the UI.
+type Thread struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Assistant   string `json:"assistant"`
+	Description string `json:"description"`
+}
+
+// ThreadsDisposal is what happens to the threads of a deleted project.
+type ThreadsDisposal string
+
+const (
+	// ArchiveThreads keeps the threads, archived with the project, and on OpenAI.
+	ArchiveThreads ThreadsDisposal = "archive"
+	// PurgeThreads deletes the threads, on OpenAI too.
+	PurgeThreads ThreadsDisposal = "purge"
+)
+
+// DeleteProjectOptions are the options of `DELETE /projects/:project_name`.
+type DeleteProjectOptions struct {
+	// Threads defaults to ArchiveThreads.
+	Threads ThreadsDisposal
+	// PurgeSnippets removes the code snippets directory of the project, with
+	// the history of the snippets.
+	PurgeSnippets bool
+}
+
+// ProjectDeletion summarizes what deleting a project did.
+type ProjectDeletion struct {
+	Project string `json:"project"`
+	ID      string `json:"id"`
+	// ThreadsArchived are the threads kept, including those which could not
+	// be deleted on OpenAI, when purging.
+	ThreadsArchived int  `json:"threads_archived"`
+	ThreadsPurged   int  `json:"threads_purged"`
+	SnippetsPurged  bool `json:"snippets_purged"`
+	// ActiveProject is the active project after the deletion; it changes if
+	// the deleted project was the active one.
+	ActiveProject string `json:"active_project"`
+}
+
+// FixTestsRequest asks Majordomo to iteratively fix the failing tests of a project.
+type FixTestsRequest struct {
+	// The assistant to use; always required.
+	Assistant string `json:"assistant" validate:"required"`
+
+	// The packages to test, relative to the project's location (e.g., `./pkg/...`);
+	// if empty, all packages are tested. They are appended to the test command,
+	// so flags (e.g., `-exec`) are rejected.
+	Packages []string `json:"packages,omitempty"`
+
+	// An optional Thread ID to continue an existing conversation.
+	ThreadId string `json:"thread_id,omitempty"`
+
+	// The maximum number of prompts sent to the LLM; if zero, the configured
+	// `max_fix_iterations` is used.
+	MaxIterations int `json:"max_iterations,omitempty"`
+
+	// If true, the scratch worktree is not removed at the end.
+	KeepWorktree bool `json:"keep_worktree,omitempty"`
+}
+
+// Validate checks if the FixTestsRequest has all the required fields.
+func (fr *FixTestsRequest) Validate() error {
+	if fr.Assistant == "" {
+		return fmt.Errorf("Assistant field is required")
+	}
+	if fr.MaxIterations < 0 {
+		return fmt.Errorf("max_iterations cannot be negative")
+	}
+	for _, pkg := range fr.Packages {
+		if pkg == "" || strings.HasPrefix(pkg, "-") {
+			return fmt.Errorf("invalid package %q", pkg)
+		}
+	}
+	return nil
+}
+
+// TestResult is the outcome of a test run.
+type TestResult struct {
+	Passed      bool          `json:"passed"`
+	Output      string        `json:"output"`
+	FailedTests []string      `json:"failed_tests,omitempty"`
+	Duration    time.Duration `json:"duration"`
+}
+
+// FixIteration reports the outcome of each test run, and what was sent to
+// (and received from) the LLM to fix the failures.
+type FixIteration struct {
+	Iteration    int         `json:"iteration"`
+	TestResult   *TestResult `json:"test_result"`
+	FilesSent    []string    `json:"files_sent,omitempty"`
+	FilesChanged []string    `json:"files_changed,omitempty"`
+	Response     string      `json:"response,omitempty"`
+	Error        string      `json:"error,omitempty"`
+}
+
+// FixTestsReport is the final outcome of the test-fixing loop.
+type FixTestsReport struct {
+	Project    string         `json:"project"`
+	ThreadId   string         `json:"thread_id"`
+	Passed     bool           `json:"passed"`
+	Worktree   string         `json:"worktree,omitempty"`
+	Changed    []string       `json:"changed,omitempty"`
+	Iterations []FixIteration `json:"iterations"`
+}
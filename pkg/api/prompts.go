@@ -0,0 +1,200 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package api
+
+import "time"
+
+// PromptRequest is the body of `POST /prompt`, `POST /parse` and `POST /jobs`.
+type PromptRequest struct {
+	// The assistant to use (selected by the user); always required.
+	Assistant string `json:"assistant" validate:"required"`
+
+	// The project whose files are sent to (and saved from) the LLM; the
+	// active one, if empty.
+	Project string `json:"project,omitempty"`
+
+	// The Thread ID (if any) to keep track of past prompts/responses in the conversation.
+	// If empty, a new conversation is started.
+	ThreadId string `json:"thread_id,omitempty"`
+
+	// The name of the thread, used to identify the conversation.
+	// If both ThreadId and ThreadName are empty, a name will be suggested by the LLM.
+	ThreadName string `json:"thread_name,omitempty"`
+
+	// The user prompt.
+	Prompt string `json:"prompt" validate:"required"`
+
+	// Save, if false, only previews the code snippets in the response,
+	// without saving them; if missing, the snippets are saved.
+	Save *bool `json:"save,omitempty"`
+
+	// Speak, if true, also converts the text of the response (without the
+	// code blocks) to audio.
+	Speak bool `json:"speak,omitempty"`
+
+	// User who sent the prompt, recorded in the audit log; the one running
+	// the server, if empty.
+	User string `json:"user,omitempty"`
+}
+
+// ShouldSave is true unless saving the code snippets was explicitly disabled.
+func (pr *PromptRequest) ShouldSave() bool {
+	return pr.Save == nil || *pr.Save
+}
+
+// CodeBlockResult describes a code block in the LLM's response, and what was
+// done with it.
+type CodeBlockResult struct {
+	Path     string `json:"path,omitempty"`
+	Language string `json:"language,omitempty"`
+	// Format is the edit format used by the block, if any.
+	Format string `json:"format,omitempty"`
+	// Location is where the file was saved, if it was, and the store can tell.
+	Location string `json:"location,omitempty"`
+	// Bytes is the size of the file, after applying any edits.
+	Bytes int `json:"bytes"`
+	// Changed is true if the file differs from the one in the project, or is new.
+	Changed bool `json:"changed"`
+	Saved   bool `json:"saved"`
+	// Reason explains why the block could not be saved.
+	Reason string `json:"reason,omitempty"`
+}
+
+// EditFailure reports an edit which could not be applied.
+type EditFailure struct {
+	Path   string `json:"path"`
+	Format string `json:"format"`
+	// Line is the line of the bot response where the code block starts.
+	Line int `json:"line"`
+	// Hunk is the (1-based) position of the edit in the code block.
+	Hunk int `json:"hunk"`
+	// Search are the lines which were looked for.
+	Search string `json:"search,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// BotResponse is the LLM's response, split into its explanatory text, the
+// code blocks and the shell commands.
+type BotResponse struct {
+	// Message is the full response, as received from the LLM.
+	Message string `json:"message"`
+	// Text is the response, without the code blocks and the commands.
+	Text         string            `json:"text"`
+	CodeBlocks   []CodeBlockResult `json:"code_blocks"`
+	Commands     []string          `json:"commands"`
+	EditFailures []EditFailure     `json:"edit_failures,omitempty"`
+	// Saved is false if the code blocks were only previewed, and not saved.
+	Saved bool `json:"saved"`
+}
+
+// Redaction reports a secret that was replaced by a placeholder; the secret
+// itself is never reported.
+type Redaction struct {
+	Rule        string `json:"rule"`
+	Source      string `json:"source"`
+	Placeholder string `json:"placeholder"`
+}
+
+// ProgressStage is a step in running a prompt.
+type ProgressStage string
+
+const (
+	// ProgressThread is reported once the prompt's Thread is known, after
+	// creating it if necessary.
+	ProgressThread ProgressStage = "thread"
+	// ProgressRun is reported when the Run is created, and every time its
+	// status changes.
+	ProgressRun ProgressStage = "run"
+	// ProgressOutput carries the text of the response, as soon as the Run
+	// completes, before its code blocks are processed; it is the whole text,
+	// as the response is not streamed.
+	ProgressOutput ProgressStage = "output"
+	// ProgressSnippetSaved is reported for each code snippet saved from the response.
+	ProgressSnippetSaved ProgressStage = "snippet_saved"
+)
+
+// Progress is reported while running a prompt; only the fields relevant to
+// the Stage are set.
+type Progress struct {
+	Stage      ProgressStage `json:"stage"`
+	ThreadId   string        `json:"thread_id,omitempty"`
+	ThreadName string        `json:"thread_name,omitempty"`
+	RunId      string        `json:"run_id,omitempty"`
+	// RunStatus is the status of the OpenAI Run, e.g. `queued` or `in_progress`.
+	RunStatus string `json:"run_status,omitempty"`
+	Text      string `json:"text,omitempty"`
+	// Path is the path of the snippet saved.
+	Path string `json:"path,omitempty"`
+}
+
+// ChatState is what a chat session is bound to: the prompts are sent to the
+// assistant, on the Thread of the project.
+type ChatState struct {
+	Project   string `json:"project"`
+	Assistant string `json:"assistant"`
+	// ThreadId is empty until the first prompt creates a new Thread, named
+	// ThreadName (or as suggested by the LLM, if empty).
+	ThreadId   string `json:"thread_id,omitempty"`
+	ThreadName string `json:"thread_name,omitempty"`
+}
+
+// ThreadMessage is a message in a Thread, either a prompt (as it was sent,
+// with the code included) or the assistant's response.
+type ThreadMessage struct {
+	ID   string `json:"id"`
+	Role string `json:"role"`
+	// Text joins all the text parts of the message.
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SpeechRequest is the text to convert to audio; Voice, Format and Speed
+// default to the configured ones, if not set.
+type SpeechRequest struct {
+	Text   string  `json:"text"`
+	Voice  string  `json:"voice,omitempty"`
+	Format string  `json:"format,omitempty"`
+	Speed  float64 `json:"speed,omitempty"`
+}
+
+// TranscriptionOptions describe the recording, and how to transcribe it;
+// Language and Prompt default to the configured ones.
+type TranscriptionOptions struct {
+	Filename    string
+	ContentType string
+	Language    string
+	Prompt      string
+	// Translate transcribes the recording in English, whatever its language.
+	Translate bool
+}
+
+// Segment is a part of the transcription, with its timestamps (in seconds).
+type Segment struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+}
+
+// Transcription is the text of a recording.
+type Transcription struct {
+	Text string `json:"text"`
+	// Language is the (detected, or hinted) language of the recording.
+	Language   string    `json:"language,omitempty"`
+	Duration   float64   `json:"duration"`
+	Format     string    `json:"format"`
+	Translated bool      `json:"translated"`
+	Transcoded bool      `json:"transcoded"`
+	Segments   []Segment `json:"segments"`
+}
+
+// Intent is the classification of a spoken command, with its arguments
+// (e.g., the name of the project to switch to).
+type Intent struct {
+	Name string            `json:"name"`
+	Args map[string]string `json:"args,omitempty"`
+	// Matched is the template which matched the command; empty if the intent
+	// was inferred by the LLM, or is the default.
+	Matched string `json:"matched,omitempty"`
+}
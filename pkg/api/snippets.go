@@ -0,0 +1,27 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package api
+
+import "time"
+
+// SnippetInfo describes a stored code snippet.
+type SnippetInfo struct {
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// SnippetVersion describes one saved version of a code snippet.
+type SnippetVersion struct {
+	ID        string    `json:"id"`
+	Path      string    `json:"path"`
+	ThreadId  string    `json:"thread_id,omitempty"`
+	RunId     string    `json:"run_id,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	Hash      string    `json:"hash"`
+	Size      int       `json:"size"`
+	// RestoredFrom is the ID of the version this one was restored from, if any.
+	RestoredFrom string `json:"restored_from,omitempty"`
+}
@@ -0,0 +1,15 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+package audio_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"testing"
+)
+
+func TestAudio(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Audio Suite")
+}
@@ -0,0 +1,173 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+// Package audio detects the format of the audio recordings sent for
+// transcription, and converts those which cannot be transcribed as they are.
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"mime"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrUnsupported is returned for audio formats which cannot be transcribed.
+	ErrUnsupported = errors.New("unsupported audio format")
+	// ErrTooLarge is returned when the audio exceeds the maximum size.
+	ErrTooLarge = errors.New("audio too large")
+	// ErrTooLong is returned when the audio exceeds the maximum duration.
+	ErrTooLong = errors.New("audio too long")
+)
+
+// Format is a container format for audio recordings.
+type Format struct {
+	Name string `json:"name"`
+	// Extension is used to name the upload, which is how the format is
+	// conveyed to the transcription API.
+	Extension   string `json:"extension"`
+	ContentType string `json:"content_type"`
+	// Supported is true if the transcription API accepts the format.
+	Supported bool `json:"supported"`
+}
+
+// The known formats.
+var (
+	MP3  = Format{Name: "mp3", Extension: "mp3", ContentType: "audio/mpeg", Supported: true}
+	MP4  = Format{Name: "mp4", Extension: "mp4", ContentType: "audio/mp4", Supported: true}
+	M4A  = Format{Name: "m4a", Extension: "m4a", ContentType: "audio/x-m4a", Supported: true}
+	WAV  = Format{Name: "wav", Extension: "wav", ContentType: "audio/wav", Supported: true}
+	WebM = Format{Name: "webm", Extension: "webm", ContentType: "audio/webm", Supported: true}
+	Ogg  = Format{Name: "ogg", Extension: "ogg", ContentType: "audio/ogg", Supported: true}
+	FLAC = Format{Name: "flac", Extension: "flac", ContentType: "audio/flac", Supported: true}
+	AIFF = Format{Name: "aiff", Extension: "aiff", ContentType: "audio/aiff"}
+	AMR  = Format{Name: "amr", Extension: "amr", ContentType: "audio/amr"}
+	CAF  = Format{Name: "caf", Extension: "caf", ContentType: "audio/x-caf"}
+)
+
+// Formats are all the known formats.
+var Formats = []Format{MP3, MP4, M4A, WAV, WebM, Ogg, FLAC, AIFF, AMR, CAF}
+
+// aliases are the other content types, and extensions, used for the formats.
+var aliases = map[string]Format{
+	"audio/mp3":       MP3,
+	"audio/mpga":      MP3,
+	"mpga":            MP3,
+	"mpeg":            MP3,
+	"audio/m4a":       M4A,
+	"video/mp4":       MP4,
+	"audio/wave":      WAV,
+	"audio/x-wav":     WAV,
+	"audio/vnd.wave":  WAV,
+	"video/webm":      WebM,
+	"application/ogg": Ogg,
+	"audio/opus":      Ogg,
+	"oga":             Ogg,
+	"opus":            Ogg,
+	"audio/x-flac":    FLAC,
+	"audio/x-aiff":    AIFF,
+	"aif":             AIFF,
+	"audio/x-caf":     CAF,
+}
+
+// Detect returns the format of the audio, from its first bytes (which are
+// the most reliable, browsers often send WebM recordings as `audio/mpeg`) or,
+// if they are not recognized, from its content type or the file extension.
+func Detect(data []byte, filename, contentType string) (Format, error) {
+	if f, ok := detectMagic(data); ok {
+		return f, nil
+	}
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		if f, ok := lookup(mediaType, func(f Format) string { return f.ContentType }); ok {
+			return f, nil
+		}
+	}
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(filename), "."))
+	if f, ok := lookup(ext, func(f Format) string { return f.Extension }); ok {
+		return f, nil
+	}
+	return Format{}, fmt.Errorf("%w: %s (%s)", ErrUnsupported, filename, contentType)
+}
+
+func lookup(key string, field func(Format) string) (Format, bool) {
+	if key == "" {
+		return Format{}, false
+	}
+	for _, f := range Formats {
+		if field(f) == key {
+			return f, true
+		}
+	}
+	f, ok := aliases[key]
+	return f, ok
+}
+
+// detectMagic recognizes the format from the signature at the start of the data.
+func detectMagic(data []byte) (Format, bool) {
+	switch {
+	case len(data) >= 12 && bytes.HasPrefix(data, []byte("RIFF")) && string(data[8:12]) == "WAVE":
+		return WAV, true
+	case len(data) >= 12 && bytes.HasPrefix(data, []byte("FORM")) &&
+		(string(data[8:12]) == "AIFF" || string(data[8:12]) == "AIFC"):
+		return AIFF, true
+	case len(data) >= 12 && string(data[4:8]) == "ftyp":
+		if strings.HasPrefix(string(data[8:12]), "M4A") {
+			return M4A, true
+		}
+		return MP4, true
+	case bytes.HasPrefix(data, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		return WebM, true
+	case bytes.HasPrefix(data, []byte("OggS")):
+		return Ogg, true
+	case bytes.HasPrefix(data, []byte("fLaC")):
+		return FLAC, true
+	case bytes.HasPrefix(data, []byte("#!AMR")):
+		return AMR, true
+	case bytes.HasPrefix(data, []byte("caff")):
+		return CAF, true
+	case bytes.HasPrefix(data, []byte("ID3")):
+		return MP3, true
+	case len(data) >= 2 && data[0] == 0xFF && data[1]&0xE0 == 0xE0 && data[1]&0x06 != 0:
+		// MPEG audio frame sync, with a layer (which AAC ADTS frames lack).
+		return MP3, true
+	}
+	return Format{}, false
+}
+
+// WAVDuration returns the duration of a WAV recording, from its header; it
+// returns false if the header cannot be parsed.
+func WAVDuration(data []byte) (time.Duration, bool) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return 0, false
+	}
+	var byteRate uint32
+	for pos := 12; pos+8 <= len(data); {
+		id := string(data[pos : pos+4])
+		size := binary.LittleEndian.Uint32(data[pos+4 : pos+8])
+		body := pos + 8
+		switch id {
+		case "fmt ":
+			if body+12 > len(data) {
+				return 0, false
+			}
+			byteRate = binary.LittleEndian.Uint32(data[body+8 : body+12])
+		case "data":
+			if byteRate == 0 {
+				return 0, false
+			}
+			// Streamed recordings may not know the size of the data in advance.
+			if size == 0 || size == 0xFFFFFFFF || body+int(size) > len(data) {
+				size = uint32(len(data) - body)
+			}
+			return time.Duration(float64(size) / float64(byteRate) * float64(time.Second)), true
+		}
+		pos = body + int(size) + int(size%2)
+	}
+	return 0, false
+}
@@ -0,0 +1,109 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package audio_test
+
+import (
+	"context"
+	"encoding/binary"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alertavert/gpt4-go/pkg/audio"
+)
+
+// wav returns the header of a 16kHz, 16-bit mono WAV recording, lasting `seconds`.
+func wav(seconds int) []byte {
+	const byteRate = 16000 * 2
+	data := []byte("RIFF\x00\x00\x00\x00WAVEfmt ")
+	data = binary.LittleEndian.AppendUint32(data, 16)
+	data = binary.LittleEndian.AppendUint16(data, 1)
+	data = binary.LittleEndian.AppendUint16(data, 1)
+	data = binary.LittleEndian.AppendUint32(data, 16000)
+	data = binary.LittleEndian.AppendUint32(data, byteRate)
+	data = binary.LittleEndian.AppendUint16(data, 2)
+	data = binary.LittleEndian.AppendUint16(data, 16)
+	data = append(data, "data"...)
+	data = binary.LittleEndian.AppendUint32(data, uint32(seconds*byteRate))
+	return data
+}
+
+var _ = Describe("Audio formats", func() {
+	Context("detecting the format", func() {
+		It("should trust the magic bytes over the name and content type", func() {
+			f, err := audio.Detect([]byte{0x1A, 0x45, 0xDF, 0xA3, 0x01}, "audio.mp3", "audio/mpeg")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(f).To(Equal(audio.WebM))
+		})
+		It("should recognize the common containers", func() {
+			for data, expected := range map[string]audio.Format{
+				"OggS\x00\x02":             audio.Ogg,
+				"ID3\x04\x00":              audio.MP3,
+				"\xFF\xFB\x90\x64":         audio.MP3,
+				"fLaC\x00\x00":             audio.FLAC,
+				"\x00\x00\x00\x20ftypM4A ": audio.M4A,
+				"\x00\x00\x00\x20ftypisom": audio.MP4,
+				"FORM\x00\x00\x00\x00AIFF": audio.AIFF,
+				"#!AMR\n":                  audio.AMR,
+			} {
+				f, err := audio.Detect([]byte(data), "", "")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(f).To(Equal(expected), "for %q", data)
+			}
+			f, err := audio.Detect(wav(1), "", "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(f).To(Equal(audio.WAV))
+		})
+		It("should fall back to the content type, then to the extension", func() {
+			f, err := audio.Detect([]byte("????"), "recording", "audio/webm;codecs=opus")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(f).To(Equal(audio.WebM))
+			f, err = audio.Detect([]byte("????"), "recording.OGA", "application/octet-stream")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(f).To(Equal(audio.Ogg))
+		})
+		It("should fail for unknown formats", func() {
+			_, err := audio.Detect([]byte("????"), "notes.txt", "text/plain")
+			Expect(err).To(MatchError(audio.ErrUnsupported))
+		})
+	})
+
+	Context("reading the WAV header", func() {
+		It("should compute the duration", func() {
+			d, ok := audio.WAVDuration(append(wav(3), make([]byte, 3*32000)...))
+			Expect(ok).To(BeTrue())
+			Expect(d).To(Equal(3 * time.Second))
+		})
+		It("should use the declared size of truncated data", func() {
+			d, ok := audio.WAVDuration(wav(600))
+			Expect(ok).To(BeTrue())
+			Expect(d).To(BeNumerically("<", time.Second))
+		})
+		It("should not parse other formats", func() {
+			_, ok := audio.WAVDuration([]byte("OggS\x00\x02"))
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Context("transcoding", func() {
+		It("should fail without a command", func() {
+			var t *audio.Transcoder
+			_, err := t.Transcode(context.Background(), []byte("#!AMR\n"), audio.AMR)
+			Expect(err).To(MatchError(audio.ErrUnsupported))
+		})
+		It("should run the command on the recording", func() {
+			t := &audio.Transcoder{Command: []string{"cp", "{input}", "{output}"}}
+			data, err := t.Transcode(context.Background(), []byte("#!AMR\n"), audio.AMR)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(Equal("#!AMR\n"))
+		})
+		It("should report the failures of the command", func() {
+			t := &audio.Transcoder{Command: []string{"sh", "-c", "echo bad input >&2; exit 1"}}
+			_, err := t.Transcode(context.Background(), []byte("#!AMR\n"), audio.AMR)
+			Expect(err).To(MatchError(ContainSubstring("bad input")))
+		})
+	})
+})
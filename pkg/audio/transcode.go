@@ -0,0 +1,77 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package audio
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// InputPlaceholder and OutputPlaceholder are replaced, in the transcoder's
+	// command, by the paths of the original recording and of the converted one.
+	InputPlaceholder  = "{input}"
+	OutputPlaceholder = "{output}"
+
+	// TranscodeTimeout is the maximum time allowed for the conversion.
+	TranscodeTimeout = 2 * time.Minute
+)
+
+// Transcoder converts the recordings in an unsupported Format to MP3, by
+// running an external command, e.g.:
+//
+//	ffmpeg -y -i {input} -f mp3 {output}
+type Transcoder struct {
+	Command []string
+}
+
+// Transcode converts the audio, in the `from` format, to MP3.
+func (t *Transcoder) Transcode(ctx context.Context, data []byte, from Format) ([]byte, error) {
+	if t == nil || len(t.Command) == 0 {
+		return nil, fmt.Errorf("%w: %s, and no transcoder is configured", ErrUnsupported, from.Name)
+	}
+	dir, err := os.MkdirTemp("", "majordomo-audio-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	input := filepath.Join(dir, "input."+from.Extension)
+	output := filepath.Join(dir, "output."+MP3.Extension)
+	if err = os.WriteFile(input, data, 0600); err != nil {
+		return nil, err
+	}
+	args := make([]string, len(t.Command))
+	for i, arg := range t.Command {
+		arg = strings.ReplaceAll(arg, InputPlaceholder, input)
+		args[i] = strings.ReplaceAll(arg, OutputPlaceholder, output)
+	}
+	ctx, cancel := context.WithTimeout(ctx, TranscodeTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err = cmd.Run(); err != nil {
+		return nil, fmt.Errorf("error converting %s audio: %w: %s", from.Name, err,
+			strings.TrimSpace(stderr.String()))
+	}
+	converted, err := os.ReadFile(output)
+	if err != nil {
+		return nil, fmt.Errorf("the transcoder did not create the converted audio: %w", err)
+	}
+	log.Debug().
+		Str("from", from.Name).
+		Int("size", len(data)).
+		Int("converted_size", len(converted)).
+		Msg("audio converted")
+	return converted, nil
+}
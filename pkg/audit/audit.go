@@ -0,0 +1,395 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+// Package audit keeps an append-only log of what is sent to, and received
+// from, the LLM: which files, from which project, and which were written back.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/alertavert/gpt4-go/pkg/config"
+	"github.com/alertavert/gpt4-go/pkg/preprocessors"
+)
+
+const (
+	// DefaultMaxSizeMB is the size of the log file, after which it is rotated, if not configured.
+	DefaultMaxSizeMB = 100
+	// DefaultMaxFiles is the number of rotated log files kept, if not configured.
+	DefaultMaxFiles = 10
+)
+
+const (
+	// EventPrompt records a prompt sent to the LLM.
+	EventPrompt = "prompt"
+	// EventResponse records the snippets written back from the LLM's response.
+	EventResponse = "response"
+)
+
+// ErrTampered is returned by Verify when the hash chain is broken.
+var ErrTampered = errors.New("audit log tampered with")
+
+// File is a source file included in a prompt; its hash is of the contents
+// read from the project, before any secret was redacted.
+type File struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+	Bytes  int    `json:"bytes"`
+}
+
+// Files describes the files in the code map, sorted by path.
+func Files(codeMap preprocessors.SourceCodeMap) []File {
+	files := make([]File, 0, len(codeMap))
+	for path, content := range codeMap {
+		sum := sha256.Sum256([]byte(content))
+		files = append(files, File{Path: path, SHA256: hex.EncodeToString(sum[:]), Bytes: len(content)})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files
+}
+
+// Entry is a line of the audit log.
+type Entry struct {
+	Time      time.Time `json:"time"`
+	Event     string    `json:"event"`
+	User      string    `json:"user"`
+	Project   string    `json:"project"`
+	Assistant string    `json:"assistant,omitempty"`
+	// ThreadId is not known yet when the prompt starts a new thread; it is
+	// recorded in the response entry.
+	ThreadId   string `json:"thread_id,omitempty"`
+	ThreadName string `json:"thread_name,omitempty"`
+	RunId      string `json:"run_id,omitempty"`
+	// Files are those included in the prompt.
+	Files []File `json:"files,omitempty"`
+	// Redactions are the secrets replaced by placeholders in the prompt.
+	Redactions []preprocessors.Redaction `json:"redactions,omitempty"`
+	// Snippets are the paths of the files saved from the response.
+	Snippets []string `json:"snippets,omitempty"`
+
+	// PrevHash and Hash chain the entries, if enabled: Hash is computed over
+	// the entry (with PrevHash, and without Hash).
+	PrevHash string `json:"prev_hash,omitempty"`
+	Hash     string `json:"hash,omitempty"`
+}
+
+// computeHash returns the hash of the entry, ignoring its Hash field.
+func (e Entry) computeHash() (string, error) {
+	e.Hash = ""
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Logger appends the entries to a JSONL file, rotating it when it grows
+// beyond the configured size; it is safe for concurrent use.
+// A nil Logger discards the entries.
+type Logger struct {
+	location  string
+	maxBytes  int64
+	maxFiles  int
+	chain     bool
+	localUser string
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	lastHash string
+}
+
+// NewLogger opens the audit log configured in `cfg`; it returns nil if the
+// audit log is disabled (i.e., its location is not configured).
+func NewLogger(cfg config.AuditConfig) (*Logger, error) {
+	if cfg.Location == "" {
+		return nil, nil
+	}
+	l := &Logger{
+		location: os.ExpandEnv(cfg.Location),
+		maxBytes: int64(cfg.MaxSizeMB) << 20,
+		maxFiles: cfg.MaxFiles,
+		chain:    cfg.HashChain,
+	}
+	if l.maxBytes <= 0 {
+		l.maxBytes = DefaultMaxSizeMB << 20
+	}
+	if l.maxFiles <= 0 {
+		l.maxFiles = DefaultMaxFiles
+	}
+	if u, err := user.Current(); err == nil {
+		l.localUser = u.Username
+	}
+	// The chain continues from the last entry, possibly in a rotated file.
+	for _, path := range l.Paths() {
+		last, err := lastEntry(path)
+		if err != nil {
+			return nil, err
+		}
+		if last != nil {
+			l.lastHash = last.Hash
+		}
+	}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Record appends the entry to the log, filling in its time, its user (the
+// one running the server, if not known) and its hashes.
+func (l *Logger) Record(e Entry) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	if e.User == "" {
+		e.User = l.localUser
+	}
+	if l.chain {
+		e.PrevHash = l.lastHash
+		hash, err := e.computeHash()
+		if err != nil {
+			return err
+		}
+		e.Hash = hash
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	if l.size > 0 && l.size+int64(len(line)) > l.maxBytes {
+		if err = l.rotate(); err != nil {
+			return fmt.Errorf("error rotating the audit log: %w", err)
+		}
+	}
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("error writing the audit log: %w", err)
+	}
+	l.lastHash = e.Hash
+	return nil
+}
+
+// Close closes the log file.
+func (l *Logger) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+// Paths are the log files, oldest first: the rotated ones (`audit.jsonl.N`
+// ... `audit.jsonl.1`), then the current one, if they exist.
+func (l *Logger) Paths() []string {
+	return paths(l.location)
+}
+
+func paths(location string) []string {
+	var found []string
+	if _, err := os.Stat(location); err == nil {
+		found = append(found, location)
+	}
+	// The rotated files are numbered without gaps.
+	for i := 1; ; i++ {
+		path := rotated(location, i)
+		if _, err := os.Stat(path); err != nil {
+			break
+		}
+		found = append(found, path)
+	}
+	for i, j := 0, len(found)-1; i < j; i, j = i+1, j-1 {
+		found[i], found[j] = found[j], found[i]
+	}
+	return found
+}
+
+func rotated(location string, i int) string {
+	if i == 0 {
+		return location
+	}
+	return fmt.Sprintf("%s.%d", location, i)
+}
+
+// open opens the current log file, for appending; the caller must hold the lock.
+func (l *Logger) open() error {
+	f, err := os.OpenFile(l.location, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("error opening the audit log: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	l.file, l.size = f, info.Size()
+	return nil
+}
+
+// rotate renames the current file to `.1` (and `.1` to `.2`, and so on,
+// dropping the oldest), then starts a new one; the caller must hold the lock.
+func (l *Logger) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+	if err := os.Remove(rotated(l.location, l.maxFiles)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for i := l.maxFiles - 1; i >= 0; i-- {
+		err := os.Rename(rotated(l.location, i), rotated(l.location, i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return l.open()
+}
+
+// lastEntry returns the last entry in the file, or nil if it is empty.
+func lastEntry(path string) (*Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	data = bytes.TrimRight(data, "\n")
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var e Entry
+	if err = json.Unmarshal(data[bytes.LastIndexByte(data, '\n')+1:], &e); err != nil {
+		return nil, fmt.Errorf("error reading the last entry of %s: %w", path, err)
+	}
+	return &e, nil
+}
+
+// Filter selects the entries of the log; its zero value selects all of them.
+type Filter struct {
+	// Since and Until bound the time of the entries (Until excluded).
+	Since, Until time.Time
+	Project      string
+	// File selects the entries which included, or wrote back, the file.
+	File string
+}
+
+// Match is true if the entry is selected by the filter.
+func (f Filter) Match(e *Entry) bool {
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !e.Time.Before(f.Until) {
+		return false
+	}
+	if f.Project != "" && e.Project != f.Project {
+		return false
+	}
+	if f.File == "" {
+		return true
+	}
+	for _, file := range e.Files {
+		if file.Path == f.File {
+			return true
+		}
+	}
+	for _, path := range e.Snippets {
+		if path == f.File {
+			return true
+		}
+	}
+	return false
+}
+
+// Query returns the entries, oldest first, of the log at `location` (and of
+// its rotated files) which match the filter.
+func Query(location string, filter Filter) ([]Entry, error) {
+	var entries []Entry
+	err := scan(location, func(e *Entry, _ string, _ int) error {
+		if filter.Match(e) {
+			entries = append(entries, *e)
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// Verify checks the hash chain of the log at `location`, across its rotated
+// files; the first entry of the oldest file is trusted, as its predecessors
+// may have been rotated away. It returns the number of entries checked.
+func Verify(location string) (int, error) {
+	var prev *Entry
+	checked := 0
+	err := scan(location, func(e *Entry, path string, line int) error {
+		if e.Hash == "" {
+			return fmt.Errorf("%w: %s:%d is not hashed (is hash_chain enabled?)", ErrTampered, path, line)
+		}
+		hash, err := e.computeHash()
+		if err != nil {
+			return err
+		}
+		if e.Hash != hash {
+			return fmt.Errorf("%w: %s:%d does not match its hash", ErrTampered, path, line)
+		}
+		if prev != nil && e.PrevHash != prev.Hash {
+			return fmt.Errorf("%w: %s:%d does not follow the previous entry", ErrTampered, path, line)
+		}
+		prev = e
+		checked++
+		return nil
+	})
+	return checked, err
+}
+
+// scan calls `fn` for each entry of the log, oldest first.
+func scan(location string, fn func(e *Entry, path string, line int) error) error {
+	for _, path := range paths(location) {
+		if err := scanFile(path, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func scanFile(path string, fn func(e *Entry, path string, line int) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var e Entry
+		if err = json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return fmt.Errorf("error reading %s:%d: %w", path, line, err)
+		}
+		if err = fn(&e, path, line); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,175 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package audit_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alertavert/gpt4-go/pkg/audit"
+	"github.com/alertavert/gpt4-go/pkg/config"
+	"github.com/alertavert/gpt4-go/pkg/preprocessors"
+)
+
+var _ = Describe("Audit log", func() {
+	var (
+		tmpDir   string
+		location string
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = os.MkdirTemp("", "audit-test-")
+		Expect(err).NotTo(HaveOccurred())
+		location = filepath.Join(tmpDir, "audit.jsonl")
+	})
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	day := func(d int) time.Time {
+		return time.Date(2025, time.March, d, 12, 0, 0, 0, time.UTC)
+	}
+
+	It("is disabled if not configured", func() {
+		l, err := audit.NewLogger(config.AuditConfig{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(l).To(BeNil())
+		Expect(l.Record(audit.Entry{Event: audit.EventPrompt})).To(Succeed())
+		Expect(l.Close()).To(Succeed())
+	})
+
+	It("hashes the files included in the prompt", func() {
+		files := audit.Files(preprocessors.SourceCodeMap{
+			"pkg/b.go": "package b",
+			"pkg/a.go": "",
+		})
+		Expect(files).To(HaveLen(2))
+		Expect(files[0].Path).To(Equal("pkg/a.go"))
+		Expect(files[0].SHA256).To(Equal("e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"))
+		Expect(files[1].Bytes).To(Equal(len("package b")))
+	})
+
+	It("appends the entries, and queries them by date, project or file", func() {
+		l, err := audit.NewLogger(config.AuditConfig{Location: location})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(l.Record(audit.Entry{Time: day(1), Event: audit.EventPrompt, User: "alice", Project: "one",
+			Files: []audit.File{{Path: "main.go", SHA256: "abc"}}})).To(Succeed())
+		Expect(l.Record(audit.Entry{Time: day(2), Event: audit.EventResponse, Project: "one",
+			Snippets: []string{"pkg/util.go"}})).To(Succeed())
+		Expect(l.Record(audit.Entry{Time: day(3), Event: audit.EventPrompt, Project: "two",
+			Files: []audit.File{{Path: "main.go", SHA256: "def"}}})).To(Succeed())
+		Expect(l.Close()).To(Succeed())
+
+		all, err := audit.Query(location, audit.Filter{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(all).To(HaveLen(3))
+		Expect(all[0].User).To(Equal("alice"))
+		// The user running the server, if not known.
+		Expect(all[1].User).NotTo(BeEmpty())
+
+		entries, err := audit.Query(location, audit.Filter{Project: "one"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(2))
+
+		entries, err = audit.Query(location, audit.Filter{File: "main.go"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(2))
+		Expect(entries[1].Project).To(Equal("two"))
+
+		entries, err = audit.Query(location, audit.Filter{File: "pkg/util.go"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(1))
+
+		entries, err = audit.Query(location, audit.Filter{Since: day(2), Until: day(3)})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].Event).To(Equal(audit.EventResponse))
+	})
+
+	It("rotates the log, keeping at most the configured number of files", func() {
+		l, err := audit.NewLogger(config.AuditConfig{Location: location, MaxSizeMB: 1, MaxFiles: 2})
+		Expect(err).NotTo(HaveOccurred())
+		// Each entry is about 100KB, so that ten of them fill a file.
+		big := []string{strings.Repeat("x", 100*1024)}
+		for i := 0; i < 35; i++ {
+			Expect(l.Record(audit.Entry{Event: audit.EventResponse, Snippets: big})).To(Succeed())
+		}
+		Expect(l.Close()).To(Succeed())
+		Expect(l.Paths()).To(Equal([]string{location + ".2", location + ".1", location}))
+		for _, path := range l.Paths() {
+			info, err := os.Stat(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.Size()).To(BeNumerically("<=", 1<<20))
+		}
+	})
+
+	Context("with the hash chain", func() {
+		var cfg config.AuditConfig
+
+		BeforeEach(func() {
+			cfg = config.AuditConfig{Location: location, HashChain: true}
+		})
+
+		It("chains the entries, across restarts", func() {
+			l, err := audit.NewLogger(cfg)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(l.Record(audit.Entry{Event: audit.EventPrompt, Project: "one"})).To(Succeed())
+			Expect(l.Close()).To(Succeed())
+			l, err = audit.NewLogger(cfg)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(l.Record(audit.Entry{Event: audit.EventResponse, Project: "one"})).To(Succeed())
+			Expect(l.Close()).To(Succeed())
+
+			entries, err := audit.Query(location, audit.Filter{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(HaveLen(2))
+			Expect(entries[0].PrevHash).To(BeEmpty())
+			Expect(entries[1].PrevHash).To(Equal(entries[0].Hash))
+
+			n, err := audit.Verify(location)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(n).To(Equal(2))
+		})
+
+		It("detects an altered entry", func() {
+			l, err := audit.NewLogger(cfg)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(l.Record(audit.Entry{Event: audit.EventPrompt, Project: "one"})).To(Succeed())
+			Expect(l.Record(audit.Entry{Event: audit.EventPrompt, Project: "two"})).To(Succeed())
+			Expect(l.Close()).To(Succeed())
+
+			data, err := os.ReadFile(location)
+			Expect(err).NotTo(HaveOccurred())
+			altered := strings.Replace(string(data), `"project":"one"`, `"project":"six"`, 1)
+			Expect(os.WriteFile(location, []byte(altered), 0600)).To(Succeed())
+
+			_, err = audit.Verify(location)
+			Expect(err).To(MatchError(audit.ErrTampered))
+		})
+
+		It("detects a removed entry", func() {
+			l, err := audit.NewLogger(cfg)
+			Expect(err).NotTo(HaveOccurred())
+			for _, project := range []string{"one", "two", "three"} {
+				Expect(l.Record(audit.Entry{Event: audit.EventPrompt, Project: project})).To(Succeed())
+			}
+			Expect(l.Close()).To(Succeed())
+
+			data, err := os.ReadFile(location)
+			Expect(err).NotTo(HaveOccurred())
+			lines := strings.SplitAfter(string(data), "\n")
+			Expect(os.WriteFile(location, []byte(lines[0]+lines[2]), 0600)).To(Succeed())
+
+			_, err = audit.Verify(location)
+			Expect(err).To(MatchError(audit.ErrTampered))
+		})
+	})
+})
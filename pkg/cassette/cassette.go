@@ -0,0 +1,313 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+// Package cassette records the HTTP interactions with the OpenAI API to
+// "cassette" files, with the secrets scrubbed, and replays them, so that the
+// tests can exercise the code which calls the API without the network.
+package cassette
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/alertavert/gpt4-go/pkg/config"
+)
+
+type Mode string
+
+const (
+	// ModeOff sends the requests to the API, without recording them.
+	ModeOff Mode = ""
+	// ModeRecord sends the requests to the API, and appends the interactions to the cassette.
+	ModeRecord Mode = "record"
+	// ModeReplay returns the recorded responses, and never sends the requests.
+	ModeReplay Mode = "replay"
+)
+
+const (
+	// ModeEnv and LocationEnv override the configured mode and cassette file.
+	ModeEnv     = "MAJORDOMO_CASSETTE_MODE"
+	LocationEnv = "MAJORDOMO_CASSETTE"
+
+	// Redacted replaces the secrets in the cassettes.
+	Redacted = "REDACTED"
+)
+
+// ErrNoInteraction is returned, when replaying, for requests which were not recorded.
+var ErrNoInteraction = errors.New("no recorded interaction")
+
+// scrubbedHeaders identify the account, or the request, and are never recorded.
+var scrubbedHeaders = []string{
+	"Authorization", "Cookie", "Set-Cookie", "OpenAI-Organization", "OpenAI-Project", "X-Request-Id",
+}
+
+type Request struct {
+	Method  string      `yaml:"method"`
+	URL     string      `yaml:"url"`
+	Headers http.Header `yaml:"headers,omitempty"`
+	Body    string      `yaml:"body,omitempty"`
+	// Encoding is `base64` for binary bodies (e.g., audio).
+	Encoding string `yaml:"encoding,omitempty"`
+}
+
+type Response struct {
+	Status   int         `yaml:"status"`
+	Headers  http.Header `yaml:"headers,omitempty"`
+	Body     string      `yaml:"body,omitempty"`
+	Encoding string      `yaml:"encoding,omitempty"`
+}
+
+// Interaction is a request, and the response it received.
+type Interaction struct {
+	Request  Request  `yaml:"request"`
+	Response Response `yaml:"response"`
+}
+
+// Cassette is the sequence of the recorded interactions.
+type Cassette struct {
+	Interactions []*Interaction `yaml:"interactions"`
+
+	path string
+	used []bool
+}
+
+// Load reads the cassette at `path`; a missing file is an empty cassette.
+func Load(path string) (*Cassette, error) {
+	c := &Cassette{path: path}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c, nil
+		}
+		return nil, err
+	}
+	if err = yaml.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("error reading cassette %s: %w", path, err)
+	}
+	c.used = make([]bool, len(c.Interactions))
+	return c, nil
+}
+
+// Save writes the cassette to its file.
+func (c *Cassette) Save() error {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, data, 0644)
+}
+
+// next returns the first interaction, not replayed yet, with the same method
+// and URL as the request; the bodies are not compared, as they may differ
+// between runs (e.g., the boundaries of multipart forms).
+func (c *Cassette) next(method, url string) *Interaction {
+	for i, interaction := range c.Interactions {
+		if !c.used[i] && interaction.Request.Method == method && interaction.Request.URL == url {
+			c.used[i] = true
+			return interaction
+		}
+	}
+	return nil
+}
+
+// Recorder is an http.RoundTripper which records, or replays, the interactions.
+// It is safe for concurrent use, although interactions recorded concurrently
+// are replayed in the order they completed.
+type Recorder struct {
+	Mode     Mode
+	Cassette *Cassette
+	// Transport sends the requests, when recording; http.DefaultTransport if nil.
+	Transport http.RoundTripper
+	// Secrets are replaced by Redacted in the recorded bodies.
+	Secrets []string
+
+	mu sync.Mutex
+}
+
+// Settings returns the configured mode and cassette, overridden by the
+// ModeEnv and LocationEnv environment variables, if set.
+func Settings(cfg config.CassetteConfig) (Mode, string) {
+	mode, location := Mode(cfg.Mode), cfg.Location
+	if env := os.Getenv(ModeEnv); env != "" {
+		mode = Mode(env)
+	}
+	if env := os.Getenv(LocationEnv); env != "" {
+		location = env
+	}
+	return mode, location
+}
+
+// NewTransport returns `base` itself if the cassettes are off, or a Recorder
+// of the configured cassette wrapping it; `secrets` are scrubbed from it.
+func NewTransport(cfg config.CassetteConfig, base http.RoundTripper, secrets ...string) (http.RoundTripper, error) {
+	mode, location := Settings(cfg)
+	switch mode {
+	case ModeOff:
+		return base, nil
+	case ModeRecord, ModeReplay:
+	default:
+		return nil, fmt.Errorf("invalid cassette mode %q, must be one of %q or %q", mode, ModeRecord, ModeReplay)
+	}
+	if location == "" {
+		return nil, fmt.Errorf("the cassette location must be set, in %s mode", mode)
+	}
+	c, err := Load(location)
+	if err != nil {
+		return nil, err
+	}
+	if mode == ModeReplay && len(c.Interactions) == 0 {
+		return nil, fmt.Errorf("%w in cassette %s", ErrNoInteraction, location)
+	}
+	var nonEmpty []string
+	for _, s := range secrets {
+		if s != "" {
+			nonEmpty = append(nonEmpty, s)
+		}
+	}
+	return &Recorder{Mode: mode, Cassette: c, Transport: base, Secrets: nonEmpty}, nil
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	if r.Mode == ModeReplay {
+		return r.replay(req)
+	}
+	return r.record(req)
+}
+
+func (r *Recorder) replay(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		_, _ = io.Copy(io.Discard, req.Body)
+		_ = req.Body.Close()
+	}
+	r.mu.Lock()
+	interaction := r.Cassette.next(req.Method, req.URL.String())
+	r.mu.Unlock()
+	if interaction == nil {
+		return nil, fmt.Errorf("%w for %s %s", ErrNoInteraction, req.Method, req.URL)
+	}
+	body, err := decode(interaction.Response.Body, interaction.Response.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	headers := interaction.Response.Headers.Clone()
+	if headers == nil {
+		headers = make(http.Header)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", interaction.Response.Status, http.StatusText(interaction.Response.Status)),
+		StatusCode:    interaction.Response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        headers,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+func (r *Recorder) record(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		if reqBody, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		_ = req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	interaction := &Interaction{
+		Request: Request{
+			Method:  req.Method,
+			URL:     r.scrub(req.URL.String()),
+			Headers: r.scrubHeaders(req.Header),
+		},
+		Response: Response{
+			Status:  resp.StatusCode,
+			Headers: r.scrubHeaders(resp.Header),
+		},
+	}
+	interaction.Request.Body, interaction.Request.Encoding = r.encode(reqBody)
+	interaction.Response.Body, interaction.Response.Encoding = r.encode(respBody)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Cassette.Interactions = append(r.Cassette.Interactions, interaction)
+	r.Cassette.used = append(r.Cassette.used, false)
+	// Saved at every interaction, so that nothing is lost if the test fails.
+	if err = r.Cassette.Save(); err != nil {
+		return nil, fmt.Errorf("error saving cassette %s: %w", r.Cassette.path, err)
+	}
+	return resp, nil
+}
+
+func (r *Recorder) scrub(text string) string {
+	for _, secret := range r.Secrets {
+		text = strings.ReplaceAll(text, secret, Redacted)
+	}
+	return text
+}
+
+func (r *Recorder) scrubHeaders(headers http.Header) http.Header {
+	scrubbed := make(http.Header, len(headers))
+	for name, values := range headers {
+		for _, v := range values {
+			scrubbed.Add(name, r.scrub(v))
+		}
+	}
+	for _, name := range scrubbedHeaders {
+		scrubbed.Del(name)
+	}
+	if len(scrubbed) == 0 {
+		return nil
+	}
+	return scrubbed
+}
+
+// encode returns the scrubbed body, as text if possible, or in base64.
+func (r *Recorder) encode(body []byte) (string, string) {
+	if utf8.Valid(body) {
+		return r.scrub(string(body)), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
+func decode(body, encoding string) ([]byte, error) {
+	switch encoding {
+	case "":
+		return []byte(body), nil
+	case "base64":
+		return base64.StdEncoding.DecodeString(body)
+	}
+	return nil, fmt.Errorf("unknown body encoding %q", encoding)
+}
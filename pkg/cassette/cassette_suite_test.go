@@ -0,0 +1,17 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package cassette_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestCassette(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Cassette Suite")
+}
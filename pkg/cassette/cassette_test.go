@@ -0,0 +1,137 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package cassette_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alertavert/gpt4-go/pkg/cassette"
+	"github.com/alertavert/gpt4-go/pkg/config"
+)
+
+const apiKey = "sk-test-0123456789abcdef"
+
+var _ = Describe("Cassettes", func() {
+	var (
+		tmpDir   string
+		location string
+		api      *httptest.Server
+		calls    int
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = os.MkdirTemp("", "cassette-test-")
+		Expect(err).NotTo(HaveOccurred())
+		location = filepath.Join(tmpDir, "cassettes", "test.yaml")
+		calls = 0
+		api = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			body, _ := io.ReadAll(r.Body)
+			w.Header().Set("Openai-Organization", "my-org")
+			w.Header().Set("Content-Type", "application/json")
+			switch r.URL.Path {
+			case "/v1/audio/speech":
+				_, _ = w.Write([]byte{0xFF, 0xFB, 0x90, 0x00})
+			default:
+				_, _ = w.Write([]byte(`{"echo":` + string(body) + `}`))
+			}
+		}))
+	})
+	AfterEach(func() {
+		api.Close()
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	send := func(transport http.RoundTripper, method, path, body string) (int, string) {
+		req, err := http.NewRequest(method, api.URL+path, strings.NewReader(body))
+		Expect(err).NotTo(HaveOccurred())
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+		resp, err := transport.RoundTrip(req)
+		Expect(err).NotTo(HaveOccurred())
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		Expect(err).NotTo(HaveOccurred())
+		return resp.StatusCode, string(data)
+	}
+
+	It("is off unless configured", func() {
+		transport, err := cassette.NewTransport(config.CassetteConfig{}, http.DefaultTransport)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(transport).To(BeIdenticalTo(http.DefaultTransport))
+
+		_, err = cassette.NewTransport(config.CassetteConfig{Mode: "rewind", Location: location}, nil)
+		Expect(err).To(HaveOccurred())
+		_, err = cassette.NewTransport(config.CassetteConfig{Mode: "record"}, nil)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("records the interactions, scrubbing the secrets, and replays them", func() {
+		recorder, err := cassette.NewTransport(config.CassetteConfig{Mode: "record", Location: location},
+			http.DefaultTransport, apiKey)
+		Expect(err).NotTo(HaveOccurred())
+		status, body := send(recorder, http.MethodPost, "/v1/threads", `{"key":"`+apiKey+`"}`)
+		Expect(status).To(Equal(http.StatusOK))
+		// The response is not altered while recording.
+		Expect(body).To(ContainSubstring(apiKey))
+		_, audio := send(recorder, http.MethodPost, "/v1/audio/speech", `{"input":"hello"}`)
+		Expect(calls).To(Equal(2))
+
+		data, err := os.ReadFile(location)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).NotTo(ContainSubstring(apiKey))
+		Expect(string(data)).NotTo(ContainSubstring("my-org"))
+		Expect(string(data)).To(ContainSubstring(cassette.Redacted))
+		Expect(string(data)).To(ContainSubstring("encoding: base64"))
+
+		player, err := cassette.NewTransport(config.CassetteConfig{Mode: "replay", Location: location}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		status, body = send(player, http.MethodPost, "/v1/threads", `{"other":"body"}`)
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(body).To(Equal(`{"echo":{"key":"REDACTED"}}`))
+		_, replayed := send(player, http.MethodPost, "/v1/audio/speech", "")
+		Expect(replayed).To(Equal(audio))
+		Expect(calls).To(Equal(2))
+	})
+
+	It("replays the same request's responses in order, and fails when they run out", func() {
+		recorder, err := cassette.NewTransport(config.CassetteConfig{Mode: "record", Location: location},
+			http.DefaultTransport)
+		Expect(err).NotTo(HaveOccurred())
+		send(recorder, http.MethodPost, "/v1/runs", `"first"`)
+		send(recorder, http.MethodPost, "/v1/runs", `"second"`)
+
+		player, err := cassette.NewTransport(config.CassetteConfig{Mode: "replay", Location: location}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		_, body := send(player, http.MethodPost, "/v1/runs", "")
+		Expect(body).To(Equal(`{"echo":"first"}`))
+		_, body = send(player, http.MethodPost, "/v1/runs", "")
+		Expect(body).To(Equal(`{"echo":"second"}`))
+
+		req, _ := http.NewRequest(http.MethodPost, api.URL+"/v1/runs", nil)
+		_, err = player.RoundTrip(req)
+		Expect(err).To(MatchError(cassette.ErrNoInteraction))
+	})
+
+	It("can be selected with the environment variables", func() {
+		Expect(os.Setenv(cassette.ModeEnv, "replay")).To(Succeed())
+		Expect(os.Setenv(cassette.LocationEnv, location)).To(Succeed())
+		defer func() {
+			Expect(os.Unsetenv(cassette.ModeEnv)).To(Succeed())
+			Expect(os.Unsetenv(cassette.LocationEnv)).To(Succeed())
+		}()
+		mode, loc := cassette.Settings(config.CassetteConfig{Mode: "record", Location: "elsewhere.yaml"})
+		Expect(mode).To(Equal(cassette.ModeReplay))
+		Expect(loc).To(Equal(location))
+	})
+})
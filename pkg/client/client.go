@@ -0,0 +1,524 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+// Package client is the Go client of the Majordomo REST API; the requests and
+// responses are the same types used by the server, in the api package, so
+// that the client does not depend on the server's internals.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+
+	"github.com/alertavert/gpt4-go/pkg/api"
+)
+
+// DefaultPollInterval is how often WaitJob checks the status of the job.
+const DefaultPollInterval = 2 * time.Second
+
+// Error is returned for the error responses of the server.
+type Error struct {
+	StatusCode int
+	// Code, Message and Details are those of the api.Error in the response.
+	Code    string
+	Message string
+	Details map[string]any
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("majordomo: %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// Client sends the requests to the Majordomo server; it is safe for
+// concurrent use.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	user       string
+}
+
+// Option configures the Client.
+type Option func(*Client)
+
+// WithHTTPClient sends the requests with `httpClient`, e.g. one configured
+// with the client certificates for mTLS.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithUser sends the requests on behalf of `user`, who is recorded in the
+// audit log of the prompts.
+func WithUser(user string) Option {
+	return func(c *Client) {
+		c.user = user
+	}
+}
+
+// New creates a client for the server at `baseURL` (e.g.
+// `http://localhost:5005`), without the API's prefix.
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Health returns nil if the server is up.
+func (c *Client) Health(ctx context.Context) error {
+	resp, err := c.send(ctx, http.MethodGet, c.baseURL+"/health", "", nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Prompt sends the prompt to the assistant, and returns its response.
+func (c *Client) Prompt(ctx context.Context, request *api.PromptRequest) (*api.PromptResponse, error) {
+	return call[api.PromptResponse](ctx, c, http.MethodPost, "/prompt", nil, request)
+}
+
+// Parse returns the prompt as it would be sent to the assistant, without sending it.
+func (c *Client) Parse(ctx context.Context, request *api.PromptRequest) (*api.ParseResponse, error) {
+	return call[api.ParseResponse](ctx, c, http.MethodPost, "/parse", nil, request)
+}
+
+// Command carries out the typed command.
+func (c *Client) Command(ctx context.Context, text string) (*api.CommandResponse, error) {
+	form := url.Values{"text": {text}}
+	var response api.CommandResponse
+	if err := c.doBody(ctx, http.MethodPost, c.url("/command", nil),
+		"application/x-www-form-urlencoded", strings.NewReader(form.Encode()), &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// SpokenCommand transcribes the audio, and carries out the command; the
+// audio is streamed to the server, rather than read in memory.
+func (c *Client) SpokenCommand(ctx context.Context, audio io.Reader,
+	options api.TranscriptionOptions) (*api.CommandResponse, error) {
+	body, writer := io.Pipe()
+	form := multipart.NewWriter(writer)
+	go func() {
+		writer.CloseWithError(writeAudioForm(form, audio, options))
+	}()
+	var response api.CommandResponse
+	err := c.doBody(ctx, http.MethodPost, c.url("/command", nil), form.FormDataContentType(), body, &response)
+	// Stops writing the form, if the request failed before reading it all.
+	_ = body.Close()
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// writeAudioForm writes the fields of the `/command` form, and the audio file.
+func writeAudioForm(form *multipart.Writer, audio io.Reader, options api.TranscriptionOptions) error {
+	for name, value := range map[string]string{
+		"language": options.Language,
+		"prompt":   options.Prompt,
+	} {
+		if value == "" {
+			continue
+		}
+		if err := form.WriteField(name, value); err != nil {
+			return err
+		}
+	}
+	if options.Translate {
+		if err := form.WriteField("translate", "true"); err != nil {
+			return err
+		}
+	}
+	filename := options.Filename
+	if filename == "" {
+		filename = "audio"
+	}
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="audio"; filename=%q`, filename))
+	if options.ContentType != "" {
+		header.Set("Content-Type", options.ContentType)
+	} else {
+		header.Set("Content-Type", "application/octet-stream")
+	}
+	part, err := form.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(part, audio); err != nil {
+		return err
+	}
+	return form.Close()
+}
+
+// Speak converts the text to audio, and returns it with its content type;
+// the caller must close it.
+func (c *Client) Speak(ctx context.Context, request *api.SpeechRequest) (io.ReadCloser, string, error) {
+	resp, err := c.sendJSON(ctx, http.MethodPost, c.url("/speak", nil), request)
+	if err != nil {
+		return nil, "", err
+	}
+	return resp.Body, resp.Header.Get("Content-Type"), nil
+}
+
+// SpeakURL converts the text to audio, and returns where it can be
+// downloaded from, with Speech.
+func (c *Client) SpeakURL(ctx context.Context, request *api.SpeechRequest) (*api.SpeechResponse, error) {
+	return call[api.SpeechResponse](ctx, c, http.MethodPost, "/speak", url.Values{"url": {"true"}}, request)
+}
+
+// Speech downloads the audio file, given its name or the URL returned by
+// SpeakURL; the caller must close it.
+func (c *Client) Speech(ctx context.Context, file string) (io.ReadCloser, string, error) {
+	file = strings.TrimPrefix(file, api.Prefix+"/speech/")
+	resp, err := c.send(ctx, http.MethodGet, c.url("/speech/"+url.PathEscape(file), nil), "", nil)
+	if err != nil {
+		return nil, "", err
+	}
+	return resp.Body, resp.Header.Get("Content-Type"), nil
+}
+
+// SubmitJob queues the prompt, to be run in the background; see WaitJob.
+func (c *Client) SubmitJob(ctx context.Context, request *api.PromptRequest) (*api.Job, error) {
+	return call[api.Job](ctx, c, http.MethodPost, "/jobs", nil, request)
+}
+
+// Jobs returns all the jobs, oldest first.
+func (c *Client) Jobs(ctx context.Context) ([]*api.Job, error) {
+	jobs, err := call[[]*api.Job](ctx, c, http.MethodGet, "/jobs", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return *jobs, nil
+}
+
+// Job returns the status of the job and, once completed, the response.
+func (c *Client) Job(ctx context.Context, id string) (*api.Job, error) {
+	return call[api.Job](ctx, c, http.MethodGet, "/jobs/"+url.PathEscape(id), nil, nil)
+}
+
+// CancelJob cancels the job, if it has not finished yet.
+func (c *Client) CancelJob(ctx context.Context, id string) (*api.Job, error) {
+	return call[api.Job](ctx, c, http.MethodDelete, "/jobs/"+url.PathEscape(id), nil, nil)
+}
+
+// WaitJob polls the job every `interval` (DefaultPollInterval, if zero) until
+// it is finished, or `ctx` is done.
+func (c *Client) WaitJob(ctx context.Context, id string, interval time.Duration) (*api.Job, error) {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		job, err := c.Job(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if job.Finished() {
+			return job, nil
+		}
+		select {
+		case <-ctx.Done():
+			return job, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Projects returns all the projects, and the active one.
+func (c *Client) Projects(ctx context.Context) (*api.ProjectsResponse, error) {
+	return call[api.ProjectsResponse](ctx, c, http.MethodGet, "/projects", nil, nil)
+}
+
+// Project returns the project named `name`.
+func (c *Client) Project(ctx context.Context, name string) (*api.Project, error) {
+	return call[api.Project](ctx, c, http.MethodGet, projectPath(name), nil, nil)
+}
+
+// CreateProject adds the project.
+func (c *Client) CreateProject(ctx context.Context, project *api.Project) (*api.Project, error) {
+	return call[api.Project](ctx, c, http.MethodPost, "/projects", nil, project)
+}
+
+// UpdateProject changes the non-empty fields of `update`, in the project
+// named `name`; the update's Name is required, and renames the project.
+func (c *Client) UpdateProject(ctx context.Context, name string, update *api.Project) (*api.Project, error) {
+	return call[api.Project](ctx, c, http.MethodPut, projectPath(name), nil, update)
+}
+
+// DeleteProject removes the project named `name`; its threads are archived,
+// and its code snippets kept, unless `opts` purges them.
+func (c *Client) DeleteProject(ctx context.Context, name string,
+	opts api.DeleteProjectOptions) (*api.ProjectDeletionResponse, error) {
+	query := url.Values{}
+	if opts.Threads != "" {
+		query.Set("threads", string(opts.Threads))
+	}
+	if opts.PurgeSnippets {
+		query.Set("snippets", "purge")
+	}
+	return call[api.ProjectDeletionResponse](ctx, c, http.MethodDelete, projectPath(name), query, nil)
+}
+
+// SetActiveProject makes the project named `name` the active one.
+func (c *Client) SetActiveProject(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodPut, "/projects", nil, api.ActiveProjectRequest{ActiveProject: name}, nil)
+}
+
+// Conversations returns the conversation threads of the project.
+func (c *Client) Conversations(ctx context.Context, project string) ([]api.Thread, error) {
+	var response api.ConversationsResponse
+	err := c.do(ctx, http.MethodGet, projectPath(project)+"/conversations", nil, nil, &response)
+	return response.Threads, err
+}
+
+// Thread returns the conversation thread of the project.
+func (c *Client) Thread(ctx context.Context, project, threadId string) (*api.Thread, error) {
+	return call[api.Thread](ctx, c, http.MethodGet, "/conversations/"+url.PathEscape(threadId),
+		url.Values{"project": {project}}, nil)
+}
+
+// ThreadMessages returns the most recent messages (up to `limit`, or the
+// server's default if not positive) of the project's conversation thread, oldest first.
+func (c *Client) ThreadMessages(ctx context.Context, project, threadId string,
+	limit int) ([]api.ThreadMessage, error) {
+	query := url.Values{"project": {project}}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	var response api.ThreadMessagesResponse
+	err := c.do(ctx, http.MethodGet, "/conversations/"+url.PathEscape(threadId)+"/messages", query, nil, &response)
+	return response.Messages, err
+}
+
+// Files lists the project's files which can be included in the prompts (up
+// to `limit`, or the server's default if not positive).
+func (c *Client) Files(ctx context.Context, project string, limit int) (*api.FilesResponse, error) {
+	var query url.Values
+	if limit > 0 {
+		query = url.Values{"limit": {strconv.Itoa(limit)}}
+	}
+	return call[api.FilesResponse](ctx, c, http.MethodGet, projectPath(project)+"/files", query, nil)
+}
+
+// FixTests runs the project's tests, and asks the assistant to fix those failing.
+func (c *Client) FixTests(ctx context.Context, project string,
+	request *api.FixTestsRequest) (*api.FixTestsReport, error) {
+	return call[api.FixTestsReport](ctx, c, http.MethodPost, projectPath(project)+"/fix-tests", nil, request)
+}
+
+// Snippets returns the snippets stored for the project, with their versions.
+func (c *Client) Snippets(ctx context.Context, project string) ([]api.Snippet, error) {
+	var response api.SnippetsResponse
+	err := c.do(ctx, http.MethodGet, projectPath(project)+"/snippets", nil, nil, &response)
+	return response.Snippets, err
+}
+
+// Snippet returns the snippet at `path`; if `withDiff`, it is also compared
+// against the project's file.
+func (c *Client) Snippet(ctx context.Context, project, path string, withDiff bool) (*api.SnippetResponse, error) {
+	var query url.Values
+	if withDiff {
+		query = url.Values{"diff": {"true"}}
+	}
+	return call[api.SnippetResponse](ctx, c, http.MethodGet, snippetPath(project, path), query, nil)
+}
+
+// DeleteSnippet removes the snippet at `path`.
+func (c *Client) DeleteSnippet(ctx context.Context, project, path string) error {
+	return c.do(ctx, http.MethodDelete, snippetPath(project, path), nil, nil, nil)
+}
+
+// DeleteSnippets removes all the snippets of the project.
+func (c *Client) DeleteSnippets(ctx context.Context, project string) error {
+	return c.do(ctx, http.MethodDelete, projectPath(project)+"/snippets", nil, nil, nil)
+}
+
+// SnippetsZip returns all the snippets of the project in a zip archive; the
+// caller must close it.
+func (c *Client) SnippetsZip(ctx context.Context, project string) (io.ReadCloser, error) {
+	resp, err := c.send(ctx, http.MethodGet, c.url(projectPath(project)+"/snippets.zip", nil), "", nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Version returns the version of the snippet, and its content.
+func (c *Client) Version(ctx context.Context, project, versionId string) (*api.VersionResponse, error) {
+	return call[api.VersionResponse](ctx, c, http.MethodGet, versionPath(project, versionId), nil, nil)
+}
+
+// VersionDiff compares the version against the `against` one or, if empty,
+// against the current version of the same snippet.
+func (c *Client) VersionDiff(ctx context.Context, project, versionId,
+	against string) (*api.VersionDiffResponse, error) {
+	var query url.Values
+	if against != "" {
+		query = url.Values{"against": {against}}
+	}
+	return call[api.VersionDiffResponse](ctx, c, http.MethodGet, versionPath(project, versionId)+"/diff", query, nil)
+}
+
+// RestoreVersion makes the version the current one, returning the new version.
+func (c *Client) RestoreVersion(ctx context.Context, project, versionId string) (*api.SnippetVersion, error) {
+	return call[api.SnippetVersion](ctx, c, http.MethodPost, versionPath(project, versionId)+"/restore", nil, nil)
+}
+
+// Assistants returns the assistants available.
+func (c *Client) Assistants(ctx context.Context) ([]openai.Assistant, error) {
+	assistants, err := call[[]openai.Assistant](ctx, c, http.MethodGet, "/assistants", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return *assistants, nil
+}
+
+// RefreshAssistants lists the assistants again.
+func (c *Client) RefreshAssistants(ctx context.Context) (*api.RefreshResponse, error) {
+	return call[api.RefreshResponse](ctx, c, http.MethodPost, "/assistants/refresh", nil, nil)
+}
+
+func projectPath(project string) string {
+	return "/projects/" + url.PathEscape(project)
+}
+
+func versionPath(project, versionId string) string {
+	return projectPath(project) + "/versions/" + url.PathEscape(versionId)
+}
+
+// snippetPath escapes each segment of the snippet's path, keeping the slashes.
+func snippetPath(project, path string) string {
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return projectPath(project) + "/snippets/" + strings.Join(segments, "/")
+}
+
+// url is the URL of the API's `path`; it is already escaped.
+func (c *Client) url(path string, query url.Values) string {
+	u := c.baseURL + api.Prefix + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	return u
+}
+
+// call is like do, returning the response decoded as T.
+func call[T any](ctx context.Context, c *Client, method, path string, query url.Values, body any) (*T, error) {
+	var out T
+	if err := c.do(ctx, method, path, query, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// do sends `body` (if not nil) as JSON, and decodes the response in `out`
+// (if not nil).
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	resp, err := c.sendJSON(ctx, method, c.url(path, query), body)
+	if err != nil {
+		return err
+	}
+	return decode(resp, out)
+}
+
+// doBody is like do, for bodies which are not JSON.
+func (c *Client) doBody(ctx context.Context, method, target, contentType string, body io.Reader, out any) error {
+	resp, err := c.send(ctx, method, target, contentType, body)
+	if err != nil {
+		return err
+	}
+	return decode(resp, out)
+}
+
+func (c *Client) sendJSON(ctx context.Context, method, target string, body any) (*http.Response, error) {
+	if body == nil {
+		return c.send(ctx, method, target, "", nil)
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return c.send(ctx, method, target, "application/json", bytes.NewReader(data))
+}
+
+// send sends the request, and returns the response if successful, or an
+// *Error if the server returned one.
+func (c *Client) send(ctx context.Context, method, target, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.user != "" {
+		req.Header.Set(api.UserHeader, c.user)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer resp.Body.Close()
+		return nil, responseError(resp)
+	}
+	return resp, nil
+}
+
+// responseError reads the error in the api.ErrorResponse envelope; the
+// errors which are not in it (e.g., from a proxy) are reported with their body.
+func responseError(resp *http.Response) error {
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("majordomo: %d: error reading the response: %w", resp.StatusCode, err)
+	}
+	var envelope api.ErrorResponse
+	if err := json.Unmarshal(data, &envelope); err == nil && envelope.Error != nil {
+		return &Error{
+			StatusCode: resp.StatusCode,
+			Code:       envelope.Error.Code,
+			Message:    envelope.Error.Message,
+			Details:    envelope.Error.Details,
+		}
+	}
+	return &Error{
+		StatusCode: resp.StatusCode,
+		Code:       api.CodeForStatus(resp.StatusCode),
+		Message:    strings.TrimSpace(string(data)),
+	}
+}
+
+func decode(resp *http.Response, out any) error {
+	defer resp.Body.Close()
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		_, err := io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("majordomo: error decoding the response: %w", err)
+	}
+	return nil
+}
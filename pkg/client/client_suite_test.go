@@ -0,0 +1,25 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package client_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rs/zerolog"
+)
+
+func TestClient(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Client Suite")
+}
+
+const TestConfigLocation = "../../testdata/test_config.yaml"
+
+var _ = BeforeSuite(func() {
+	// Silence the logs
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+})
@@ -0,0 +1,202 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alertavert/gpt4-go/pkg/api"
+	"github.com/alertavert/gpt4-go/pkg/client"
+	"github.com/alertavert/gpt4-go/pkg/completions"
+	"github.com/alertavert/gpt4-go/pkg/config"
+	"github.com/alertavert/gpt4-go/pkg/server"
+)
+
+// echoRunner completes the prompts immediately, echoing them.
+type echoRunner struct{}
+
+func (echoRunner) StartPrompt(context.Context, *completions.PromptRequest, string) (string, error) {
+	return "run_1", nil
+}
+
+func (echoRunner) FinishPrompt(_ context.Context, prompt *completions.PromptRequest, _,
+	_ string) (*completions.PromptResponse, error) {
+	return &completions.PromptResponse{BotResponse: api.BotResponse{Message: prompt.Prompt, Text: prompt.Prompt}}, nil
+}
+
+func (echoRunner) CancelRun(string, string) error {
+	return nil
+}
+
+// apiError is the *client.Error returned as `err`.
+func apiError(err error) *client.Error {
+	var e *client.Error
+	Expect(errors.As(err, &e)).To(BeTrue(), "%v is not a client.Error", err)
+	return e
+}
+
+var _ = Describe("Client", func() {
+	var (
+		ctx    context.Context
+		svr    *httptest.Server
+		c      *client.Client
+		tmpDir string
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		var err error
+		tmpDir, err = os.MkdirTemp("", "client-test-")
+		Expect(err).NotTo(HaveOccurred())
+		cfgLoc := filepath.Join(tmpDir, "config.yaml")
+		data, err := os.ReadFile(TestConfigLocation)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.WriteFile(cfgLoc, data, 0600)).To(Succeed())
+		cfg, err := config.LoadConfig(cfgLoc)
+		Expect(err).NotTo(HaveOccurred())
+		cfg.ThreadsLocation = filepath.Join(tmpDir, "threads.json")
+		assistant, err := completions.NewMajordomo(cfg)
+		Expect(err).NotTo(HaveOccurred())
+		assistant.Jobs, err = completions.NewJobQueue(echoRunner{}, "", 1)
+		Expect(err).NotTo(HaveOccurred())
+
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		server.SetupTestRoutes(router, assistant)
+		svr = httptest.NewServer(router)
+		c = client.New(svr.URL+"/", client.WithUser("alice"))
+	})
+	AfterEach(func() {
+		svr.Close()
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	It("checks the server's health", func() {
+		Expect(c.Health(ctx)).To(Succeed())
+	})
+
+	Context("managing the projects", func() {
+		It("lists them, and returns their details", func() {
+			projects, err := c.Projects(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(projects.Projects).To(HaveLen(3))
+
+			project, err := c.Project(ctx, "test-project-2")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(project.Description).To(Equal("test-description-2"))
+		})
+
+		It("creates, updates, activates and deletes them", func() {
+			created, err := c.CreateProject(ctx, &api.Project{
+				Name: "new-project", Location: "/tmp/new", Description: "new"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(created.Name).To(Equal("new-project"))
+
+			updated, err := c.UpdateProject(ctx, "new-project",
+				&api.Project{Name: "new-project", Description: "changed"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated.Description).To(Equal("changed"))
+			Expect(updated.Location).To(Equal("/tmp/new"))
+
+			Expect(c.SetActiveProject(ctx, "new-project")).To(Succeed())
+			projects, err := c.Projects(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(projects.ActiveProject).To(Equal("new-project"))
+
+			deletion, err := c.DeleteProject(ctx, "test-project-2", api.DeleteProjectOptions{
+				Threads: api.ArchiveThreads,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(deletion.ID).To(Equal("test-project-2"))
+			Expect(deletion.ActiveProject).To(Equal("new-project"))
+			_, err = c.Project(ctx, "test-project-2")
+			Expect(apiError(err).StatusCode).To(Equal(http.StatusNotFound))
+		})
+
+		It("returns the server's errors", func() {
+			_, err := c.Project(ctx, "nonexistent")
+			e := apiError(err)
+			Expect(e.StatusCode).To(Equal(http.StatusNotFound))
+			Expect(e.Code).To(Equal(api.CodeNotFound))
+			Expect(e.Message).To(Equal("Project not found"))
+
+			_, err = c.CreateProject(ctx, &api.Project{Name: "test-project", Location: "/tmp"})
+			Expect(apiError(err).Code).To(Equal(api.CodeConflict))
+		})
+
+		It("returns the conversations and the snippets", func() {
+			threads, err := c.Conversations(ctx, "test-project")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(threads).To(BeEmpty())
+			_, err = c.Thread(ctx, "test-project", "thread_none")
+			Expect(apiError(err).Code).To(Equal(api.CodeNotFound))
+			_, err = c.ThreadMessages(ctx, "test-project", "thread_none", 10)
+			Expect(apiError(err).Code).To(Equal(api.CodeNotFound))
+
+			files, err := c.Files(ctx, "actual", 2)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(files.Files).To(Equal([]string{"go.mod", "pkg/simple.go"}))
+			Expect(files.Truncated).To(BeTrue())
+
+			_, err = c.Snippets(ctx, "nonexistent")
+			Expect(apiError(err).Code).To(Equal(api.CodeNotFound))
+			_, err = c.Snippet(ctx, "actual", "pkg/missing.go", true)
+			Expect(apiError(err).Code).To(Equal(api.CodeNotFound))
+		})
+	})
+
+	Context("sending the prompts", func() {
+		It("parses them", func() {
+			parsed, err := c.Parse(ctx, &api.PromptRequest{Assistant: "default", Prompt: "Hello"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(parsed.Message).To(ContainSubstring("Hello"))
+		})
+
+		It("rejects those invalid, before sending them", func() {
+			_, err := c.Prompt(ctx, &api.PromptRequest{Assistant: "default"})
+			e := apiError(err)
+			Expect(e.StatusCode).To(Equal(http.StatusBadRequest))
+			Expect(e.Code).To(Equal(api.CodeInvalidRequest))
+		})
+
+		It("runs them as jobs, and waits for them", func() {
+			job, err := c.SubmitJob(ctx, &api.PromptRequest{Assistant: "default", Prompt: "Hello"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(job.Request.User).To(Equal("alice"))
+
+			ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			defer cancel()
+			job, err = c.WaitJob(ctx, job.ID, 10*time.Millisecond)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(job.Status).To(Equal(api.JobCompleted))
+			Expect(job.Response.Text).To(Equal("Hello"))
+
+			jobs, err := c.Jobs(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(jobs).To(HaveLen(1))
+			_, err = c.CancelJob(ctx, job.ID)
+			Expect(apiError(err).Code).To(Equal(api.CodeConflict))
+		})
+	})
+
+	It("streams the audio of the spoken commands", func() {
+		_, err := c.SpokenCommand(ctx, strings.NewReader("not really audio"),
+			api.TranscriptionOptions{Filename: "notes.txt", ContentType: "text/plain"})
+		e := apiError(err)
+		Expect(e.StatusCode).To(Equal(http.StatusUnsupportedMediaType))
+		Expect(e.Message).To(ContainSubstring("notes.txt"))
+	})
+})
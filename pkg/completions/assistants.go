@@ -5,15 +5,48 @@
 package completions
 
 import (
+	"fmt"
 	"gopkg.in/yaml.v3"
 	"os"
+	"strings"
+
+	"github.com/alertavert/gpt4-go/pkg/preprocessors"
 )
 
+// EditFormatInstructions explain to the assistants how to use each of the
+// preprocessors.EditFormats, to send back only the changes to a file.
+var EditFormatInstructions = map[string]string{
+	preprocessors.FormatSearchReplace: `To change only part of an existing file, instead of sending it back in full,
+you can use one or more SEARCH/REPLACE blocks, with the path of the file in the first line:
+'''pkg/server/server.go
+<<<<<<< SEARCH
+the exact lines to change, including a few unchanged lines around them
+=======
+the lines which replace them
+>>>>>>> REPLACE
+'''
+`,
+	preprocessors.FormatUnifiedDiff: `To change only part of an existing file, instead of sending it back in full,
+you can send a unified diff, with the paths of the files in the headers:
+'''
+--- a/pkg/server/server.go
++++ b/pkg/server/server.go
+@@ -10,3 +10,3 @@
+ an unchanged line
+-the line to remove
++the line to add
+ another unchanged line
+'''
+`,
+}
 
 // Assistants is a struct that contains the data necessary to instantiate Assistants.
 type Assistants struct {
 	Common       string            `yaml:"common"`
 	Instructions map[string]string `yaml:"instructions"`
+	// EditFormats are, for each assistant, the preprocessors.EditFormats it
+	// can use to send back partial edits to files.
+	EditFormats map[string][]string `yaml:"edit_formats,omitempty"`
 }
 
 // GetInstructions is a method that returns the instructions for a given assistant.
@@ -24,6 +57,17 @@ func (s *Assistants) GetInstructions(name string) string {
 	return s.Instructions[name]
 }
 
+// GetEditFormatInstructions returns the instructions for the edit formats the
+// assistant opted into, if any.
+func (s *Assistants) GetEditFormatInstructions(name string) string {
+	var sb strings.Builder
+	for _, format := range s.EditFormats[name] {
+		sb.WriteString("\n")
+		sb.WriteString(EditFormatInstructions[format])
+	}
+	return sb.String()
+}
+
 // Names returns the names of all the configured assistants.
 //
 // This may not necessarily accurately reflect those configured in OpenAI:
@@ -50,5 +94,12 @@ func ReadInstructions(location string) (*Assistants, error) {
 	if err != nil {
 		return nil, err
 	}
+	for name, formats := range assistants.EditFormats {
+		for _, format := range formats {
+			if _, found := EditFormatInstructions[format]; !found {
+				return nil, fmt.Errorf("unknown edit format %q for assistant %s", format, name)
+			}
+		}
+	}
 	return &assistants, nil
 }
@@ -29,6 +29,21 @@ var _ = Describe("Assistants", func() {
 				Expect(assistants.Instructions).To(HaveKeyWithValue("test", ContainSubstring("This is a test scenario")))
 			})
 		})
+		Context("when assistants opt into edit formats", func() {
+			BeforeEach(func() {
+				assistants, err = completions.ReadInstructions("../../testdata/test_assistants.yaml")
+				Expect(err).NotTo(HaveOccurred())
+			})
+			It("should add the instructions for the formats", func() {
+				Expect(assistants.GetEditFormatInstructions("dev")).To(
+					And(ContainSubstring("SEARCH/REPLACE"), ContainSubstring("unified diff")))
+				Expect(assistants.GetEditFormatInstructions("test")).To(BeEmpty())
+			})
+			It("should reject unknown formats", func() {
+				_, err = completions.ReadInstructions("../../testdata/unknown_format_assistants.yaml")
+				Expect(err).To(HaveOccurred())
+			})
+		})
 		Context("when the YAML file does not exist", func() {
 			BeforeEach(func() {
 				_, err = completions.ReadInstructions("../../testdata/invalid_path.yaml")
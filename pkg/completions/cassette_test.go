@@ -0,0 +1,175 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package completions_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alertavert/gpt4-go/pkg/api"
+	"github.com/alertavert/gpt4-go/pkg/cassette"
+	"github.com/alertavert/gpt4-go/pkg/completions"
+	"github.com/alertavert/gpt4-go/pkg/config"
+	"github.com/alertavert/gpt4-go/pkg/conversations"
+)
+
+// The cassettes replay interactions with the OpenAI API; they are synthetic,
+// written by hand rather than recorded, until recorded with `make cassettes`
+// (which runs the tests with MAJORDOMO_CASSETTE_MODE=record, MAJORDOMO_CASSETTE
+// set to the cassette, and OPENAI_API_KEY).
+const cassettesDir = "../../testdata/cassettes"
+
+var _ = Describe("Replaying the OpenAI interactions", func() {
+	var (
+		cfg    *config.Config
+		tmpDir string
+	)
+
+	replaying := func(name string) *completions.Majordomo {
+		cfg.Cassette = config.CassetteConfig{
+			Mode:     string(cassette.ModeReplay),
+			Location: filepath.Join(cassettesDir, name),
+		}
+		// Only used when recording: the key is never sent, when replaying.
+		if apiKey != "" {
+			cfg.OpenAIApiKey = apiKey
+		}
+		m, err := completions.NewMajordomo(cfg)
+		Expect(err).NotTo(HaveOccurred())
+		return m
+	}
+
+	BeforeEach(func() {
+		var err error
+		cfg, err = config.LoadConfig(TestConfigLocation)
+		Expect(err).NotTo(HaveOccurred())
+		tmpDir, err = os.MkdirTemp("", "cassette-test-")
+		Expect(err).NotTo(HaveOccurred())
+		cfg.ThreadsLocation = filepath.Join(tmpDir, "threads.json")
+		cfg.OpenAI.PollInterval = time.Millisecond
+	})
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	It("fails if the cassette is missing", func() {
+		cfg.Cassette = config.CassetteConfig{Mode: "replay", Location: filepath.Join(tmpDir, "missing.yaml")}
+		_, err := completions.NewMajordomo(cfg)
+		Expect(err).To(MatchError(cassette.ErrNoInteraction))
+	})
+
+	It("queries the bot on a new thread", func() {
+		m := replaying("query_bot.yaml")
+		save := false
+		request := completions.PromptRequest{PromptRequest: api.PromptRequest{
+			Assistant:  "go_developer",
+			Project:    "actual",
+			ThreadName: "Greeting",
+			Prompt:     "Please greet the user by name:\n'''sample/main.go\n'''",
+			Save:       &save,
+		}}
+		response, err := m.QueryBot(context.Background(), &request)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(request.ThreadId).To(Equal("thread_Gr33t1ng"))
+		thread, found := m.Threads.GetThread("actual", "thread_Gr33t1ng")
+		Expect(found).To(BeTrue())
+		Expect(thread.Name).To(Equal("Greeting"))
+
+		Expect(response.Text).To(ContainSubstring("This greets the user by name"))
+		Expect(response.Commands).To(Equal([]string{"go run ./sample"}))
+		Expect(response.Saved).To(BeFalse())
+		Expect(response.CodeBlocks).To(HaveLen(1))
+		Expect(response.CodeBlocks[0].Path).To(Equal("sample/main.go"))
+		Expect(response.CodeBlocks[0].Changed).To(BeTrue())
+	})
+
+	It("suggests the name of a thread", func() {
+		m := replaying("suggest_thread_name.yaml")
+		name, err := m.SuggestThreadName("Split the server setup from the handlers")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(name).To(Equal("Refactoring the HTTP Server"))
+	})
+
+	It("returns the messages of a thread, oldest first", func() {
+		m := replaying("thread_messages.yaml")
+		messages, err := m.ThreadMessages(context.Background(), "thread_Gr33t1ng", 0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(messages).To(Equal([]completions.ThreadMessage{{
+			ID:        "msg_Us3r",
+			Role:      "user",
+			Text:      "Please greet the user by name\n\n'''sample/main.go\npackage main\n'''",
+			CreatedAt: time.Unix(1735689601, 0).UTC(),
+		}, {
+			ID:        "msg_B0t",
+			Role:      "assistant",
+			Text:      "This greets the user by name.",
+			CreatedAt: time.Unix(1735689605, 0).UTC(),
+		}}))
+	})
+
+	It("purges the threads of a deleted project, archiving those still on OpenAI", func() {
+		m := replaying("delete_threads.yaml")
+		cfg.LoadedFrom = filepath.Join(tmpDir, "config.yaml")
+		for _, id := range []string{"thread_D3l3t3d", "thread_G0n3", "thread_Runn1ng"} {
+			thread := conversations.Thread{ID: id, Name: id, Assistant: "go_developer"}
+			Expect(m.Threads.AddThread("actual", thread)).To(Succeed())
+		}
+
+		deletion, err := m.DeleteProject(context.Background(), "actual",
+			completions.DeleteProjectOptions{Threads: completions.PurgeThreads})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(deletion.ThreadsPurged).To(Equal(2))
+		Expect(deletion.ThreadsArchived).To(Equal(1))
+		Expect(m.Threads.GetAllThreads("actual")).To(BeEmpty())
+		archived := m.Threads.ArchivedThreads("actual")
+		Expect(archived).To(HaveLen(1))
+		Expect(archived[0].ID).To(Equal("thread_Runn1ng"))
+	})
+
+	It("creates only the missing assistants", func() {
+		m := replaying("create_assistants.yaml")
+		assistants, err := completions.ReadInstructions("../../testdata/test_assistants.yaml")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(m.CreateAssistants(assistants)).To(Succeed())
+
+		// Listed again, as the new assistant was not cached.
+		id, err := m.GetAssistantId("test")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(id).To(Equal("asst_T3st"))
+	})
+
+	It("transcribes a recording", func() {
+		m := replaying("transcribe.yaml")
+		// The header of a 16kHz, 16-bit mono WAV recording, lasting 2 seconds.
+		recording := []byte("RIFF\x00\x00\x00\x00WAVEfmt ")
+		recording = binary.LittleEndian.AppendUint32(recording, 16)
+		recording = binary.LittleEndian.AppendUint16(recording, 1)
+		recording = binary.LittleEndian.AppendUint16(recording, 1)
+		recording = binary.LittleEndian.AppendUint32(recording, 16000)
+		recording = binary.LittleEndian.AppendUint32(recording, 32000)
+		recording = binary.LittleEndian.AppendUint16(recording, 2)
+		recording = binary.LittleEndian.AppendUint16(recording, 16)
+		recording = append(recording, "data"...)
+		recording = binary.LittleEndian.AppendUint32(recording, 64000)
+
+		transcription, err := m.Transcribe(bytes.NewReader(recording), completions.TranscriptionOptions{
+			Filename: "command.wav",
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(transcription.Text).To(Equal("Run the tests in the server package."))
+		Expect(transcription.Language).To(Equal("english"))
+		Expect(transcription.Format).To(Equal("wav"))
+		Expect(transcription.Segments).To(HaveLen(2))
+		Expect(transcription.Segments[1].Start).To(Equal(1.2))
+	})
+})
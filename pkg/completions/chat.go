@@ -0,0 +1,199 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package completions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/alertavert/gpt4-go/pkg/api"
+)
+
+var (
+	// ErrPromptRunning is returned when sending a prompt to a ChatSession,
+	// while the previous one is still running.
+	ErrPromptRunning = errors.New("a prompt is already running")
+	// ErrNoPromptRunning is returned when cancelling, if no prompt is running.
+	ErrNoPromptRunning = errors.New("no prompt running")
+	// ErrPromptCancelled is returned for the prompts cancelled while running.
+	ErrPromptCancelled = errors.New("prompt cancelled")
+	// ErrThreadNotFound is returned when there is no Thread with the given ID in the project.
+	ErrThreadNotFound = errors.New("thread not found")
+)
+
+// ChatState is what a ChatSession is bound to: the prompts are sent to the
+// assistant, on the Thread of the project.
+type ChatState = api.ChatState
+
+// ChatSession is an interactive conversation (e.g., over a WebSocket), which
+// keeps the project, the assistant and the Thread across the prompts; they
+// are run one at a time, and the one running can be cancelled.
+type ChatSession struct {
+	m      *Majordomo
+	runner PromptRunner
+	user   string
+
+	mu    sync.Mutex
+	state ChatState
+	// cancel stops the prompt running, if any, whose Run is runId (once created).
+	cancel    context.CancelFunc
+	runId     string
+	cancelled bool
+}
+
+// NewChatSession starts the session on `state`; the project is the active
+// one, if empty, and the Thread, if any, must be one of the project's.
+// The prompts are run by `runner` (usually, `m` itself) on behalf of `user`.
+func NewChatSession(m *Majordomo, runner PromptRunner, state ChatState, user string) (*ChatSession, error) {
+	_, project, err := m.ProjectStore(state.Project)
+	if err != nil {
+		return nil, err
+	}
+	state.Project = project
+	if state.Assistant == "" {
+		return nil, fmt.Errorf("assistant is required")
+	}
+	if state.ThreadId != "" {
+		thread, found := m.Threads.GetThread(m.ProjectID(project), state.ThreadId)
+		if !found {
+			return nil, fmt.Errorf("%w: %s", ErrThreadNotFound, state.ThreadId)
+		}
+		state.ThreadName = thread.Name
+	}
+	return &ChatSession{m: m, runner: runner, user: user, state: state}, nil
+}
+
+// State returns what the session is bound to.
+func (s *ChatSession) State() ChatState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// SetAssistant sends the next prompts to `assistant`, on the same Thread.
+func (s *ChatSession) SetAssistant(assistant string) error {
+	if assistant == "" {
+		return fmt.Errorf("assistant is required")
+	}
+	s.mu.Lock()
+	s.state.Assistant = assistant
+	s.mu.Unlock()
+	return nil
+}
+
+// request is the PromptRequest for `text`, in the current state.
+func (s *ChatSession) request(text string, save *bool) PromptRequest {
+	return PromptRequest{PromptRequest: api.PromptRequest{
+		Assistant:  s.state.Assistant,
+		Project:    s.state.Project,
+		ThreadId:   s.state.ThreadId,
+		ThreadName: s.state.ThreadName,
+		Prompt:     text,
+		Save:       save,
+		User:       s.user,
+	}}
+}
+
+// Parse returns the prompt as it would be sent, without sending it.
+func (s *ChatSession) Parse(text string) (*PromptRequest, error) {
+	s.mu.Lock()
+	request := s.request(text, nil)
+	s.mu.Unlock()
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+	if err := s.m.PreparePrompt(&request); err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// Prompt sends the prompt, and waits for the response; the progress is
+// reported to the ProgressFunc of `ctx`, if any.
+// The first prompt creates the Thread, which the next ones continue.
+func (s *ChatSession) Prompt(ctx context.Context, text string, save *bool) (*PromptResponse, error) {
+	s.mu.Lock()
+	if s.cancel != nil {
+		s.mu.Unlock()
+		return nil, ErrPromptRunning
+	}
+	request := s.request(text, save)
+	if err := request.Validate(); err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	s.cancel, s.cancelled = cancel, false
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.cancel, s.runId = nil, ""
+		s.mu.Unlock()
+		cancel()
+	}()
+
+	runId, err := s.runner.StartPrompt(ctx, &request, request.Project)
+	s.mu.Lock()
+	// The Thread may have been created, even if the Run was not.
+	if request.ThreadId != "" {
+		s.state.ThreadId, s.state.ThreadName = request.ThreadId, request.ThreadName
+	}
+	s.runId = runId
+	cancelled := s.cancelled
+	s.mu.Unlock()
+	if err == nil && cancelled {
+		// Cancelled while the Run was being created.
+		s.cancelRun(request.ThreadId, runId)
+	}
+	var response *PromptResponse
+	if err == nil {
+		response, err = s.runner.FinishPrompt(ctx, &request, request.Project, runId)
+	}
+	if err != nil {
+		s.mu.Lock()
+		cancelled = s.cancelled
+		s.mu.Unlock()
+		if cancelled {
+			return nil, ErrPromptCancelled
+		}
+		return nil, err
+	}
+	return response, nil
+}
+
+// Cancel stops the prompt running, and its Run.
+func (s *ChatSession) Cancel() error {
+	s.mu.Lock()
+	if s.cancel == nil {
+		s.mu.Unlock()
+		return ErrNoPromptRunning
+	}
+	s.cancel()
+	s.cancelled = true
+	threadId, runId := s.state.ThreadId, s.runId
+	s.mu.Unlock()
+	if runId != "" {
+		s.cancelRun(threadId, runId)
+	}
+	return nil
+}
+
+// Close cancels the prompt running, if any.
+func (s *ChatSession) Close() {
+	if err := s.Cancel(); err == nil {
+		log.Debug().Str("thread_id", s.State().ThreadId).Msg("chat session closed, prompt cancelled")
+	}
+}
+
+func (s *ChatSession) cancelRun(threadId, runId string) {
+	if err := s.runner.CancelRun(threadId, runId); err != nil {
+		// The Run may have completed in the meantime.
+		log.Warn().Err(err).Str("run_id", runId).Msg("could not cancel the run")
+	}
+}
@@ -0,0 +1,140 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package completions_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alertavert/gpt4-go/pkg/completions"
+	"github.com/alertavert/gpt4-go/pkg/config"
+	"github.com/alertavert/gpt4-go/pkg/conversations"
+)
+
+// progressRunner is a fakeRunner which reports the progress of the prompts.
+type progressRunner struct {
+	*fakeRunner
+}
+
+func (r progressRunner) FinishPrompt(ctx context.Context, prompt *completions.PromptRequest, project,
+	runId string) (*completions.PromptResponse, error) {
+	completions.ReportProgress(ctx, completions.Progress{Stage: completions.ProgressRun,
+		RunId: runId, RunStatus: "in_progress"})
+	return r.fakeRunner.FinishPrompt(ctx, prompt, project, runId)
+}
+
+var _ = Describe("Chat sessions", func() {
+	var (
+		majordomo *completions.Majordomo
+		runner    *fakeRunner
+		tmpDir    string
+	)
+
+	BeforeEach(func() {
+		cfg, err := config.LoadConfig(TestConfigLocation)
+		Expect(err).NotTo(HaveOccurred())
+		tmpDir, err = os.MkdirTemp("", "chat-test-")
+		Expect(err).NotTo(HaveOccurred())
+		cfg.ThreadsLocation = filepath.Join(tmpDir, "threads.json")
+		majordomo, err = completions.NewMajordomo(cfg)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(majordomo.Threads.AddThread("actual", conversations.Thread{
+			ID:        "thread_abc",
+			Name:      "Parser refactoring",
+			Assistant: "go_developer",
+		})).To(Succeed())
+		runner = &fakeRunner{release: make(chan struct{})}
+	})
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	It("binds the session to an existing project and Thread", func() {
+		session, err := completions.NewChatSession(majordomo, runner, completions.ChatState{
+			Project: "actual", Assistant: "go_developer", ThreadId: "thread_abc"}, "alice")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(session.State().ThreadName).To(Equal("Parser refactoring"))
+
+		_, err = completions.NewChatSession(majordomo, runner, completions.ChatState{
+			Project: "actual", Assistant: "go_developer", ThreadId: "thread_none"}, "")
+		Expect(err).To(MatchError(completions.ErrThreadNotFound))
+		_, err = completions.NewChatSession(majordomo, runner, completions.ChatState{
+			Project: "none", Assistant: "go_developer"}, "")
+		Expect(err).To(MatchError(completions.ErrProjectNotFound))
+		_, err = completions.NewChatSession(majordomo, runner, completions.ChatState{Project: "actual"}, "")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("continues the Thread created by the first prompt, and reports the progress", func() {
+		session, err := completions.NewChatSession(majordomo, progressRunner{runner},
+			completions.ChatState{Assistant: "go_developer"}, "alice")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(session.State().Project).To(Equal(majordomo.ActiveProject()))
+		close(runner.release)
+
+		var mu sync.Mutex
+		var progress []completions.Progress
+		ctx := completions.WithProgress(context.Background(), func(p completions.Progress) {
+			mu.Lock()
+			defer mu.Unlock()
+			progress = append(progress, p)
+		})
+		response, err := session.Prompt(ctx, "Hello", nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(response.Message).To(Equal("done: Hello"))
+		Expect(progress).To(ConsistOf(completions.Progress{Stage: completions.ProgressRun,
+			RunId: "run_Hello", RunStatus: "in_progress"}))
+		Expect(session.State().ThreadId).To(Equal("thread_" + majordomo.ActiveProject()))
+
+		Expect(session.SetAssistant("web_developer")).To(Succeed())
+		_, err = session.Prompt(context.Background(), "Again", nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(session.State()).To(Equal(completions.ChatState{
+			Project:   majordomo.ActiveProject(),
+			Assistant: "web_developer",
+			ThreadId:  "thread_" + majordomo.ActiveProject(),
+		}))
+	})
+
+	It("runs one prompt at a time, and cancels it", func() {
+		session, err := completions.NewChatSession(majordomo, runner,
+			completions.ChatState{Project: "actual", Assistant: "go_developer", ThreadId: "thread_abc"}, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(session.Cancel()).To(MatchError(completions.ErrNoPromptRunning))
+
+		done := make(chan error, 1)
+		go func() {
+			_, err := session.Prompt(context.Background(), "Slow", nil)
+			done <- err
+		}()
+		Eventually(func() int {
+			runner.mu.Lock()
+			defer runner.mu.Unlock()
+			return runner.running
+		}).Should(Equal(1))
+		_, err = session.Prompt(context.Background(), "Another", nil)
+		Expect(err).To(MatchError(completions.ErrPromptRunning))
+
+		Expect(session.Cancel()).To(Succeed())
+		Eventually(done).Should(Receive(MatchError(completions.ErrPromptCancelled)))
+		Expect(runner.cancelled).To(ConsistOf("run_Slow"))
+		Expect(session.Cancel()).To(MatchError(completions.ErrNoPromptRunning))
+	})
+
+	It("parses the prompts, without sending them", func() {
+		session, err := completions.NewChatSession(majordomo, runner,
+			completions.ChatState{Project: "actual", Assistant: "go_developer"}, "")
+		Expect(err).NotTo(HaveOccurred())
+		prompt, err := session.Parse("Hello")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(prompt.Prompt).To(ContainSubstring("Hello"))
+		Expect(runner.started).To(BeEmpty())
+	})
+})
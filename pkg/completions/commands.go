@@ -0,0 +1,326 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package completions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sashabaranov/go-openai"
+
+	"github.com/alertavert/gpt4-go/pkg/api"
+)
+
+// CommandError is returned when a spoken command cannot be carried out, as
+// requested by the user (e.g., the project does not exist).
+type CommandError struct {
+	Intent string
+	Reason string
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("cannot %s: %s", strings.ReplaceAll(e.Intent, "_", " "), e.Reason)
+}
+
+// Session keeps track of the conversation carried out via the spoken
+// commands, which (unlike the prompts) do not carry the assistant and the
+// thread with them.
+type Session struct {
+	mu sync.Mutex
+	// Assistant answers the requests; the configured default, if empty.
+	Assistant string
+	// ThreadId is the conversation the requests are added to; a new one is
+	// started by the next request, if empty.
+	ThreadId   string
+	ThreadName string
+	// LastAnswer is the most recent response from the assistant.
+	LastAnswer *PromptResponse
+}
+
+// CommandResult is the outcome of a spoken command.
+type CommandResult struct {
+	// Transcript is the command, as received.
+	Transcript string  `json:"transcript"`
+	Intent     *Intent `json:"intent"`
+	// Message describes what was done, or is the answer to be read back.
+	Message       string `json:"message"`
+	ActiveProject string `json:"active_project"`
+	Assistant     string `json:"assistant,omitempty"`
+	ThreadId      string `json:"thread_id,omitempty"`
+	ThreadName    string `json:"thread_name,omitempty"`
+	// Response is the assistant's answer, for the `ask` and `read_back` intents.
+	Response *PromptResponse `json:"response,omitempty"`
+}
+
+// classifierPrompt asks the LLM to classify the commands which do not match
+// any of the templates.
+const classifierPrompt = `You classify the spoken commands sent to a coding assistant.
+Reply only with a JSON object, with an "intent" field, which is one of:
+- "switch_project": change the active project; its name is in the "project" field;
+- "new_conversation": start a new conversation; its topic, if any, is in the "name" field,
+  and the assistant, if any, in the "assistant" field;
+- "continue_thread": continue an existing conversation; its name is in the "thread" field;
+- "read_back": repeat the last answer;
+- "ask": anything else, which is a request to the assistant; its text is in the "prompt" field.
+The projects are: %s.
+The conversations are: %s.`
+
+// classify matches the transcript against the CommandGrammar and, if none
+// of the templates matches, asks the LLM; if that fails too, the whole
+// transcript is sent to the assistant.
+func (m *Majordomo) classify(ctx context.Context, transcript string) *Intent {
+	if intent, ok := m.Commands.Match(transcript); ok {
+		return intent
+	}
+	if !m.Config.Voice.DisableLLMFallback {
+		intent, err := m.classifyWithLLM(ctx, transcript)
+		if err == nil {
+			return intent
+		}
+		log.Warn().Err(err).Msg("cannot classify the command, sending it to the assistant")
+	}
+	return &Intent{Name: IntentAsk, Args: map[string]string{"prompt": NormalizeTranscript(transcript)}}
+}
+
+// classifyWithLLM asks the LLM for the intent of the transcript.
+func (m *Majordomo) classifyWithLLM(ctx context.Context, transcript string) (*Intent, error) {
+	if m.Client == nil {
+		return nil, fmt.Errorf("OpenAI client not initialized")
+	}
+	var projects, threads []string
+	configured, _ := m.Projects()
+	for _, p := range configured {
+		projects = append(projects, p.Name)
+	}
+	for _, t := range m.Threads.GetAllThreads(m.ProjectID(m.ActiveProject())) {
+		threads = append(threads, t.Name)
+	}
+	resp, err := m.Client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model: m.Model,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role: openai.ChatMessageRoleSystem,
+				Content: fmt.Sprintf(classifierPrompt,
+					strings.Join(projects, ", "), strings.Join(threads, ", ")),
+			},
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: transcript,
+			},
+		},
+		ResponseFormat: &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("no classification returned from OpenAI")
+	}
+	var fields map[string]string
+	if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), &fields); err != nil {
+		return nil, fmt.Errorf("cannot parse the classification: %w", err)
+	}
+	intent := &Intent{Name: fields["intent"], Args: make(map[string]string)}
+	delete(fields, "intent")
+	for k, v := range fields {
+		if v != "" {
+			intent.Args[k] = v
+		}
+	}
+	known := false
+	for _, name := range Intents {
+		known = known || name == intent.Name
+	}
+	if !known {
+		return nil, fmt.Errorf("unknown intent %q", intent.Name)
+	}
+	if intent.Name == IntentAsk && intent.Args["prompt"] == "" {
+		intent.Args["prompt"] = NormalizeTranscript(transcript)
+	}
+	log.Debug().
+		Str("intent", intent.Name).
+		Interface("args", intent.Args).
+		Msg("command classified by the LLM")
+	return intent, nil
+}
+
+// spokenName reduces a name to its lowercase letters and digits, so that
+// "common utils" matches "common-utils".
+func spokenName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+}
+
+// ExecuteCommand classifies the transcript of a spoken command, and carries
+// it out; it returns a CommandError if the command cannot be carried out as
+// requested. Cancelling `ctx` stops querying the LLM.
+func (m *Majordomo) ExecuteCommand(ctx context.Context, transcript string) (*CommandResult, error) {
+	if strings.TrimSpace(transcript) == "" {
+		return nil, &CommandError{Intent: "understand", Reason: "the command is empty"}
+	}
+	intent := m.classify(ctx, transcript)
+	log.Debug().
+		Str("transcript", transcript).
+		Str("intent", intent.Name).
+		Interface("args", intent.Args).
+		Msg("executing command")
+
+	result := &CommandResult{Transcript: transcript, Intent: intent}
+	var err error
+	if intent.Name == IntentAsk {
+		err = m.ask(ctx, intent, result)
+	} else {
+		err = m.updateSession(intent, result)
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.ActiveProject = m.ActiveProject()
+	return result, nil
+}
+
+// updateSession carries out the commands which do not query the assistant,
+// holding the session's lock.
+func (m *Majordomo) updateSession(intent *Intent, result *CommandResult) error {
+	s := m.Session
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var err error
+	switch intent.Name {
+	case IntentSwitchProject:
+		err = m.switchProject(intent, result)
+	case IntentNewConversation:
+		s.ThreadId, s.ThreadName = "", intent.Args["name"]
+		if assistant := intent.Args["assistant"]; assistant != "" {
+			s.Assistant = strings.Join(strings.Fields(strings.ToLower(assistant)), "_")
+		}
+		result.Message = "Started a new conversation"
+		if s.ThreadName != "" {
+			result.Message += " about " + s.ThreadName
+		}
+	case IntentContinueThread:
+		err = m.continueThread(intent, result)
+	case IntentReadBack:
+		if s.LastAnswer == nil {
+			return &CommandError{Intent: intent.Name, Reason: "there is no answer yet"}
+		}
+		result.Message = s.LastAnswer.Text
+		result.Response = s.LastAnswer
+	default:
+		err = &CommandError{Intent: intent.Name, Reason: "unknown intent"}
+	}
+	if err != nil {
+		return err
+	}
+	result.Assistant = m.sessionAssistant()
+	result.ThreadId, result.ThreadName = s.ThreadId, s.ThreadName
+	return nil
+}
+
+// sessionAssistant is the assistant answering the spoken requests; the
+// session must be locked.
+func (m *Majordomo) sessionAssistant() string {
+	if m.Session.Assistant != "" {
+		return m.Session.Assistant
+	}
+	return m.Config.Voice.DefaultAssistant
+}
+
+func (m *Majordomo) switchProject(intent *Intent, result *CommandResult) error {
+	spoken := spokenName(intent.Args["project"])
+	projects, _ := m.Projects()
+	for _, p := range projects {
+		if spokenName(p.Name) != spoken {
+			continue
+		}
+		if err := m.SetActiveProject(p.Name); err != nil {
+			return err
+		}
+		// Conversations belong to a project.
+		m.Session.ThreadId, m.Session.ThreadName = "", ""
+		result.Message = "Switched to project " + p.Name
+		return nil
+	}
+	return &CommandError{Intent: intent.Name, Reason: fmt.Sprintf("project %q not found", intent.Args["project"])}
+}
+
+func (m *Majordomo) continueThread(intent *Intent, result *CommandResult) error {
+	wanted := intent.Args["thread"]
+	threads := m.Threads.GetAllThreads(m.ProjectID(m.ActiveProject()))
+	// The ID is an exact match, the name is the closest match.
+	for _, t := range threads {
+		if t.ID == wanted {
+			m.continueWith(t.ID, t.Name, t.Assistant, result)
+			return nil
+		}
+	}
+	spoken := spokenName(wanted)
+	for _, exact := range []bool{true, false} {
+		for _, t := range threads {
+			name := spokenName(t.Name)
+			if name == spoken || (!exact && spoken != "" && strings.Contains(name, spoken)) {
+				m.continueWith(t.ID, t.Name, t.Assistant, result)
+				return nil
+			}
+		}
+	}
+	return &CommandError{Intent: intent.Name, Reason: fmt.Sprintf("conversation %q not found in project %s",
+		wanted, m.ActiveProject())}
+}
+
+func (m *Majordomo) continueWith(threadId, threadName, assistant string, result *CommandResult) {
+	m.Session.ThreadId, m.Session.ThreadName = threadId, threadName
+	if assistant != "" {
+		m.Session.Assistant = assistant
+	}
+	result.Message = "Continuing the conversation " + threadName
+}
+
+// ask sends the request to the assistant, in the session's conversation;
+// the session is not locked while the assistant answers, which can take
+// minutes, so that the other commands are not blocked.
+func (m *Majordomo) ask(ctx context.Context, intent *Intent, result *CommandResult) error {
+	s := m.Session
+	s.mu.Lock()
+	prompt := &PromptRequest{PromptRequest: api.PromptRequest{
+		Assistant:  m.sessionAssistant(),
+		ThreadId:   s.ThreadId,
+		ThreadName: s.ThreadName,
+		Prompt:     intent.Args["prompt"],
+	}}
+	s.mu.Unlock()
+	if prompt.Assistant == "" {
+		return &CommandError{Intent: intent.Name, Reason: "no assistant selected, start a new conversation with one"}
+	}
+	if err := prompt.Validate(); err != nil {
+		return &CommandError{Intent: intent.Name, Reason: err.Error()}
+	}
+	threadId, threadName := prompt.ThreadId, prompt.ThreadName
+	response, err := m.QueryBot(ctx, prompt)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	// Unless another command moved the session to a different conversation, meanwhile.
+	if s.ThreadId == threadId && s.ThreadName == threadName {
+		s.ThreadId, s.ThreadName = prompt.ThreadId, prompt.ThreadName
+	}
+	s.LastAnswer = response
+	s.mu.Unlock()
+	result.Message = response.Text
+	result.Response = response
+	result.Assistant = prompt.Assistant
+	result.ThreadId, result.ThreadName = prompt.ThreadId, prompt.ThreadName
+	return nil
+}
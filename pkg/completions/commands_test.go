@@ -0,0 +1,216 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package completions_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/alertavert/gpt4-go/pkg/api"
+	"github.com/alertavert/gpt4-go/pkg/completions"
+	"github.com/alertavert/gpt4-go/pkg/config"
+	"github.com/alertavert/gpt4-go/pkg/conversations"
+	"github.com/sashabaranov/go-openai"
+)
+
+var _ = Describe("Spoken commands", func() {
+	Context("matching the grammar", func() {
+		var grammar *completions.CommandGrammar
+
+		BeforeEach(func() {
+			var err error
+			grammar, err = completions.NewCommandGrammar(map[string][]string{
+				completions.IntentSwitchProject: {"let's work on {project}"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		DescribeTable("classifies the transcripts",
+			func(transcript, intent string, args map[string]string) {
+				match, ok := grammar.Match(transcript)
+				Expect(ok).To(BeTrue())
+				Expect(match.Name).To(Equal(intent))
+				for k, v := range args {
+					Expect(match.Args).To(HaveKeyWithValue(k, v))
+				}
+			},
+			Entry("switch project", "Switch to project Majordomo.", completions.IntentSwitchProject,
+				map[string]string{"project": "Majordomo"}),
+			Entry("custom template", "Let's work on common utils", completions.IntentSwitchProject,
+				map[string]string{"project": "common utils"}),
+			Entry("new conversation", "Please, start a new conversation about the parser!",
+				completions.IntentNewConversation, map[string]string{"name": "the parser"}),
+			Entry("new conversation with assistant", "new conversation with web developer",
+				completions.IntentNewConversation, map[string]string{"assistant": "web developer"}),
+			Entry("continue thread", "Continue the  refactoring conversation.", completions.IntentContinueThread,
+				map[string]string{"thread": "refactoring"}),
+			Entry("read back", "Hey Majordomo, repeat that?", completions.IntentReadBack, nil),
+			Entry("ask", "Ask the assistant to add a test for the parser", completions.IntentAsk,
+				map[string]string{"prompt": "add a test for the parser"}),
+		)
+
+		It("does not match free-form requests", func() {
+			_, ok := grammar.Match("what's wrong with the server tests")
+			Expect(ok).To(BeFalse())
+		})
+
+		It("rejects unknown intents", func() {
+			_, err := completions.NewCommandGrammar(map[string][]string{"dance": {"dance"}})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("executing the commands", func() {
+		var (
+			majordomo *completions.Majordomo
+			tmpDir    string
+		)
+
+		BeforeEach(func() {
+			cfg, err := config.LoadConfig(TestConfigLocation)
+			Expect(err).NotTo(HaveOccurred())
+			tmpDir, err = os.MkdirTemp("", "commands-test-")
+			Expect(err).NotTo(HaveOccurred())
+			cfg.ThreadsLocation = filepath.Join(tmpDir, "threads.json")
+			cfg.Voice.DisableLLMFallback = true
+			cfg.Voice.DefaultAssistant = "go_developer"
+			majordomo, err = completions.NewMajordomo(cfg)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(majordomo.Threads.AddThread("actual", conversations.Thread{
+				ID:        "thread_abc",
+				Name:      "Parser refactoring",
+				Assistant: "web_developer",
+			})).To(Succeed())
+		})
+		AfterEach(func() {
+			Expect(os.RemoveAll(tmpDir)).To(Succeed())
+		})
+
+		It("switches the active project", func() {
+			result, err := majordomo.ExecuteCommand(context.Background(), "Switch to the Test Project 2 project.")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.ActiveProject).To(Equal("test-project-2"))
+			Expect(majordomo.Config.ActiveProject).To(Equal("test-project-2"))
+		})
+
+		It("fails to switch to an unknown project", func() {
+			_, err := majordomo.ExecuteCommand(context.Background(), "open project nowhere")
+			var commandErr *completions.CommandError
+			Expect(errors.As(err, &commandErr)).To(BeTrue())
+			Expect(commandErr.Intent).To(Equal(completions.IntentSwitchProject))
+		})
+
+		It("starts and continues conversations", func() {
+			Expect(majordomo.SetActiveProject("actual")).To(Succeed())
+			result, err := majordomo.ExecuteCommand(context.Background(), "continue the parser conversation")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.ThreadId).To(Equal("thread_abc"))
+			Expect(result.Assistant).To(Equal("web_developer"))
+
+			result, err = majordomo.ExecuteCommand(context.Background(), "start a new conversation about logging")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.ThreadId).To(BeEmpty())
+			Expect(result.ThreadName).To(Equal("logging"))
+			Expect(majordomo.Session.ThreadName).To(Equal("logging"))
+		})
+
+		It("cannot read back before any answer", func() {
+			_, err := majordomo.ExecuteCommand(context.Background(), "read back the last answer")
+			var commandErr *completions.CommandError
+			Expect(errors.As(err, &commandErr)).To(BeTrue())
+
+			majordomo.Session.LastAnswer = &completions.PromptResponse{BotResponse: api.BotResponse{Text: "All good."}}
+			result, err := majordomo.ExecuteCommand(context.Background(), "read back the last answer")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Message).To(Equal("All good."))
+		})
+
+		It("stops asking the assistant, when the request is cancelled", func() {
+			// OpenAI stalls, until released.
+			release := make(chan struct{})
+			received := make(chan struct{}, 1)
+			stalled := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case received <- struct{}{}:
+				default:
+				}
+				<-release
+				http.Error(w, `{"error":{"message":"bad request"}}`, http.StatusBadRequest)
+			}))
+			defer stalled.Close()
+			var releaseOnce sync.Once
+			// Deferred after Close, so that it runs before, even if the test fails.
+			defer releaseOnce.Do(func() { close(release) })
+			clientConfig := openai.DefaultConfig("test-key")
+			clientConfig.BaseURL = stalled.URL + "/v1"
+			majordomo.Client = openai.NewClientWithConfig(clientConfig)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			asked := make(chan error, 1)
+			go func() {
+				defer GinkgoRecover()
+				_, err := majordomo.ExecuteCommand(ctx, "how do I configure the logger")
+				asked <- err
+			}()
+			Eventually(received).Should(Receive())
+			cancel()
+			Eventually(asked).Should(Receive(MatchError(context.Canceled)))
+		})
+		It("does not block the other commands, while the assistant answers", func() {
+			// OpenAI stalls, until released.
+			release := make(chan struct{})
+			received := make(chan struct{}, 1)
+			stalled := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case received <- struct{}{}:
+				default:
+				}
+				<-release
+				http.Error(w, `{"error":{"message":"bad request"}}`, http.StatusBadRequest)
+			}))
+			defer stalled.Close()
+			var releaseOnce sync.Once
+			// Deferred after Close, so that it runs before, even if the test fails.
+			defer releaseOnce.Do(func() { close(release) })
+			clientConfig := openai.DefaultConfig("test-key")
+			clientConfig.BaseURL = stalled.URL + "/v1"
+			majordomo.Client = openai.NewClientWithConfig(clientConfig)
+
+			asked := make(chan error, 1)
+			go func() {
+				defer GinkgoRecover()
+				_, err := majordomo.ExecuteCommand(context.Background(), "how do I configure the logger")
+				asked <- err
+			}()
+			Eventually(received).Should(Receive())
+
+			started := make(chan *completions.CommandResult, 1)
+			go func() {
+				defer GinkgoRecover()
+				result, err := majordomo.ExecuteCommand(context.Background(), "start a new conversation about logging")
+				Expect(err).NotTo(HaveOccurred())
+				started <- result
+			}()
+			var result *completions.CommandResult
+			Eventually(started).Should(Receive(&result))
+			Expect(result.ThreadName).To(Equal("logging"))
+
+			releaseOnce.Do(func() { close(release) })
+			Eventually(asked).Should(Receive(HaveOccurred()))
+			// The failed request left the session alone.
+			Expect(majordomo.Session.ThreadName).To(Equal("logging"))
+		})
+	})
+})
@@ -0,0 +1,181 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package completions
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/alertavert/gpt4-go/pkg/api"
+	"github.com/alertavert/gpt4-go/pkg/preprocessors"
+	"github.com/alertavert/gpt4-go/pkg/testrunner"
+)
+
+const (
+	// DefaultMaxFixIterations is used if `max_fix_iterations` is not configured.
+	DefaultMaxFixIterations = 3
+	// MaxFixSourceFiles limits the number of source files sent to the LLM in each iteration.
+	MaxFixSourceFiles = 10
+)
+
+// FixTestsRequest asks Majordomo to iteratively fix the failing tests of a project.
+type FixTestsRequest = api.FixTestsRequest
+
+// FixIteration reports the outcome of each test run, and what was sent to
+// (and received from) the LLM to fix the failures.
+type FixIteration = api.FixIteration
+
+// FixTestsReport is the final outcome of the test-fixing loop.
+type FixTestsReport = api.FixTestsReport
+
+// FixTests runs the project's tests in a scratch worktree and, while they
+// fail, sends the failures (and the relevant source files) to the assistant,
+// applying the returned code to the worktree and re-running the tests, until
+// they either pass or the maximum number of iterations is reached.
+//
+// The files changed in the worktree are finally saved to the project's code
+// snippets location, as with any other prompt.
+func (m *Majordomo) FixTests(ctx context.Context, projectName string, req *FixTestsRequest) (*FixTestsReport, error) {
+	project := m.GetProject(projectName)
+	if project == nil {
+		return nil, fmt.Errorf("project %s not found", projectName)
+	}
+	packages := req.Packages
+	if len(packages) == 0 {
+		packages = []string{"./..."}
+	}
+	maxIterations := req.MaxIterations
+	if maxIterations == 0 {
+		maxIterations = m.Config.MaxFixIterations
+	}
+	if maxIterations <= 0 {
+		maxIterations = DefaultMaxFixIterations
+	}
+
+	wt, err := testrunner.NewWorktree(project.Location, project.ResolvedCodeSnippetsDir)
+	if err != nil {
+		return nil, err
+	}
+	report := &FixTestsReport{
+		Project:  project.Name,
+		ThreadId: req.ThreadId,
+	}
+	if req.KeepWorktree {
+		report.Worktree = wt.Dir
+	} else {
+		defer func() {
+			if err := wt.Remove(); err != nil {
+				log.Warn().Err(err).Str("worktree", wt.Dir).Msg("could not remove worktree")
+			}
+		}()
+	}
+	// The LLM reads from, and writes to, the worktree; subject to the same
+	// include/exclude rules as the project.
+	rules, err := preprocessors.NewAccessRules(project.Include, project.Exclude)
+	if err != nil {
+		return nil, fmt.Errorf("invalid include/exclude rules for %s: %w", project.Name, err)
+	}
+	store := &preprocessors.FilesystemStore{
+		SourceCodeDir: wt.Dir,
+		DestCodeDir:   wt.Dir,
+		Rules:         rules,
+	}
+	runner := testrunner.NewRunner(wt.Dir, project.TestCommand)
+	changed := make(map[string]bool)
+
+	for i := 1; ; i++ {
+		result, err := runner.Run(ctx, packages)
+		if err != nil {
+			return nil, err
+		}
+		iteration := FixIteration{Iteration: i, TestResult: result}
+		if result.Passed || i > maxIterations {
+			report.Passed = result.Passed
+			report.Iterations = append(report.Iterations, iteration)
+			break
+		}
+		iteration.FilesSent = testrunner.RelevantFiles(wt.Dir, packages, result.Output, MaxFixSourceFiles)
+		prompt := &PromptRequest{PromptRequest: api.PromptRequest{
+			Assistant:  req.Assistant,
+			ThreadId:   report.ThreadId,
+			ThreadName: fmt.Sprintf("Fix tests in %s", strings.Join(packages, " ")),
+			Prompt:     fixTestsPrompt(runner.Command, packages, result, iteration.FilesSent),
+		}}
+		reply, err := m.queryBot(ctx, prompt, project.Name, store)
+		report.ThreadId = prompt.ThreadId
+		if err != nil {
+			iteration.Error = err.Error()
+			report.Iterations = append(report.Iterations, iteration)
+			log.Err(err).Int("iteration", i).Msg("error querying the LLM to fix tests")
+			break
+		}
+		iteration.Response = reply.Text
+		for path := range reply.Snippets {
+			iteration.FilesChanged = append(iteration.FilesChanged, path)
+			changed[path] = true
+		}
+		sort.Strings(iteration.FilesChanged)
+		if len(reply.Snippets) == 0 {
+			// Nothing was changed, there is no point in re-running the tests.
+			iteration.Error = "no code returned by the assistant"
+			report.Iterations = append(report.Iterations, iteration)
+			break
+		}
+		report.Iterations = append(report.Iterations, iteration)
+		log.Debug().
+			Int("iteration", i).
+			Str("thread_id", report.ThreadId).
+			Strs("changed", iteration.FilesChanged).
+			Msg("fix applied, re-running tests")
+	}
+
+	// Saves the fixed files to the project's snippets, so they can be reviewed.
+	if len(changed) > 0 {
+		fixed := make(preprocessors.SourceCodeMap)
+		for path := range changed {
+			content, err := wt.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			fixed[path] = content
+			report.Changed = append(report.Changed, path)
+		}
+		sort.Strings(report.Changed)
+		if err := m.Stores.Get(project).PutSourceCode(fixed); err != nil {
+			return nil, fmt.Errorf("error saving fixed files: %w", err)
+		}
+	}
+	log.Info().
+		Str("project", project.Name).
+		Str("thread_id", report.ThreadId).
+		Bool("passed", report.Passed).
+		Int("iterations", len(report.Iterations)).
+		Msg("fix tests completed")
+	return report, nil
+}
+
+// fixTestsPrompt builds the prompt asking the LLM to fix the failures.
+// The source files are added as empty snippets, to be filled in by the CodeStoreHandler.
+func fixTestsPrompt(command, packages []string, result *testrunner.Result, files []string) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Running `%s %s` fails",
+		strings.Join(command, " "), strings.Join(packages, " ")))
+	if len(result.FailedTests) > 0 {
+		sb.WriteString(fmt.Sprintf(" (failed: %s)", strings.Join(result.FailedTests, ", ")))
+	}
+	sb.WriteString(fmt.Sprintf(" after %v, with the following output:\n'''\n%s\n'''\n",
+		result.Duration.Round(time.Millisecond), strings.TrimSpace(result.Output)))
+	sb.WriteString("Please fix the code so that the tests pass, and send back the complete " +
+		"contents of every file you modify. These are the relevant source files:\n")
+	for _, f := range files {
+		sb.WriteString(fmt.Sprintf("'''%s\n'''\n", f))
+	}
+	return sb.String()
+}
@@ -0,0 +1,202 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package completions
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/alertavert/gpt4-go/pkg/api"
+)
+
+// The intents of the spoken commands.
+const (
+	IntentSwitchProject   = "switch_project"
+	IntentNewConversation = "new_conversation"
+	IntentContinueThread  = "continue_thread"
+	IntentAsk             = "ask"
+	IntentReadBack        = "read_back"
+)
+
+// Intents are all the known intents, in the order in which they are matched.
+var Intents = []string{
+	IntentSwitchProject,
+	IntentNewConversation,
+	IntentContinueThread,
+	IntentReadBack,
+	IntentAsk,
+}
+
+// DefaultCommands are the built-in templates of the spoken commands, for each
+// intent; the words in braces are placeholders, which capture the arguments.
+// More specific templates must come first.
+var DefaultCommands = map[string][]string{
+	IntentSwitchProject: {
+		"switch to project {project}",
+		"switch to the {project} project",
+		"switch to {project} project",
+		"change project to {project}",
+		"open project {project}",
+		"open the {project} project",
+		"use project {project}",
+	},
+	IntentNewConversation: {
+		"start a new conversation with {assistant} about {name}",
+		"start a new conversation about {name}",
+		"start a new conversation with {assistant}",
+		"start a new conversation",
+		"new conversation about {name}",
+		"new conversation with {assistant}",
+		"new conversation",
+		"start a new thread about {name}",
+		"start a new thread",
+		"new thread",
+	},
+	IntentContinueThread: {
+		"continue the {thread} conversation",
+		"continue the {thread} thread",
+		"continue conversation {thread}",
+		"continue thread {thread}",
+		"switch to thread {thread}",
+		"go back to {thread}",
+	},
+	IntentReadBack: {
+		"read back the last answer",
+		"read the last answer",
+		"read back",
+		"repeat the last answer",
+		"repeat that",
+		"what did you say",
+	},
+	IntentAsk: {
+		"ask the assistant to {prompt}",
+		"ask the assistant {prompt}",
+		"ask {prompt}",
+	},
+}
+
+// Intent is the classification of a spoken command, with its arguments
+// (e.g., the name of the project to switch to).
+type Intent = api.Intent
+
+// commandTemplate is a compiled template of a spoken command.
+type commandTemplate struct {
+	intent   string
+	template string
+	regex    *regexp.Regexp
+}
+
+// CommandGrammar matches the transcripts of the spoken commands against the
+// configured templates.
+type CommandGrammar struct {
+	templates []commandTemplate
+}
+
+var placeholderRegex = regexp.MustCompile(`\{(\w+)}`)
+
+// compileTemplate turns a template into a case-insensitive regular expression,
+// matching the whole command, where each placeholder captures one or more words.
+func compileTemplate(template string) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	sb.WriteString(`(?i)^`)
+	last := 0
+	for _, loc := range placeholderRegex.FindAllStringSubmatchIndex(template, -1) {
+		sb.WriteString(wordsPattern(template[last:loc[0]]))
+		sb.WriteString(fmt.Sprintf(`(?P<%s>.+?)`, template[loc[2]:loc[3]]))
+		last = loc[1]
+	}
+	sb.WriteString(wordsPattern(template[last:]))
+	sb.WriteString(`$`)
+	return regexp.Compile(sb.String())
+}
+
+// wordsPattern matches the literal words, separated by any whitespace.
+func wordsPattern(words string) string {
+	var parts []string
+	for _, w := range strings.Fields(words) {
+		parts = append(parts, regexp.QuoteMeta(w))
+	}
+	pattern := strings.Join(parts, `\s+`)
+	if strings.HasPrefix(words, " ") && pattern != "" {
+		pattern = `\s+` + pattern
+	}
+	if strings.HasSuffix(words, " ") {
+		pattern += `\s+`
+	}
+	return pattern
+}
+
+// NewCommandGrammar compiles the templates in `commands`, which are matched
+// before the DefaultCommands; it returns an error if any of the intents is
+// unknown, or any of the templates is invalid.
+func NewCommandGrammar(commands map[string][]string) (*CommandGrammar, error) {
+	known := make(map[string]bool)
+	for _, intent := range Intents {
+		known[intent] = true
+	}
+	for intent := range commands {
+		if !known[intent] {
+			return nil, fmt.Errorf("unknown intent %q in the voice commands", intent)
+		}
+	}
+	grammar := new(CommandGrammar)
+	for _, source := range []map[string][]string{commands, DefaultCommands} {
+		for _, intent := range Intents {
+			for _, template := range source[intent] {
+				regex, err := compileTemplate(template)
+				if err != nil {
+					return nil, fmt.Errorf("invalid voice command %q: %w", template, err)
+				}
+				grammar.templates = append(grammar.templates, commandTemplate{
+					intent:   intent,
+					template: template,
+					regex:    regex,
+				})
+			}
+		}
+	}
+	return grammar, nil
+}
+
+var (
+	// Transcripts are punctuated, and often polite.
+	trailingPunctuation = regexp.MustCompile(`[\s.,;:!?]+$`)
+	politeness          = regexp.MustCompile(`(?i)^(please|hey majordomo|majordomo|ok|okay)[\s,]+|[\s,]+please$`)
+)
+
+// NormalizeTranscript removes the punctuation and the courtesies around the
+// command, which are not part of the templates.
+func NormalizeTranscript(transcript string) string {
+	text := strings.TrimSpace(transcript)
+	for {
+		normalized := trailingPunctuation.ReplaceAllString(text, "")
+		normalized = strings.TrimSpace(politeness.ReplaceAllString(normalized, ""))
+		if normalized == text {
+			return text
+		}
+		text = normalized
+	}
+}
+
+// Match returns the intent of the first template matching the transcript,
+// or false if there is none.
+func (g *CommandGrammar) Match(transcript string) (*Intent, bool) {
+	text := NormalizeTranscript(transcript)
+	for _, t := range g.templates {
+		groups := t.regex.FindStringSubmatch(text)
+		if groups == nil {
+			continue
+		}
+		intent := &Intent{Name: t.intent, Args: make(map[string]string), Matched: t.template}
+		for i, name := range t.regex.SubexpNames() {
+			if name != "" {
+				intent.Args[name] = strings.TrimSpace(groups[i])
+			}
+		}
+		return intent, true
+	}
+	return nil, false
+}
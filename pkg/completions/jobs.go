@@ -0,0 +1,443 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package completions
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/alertavert/gpt4-go/pkg/api"
+)
+
+const (
+	// DefaultJobWorkers is the number of prompt jobs run concurrently, if not configured.
+	DefaultJobWorkers = 2
+	// DefaultJobRetention is how long the finished jobs are kept, if not configured.
+	DefaultJobRetention = 24 * time.Hour
+)
+
+type JobStatus = api.JobStatus
+
+const (
+	JobQueued    = api.JobQueued
+	JobRunning   = api.JobRunning
+	JobCompleted = api.JobCompleted
+	JobFailed    = api.JobFailed
+	JobCancelled = api.JobCancelled
+)
+
+var (
+	// ErrJobNotFound is returned when there is no job with the given ID.
+	ErrJobNotFound = errors.New("job not found")
+	// ErrJobFinished is returned when cancelling a job which has already finished.
+	ErrJobFinished = errors.New("job already finished")
+	// ErrQueueStopped is returned when submitting a job while the server shuts down.
+	ErrQueueStopped = errors.New("job queue stopped")
+)
+
+// Job is a prompt run in the background.
+type Job = api.Job
+
+// PromptRunner sends the prompts to the LLM, and retrieves the responses;
+// it is implemented by Majordomo.
+type PromptRunner interface {
+	StartPrompt(ctx context.Context, prompt *PromptRequest, project string) (string, error)
+	FinishPrompt(ctx context.Context, prompt *PromptRequest, project, runId string) (*PromptResponse, error)
+	CancelRun(threadId, runId string) error
+}
+
+// JobQueue runs the prompt jobs in the background, at most `workers` at a
+// time, and keeps track of them on disk, so that those which had not
+// finished are resumed after a restart.
+type JobQueue struct {
+	// Retention is how long the finished jobs are kept; the older ones are
+	// pruned whenever the jobs are saved.
+	Retention time.Duration
+
+	runner   PromptRunner
+	location string
+	slots    chan struct{}
+
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	cancels map[string]context.CancelFunc
+	wg      sync.WaitGroup
+	// stopping is closed by Drain: the queued jobs are no longer started.
+	stopping chan struct{}
+}
+
+// NewJobQueue creates a JobQueue, loading the jobs stored at `location`, if
+// any; call Resume to restart those which had not finished.
+func NewJobQueue(runner PromptRunner, location string, workers int) (*JobQueue, error) {
+	if workers <= 0 {
+		workers = DefaultJobWorkers
+	}
+	q := &JobQueue{
+		Retention: DefaultJobRetention,
+		runner:    runner,
+		location:  location,
+		slots:     make(chan struct{}, workers),
+		jobs:      make(map[string]*Job),
+		cancels:   make(map[string]context.CancelFunc),
+		stopping:  make(chan struct{}),
+	}
+	if err := q.load(); err != nil {
+		return nil, fmt.Errorf("error loading jobs from %s: %w", location, err)
+	}
+	return q, nil
+}
+
+// Resume restarts the jobs which were queued, or running, when the server
+// stopped; those whose Run had been created wait for it to complete.
+func (q *JobQueue) Resume() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	resumed := 0
+	for _, job := range q.sortedJobs() {
+		if !job.Finished() {
+			q.start(job)
+			resumed++
+		}
+	}
+	if resumed > 0 {
+		log.Info().
+			Int("jobs", resumed).
+			Msg("resuming prompt jobs")
+	}
+}
+
+// Submit queues the prompt, to be run on `project`.
+func (q *JobQueue) Submit(project string, request PromptRequest) (*Job, error) {
+	id, err := newJobId()
+	if err != nil {
+		return nil, err
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.stopped() {
+		return nil, ErrQueueStopped
+	}
+	job := &Job{
+		ID:        id,
+		Status:    JobQueued,
+		Project:   project,
+		Request:   request.PromptRequest,
+		CreatedAt: time.Now().UTC(),
+	}
+	q.jobs[id] = job
+	if err = q.save(); err != nil {
+		delete(q.jobs, id)
+		return nil, err
+	}
+	q.start(job)
+	log.Debug().
+		Str("job_id", id).
+		Str("project", project).
+		Str("assistant", request.Assistant).
+		Msg("prompt job queued")
+	copied := *job
+	return &copied, nil
+}
+
+// Get returns a copy of the job with the given ID.
+func (q *JobQueue) Get(id string) (*Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, found := q.jobs[id]
+	if !found {
+		return nil, false
+	}
+	copied := *job
+	return &copied, true
+}
+
+// List returns copies of all the jobs, oldest first.
+func (q *JobQueue) List() []*Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	jobs := q.sortedJobs()
+	for i, job := range jobs {
+		copied := *job
+		jobs[i] = &copied
+	}
+	return jobs
+}
+
+// Cancel stops the job, and its Run, if it has not finished yet.
+func (q *JobQueue) Cancel(id string) (*Job, error) {
+	q.mu.Lock()
+	job, found := q.jobs[id]
+	if !found {
+		q.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s", ErrJobNotFound, id)
+	}
+	if job.Finished() {
+		q.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s is %s", ErrJobFinished, id, job.Status)
+	}
+	q.finish(job, JobCancelled, nil, nil)
+	copied := *job
+	q.mu.Unlock()
+
+	if copied.RunId != "" {
+		if err := q.runner.CancelRun(copied.Request.ThreadId, copied.RunId); err != nil {
+			// The Run may have completed in the meantime.
+			log.Warn().Err(err).Str("job_id", id).Msg("could not cancel the run")
+		}
+	}
+	log.Debug().Str("job_id", id).Msg("prompt job cancelled")
+	return &copied, nil
+}
+
+// Wait blocks until all the jobs have finished, or been cancelled.
+func (q *JobQueue) Wait() {
+	q.wg.Wait()
+}
+
+// Drain stops starting the queued jobs, and waits for the running ones to
+// finish, until `ctx` is done; then, those still running are interrupted.
+// Either way, the jobs which did not finish are left as they were on disk,
+// and are resumed after a restart.
+func (q *JobQueue) Drain(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.stopped() {
+		close(q.stopping)
+	}
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+	q.mu.Lock()
+	interrupted := len(q.cancels)
+	for _, cancel := range q.cancels {
+		cancel()
+	}
+	q.mu.Unlock()
+	log.Warn().
+		Int("jobs", interrupted).
+		Msg("prompt jobs interrupted, they will be resumed after a restart")
+	<-done
+	return ctx.Err()
+}
+
+// stopped is true once Drain was called; the caller must hold the lock.
+func (q *JobQueue) stopped() bool {
+	select {
+	case <-q.stopping:
+		return true
+	default:
+		return false
+	}
+}
+
+// start runs the job in the background; the caller must hold the lock.
+func (q *JobQueue) start(job *Job) {
+	ctx, cancel := context.WithCancel(context.Background())
+	q.cancels[job.ID] = cancel
+	q.wg.Add(1)
+	go q.run(ctx, job.ID)
+}
+
+// run waits for a free worker, then sends the prompt (unless it was sent
+// before a restart) and waits for the response.
+func (q *JobQueue) run(ctx context.Context, id string) {
+	defer q.wg.Done()
+	select {
+	case q.slots <- struct{}{}:
+		defer func() { <-q.slots }()
+	case <-ctx.Done():
+		return
+	case <-q.stopping:
+		return
+	}
+
+	q.mu.Lock()
+	job := q.jobs[id]
+	if job.Finished() || q.stopped() {
+		q.mu.Unlock()
+		return
+	}
+	now := time.Now().UTC()
+	job.Status = JobRunning
+	if job.StartedAt == nil {
+		job.StartedAt = &now
+	}
+	q.saveOrLog()
+	prompt, project, runId := PromptRequest{PromptRequest: job.Request}, job.Project, job.RunId
+	q.mu.Unlock()
+
+	if runId == "" {
+		var err error
+		runId, err = q.runner.StartPrompt(ctx, &prompt, project)
+		q.mu.Lock()
+		if err != nil {
+			if !q.interrupted(job, err) {
+				q.finish(job, JobFailed, nil, err)
+			}
+			q.mu.Unlock()
+			return
+		}
+		// Recorded, so that the response can be retrieved after a restart.
+		job.RunId = runId
+		job.Request.ThreadId = prompt.ThreadId
+		job.Request.ThreadName = prompt.ThreadName
+		q.saveOrLog()
+		cancelled := job.Status == JobCancelled
+		q.mu.Unlock()
+		if cancelled {
+			// Cancelled while the Run was being created.
+			if err = q.runner.CancelRun(prompt.ThreadId, runId); err != nil {
+				log.Warn().Err(err).Str("job_id", id).Msg("could not cancel the run")
+			}
+			return
+		}
+	}
+	response, err := q.runner.FinishPrompt(ctx, &prompt, project, runId)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err != nil {
+		if !q.interrupted(job, err) {
+			q.finish(job, JobFailed, nil, err)
+		}
+	} else {
+		q.finish(job, JobCompleted, response, nil)
+	}
+}
+
+// interrupted is true if the job failed because it was interrupted by Drain
+// (and not cancelled); the caller must hold the lock.
+func (q *JobQueue) interrupted(job *Job, err error) bool {
+	if !q.stopped() || job.Finished() || !errors.Is(err, context.Canceled) {
+		return false
+	}
+	delete(q.cancels, job.ID)
+	log.Info().Str("job_id", job.ID).Msg("prompt job interrupted")
+	return true
+}
+
+// finish records the outcome of the job, unless it was already cancelled,
+// and stops it; the caller must hold the lock.
+func (q *JobQueue) finish(job *Job, status JobStatus, response *PromptResponse, err error) {
+	if cancel := q.cancels[job.ID]; cancel != nil {
+		cancel()
+		delete(q.cancels, job.ID)
+	}
+	if job.Finished() {
+		return
+	}
+	now := time.Now().UTC()
+	job.Status = status
+	if response != nil {
+		job.Response = &response.BotResponse
+	}
+	job.FinishedAt = &now
+	if err != nil {
+		job.Error = err.Error()
+		log.Err(err).Str("job_id", job.ID).Msg("prompt job failed")
+	} else if status == JobCompleted {
+		log.Debug().Str("job_id", job.ID).Msg("prompt job completed")
+	}
+	q.saveOrLog()
+}
+
+// sortedJobs returns the jobs, oldest first; the caller must hold the lock.
+func (q *JobQueue) sortedJobs() []*Job {
+	jobs := make([]*Job, 0, len(q.jobs))
+	for _, job := range q.jobs {
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		if jobs[i].CreatedAt.Equal(jobs[j].CreatedAt) {
+			return jobs[i].ID < jobs[j].ID
+		}
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+	return jobs
+}
+
+// load retrieves the jobs from the disk.
+func (q *JobQueue) load() error {
+	if q.location == "" {
+		return nil
+	}
+	data, err := os.ReadFile(q.location)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var jobs []*Job
+	if err = json.Unmarshal(data, &jobs); err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		q.jobs[job.ID] = job
+	}
+	return nil
+}
+
+// prune removes the jobs which finished more than Retention ago; the caller
+// must hold the lock.
+func (q *JobQueue) prune() {
+	cutoff := time.Now().Add(-q.Retention)
+	for id, job := range q.jobs {
+		if job.Finished() && job.FinishedAt != nil && job.FinishedAt.Before(cutoff) {
+			delete(q.jobs, id)
+		}
+	}
+}
+
+// save prunes the finished jobs, and persists the others to the disk; the
+// caller must hold the lock.
+func (q *JobQueue) save() error {
+	q.prune()
+	if q.location == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(q.sortedJobs(), "", "  ")
+	if err != nil {
+		return err
+	}
+	// Written to a temporary file first, so that a crash does not lose all the jobs.
+	tmp := q.location + ".tmp"
+	if err = os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("error saving jobs: %w", err)
+	}
+	return os.Rename(tmp, q.location)
+}
+
+func (q *JobQueue) saveOrLog() {
+	if err := q.save(); err != nil {
+		log.Err(err).Str("location", q.location).Msg("error saving jobs")
+	}
+}
+
+func newJobId() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "job_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,255 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package completions_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alertavert/gpt4-go/pkg/api"
+	"github.com/alertavert/gpt4-go/pkg/completions"
+)
+
+// fakeRunner answers the prompts once `release` is closed.
+type fakeRunner struct {
+	mu        sync.Mutex
+	started   []string
+	finished  []string
+	cancelled []string
+	running   int
+	maxActive int
+	release   chan struct{}
+	err       error
+}
+
+func (f *fakeRunner) StartPrompt(_ context.Context, prompt *completions.PromptRequest,
+	project string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return "", f.err
+	}
+	f.started = append(f.started, prompt.Prompt)
+	if prompt.ThreadId == "" {
+		prompt.ThreadId = "thread_" + project
+	}
+	return "run_" + prompt.Prompt, nil
+}
+
+func (f *fakeRunner) FinishPrompt(ctx context.Context, prompt *completions.PromptRequest, _,
+	runId string) (*completions.PromptResponse, error) {
+	f.mu.Lock()
+	f.running++
+	f.maxActive = max(f.maxActive, f.running)
+	f.mu.Unlock()
+	defer func() {
+		f.mu.Lock()
+		f.running--
+		f.mu.Unlock()
+	}()
+	select {
+	case <-f.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.finished = append(f.finished, runId)
+	return &completions.PromptResponse{BotResponse: api.BotResponse{Message: "done: " + prompt.Prompt}}, nil
+}
+
+func (f *fakeRunner) CancelRun(_, runId string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cancelled = append(f.cancelled, runId)
+	return nil
+}
+
+var _ = Describe("Prompt jobs", func() {
+	var (
+		runner   *fakeRunner
+		queue    *completions.JobQueue
+		dir      string
+		location string
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "jobs-test-")
+		Expect(err).NotTo(HaveOccurred())
+		location = filepath.Join(dir, "jobs.json")
+		runner = &fakeRunner{release: make(chan struct{})}
+		queue, err = completions.NewJobQueue(runner, location, 2)
+		Expect(err).NotTo(HaveOccurred())
+	})
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	status := func(id string) func() completions.JobStatus {
+		return func() completions.JobStatus {
+			job, found := queue.Get(id)
+			Expect(found).To(BeTrue())
+			return job.Status
+		}
+	}
+
+	It("should run the prompts in the background", func() {
+		job, err := queue.Submit("actual", completions.PromptRequest{PromptRequest: api.PromptRequest{Assistant: "go_developer", Prompt: "one"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(job.ID).To(HavePrefix("job_"))
+		Expect(job.Status).To(Equal(completions.JobQueued))
+		Eventually(status(job.ID)).Should(Equal(completions.JobRunning))
+
+		close(runner.release)
+		queue.Wait()
+		job, _ = queue.Get(job.ID)
+		Expect(job.Status).To(Equal(completions.JobCompleted))
+		Expect(job.Response.Message).To(Equal("done: one"))
+		Expect(job.RunId).To(Equal("run_one"))
+		Expect(job.Request.ThreadId).To(Equal("thread_actual"))
+		Expect(job.FinishedAt).NotTo(BeNil())
+	})
+
+	It("should limit the number of concurrent jobs", func() {
+		for _, p := range []string{"one", "two", "three", "four"} {
+			_, err := queue.Submit("actual", completions.PromptRequest{PromptRequest: api.PromptRequest{Assistant: "go_developer", Prompt: p}})
+			Expect(err).NotTo(HaveOccurred())
+		}
+		time.Sleep(50 * time.Millisecond)
+		close(runner.release)
+		queue.Wait()
+		Expect(runner.finished).To(HaveLen(4))
+		Expect(runner.maxActive).To(Equal(2))
+	})
+
+	It("should record the failures", func() {
+		runner.err = errors.New("invalid API key")
+		job, err := queue.Submit("actual", completions.PromptRequest{PromptRequest: api.PromptRequest{Assistant: "go_developer", Prompt: "one"}})
+		Expect(err).NotTo(HaveOccurred())
+		queue.Wait()
+		job, _ = queue.Get(job.ID)
+		Expect(job.Status).To(Equal(completions.JobFailed))
+		Expect(job.Error).To(Equal("invalid API key"))
+	})
+
+	It("should cancel the jobs and their runs", func() {
+		job, err := queue.Submit("actual", completions.PromptRequest{PromptRequest: api.PromptRequest{Assistant: "go_developer", Prompt: "one"}})
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(func() string {
+			j, _ := queue.Get(job.ID)
+			return j.RunId
+		}).ShouldNot(BeEmpty())
+
+		job, err = queue.Cancel(job.ID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(job.Status).To(Equal(completions.JobCancelled))
+		queue.Wait()
+		Expect(runner.cancelled).To(Equal([]string{"run_one"}))
+		Expect(runner.finished).To(BeEmpty())
+		Expect(status(job.ID)()).To(Equal(completions.JobCancelled))
+
+		_, err = queue.Cancel(job.ID)
+		Expect(err).To(MatchError(completions.ErrJobFinished))
+		_, err = queue.Cancel("job_none")
+		Expect(err).To(MatchError(completions.ErrJobNotFound))
+	})
+
+	It("should prune the finished jobs, after the retention period", func() {
+		queue.Retention = 10 * time.Millisecond
+		close(runner.release)
+		finished, err := queue.Submit("actual", completions.PromptRequest{PromptRequest: api.PromptRequest{Assistant: "go_developer", Prompt: "one"}})
+		Expect(err).NotTo(HaveOccurred())
+		queue.Wait()
+		time.Sleep(20 * time.Millisecond)
+
+		kept, err := queue.Submit("actual", completions.PromptRequest{PromptRequest: api.PromptRequest{Assistant: "go_developer", Prompt: "two"}})
+		Expect(err).NotTo(HaveOccurred())
+		queue.Wait()
+		_, found := queue.Get(finished.ID)
+		Expect(found).To(BeFalse())
+		_, found = queue.Get(kept.ID)
+		Expect(found).To(BeTrue())
+
+		reloaded, err := completions.NewJobQueue(runner, location, 2)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reloaded.List()).To(HaveLen(1))
+	})
+
+	It("should resume the jobs after a restart", func() {
+		running, err := queue.Submit("actual", completions.PromptRequest{PromptRequest: api.PromptRequest{Assistant: "go_developer", Prompt: "one"}})
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(func() string {
+			j, _ := queue.Get(running.ID)
+			return j.RunId
+		}).ShouldNot(BeEmpty())
+		// Simulates the server stopping, without the job finishing.
+		restarted := &fakeRunner{release: make(chan struct{})}
+		close(restarted.release)
+		resumed, err := completions.NewJobQueue(restarted, location, 2)
+		Expect(err).NotTo(HaveOccurred())
+		job, found := resumed.Get(running.ID)
+		Expect(found).To(BeTrue())
+		Expect(job.Status).To(Equal(completions.JobRunning))
+
+		resumed.Resume()
+		resumed.Wait()
+		job, _ = resumed.Get(running.ID)
+		Expect(job.Status).To(Equal(completions.JobCompleted))
+		// The prompt was not sent again, the Run was polled.
+		Expect(restarted.started).To(BeEmpty())
+		Expect(restarted.finished).To(Equal([]string{"run_one"}))
+
+		close(runner.release)
+		queue.Wait()
+	})
+
+	It("should interrupt the running jobs when drained, and resume them", func() {
+		running, err := queue.Submit("actual", completions.PromptRequest{PromptRequest: api.PromptRequest{Assistant: "go_developer", Prompt: "one"}})
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(status(running.ID)).Should(Equal(completions.JobRunning))
+		Eventually(func() string {
+			j, _ := queue.Get(running.ID)
+			return j.RunId
+		}).ShouldNot(BeEmpty())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		Expect(queue.Drain(ctx)).To(MatchError(context.DeadlineExceeded))
+		Expect(status(running.ID)()).To(Equal(completions.JobRunning))
+		Expect(runner.cancelled).To(BeEmpty())
+		_, err = queue.Submit("actual", completions.PromptRequest{PromptRequest: api.PromptRequest{Assistant: "go_developer", Prompt: "two"}})
+		Expect(err).To(MatchError(completions.ErrQueueStopped))
+
+		restarted := &fakeRunner{release: make(chan struct{})}
+		close(restarted.release)
+		resumed, err := completions.NewJobQueue(restarted, location, 2)
+		Expect(err).NotTo(HaveOccurred())
+		resumed.Resume()
+		resumed.Wait()
+		job, _ := resumed.Get(running.ID)
+		Expect(job.Status).To(Equal(completions.JobCompleted))
+		Expect(restarted.finished).To(Equal([]string{"run_one"}))
+	})
+
+	It("should wait for the running jobs to complete, when drained", func() {
+		job, err := queue.Submit("actual", completions.PromptRequest{PromptRequest: api.PromptRequest{Assistant: "go_developer", Prompt: "one"}})
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(status(job.ID)).Should(Equal(completions.JobRunning))
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			close(runner.release)
+		}()
+		Expect(queue.Drain(context.Background())).To(Succeed())
+		Expect(status(job.ID)()).To(Equal(completions.JobCompleted))
+	})
+})
@@ -0,0 +1,63 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package completions
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+
+	"github.com/alertavert/gpt4-go/pkg/api"
+	"github.com/alertavert/gpt4-go/pkg/tracing"
+)
+
+// MaxThreadMessages is the most messages returned by ThreadMessages, which
+// is the page size of the OpenAI API.
+const MaxThreadMessages = 100
+
+// ThreadMessage is a message in a Thread, either a prompt (as it was sent,
+// with the code included) or the assistant's response.
+type ThreadMessage = api.ThreadMessage
+
+// ThreadMessages returns the most recent messages of the Thread (up to
+// `limit`, or MaxThreadMessages if not positive), oldest first.
+func (m *Majordomo) ThreadMessages(ctx context.Context, threadId string, limit int) (_ []ThreadMessage, err error) {
+	ctx, span := tracing.Start(ctx, "ThreadMessages", tracing.ThreadId.String(threadId))
+	defer tracing.End(span, &err)
+	if limit <= 0 || limit > MaxThreadMessages {
+		limit = MaxThreadMessages
+	}
+	order := "desc"
+	list, err := m.Client.ListMessage(ctx, threadId, &limit, &order, nil, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error listing messages: %w", err)
+	}
+	messages := make([]ThreadMessage, 0, len(list.Messages))
+	for _, message := range list.Messages {
+		messages = append(messages, ThreadMessage{
+			ID:        message.ID,
+			Role:      message.Role,
+			Text:      messageText(message),
+			CreatedAt: time.Unix(int64(message.CreatedAt), 0).UTC(),
+		})
+	}
+	slices.Reverse(messages)
+	return messages, nil
+}
+
+// messageText joins the text parts of the message, skipping the others (e.g., images).
+func messageText(message openai.Message) string {
+	var parts []string
+	for _, content := range message.Content {
+		if content.Text != nil {
+			parts = append(parts, content.Text.Value)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
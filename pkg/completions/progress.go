@@ -0,0 +1,44 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package completions
+
+import (
+	"context"
+
+	"github.com/alertavert/gpt4-go/pkg/api"
+)
+
+// ProgressStage is a step in running a prompt.
+type ProgressStage = api.ProgressStage
+
+const (
+	ProgressThread       = api.ProgressThread
+	ProgressRun          = api.ProgressRun
+	ProgressOutput       = api.ProgressOutput
+	ProgressSnippetSaved = api.ProgressSnippetSaved
+)
+
+// Progress is reported while running a prompt; only the fields relevant to
+// the Stage are set.
+type Progress = api.Progress
+
+// ProgressFunc receives the progress of the prompts run with its context; it
+// is called synchronously, and must not block.
+type ProgressFunc func(Progress)
+
+type progressKey struct{}
+
+// WithProgress returns a context which reports the progress of the prompts to `f`.
+func WithProgress(ctx context.Context, f ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, f)
+}
+
+// ReportProgress reports the progress to the ProgressFunc of the context, if
+// any; it is called by the PromptRunner implementations.
+func ReportProgress(ctx context.Context, progress Progress) {
+	if f, ok := ctx.Value(progressKey{}).(ProgressFunc); ok && f != nil {
+		f(progress)
+	}
+}
@@ -0,0 +1,280 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package completions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sashabaranov/go-openai"
+
+	"github.com/alertavert/gpt4-go/pkg/api"
+	"github.com/alertavert/gpt4-go/pkg/config"
+	"github.com/alertavert/gpt4-go/pkg/conversations"
+	"github.com/alertavert/gpt4-go/pkg/tracing"
+)
+
+var (
+	// ErrProjectExists is returned when renaming a project to the name of another one.
+	ErrProjectExists = errors.New("project already exists")
+	// ErrSharedSnippets is returned when purging the code snippets of a
+	// project, whose directory is also used by other projects (or is where
+	// the sources are).
+	ErrSharedSnippets = errors.New("code snippets directory shared with other projects")
+	// ErrInvalidDisposal is returned for an unknown ThreadsDisposal.
+	ErrInvalidDisposal = errors.New("invalid threads disposal")
+)
+
+// ThreadsDisposal is what happens to the threads of a deleted project.
+type ThreadsDisposal = api.ThreadsDisposal
+
+const (
+	// ArchiveThreads keeps the threads, under the conversations.ArchiveKey of
+	// the project, and on OpenAI.
+	ArchiveThreads = api.ArchiveThreads
+	// PurgeThreads deletes the threads, on OpenAI too.
+	PurgeThreads = api.PurgeThreads
+)
+
+// DeleteProjectOptions are the options of DeleteProject.
+type DeleteProjectOptions = api.DeleteProjectOptions
+
+// ProjectDeletion summarizes what DeleteProject did.
+type ProjectDeletion = api.ProjectDeletion
+
+// UpdateProject updates the name, location and description of the project
+// named `name`, with those which are not empty in `updates`.
+// The project keeps its ID, so that its threads and its code snippets
+// store follow it; if the code snippets directory moves with the project's
+// location, the snippets are moved too (unless shared with other projects).
+func (m *Majordomo) UpdateProject(name string, updates config.Project) (*config.Project, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	idx := m.projectIndex(name)
+	if idx == -1 {
+		return nil, fmt.Errorf("%w: %s", ErrProjectNotFound, name)
+	}
+	renamed := updates.Name != "" && updates.Name != name
+	if renamed && m.Config.GetProject(updates.Name) != nil {
+		return nil, fmt.Errorf("%w: %s", ErrProjectExists, updates.Name)
+	}
+
+	original := m.Config.Projects[idx]
+	updated := original
+	if updated.ID == "" {
+		updated.ID = m.Config.NewProjectID(name)
+	}
+	if renamed {
+		updated.Name = updates.Name
+	}
+	if updates.Location != "" {
+		updated.Location = updates.Location
+	}
+	if updates.Description != "" {
+		updated.Description = updates.Description
+	}
+	m.Config.ResolveProject(&updated)
+	if err := m.moveSnippets(idx, original.ResolvedCodeSnippetsDir, updated.ResolvedCodeSnippetsDir); err != nil {
+		return nil, err
+	}
+
+	m.Config.Projects[idx] = updated
+	if renamed && m.Config.ActiveProject == name {
+		m.Config.ActiveProject = updated.Name
+	}
+	// The cached store still reads from, and writes to, the previous location.
+	m.Stores.Forget(updated.ID)
+	if err := m.Config.Save(""); err != nil {
+		return nil, fmt.Errorf("error saving the configuration: %w", err)
+	}
+	log.Info().
+		Str("project", name).
+		Str("id", updated.ID).
+		Str("name", updated.Name).
+		Str("location", updated.Location).
+		Msg("Project updated")
+	return &updated, nil
+}
+
+// moveSnippets moves the code snippets of the project at `idx`, if their
+// directory changed, and the new one does not exist yet.
+func (m *Majordomo) moveSnippets(idx int, from, to string) error {
+	if from == to || m.snippetsShared(idx, from) {
+		return nil
+	}
+	if _, err := os.Stat(from); err != nil {
+		// Nothing saved yet.
+		return nil
+	}
+	if _, err := os.Stat(to); err == nil {
+		log.Warn().
+			Str("from", from).
+			Str("to", to).
+			Msg("Code snippets directory already exists, not moving the snippets")
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return fmt.Errorf("error moving the code snippets: %w", err)
+	}
+	if err := os.Rename(from, to); err != nil {
+		return fmt.Errorf("error moving the code snippets: %w", err)
+	}
+	log.Info().
+		Str("from", from).
+		Str("to", to).
+		Msg("Code snippets moved")
+	return nil
+}
+
+// snippetsShared is true if any project, other than the one at `idx`,
+// saves its code snippets in `dir` (e.g., the global code snippets directory,
+// if absolute), or if `dir` is the location of any project.
+func (m *Majordomo) snippetsShared(idx int, dir string) bool {
+	dir = filepath.Clean(dir)
+	for i, p := range m.Config.Projects {
+		if (i != idx && filepath.Clean(p.ResolvedCodeSnippetsDir) == dir) ||
+			filepath.Clean(p.Location) == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// projectIndex returns the index of the project in the configuration, or -1.
+func (m *Majordomo) projectIndex(name string) int {
+	for i, p := range m.Config.Projects {
+		if p.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// DeleteProject removes the project from the configuration, and archives or
+// purges its threads; the code snippets are kept, unless
+// `opts.PurgeSnippets` is set.
+// If the project was the active one, the first remaining project becomes active.
+func (m *Majordomo) DeleteProject(ctx context.Context, name string, opts DeleteProjectOptions) (_ *ProjectDeletion, err error) {
+	ctx, span := tracing.Start(ctx, "DeleteProject", tracing.Project.String(name))
+	defer tracing.End(span, &err)
+	if opts.Threads == "" {
+		opts.Threads = ArchiveThreads
+	}
+	if opts.Threads != ArchiveThreads && opts.Threads != PurgeThreads {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidDisposal, opts.Threads)
+	}
+	deletion, purged, err := m.removeProject(name, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Deleting the threads on OpenAI can take a while, and must not block
+	// the requests using the other projects: the project is already gone, so
+	// it cannot be renamed, or deleted, in the meantime.
+	if err = m.purgeThreads(ctx, deletion.ID, purged, deletion); err != nil {
+		return nil, fmt.Errorf("error disposing of the threads: %w", err)
+	}
+	// The prompts sent before the project was removed may have added threads
+	// since; they are archived, so that a new project with the same name (and
+	// thus ID) does not inherit them.
+	if m.Threads != nil {
+		added, err := m.Threads.ArchiveProject(deletion.ID)
+		if err != nil {
+			return nil, fmt.Errorf("error disposing of the threads: %w", err)
+		}
+		deletion.ThreadsArchived += added
+	}
+	log.Info().
+		Str("project", name).
+		Str("id", deletion.ID).
+		Int("threads_archived", deletion.ThreadsArchived).
+		Int("threads_purged", deletion.ThreadsPurged).
+		Bool("snippets_purged", deletion.SnippetsPurged).
+		Msg("Project deleted")
+	return deletion, nil
+}
+
+// removeProject removes the project from the configuration, with its code
+// snippets if `opts.PurgeSnippets`, and archives its threads (or removes them,
+// and returns them to be deleted on OpenAI, if purging).
+func (m *Majordomo) removeProject(name string, opts DeleteProjectOptions) (*ProjectDeletion, []conversations.Thread, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	idx := m.projectIndex(name)
+	if idx == -1 {
+		return nil, nil, fmt.Errorf("%w: %s", ErrProjectNotFound, name)
+	}
+	project := m.Config.Projects[idx]
+	if project.ID == "" {
+		project.ID = name
+	}
+	if opts.PurgeSnippets && m.snippetsShared(idx, project.ResolvedCodeSnippetsDir) {
+		return nil, nil, fmt.Errorf("%w: %s", ErrSharedSnippets, project.ResolvedCodeSnippetsDir)
+	}
+	deletion := &ProjectDeletion{Project: name, ID: project.ID}
+	var purged []conversations.Thread
+	if m.Threads != nil {
+		var err error
+		if opts.Threads == PurgeThreads {
+			purged, err = m.Threads.RemoveProject(project.ID)
+		} else {
+			deletion.ThreadsArchived, err = m.Threads.ArchiveProject(project.ID)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("error disposing of the threads: %w", err)
+		}
+	}
+	if opts.PurgeSnippets {
+		if err := os.RemoveAll(project.ResolvedCodeSnippetsDir); err != nil {
+			return nil, nil, fmt.Errorf("error purging the code snippets: %w", err)
+		}
+		deletion.SnippetsPurged = true
+	}
+
+	// The readers may still hold the previous slice.
+	projects := make([]config.Project, 0, len(m.Config.Projects)-1)
+	projects = append(projects, m.Config.Projects[:idx]...)
+	m.Config.Projects = append(projects, m.Config.Projects[idx+1:]...)
+	if m.Config.ActiveProject == name {
+		m.Config.ActiveProject = ""
+		if len(m.Config.Projects) > 0 {
+			m.Config.ActiveProject = m.Config.Projects[0].Name
+		}
+	}
+	deletion.ActiveProject = m.Config.ActiveProject
+	m.Stores.Forget(project.ID)
+	if err := m.Config.Save(""); err != nil {
+		return nil, nil, fmt.Errorf("error saving the configuration: %w", err)
+	}
+	return deletion, purged, nil
+}
+
+// purgeThreads deletes the threads, removed from the project, on OpenAI; those
+// which cannot be deleted remotely are archived, so that they can be purged later.
+func (m *Majordomo) purgeThreads(ctx context.Context, projectId string, threads []conversations.Thread, deletion *ProjectDeletion) error {
+	if len(threads) == 0 {
+		return nil
+	}
+	var kept []conversations.Thread
+	for _, thread := range threads {
+		_, err := m.Client.DeleteThread(ctx, thread.ID)
+		var apiErr *openai.APIError
+		if err != nil && !(errors.As(err, &apiErr) && apiErr.HTTPStatusCode == http.StatusNotFound) {
+			log.Warn().Err(err).
+				Str("thread_id", thread.ID).
+				Msg("Could not delete the thread on OpenAI, archiving it")
+			kept = append(kept, thread)
+			continue
+		}
+		deletion.ThreadsPurged++
+	}
+	deletion.ThreadsArchived = len(kept)
+	return m.Threads.ArchiveThreads(projectId, kept)
+}
@@ -0,0 +1,211 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package completions_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sashabaranov/go-openai"
+
+	"github.com/alertavert/gpt4-go/pkg/completions"
+	"github.com/alertavert/gpt4-go/pkg/config"
+	"github.com/alertavert/gpt4-go/pkg/conversations"
+)
+
+var _ = Describe("Managing projects", func() {
+	var (
+		majordomo *completions.Majordomo
+		cfg       *config.Config
+		tmpDir    string
+		project   *config.Project
+	)
+
+	BeforeEach(func() {
+		var err error
+		cfg, err = config.LoadConfig(TestConfigLocation)
+		Expect(err).NotTo(HaveOccurred())
+		tmpDir, err = os.MkdirTemp("", "projects-test-")
+		Expect(err).NotTo(HaveOccurred())
+		cfg.ThreadsLocation = filepath.Join(tmpDir, "threads.json")
+		// Never overwrite the test configuration.
+		cfg.LoadedFrom = filepath.Join(tmpDir, "config.yaml")
+		project = &cfg.Projects[2]
+		project.Location = filepath.Join(tmpDir, "src")
+		cfg.ResolveProject(project)
+		majordomo, err = completions.NewMajordomo(cfg)
+		Expect(err).NotTo(HaveOccurred())
+	})
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	Describe("AddProject", func() {
+		It("adds and saves the project, unless the name is taken", func() {
+			added, err := majordomo.AddProject(config.Project{Name: "added", Location: filepath.Join(tmpDir, "added")})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(added.ID).To(Equal("added"))
+			Expect(majordomo.GetProject("added")).To(Equal(added))
+
+			saved, err := config.LoadConfig(cfg.LoadedFrom)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(saved.GetProject("added")).NotTo(BeNil())
+
+			_, err = majordomo.AddProject(config.Project{Name: "added"})
+			Expect(err).To(MatchError(completions.ErrProjectExists))
+			projects, _ := majordomo.Projects()
+			Expect(projects).To(HaveLen(len(cfg.Projects)))
+		})
+	})
+
+	Describe("UpdateProject", func() {
+		It("keeps the ID, and moves the code snippets with the project", func() {
+			snippet := filepath.Join(project.ResolvedCodeSnippetsDir, "main.go")
+			Expect(os.MkdirAll(filepath.Dir(snippet), 0755)).To(Succeed())
+			Expect(os.WriteFile(snippet, []byte("package main\n"), 0644)).To(Succeed())
+			thread := conversations.Thread{ID: "thread_M0v3d", Name: "Moved", Assistant: "go_developer"}
+			Expect(majordomo.Threads.AddThread(project.ID, thread)).To(Succeed())
+
+			updated, err := majordomo.UpdateProject("actual", config.Project{
+				Name:     "moved",
+				Location: filepath.Join(tmpDir, "dst"),
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated.ID).To(Equal("actual"))
+			Expect(updated.ResolvedCodeSnippetsDir).To(Equal(filepath.Join(tmpDir, "dst", ".majordomo")))
+			Expect(filepath.Join(updated.ResolvedCodeSnippetsDir, "main.go")).To(BeARegularFile())
+			Expect(snippet).NotTo(BeAnExistingFile())
+
+			thread, found := majordomo.Threads.GetThread(majordomo.Config.ProjectID("moved"), "thread_M0v3d")
+			Expect(found).To(BeTrue())
+			Expect(thread.Name).To(Equal("Moved"))
+
+			saved, err := config.LoadConfig(cfg.LoadedFrom)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(saved.GetProject("moved").ID).To(Equal("actual"))
+			Expect(saved.GetProject("actual")).To(BeNil())
+		})
+		It("keeps the active project, when renamed", func() {
+			Expect(majordomo.SetActiveProject("actual")).To(Succeed())
+			_, err := majordomo.UpdateProject("actual", config.Project{Name: "renamed"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(majordomo.ActiveProject()).To(Equal("renamed"))
+		})
+		It("fails if the name is taken", func() {
+			_, err := majordomo.UpdateProject("actual", config.Project{Name: "test-project"})
+			Expect(err).To(MatchError(completions.ErrProjectExists))
+		})
+		It("fails if the project does not exist", func() {
+			_, err := majordomo.UpdateProject("missing", config.Project{Name: "renamed"})
+			Expect(err).To(MatchError(completions.ErrProjectNotFound))
+		})
+	})
+
+	Describe("DeleteProject", func() {
+		It("archives the threads, by default", func() {
+			thread := conversations.Thread{ID: "thread_K3pt", Name: "Kept", Assistant: "go_developer"}
+			Expect(majordomo.Threads.AddThread(project.ID, thread)).To(Succeed())
+
+			deletion, err := majordomo.DeleteProject(context.Background(), "actual", completions.DeleteProjectOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(deletion.ThreadsArchived).To(Equal(1))
+			Expect(majordomo.Threads.ArchivedThreads("actual")).To(Equal([]conversations.Thread{thread}))
+			Expect(majordomo.Config.GetProject("actual")).To(BeNil())
+			// A new project with the same name does not inherit the threads.
+			Expect(cfg.NewProjectID("actual")).To(Equal("actual"))
+			Expect(majordomo.Threads.GetAllThreads("actual")).To(BeEmpty())
+		})
+		It("purges the code snippets, unless shared", func() {
+			Expect(os.MkdirAll(project.ResolvedCodeSnippetsDir, 0755)).To(Succeed())
+			cfg.Projects[0].ResolvedCodeSnippetsDir = project.ResolvedCodeSnippetsDir
+			_, err := majordomo.DeleteProject(context.Background(), "actual",
+				completions.DeleteProjectOptions{PurgeSnippets: true})
+			Expect(err).To(MatchError(completions.ErrSharedSnippets))
+			Expect(project.ResolvedCodeSnippetsDir).To(BeADirectory())
+
+			cfg.Projects[0].ResolvedCodeSnippetsDir = ""
+			deletion, err := majordomo.DeleteProject(context.Background(), "actual",
+				completions.DeleteProjectOptions{PurgeSnippets: true})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(deletion.SnippetsPurged).To(BeTrue())
+			Expect(filepath.Join(tmpDir, "src", ".majordomo")).NotTo(BeADirectory())
+		})
+		It("makes the first remaining project active", func() {
+			Expect(majordomo.ActiveProject()).To(Equal("test-project"))
+			before := cfg.Projects
+			deletion, err := majordomo.DeleteProject(context.Background(), "test-project", completions.DeleteProjectOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(deletion.ActiveProject).To(Equal("test-project-2"))
+			Expect(majordomo.ActiveProject()).To(Equal("test-project-2"))
+			// The projects read before the deletion are unchanged.
+			Expect(before[0].Name).To(Equal("test-project"))
+		})
+		It("does not block the other requests, while purging the threads on OpenAI", func() {
+			// OpenAI stalls, until released.
+			release := make(chan struct{})
+			received := make(chan struct{}, 1)
+			stalled := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case received <- struct{}{}:
+				default:
+				}
+				<-release
+				http.Error(w, `{"error":{"message":"bad request"}}`, http.StatusBadRequest)
+			}))
+			defer stalled.Close()
+			var releaseOnce sync.Once
+			// Deferred after Close, so that it runs before, even if the test fails.
+			defer releaseOnce.Do(func() { close(release) })
+			clientConfig := openai.DefaultConfig("test-key")
+			clientConfig.BaseURL = stalled.URL + "/v1"
+			majordomo.Client = openai.NewClientWithConfig(clientConfig)
+			thread := conversations.Thread{ID: "thread_St4ll3d", Name: "Stalled", Assistant: "go_developer"}
+			Expect(majordomo.Threads.AddThread(project.ID, thread)).To(Succeed())
+
+			deleted := make(chan *completions.ProjectDeletion, 1)
+			go func() {
+				defer GinkgoRecover()
+				deletion, err := majordomo.DeleteProject(context.Background(), "actual",
+					completions.DeleteProjectOptions{Threads: completions.PurgeThreads})
+				Expect(err).NotTo(HaveOccurred())
+				deleted <- deletion
+			}()
+			Eventually(received).Should(Receive())
+			// The project is removed before purging the threads on OpenAI.
+			Expect(majordomo.GetProject("actual")).To(BeNil())
+			// A prompt sent before the deletion adds its thread.
+			added := conversations.Thread{ID: "thread_L4t3", Name: "Late", Assistant: "go_developer"}
+			Expect(majordomo.Threads.AddThread(project.ID, added)).To(Succeed())
+
+			activated := make(chan error, 1)
+			go func() {
+				defer GinkgoRecover()
+				activated <- majordomo.SetActiveProject("test-project-2")
+			}()
+			Eventually(activated).Should(Receive(BeNil()))
+
+			releaseOnce.Do(func() { close(release) })
+			var deletion *completions.ProjectDeletion
+			Eventually(deleted).Should(Receive(&deletion))
+			// Both the thread which could not be deleted, and the one added since.
+			Expect(deletion.ThreadsArchived).To(Equal(2))
+			Expect(majordomo.Threads.ArchivedThreads("actual")).To(ConsistOf(thread, added))
+			Expect(majordomo.Threads.GetAllThreads("actual")).To(BeEmpty())
+			Expect(majordomo.ActiveProject()).To(Equal("test-project-2"))
+		})
+		It("fails for an unknown disposal of the threads", func() {
+			_, err := majordomo.DeleteProject(context.Background(), "actual",
+				completions.DeleteProjectOptions{Threads: "forget"})
+			Expect(err).To(MatchError(completions.ErrInvalidDisposal))
+			Expect(majordomo.Config.GetProject("actual")).NotTo(BeNil())
+		})
+	})
+})
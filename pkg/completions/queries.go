@@ -8,37 +8,43 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
+	"slices"
 	"github.com/alertavert/gpt4-go/pkg/conversations"
 	"github.com/go-playground/validator/v10"
-	"mime/multipart"
+	"sync"
 	"time"
 
 	"github.com/emirpasic/gods/sets/hashset"
 	"github.com/rs/zerolog/log"
 	"github.com/sashabaranov/go-openai"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 
+	"github.com/alertavert/gpt4-go/pkg/api"
+	"github.com/alertavert/gpt4-go/pkg/audit"
+	"github.com/alertavert/gpt4-go/pkg/cassette"
 	"github.com/alertavert/gpt4-go/pkg/config"
+	"github.com/alertavert/gpt4-go/pkg/metrics"
 	"github.com/alertavert/gpt4-go/pkg/preprocessors"
+	"github.com/alertavert/gpt4-go/pkg/retry"
+	"github.com/alertavert/gpt4-go/pkg/tracing"
 )
 
 const (
 	DefaultModel = openai.GPT4Turbo
 )
 
+// PromptRequest is the prompt, as received via the API, and what is found
+// while preparing it.
 type PromptRequest struct {
-	// The assistant to use (selected by the user); always required.
-	Assistant string `json:"assistant" validate:"required"`
+	api.PromptRequest
 
-	// The Thread ID (if any) to keep track of past prompts/responses in the conversation.
-	// If empty, a new conversation is started.
-	ThreadId string `json:"thread_id,omitempty"`
+	// The secrets redacted from the prompt, before it is sent to the LLM.
+	Redactions *preprocessors.Redactions `json:"-"`
 
-	// The name of the thread, used to identify the conversation.
-	// If both ThreadId and ThreadName are empty, a name will be suggested by the LLM.
-	ThreadName string `json:"thread_name,omitempty"`
-
-	// The user prompt.
-	Prompt string `json:"prompt" validate:"required"`
+	// The files included in the prompt.
+	Files []audit.File `json:"-"`
 }
 
 // Validate checks if the PromptRequest has all required fields, using the validator package.
@@ -61,8 +67,8 @@ type Majordomo struct {
 	// The OpenAI Client
 	Client *openai.Client
 
-	// The Code Snippets CodeStore
-	CodeStore preprocessors.CodeStoreHandler
+	// Stores are the Code Snippets stores of the projects.
+	Stores *preprocessors.StoreRegistry
 
 	// Threads of conversation with the LLM model.
 	Threads *conversations.ThreadStore
@@ -72,16 +78,67 @@ type Majordomo struct {
 
 	// The configuration object to manage the Projects in the server handlers
 	Config *config.Config
+
+	// Secrets redacts secrets from the prompts; nil if disabled.
+	Secrets *preprocessors.SecretScanner
+
+	// Commands classifies the spoken commands.
+	Commands *CommandGrammar
+
+	// Session is the conversation carried out via the spoken commands.
+	Session *Session
+
+	// Assistants caches the OpenAI Assistants.
+	Assistants *AssistantRegistry
+
+	// Jobs are the prompts run in the background.
+	Jobs *JobQueue
+
+	// Audit records the files sent to, and saved from, the LLM; nil if disabled.
+	Audit *audit.Logger
+
+	// mu protects the projects and the active one, which can be changed while
+	// serving requests: they must only be accessed via the Majordomo methods.
+	mu sync.RWMutex
 }
 
+// Shutdown waits for the running prompt jobs to finish (those which do not,
+// before `ctx` is done, are resumed after a restart), then persists the
+// conversations and closes the audit log.
+func (m *Majordomo) Shutdown(ctx context.Context) error {
+	var errs []error
+	if m.Jobs != nil {
+		if err := m.Jobs.Drain(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("error draining the prompt jobs: %w", err))
+		}
+	}
+	if m.Threads != nil {
+		if err := m.Threads.Flush(); err != nil {
+			errs = append(errs, fmt.Errorf("error saving the conversations: %w", err))
+		}
+	}
+	if err := m.Audit.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("error closing the audit log: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// ErrProjectNotFound is returned when the project in the request is not configured.
+var ErrProjectNotFound = errors.New("project not found")
+
 // SuggestThreadName suggests a title for a thread based on the prompt text.
 // It uses the OpenAI API to generate a title no longer than 5 words.
 func (m *Majordomo) SuggestThreadName(prompt string) (string, error) {
+	return m.suggestThreadName(context.Background(), prompt)
+}
+
+func (m *Majordomo) suggestThreadName(ctx context.Context, prompt string) (_ string, err error) {
 	if m.Client == nil {
 		return "", fmt.Errorf("OpenAI client not initialized")
 	}
+	ctx, span := tracing.Start(ctx, "SuggestThreadName")
+	defer tracing.End(span, &err)
 
-	ctx := context.Background()
 	resp, err := m.Client.CreateChatCompletion(
 		ctx,
 		openai.ChatCompletionRequest{
@@ -117,8 +174,16 @@ func (m *Majordomo) SuggestThreadName(prompt string) (string, error) {
 }
 
 func NewMajordomo(cfg *config.Config) (*Majordomo, error) {
+	var err error
 	var assistant = new(Majordomo)
-	assistant.Client = openai.NewClient(cfg.OpenAIApiKey)
+	clientConfig := openai.DefaultConfig(cfg.OpenAIApiKey)
+	transport, err := cassette.NewTransport(cfg.Cassette, http.DefaultTransport, cfg.OpenAIApiKey, cfg.ProjectId)
+	if err != nil {
+		return nil, err
+	}
+	// Every attempt is traced, the retries included.
+	clientConfig.HTTPClient = metrics.InstrumentDoer(retry.NewClient(tracing.HTTPClient(transport), cfg.OpenAI))
+	assistant.Client = openai.NewClientWithConfig(clientConfig)
 	if assistant.Client == nil {
 		return nil, fmt.Errorf("error initializing OpenAI client")
 	}
@@ -135,12 +200,33 @@ func NewMajordomo(cfg *config.Config) (*Majordomo, error) {
 	if p == nil {
 		return nil, fmt.Errorf("no project found for %s", cfg.ActiveProject)
 	}
-	assistant.CodeStore = *preprocessors.GetCodeStoreHandler(p)
+	assistant.Stores = preprocessors.NewStoreRegistry()
 	assistant.Config = cfg
+	assistant.Secrets, err = preprocessors.NewSecretScanner(cfg.Secrets)
+	if err != nil {
+		return nil, err
+	}
+	assistant.Commands, err = NewCommandGrammar(cfg.Voice.Commands)
+	if err != nil {
+		return nil, err
+	}
+	assistant.Session = new(Session)
+	assistant.Assistants = NewAssistantRegistry(assistant.Client, cfg.AssistantsTTL)
 	assistant.Threads = conversations.NewThreadStore(cfg)
 	if assistant.Threads == nil {
 		return nil, fmt.Errorf("error initializing thread store")
 	}
+	assistant.Audit, err = audit.NewLogger(cfg.Audit)
+	if err != nil {
+		return nil, err
+	}
+	assistant.Jobs, err = NewJobQueue(assistant, cfg.JobsLocation, cfg.JobWorkers)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.JobRetention > 0 {
+		assistant.Jobs.Retention = cfg.JobRetention
+	}
 
 	log.Debug().
 		Str("model", assistant.Model).
@@ -155,13 +241,17 @@ func NewMajordomo(cfg *config.Config) (*Majordomo, error) {
 	return assistant, nil
 }
 
+// SetActiveProject changes the project used by the requests which do not
+// specify one.
 func (m *Majordomo) SetActiveProject(projectName string) error {
+	m.mu.Lock()
 	p := m.Config.GetProject(projectName)
 	if p == nil {
+		m.mu.Unlock()
 		return fmt.Errorf("project %s not found", projectName)
 	}
 	m.Config.ActiveProject = projectName
-	m.CodeStore = *preprocessors.GetCodeStoreHandler(p)
+	m.mu.Unlock()
 	log.Debug().
 		Str("active_project", p.Name).
 		Str("source_dir", p.Location).
@@ -170,20 +260,118 @@ func (m *Majordomo) SetActiveProject(projectName string) error {
 	return nil
 }
 
-// PreparePrompt fills the prompt with the code snippets.
+// ActiveProject returns the name of the project used by the requests which
+// do not specify one.
+func (m *Majordomo) ActiveProject() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.Config.ActiveProject
+}
+
+// Projects returns a copy of the projects, and the name of the active one.
+func (m *Majordomo) Projects() ([]config.Project, string) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return slices.Clone(m.Config.Projects), m.Config.ActiveProject
+}
+
+// GetProject returns a copy of the project named `name`, or nil if there is none.
+func (m *Majordomo) GetProject(name string) *config.Project {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.Config.GetProject(name)
+}
+
+// GetProjectByID returns a copy of the project with the given ID, or nil if
+// there is none.
+func (m *Majordomo) GetProjectByID(id string) *config.Project {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.Config.GetProjectByID(id)
+}
+
+// ProjectID returns the ID of the project named `name`, which keys its threads
+// and its code snippets store.
+func (m *Majordomo) ProjectID(name string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.Config.ProjectID(name)
+}
+
+// AddProject adds the project to the configuration, and saves it; it returns
+// ErrProjectExists if another project has the same name.
+func (m *Majordomo) AddProject(project config.Project) (*config.Project, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Config.GetProject(project.Name) != nil {
+		return nil, fmt.Errorf("%w: %s", ErrProjectExists, project.Name)
+	}
+	// A renamed project may still use the name as its ID.
+	project.ID = m.Config.NewProjectID(project.Name)
+	m.Config.ResolveProject(&project)
+	m.Config.Projects = append(slices.Clone(m.Config.Projects), project)
+	if err := m.Config.Save(""); err != nil {
+		return nil, fmt.Errorf("error saving the configuration: %w", err)
+	}
+	return &project, nil
+}
+
+// CodeStore returns the Code Snippets store of the active project.
+func (m *Majordomo) CodeStore() preprocessors.CodeStoreHandler {
+	store, _, err := m.ProjectStore("")
+	if err != nil {
+		log.Err(err).Msg("active project not found")
+		return nil
+	}
+	return store
+}
+
+// ProjectStore returns the Code Snippets store for `project`, or for the
+// active one if empty, and the name of the project.
+func (m *Majordomo) ProjectStore(project string) (preprocessors.CodeStoreHandler, string, error) {
+	if project == "" {
+		project = m.ActiveProject()
+	}
+	p := m.GetProject(project)
+	if p == nil {
+		return nil, "", fmt.Errorf("%w: %s", ErrProjectNotFound, project)
+	}
+	return m.Stores.Get(p), p.Name, nil
+}
+
+// PreparePrompt fills the prompt with the code snippets of the request's project.
 func (m *Majordomo) PreparePrompt(prompt *PromptRequest) error {
+	store, _, err := m.ProjectStore(prompt.Project)
+	if err != nil {
+		return err
+	}
+	return m.preparePrompt(context.Background(), prompt, store)
+}
+
+// preparePrompt fills the prompt with the code snippets read from `store`.
+func (m *Majordomo) preparePrompt(ctx context.Context, prompt *PromptRequest,
+	store preprocessors.CodeStoreHandler) (err error) {
+	_, span := tracing.Start(ctx, "PreparePrompt")
+	defer tracing.End(span, &err)
 	p := prompt.Prompt
 	oldLen := len(p)
 	var parser = preprocessors.Parser{
 		CodeMap: make(preprocessors.SourceCodeMap),
 	}
 	parser.ParsePrompt(p)
-	err := m.CodeStore.GetSourceCode(&parser.CodeMap)
+	err = store.GetSourceCode(&parser.CodeMap)
 	if err != nil {
 		log.Err(err).Msg("error retrieving source code")
 		return err
 	}
-	prompt.Prompt, err = parser.FillPrompt(p)
+	prompt.Files = audit.Files(parser.CodeMap)
+	// Secrets are redacted before the files' contents are added to the prompt,
+	// so that we can report which file they were found in.
+	prompt.Redactions = preprocessors.NewRedactions()
+	for path, content := range parser.CodeMap {
+		parser.CodeMap[path] = m.Secrets.Redact(content, path, prompt.Redactions)
+	}
+	prompt.Prompt, err = parser.FillPrompt(m.Secrets.Redact(p, "prompt", prompt.Redactions))
 	if err != nil {
 		log.Err(err).Msg("error filling prompt")
 		return err
@@ -192,19 +380,34 @@ func (m *Majordomo) PreparePrompt(prompt *PromptRequest) error {
 		Int("prompt_len", len(prompt.Prompt)).
 		Int("old_len", oldLen).
 		Int("code_snippets", len(parser.CodeMap)).
+		Int("redacted", prompt.Redactions.Len()).
 		Msg("filled prompt")
+	span.SetAttributes(
+		attribute.Int("majordomo.code_snippets", len(parser.CodeMap)),
+		attribute.Int("majordomo.redactions", prompt.Redactions.Len()),
+	)
 	return nil
 }
 
 // CreateNewThread creates a new thread for the given project and returns the thread ID.
 func (m *Majordomo) CreateNewThread(project, assistant, threadName string) string {
-	t, err := m.Client.CreateThread(context.Background(), openai.ThreadRequest{
+	return m.createNewThread(context.Background(), project, assistant, threadName)
+}
+
+func (m *Majordomo) createNewThread(ctx context.Context, project, assistant, threadName string) string {
+	ctx, span := tracing.Start(ctx, "CreateThread",
+		tracing.Project.String(project), tracing.Assistant.String(assistant))
+	defer span.End()
+	t, err := m.Client.CreateThread(ctx, openai.ThreadRequest{
 		Metadata: map[string]any{"project": project, "assistant": assistant, "thread_name": threadName},
 	})
 	if err != nil {
 		log.Err(err).Msg("error creating thread")
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 		return ""
 	}
+	span.SetAttributes(tracing.ThreadId.String(t.ID))
 	// TODO: we need to ask the LLM for a name and description.
 	var newThread = conversations.Thread{
 		ID:          t.ID,
@@ -212,30 +415,134 @@ func (m *Majordomo) CreateNewThread(project, assistant, threadName string) strin
 		Assistant:   assistant,
 		Description: "Some brief description for this thread",
 	}
-	err = m.Threads.AddThread(project, newThread)
+	err = m.Threads.AddThread(m.ProjectID(project), newThread)
 	return t.ID
 }
 
-// QueryBot queries the LLM with the given prompt.
-func (m *Majordomo) QueryBot(prompt *PromptRequest) (string, error) {
+// botReply is the outcome of a single Run on a Thread.
+type botReply struct {
+	// Text is the full response from the LLM.
+	Text string
+	// Snippets are the code snippets extracted from the response, and saved
+	// to the CodeStoreHandler.
+	Snippets preprocessors.SourceCodeMap
+	// RunId is the ID of the OpenAI Run which generated the response.
+	RunId string
+	// Response is the structured response, see PromptResponse.
+	Response *PromptResponse
+}
+
+// QueryBot queries the LLM with the given prompt, on the request's project.
+func (m *Majordomo) QueryBot(ctx context.Context, prompt *PromptRequest) (*PromptResponse, error) {
+	store, project, err := m.ProjectStore(prompt.Project)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := m.queryBot(ctx, prompt, project, store)
+	if err != nil {
+		return nil, err
+	}
+	return reply.Response, nil
+}
+
+// StartPrompt sends the prompt to the LLM on a Thread of `project` (the
+// active one, if empty), without waiting for the response, and returns the
+// ID of the Run; the response is retrieved by FinishPrompt.
+func (m *Majordomo) StartPrompt(ctx context.Context, prompt *PromptRequest, project string) (string, error) {
+	store, project, err := m.ProjectStore(project)
+	if err != nil {
+		return "", err
+	}
+	return m.startRun(ctx, prompt, project, store)
+}
+
+// FinishPrompt waits for the Run started by StartPrompt to complete, and
+// processes the response.
+// If the prompt was not started by this process (e.g., before a restart), it
+// is prepared again, to restore any secrets redacted from it.
+func (m *Majordomo) FinishPrompt(ctx context.Context, prompt *PromptRequest, project,
+	runId string) (*PromptResponse, error) {
+	store, project, err := m.ProjectStore(project)
+	if err != nil {
+		return nil, err
+	}
+	if prompt.Redactions == nil {
+		prepared := *prompt
+		if err = m.preparePrompt(ctx, &prepared, store); err != nil {
+			return nil, err
+		}
+		prompt.Redactions = prepared.Redactions
+	}
+	reply, err := m.finishRun(ctx, prompt, project, runId, store)
+	if err != nil {
+		return nil, err
+	}
+	return reply.Response, nil
+}
+
+// CancelRun cancels the Run, if it is still in progress.
+func (m *Majordomo) CancelRun(threadId, runId string) error {
+	_, err := m.Client.CancelRun(context.Background(), threadId, runId)
+	if err != nil {
+		return fmt.Errorf("error cancelling run: %w", err)
+	}
+	return nil
+}
+
+// queryBot runs the prompt on a Thread (creating a new one for `project` if
+// necessary), using `store` to fill in the prompt and to save the code
+// snippets returned by the LLM.
+func (m *Majordomo) queryBot(ctx context.Context, prompt *PromptRequest, project string,
+	store preprocessors.CodeStoreHandler) (_ *botReply, err error) {
+	ctx, span := tracing.Start(ctx, "QueryBot",
+		tracing.Project.String(project), tracing.Assistant.String(prompt.Assistant))
+	defer tracing.End(span, &err)
+	runId, err := m.startRun(ctx, prompt, project, store)
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(tracing.ThreadId.String(prompt.ThreadId), tracing.RunId.String(runId))
+	return m.finishRun(ctx, prompt, project, runId, store)
+}
+
+// startRun adds the prompt to its Thread (creating it, if necessary) and
+// creates the Run which will generate the response; it returns the Run's ID.
+func (m *Majordomo) startRun(ctx context.Context, prompt *PromptRequest, project string,
+	store preprocessors.CodeStoreHandler) (_ string, err error) {
 	if m.Client == nil {
 		return "", fmt.Errorf("OpenAI client not initialized")
 	}
-	if m.CodeStore == nil {
+	if store == nil {
 		return "", fmt.Errorf("code snippets store not initialized")
 	}
+	ctx, span := tracing.Start(ctx, "StartRun",
+		tracing.Project.String(project), tracing.Assistant.String(prompt.Assistant))
+	defer tracing.End(span, &err)
 
-	err := m.PreparePrompt(prompt)
+	err = m.preparePrompt(ctx, prompt, store)
 	if err != nil {
 		return "", err
 	}
+	// Recorded before anything is sent, so that no prompt goes unaudited.
+	err = m.Audit.Record(audit.Entry{
+		Event:      audit.EventPrompt,
+		User:       prompt.User,
+		Project:    project,
+		Assistant:  prompt.Assistant,
+		ThreadId:   prompt.ThreadId,
+		ThreadName: prompt.ThreadName,
+		Files:      prompt.Files,
+		Redactions: prompt.Redactions.Items,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error auditing the prompt: %w", err)
+	}
 
-	// TODO: create an appropriate context for the query.
 	// Create a new conversation if the thread ID is empty.
 	if prompt.ThreadId == "" {
 		// If thread name is also empty, suggest a name based on the prompt
 		if prompt.ThreadName == "" {
-			suggestedName, err := m.SuggestThreadName(prompt.Prompt)
+			suggestedName, err := m.suggestThreadName(ctx, prompt.Prompt)
 			if err != nil {
 				log.Warn().
 					Err(err).
@@ -253,20 +560,22 @@ func (m *Majordomo) QueryBot(prompt *PromptRequest) (string, error) {
 			Str("assistant", prompt.Assistant).
 			Str("thread_name", prompt.ThreadName).
 			Msg("creating new thread")
-		prompt.ThreadId = m.CreateNewThread(m.Config.ActiveProject, prompt.Assistant, prompt.ThreadName)
+		prompt.ThreadId = m.createNewThread(ctx, project, prompt.Assistant, prompt.ThreadName)
 	}
+	span.SetAttributes(tracing.ThreadId.String(prompt.ThreadId))
+	ReportProgress(ctx, Progress{Stage: ProgressThread, ThreadId: prompt.ThreadId, ThreadName: prompt.ThreadName})
 	log.Debug().
 		Str("thread_id", prompt.ThreadId).
 		Str("assistant", prompt.Assistant).
 		Msg("thread ID set")
 	// Creates a new conversation in the thread.
-	msg, err := m.Client.CreateMessage(context.Background(), prompt.ThreadId,
+	msg, err := m.Client.CreateMessage(ctx, prompt.ThreadId,
 		openai.MessageRequest{
 			Role:    "user",
 			Content: prompt.Prompt,
 		})
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("error creating message: %w", err)
 	}
 	log.Debug().
 		// TODO: we should compute the number of tokens in debug mode only.
@@ -282,62 +591,52 @@ func (m *Majordomo) QueryBot(prompt *PromptRequest) (string, error) {
 	}
 	assistantId, err := m.GetAssistantId(prompt.Assistant)
 	if err != nil {
-		return "", fmt.Errorf("error getting assistant ID for '%s': %v", prompt.Assistant, err)
+		return "", fmt.Errorf("error getting assistant ID for '%s': %w", prompt.Assistant, err)
 	}
 	log.Debug().
 		Str("assistant_id", assistantId).
 		Str("assistant", prompt.Assistant).
 		Msg("assistant found")
 	// Create a Run - the model, and other parameters are set already in the Thread.
-	run, err := m.Client.CreateRun(context.Background(), prompt.ThreadId, openai.RunRequest{
+	run, err := m.Client.CreateRun(ctx, prompt.ThreadId, openai.RunRequest{
 		// Model:       m.Model,
 		AssistantID: assistantId,
 	})
 	if err != nil {
-		return "", fmt.Errorf("error creating run: %v", err)
+		return "", fmt.Errorf("error creating run: %w", err)
 	}
 	log.Debug().
 		Str("run_id", run.ID).
 		Str("thread_id", run.ThreadID).
 		Str("assistant_id", run.AssistantID).
 		Msg("created run")
+	span.SetAttributes(tracing.RunId.String(run.ID))
+	ReportProgress(ctx, Progress{Stage: ProgressRun, ThreadId: prompt.ThreadId, RunId: run.ID,
+		RunStatus: string(run.Status)})
+	return run.ID, nil
+}
 
-	done := false
-	// Get the response from the model.
-	for !done {
-		resp, err := m.Client.RetrieveRun(context.Background(), run.ThreadID, run.ID)
-		if err != nil {
-			return "", fmt.Errorf("error getting run: %v", err)
-		}
-		switch resp.Status {
-		case openai.RunStatusInProgress, openai.RunStatusQueued:
-			// TODO: we should have a configurable interval, or maybe exponential backoff.
-			time.Sleep(5 * time.Second)
-		case openai.RunStatusCompleted:
-			log.Debug().
-				Int("tokens", resp.Usage.TotalTokens).
-				Msg("run completed")
-			done = true
-		case openai.RunStatusFailed:
-			return "", fmt.Errorf("run failed: %v", resp.LastError.Message)
-		case openai.RunStatusCancelled, openai.RunStatusCancelling, openai.RunStatusExpired:
-			return "", fmt.Errorf("run cancelled or expired")
-		case openai.RunStatusRequiresAction:
-			log.Warn().
-				Str("action", string(resp.RequiredAction.Type)).
-				Msg("action required")
-			return "", fmt.Errorf("action required")
-		default:
-			return "", fmt.Errorf("unexpected run status: %s", resp.Status)
-		}
+// finishRun waits for the Run to complete, and processes the response;
+// `project` only labels the metrics.
+func (m *Majordomo) finishRun(ctx context.Context, prompt *PromptRequest, project, runId string,
+	store preprocessors.CodeStoreHandler) (_ *botReply, err error) {
+	if m.Client == nil {
+		return nil, fmt.Errorf("OpenAI client not initialized")
+	}
+	ctx, span := tracing.Start(ctx, "FinishRun",
+		tracing.Project.String(project), tracing.Assistant.String(prompt.Assistant),
+		tracing.ThreadId.String(prompt.ThreadId), tracing.RunId.String(runId))
+	defer tracing.End(span, &err)
+	if err = m.waitRun(ctx, prompt.ThreadId, runId, project); err != nil {
+		return nil, err
 	}
 
 	// Retrieve the most recent message in the Thread.
 	messages, err := m.Client.ListMessage(
-		context.Background(),
+		ctx,
 		prompt.ThreadId, nil, nil, nil, nil, nil)
 	if err != nil {
-		return "", fmt.Errorf("error listing messages: %v", err)
+		return nil, fmt.Errorf("error listing messages: %w", err)
 	}
 	log.Debug().
 		Int("messages", len(messages.Messages)).
@@ -356,75 +655,158 @@ func (m *Majordomo) QueryBot(prompt *PromptRequest) (string, error) {
 	log.Debug().
 		Str("bot_says", botSays).
 		Msg("bot response")
+	ReportProgress(ctx, Progress{Stage: ProgressOutput, ThreadId: prompt.ThreadId, RunId: runId, Text: botSays})
 
-	// Parse the response from the model.
-	parser := preprocessors.Parser{
-		CodeMap: make(preprocessors.SourceCodeMap),
-	}
-	err = parser.ParseBotResponse(botSays)
+	_, processSpan := tracing.Start(ctx, "ProcessResponse")
+	response, err := m.ProcessResponse(botSays, prompt, runId, store)
+	tracing.End(processSpan, &err)
 	if err != nil {
-		return "", fmt.Errorf("error parsing bot response: %v", err)
+		return nil, err
+	}
+	var saved []string
+	for _, block := range response.CodeBlocks {
+		if block.Saved {
+			metrics.SnippetsSaved.WithLabelValues(project).Inc()
+			saved = append(saved, block.Path)
+			ReportProgress(ctx, Progress{Stage: ProgressSnippetSaved, ThreadId: prompt.ThreadId,
+				RunId: runId, Path: block.Path})
+		}
 	}
-	err = m.CodeStore.PutSourceCode(parser.CodeMap)
+	err = m.Audit.Record(audit.Entry{
+		Event:      audit.EventResponse,
+		User:       prompt.User,
+		Project:    project,
+		Assistant:  prompt.Assistant,
+		ThreadId:   prompt.ThreadId,
+		ThreadName: prompt.ThreadName,
+		RunId:      runId,
+		Snippets:   saved,
+	})
 	if err != nil {
-		log.Err(err).Msg("error storing source code")
-	}
-	log.Debug().Msg("response parsed, code snippets stored")
-	return botSays, nil
+		// The snippets have been saved already.
+		log.Err(err).Str("run_id", runId).Msg("error auditing the response")
+	}
+	return &botReply{
+		Text:     botSays,
+		Snippets: response.Snippets,
+		RunId:    runId,
+		Response: response,
+	}, nil
 }
 
-func (m *Majordomo) SpeechToText(audioFile multipart.File) (string, error) {
-	resp, err := m.Client.CreateTranscription(
-		context.Background(),
-		openai.AudioRequest{
-			Model:    openai.Whisper1,
-			FilePath: "audio.mp3",
-			Reader:   audioFile,
-			Format:   openai.AudioResponseFormatText,
-		})
-	if err != nil {
-		return "", fmt.Errorf("error converting audio to text: %v", err)
+// waitRun polls the Run until it reaches a terminal status, checking less
+// often the longer it takes; it fails unless the Run completed.
+func (m *Majordomo) waitRun(ctx context.Context, threadId, runId, project string) (err error) {
+	ctx, span := tracing.Start(ctx, "WaitRun",
+		tracing.ThreadId.String(threadId), tracing.RunId.String(runId))
+	defer tracing.End(span, &err)
+	poll := retry.PollBackoff(m.Config.OpenAI)
+	var lastStatus openai.RunStatus
+	for check := 0; ; check++ {
+		resp, err := m.Client.RetrieveRun(ctx, threadId, runId)
+		if err != nil {
+			return fmt.Errorf("error getting run: %w", err)
+		}
+		recordRun(resp, project)
+		span.SetAttributes(tracing.RunStatus.String(string(resp.Status)))
+		if resp.Status != lastStatus {
+			lastStatus = resp.Status
+			ReportProgress(ctx, Progress{Stage: ProgressRun, ThreadId: threadId, RunId: runId,
+				RunStatus: string(resp.Status)})
+		}
+		switch resp.Status {
+		case openai.RunStatusInProgress, openai.RunStatusQueued:
+			if err = retry.Sleep(ctx, poll.Delay(check)); err != nil {
+				return err
+			}
+		case openai.RunStatusCompleted:
+			log.Debug().
+				Int("tokens", resp.Usage.TotalTokens).
+				Msg("run completed")
+			span.SetAttributes(
+				tracing.PromptTokens.Int(resp.Usage.PromptTokens),
+				tracing.ReplyTokens.Int(resp.Usage.CompletionTokens),
+			)
+			return nil
+		case openai.RunStatusFailed:
+			return fmt.Errorf("run failed: %v", resp.LastError.Message)
+		case openai.RunStatusCancelled, openai.RunStatusCancelling, openai.RunStatusExpired:
+			return fmt.Errorf("run cancelled or expired")
+		case openai.RunStatusRequiresAction:
+			log.Warn().
+				Str("action", string(resp.RequiredAction.Type)).
+				Msg("action required")
+			return fmt.Errorf("action required")
+		default:
+			return fmt.Errorf("unexpected run status: %s", resp.Status)
+		}
 	}
-	return resp.Text, nil
 }
 
-// GetAssistantId returns the ID of the assistant with the given name.
-// TODO: this should be cached somewhere, as the assistants change infrequently.
-func (m *Majordomo) GetAssistantId(name string) (string, error) {
-	ctx := context.Background()
-	listAssistants, err := m.Client.ListAssistants(ctx, nil, nil, nil, nil)
+// ProcessResponse parses the LLM's response to the prompt, applies any edits
+// to the files and, unless the prompt disabled it, saves the code snippets
+// to the store.
+func (m *Majordomo) ProcessResponse(botSays string, prompt *PromptRequest, runId string,
+	store preprocessors.CodeStoreHandler) (*PromptResponse, error) {
+	parser := preprocessors.Parser{
+		CodeMap: make(preprocessors.SourceCodeMap),
+	}
+	err := parser.ParseBotResponse(botSays)
 	if err != nil {
-		return "", fmt.Errorf("error listing assistants: %v", err)
+		return nil, fmt.Errorf("error parsing bot response: %v", err)
 	}
-	for _, assistant := range listAssistants.Assistants {
-		if assistant.Name == nil {
-			log.Error().
-				Str("assistant_id", assistant.ID).
-				Msg("assistant has no name")
-			continue
-		}
-		if *assistant.Name == name {
-			return assistant.ID, nil
+	// The LLM may have echoed back the placeholders for the redacted secrets.
+	prompt.Redactions.RestoreAll(parser.CodeMap)
+	prompt.Redactions.RestoreEdits(parser.Edits)
+	editFailures := parser.ApplyEdits(store)
+	if len(editFailures) > 0 {
+		log.Warn().
+			Int("failures", len(editFailures)).
+			Msg("some edits in the bot response could not be applied")
+	}
+	save := prompt.ShouldSave()
+	if save {
+		err = preprocessors.SaveSnippets(store, parser.CodeMap, preprocessors.VersionMeta{
+			ThreadId: prompt.ThreadId,
+			RunId:    runId,
+		})
+		if err != nil {
+			log.Err(err).Msg("error storing source code")
 		}
+		log.Debug().Msg("response parsed, code snippets stored")
+	} else {
+		log.Debug().Msg("response parsed, code snippets not saved (preview)")
 	}
-	return "", fmt.Errorf("assistant %s not found", name)
+	response := newPromptResponse(botSays, &parser, editFailures, store, save, err)
+	response.Snippets = parser.CodeMap
+	return response, nil
+}
+
+// GetAssistantId returns the ID of the assistant with the given name.
+func (m *Majordomo) GetAssistantId(name string) (string, error) {
+	return m.Assistants.GetId(context.Background(), name)
 }
 
 // CreateAssistants creates the OpenAI Assistants based on the instructions in the configuration file.
 func (m *Majordomo) CreateAssistants(assistants *Assistants) error {
+	// TODO: This should have a configurable timeout.
 	ctx := context.Background()
-	// TODO: This should be configurable.
-	const DefaultTimeout = 1 * time.Second
-	context.WithTimeout(ctx, DefaultTimeout)
 
-	listAssistants, err := m.Client.ListAssistants(ctx, nil, nil, nil, nil)
+	if err := m.Assistants.Refresh(ctx); err != nil {
+		return err
+	}
+	list, err := m.Assistants.List(ctx)
 	if err != nil {
-		return fmt.Errorf("error listing assistants: %v", err)
+		return err
 	}
+	// New assistants are listed on next use.
+	defer m.Assistants.Invalidate()
 	// Make it easier to check if an assistant already exists.
 	existingAssistants := hashset.New()
-	for _, assts := range listAssistants.Assistants {
-		existingAssistants.Add(*assts.Name)
+	for _, assts := range list {
+		if assts.Name != nil {
+			existingAssistants.Add(*assts.Name)
+		}
 	}
 	log.Debug().Msg(fmt.Sprintf("Existing Assistants: %v", existingAssistants.Values()))
 
@@ -434,7 +816,8 @@ func (m *Majordomo) CreateAssistants(assistants *Assistants) error {
 				"updating not implemented yet")
 			continue
 		}
-		inst := fmt.Sprintf("%s\n%s", assistants.Common, instructions)
+		inst := fmt.Sprintf("%s\n%s%s", assistants.Common, instructions,
+			assistants.GetEditFormatInstructions(name))
 		a, err := m.Client.CreateAssistant(ctx, openai.AssistantRequest{
 			Model:        m.Model,
 			Name:         &name,
@@ -452,3 +835,20 @@ func (m *Majordomo) CreateAssistants(assistants *Assistants) error {
 	}
 	return nil
 }
+
+// recordRun updates the metrics once the Run has reached a terminal status.
+func recordRun(run openai.Run, project string) {
+	switch run.Status {
+	case openai.RunStatusInProgress, openai.RunStatusQueued, openai.RunStatusCancelling:
+		return
+	}
+	status := string(run.Status)
+	metrics.Runs.WithLabelValues(status).Inc()
+	if run.CreatedAt > 0 {
+		metrics.RunDuration.WithLabelValues(status).Observe(time.Since(time.Unix(run.CreatedAt, 0)).Seconds())
+	}
+	if run.Status == openai.RunStatusCompleted {
+		metrics.Tokens.WithLabelValues(run.Model, project, "prompt").Add(float64(run.Usage.PromptTokens))
+		metrics.Tokens.WithLabelValues(run.Model, project, "completion").Add(float64(run.Usage.CompletionTokens))
+	}
+}
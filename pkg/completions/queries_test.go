@@ -1,6 +1,9 @@
 package completions_test
 
 import (
+	"context"
+
+	"github.com/alertavert/gpt4-go/pkg/api"
 	"github.com/alertavert/gpt4-go/pkg/completions"
 	"github.com/alertavert/gpt4-go/pkg/config"
 	"github.com/alertavert/gpt4-go/pkg/preprocessors"
@@ -74,34 +77,43 @@ var _ = Describe("Majordomo", func() {
 			Expect(prj).NotTo(BeNil())
 			Expect(prj.ResolvedCodeSnippetsDir).To(HaveSuffix("test/location/.majordomo"))
 			// This should also be what the CodeStore uses
-			Expect(majordomo.CodeStore).NotTo(BeNil())
+			Expect(majordomo.CodeStore()).NotTo(BeNil())
 			// We cast the CodeStore to be a FilesystemStore to access the Location field.
-			fsStore := majordomo.CodeStore.(*preprocessors.FilesystemStore)
+			fsStore := majordomo.CodeStore().(*preprocessors.FilesystemStore)
 			Expect(fsStore.DestCodeDir).To(HaveSuffix("test/location/.majordomo"))
 		})
 		It("will set the CodeStore to the new project's location", func() {
 			err := majordomo.SetActiveProject("test-project-2")
 			Expect(err).NotTo(HaveOccurred())
-			Expect(majordomo.CodeStore).NotTo(BeNil())
+			Expect(majordomo.CodeStore()).NotTo(BeNil())
 			// We cast the CodeStore to be a FilesystemStore to access the Location field.
-			fsStore := majordomo.CodeStore.(*preprocessors.FilesystemStore)
+			fsStore := majordomo.CodeStore().(*preprocessors.FilesystemStore)
 			Expect(fsStore.SourceCodeDir).To(Equal("test/location-2"))
 			// The destination for the code returned by the bot should be as
 			// configured in the test_config.yaml file, ending with the project name.
 			Expect(fsStore.DestCodeDir).To(HaveSuffix("test/location-2/.majordomo"))
 		})
+		It("does not share the code stores with other instances", func() {
+			other, err := config.LoadConfig(TestConfigLocation)
+			Expect(err).NotTo(HaveOccurred())
+			other.Projects[0].Location = "other/location"
+			otherMajordomo, err := completions.NewMajordomo(other)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(majordomo.CodeStore().(*preprocessors.FilesystemStore).SourceCodeDir).To(Equal("test/location"))
+			Expect(otherMajordomo.CodeStore().(*preprocessors.FilesystemStore).SourceCodeDir).To(Equal("other/location"))
+		})
 	})
 	Describe("When parsing a user prompt", func() {
 		It("should successfully fill in the correct content from the source code map", func() {
 			Expect(majordomo.SetActiveProject("actual")).NotTo(HaveOccurred())
 			prompt := "Please update this code:\n'''sample/main.go\n" +
 				"'''to also print the current date."
-			request := completions.PromptRequest{
+			request := completions.PromptRequest{PromptRequest: api.PromptRequest{
 				Assistant: "go_developer",
 				ThreadId:  "",
 				ThreadName: "test-thread",
 				Prompt:    prompt,
-			}
+			}}
 			Expect(majordomo.PreparePrompt(&request)).ShouldNot(HaveOccurred())
 			// Read the contents of the file from the filesystem
 			// Remember that the code snippets are stored in the SourceCodeDir relative
@@ -109,22 +121,41 @@ var _ = Describe("Majordomo", func() {
 			code := &preprocessors.SourceCodeMap{
 				"sample/main.go": "",
 			}
-			Expect(majordomo.CodeStore.GetSourceCode(code)).NotTo(HaveOccurred())
+			Expect(majordomo.CodeStore().GetSourceCode(code)).NotTo(HaveOccurred())
 			contents, found := (*code)["sample/main.go"]
 			Expect(found).To(BeTrue())
 			Expect(request.Prompt).To(ContainSubstring(contents))
+			// Recorded in the audit log.
+			Expect(request.Files).To(HaveLen(1))
+			Expect(request.Files[0].Path).To(Equal("sample/main.go"))
+			Expect(request.Files[0].Bytes).To(Equal(len(contents)))
+			Expect(request.Files[0].SHA256).To(HaveLen(64))
+		})
+		It("should use the request's project, instead of the active one", func() {
+			Expect(majordomo.SetActiveProject("test-project")).To(Succeed())
+			request := completions.PromptRequest{PromptRequest: api.PromptRequest{
+				Assistant: "go_developer",
+				Project:   "actual",
+				Prompt:    "Please update this code:\n'''sample/main.go\n'''",
+			}}
+			Expect(majordomo.PreparePrompt(&request)).To(Succeed())
+			Expect(request.Prompt).To(ContainSubstring("package main"))
+			Expect(majordomo.ActiveProject()).To(Equal("test-project"))
+
+			request.Project = "nowhere"
+			Expect(majordomo.PreparePrompt(&request)).To(MatchError(completions.ErrProjectNotFound))
 		})
 		It("should fail for an invalid file path", func() {
 			err := majordomo.SetActiveProject("actual")
 			Expect(err).NotTo(HaveOccurred())
 
 			prompt := "Please update this code:\n'''invalid/file/path\n'''"
-			request := completions.PromptRequest{
+			request := completions.PromptRequest{PromptRequest: api.PromptRequest{
 				Assistant: "go_developer",
 				ThreadId:  "",
 				ThreadName: "test-thread",
 				Prompt:    prompt,
-			}
+			}}
 			Expect(majordomo.PreparePrompt(&request)).To(HaveOccurred())
 		})
 		It("can import multiple files", func() {
@@ -134,12 +165,12 @@ var _ = Describe("Majordomo", func() {
 			prompt := "Please update this code:\n'''sample/main.go\n" +
 				"'''to also print the current date.\n" +
 				"'''pkg/simple.go\n'''"
-			request := completions.PromptRequest{
+			request := completions.PromptRequest{PromptRequest: api.PromptRequest{
 				Assistant: "go_developer",
 				ThreadId:  "",
 				ThreadName: "test-thread",
 				Prompt:    prompt,
-			}
+			}}
 			Expect(majordomo.PreparePrompt(&request)).ShouldNot(HaveOccurred())
 			// Read the contents of the files from the filesystem
 			code := &preprocessors.SourceCodeMap{
@@ -176,16 +207,16 @@ var _ = Describe("Majordomo", func() {
 		})
 		It("should attempt to query the bot with empty thread_id and thread_name", func() {
 			// Create a request with empty thread_id and thread_name
-			request := completions.PromptRequest{
+			request := completions.PromptRequest{PromptRequest: api.PromptRequest{
 				Assistant: "go_developer",
 				ThreadId:  "",
 				ThreadName: "",
 				Prompt:    "Test prompt",
-			}
+			}}
 
 			// We expect QueryBot to fail because we're not mocking the OpenAI API
 			// and the API key is invalid in the test environment
-			_, err := majordomo.QueryBot(&request)
+			_, err := majordomo.QueryBot(context.Background(), &request)
 			Expect(err).To(HaveOccurred())
 
 			// We can't verify the exact thread name that was suggested,
@@ -0,0 +1,221 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package completions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	// DefaultAssistantsTTL is how long the assistants are cached, if not configured.
+	DefaultAssistantsTTL = 10 * time.Minute
+	// DefaultMissRefreshInterval is how often, at most, the assistants are
+	// listed again because a name was not found in the cache.
+	DefaultMissRefreshInterval = 30 * time.Second
+	// assistantsPageSize is the largest page the OpenAI API returns.
+	assistantsPageSize = 100
+)
+
+var (
+	// ErrAssistantNotFound is returned when no assistant has the given name.
+	ErrAssistantNotFound = errors.New("assistant not found")
+	// ErrDuplicateAssistant is returned when more than one assistant has the given name.
+	ErrDuplicateAssistant = errors.New("duplicate assistant name")
+)
+
+// AssistantLister lists the OpenAI Assistants, one page at a time; it is
+// implemented by the openai.Client.
+type AssistantLister interface {
+	ListAssistants(ctx context.Context, limit *int, order *string, after *string,
+		before *string) (openai.AssistantsList, error)
+}
+
+// AssistantRegistry caches the OpenAI Assistants, so that looking up their
+// IDs by name does not require a round trip for every prompt.
+// It is safe for concurrent use.
+type AssistantRegistry struct {
+	Lister AssistantLister
+	// TTL is how long the assistants are cached before being listed again.
+	TTL time.Duration
+	// MissRefreshInterval is how often, at most, the assistants are listed
+	// again when looking up a name which is not cached; so that unknown (or
+	// mistyped) names do not cause a round trip for every request.
+	MissRefreshInterval time.Duration
+
+	mu          sync.RWMutex
+	assistants  []openai.Assistant
+	byName      map[string][]string
+	refreshedAt time.Time
+	// missedAt is when the assistants were last listed again, for a name which was not cached.
+	missedAt time.Time
+}
+
+// NewAssistantRegistry creates an empty AssistantRegistry, which will list the
+// assistants on first use; ttl defaults to DefaultAssistantsTTL if not positive.
+func NewAssistantRegistry(lister AssistantLister, ttl time.Duration) *AssistantRegistry {
+	if ttl <= 0 {
+		ttl = DefaultAssistantsTTL
+	}
+	return &AssistantRegistry{Lister: lister, TTL: ttl, MissRefreshInterval: DefaultMissRefreshInterval}
+}
+
+// Refresh lists all the assistants, page by page, and replaces the cached ones.
+func (r *AssistantRegistry) Refresh(ctx context.Context) error {
+	if r.Lister == nil {
+		return fmt.Errorf("OpenAI client not initialized")
+	}
+	var assistants []openai.Assistant
+	limit := assistantsPageSize
+	var after *string
+	for {
+		page, err := r.Lister.ListAssistants(ctx, &limit, nil, after, nil)
+		if err != nil {
+			return fmt.Errorf("error listing assistants: %w", err)
+		}
+		assistants = append(assistants, page.Assistants...)
+		if !page.HasMore || page.LastID == nil || len(page.Assistants) == 0 {
+			break
+		}
+		after = page.LastID
+	}
+	byName := make(map[string][]string)
+	for _, assistant := range assistants {
+		if assistant.Name == nil {
+			log.Error().
+				Str("assistant_id", assistant.ID).
+				Msg("assistant has no name")
+			continue
+		}
+		byName[*assistant.Name] = append(byName[*assistant.Name], assistant.ID)
+	}
+	for name, ids := range byName {
+		if len(ids) > 1 {
+			log.Warn().
+				Str("assistant", name).
+				Strs("assistant_ids", ids).
+				Msg("more than one assistant with the same name")
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.assistants = assistants
+	r.byName = byName
+	r.refreshedAt = time.Now()
+	log.Debug().
+		Int("assistants", len(assistants)).
+		Msg("assistants registry refreshed")
+	return nil
+}
+
+// Invalidate discards the cached assistants, which will be listed again on next use.
+func (r *AssistantRegistry) Invalidate() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.refreshedAt = time.Time{}
+}
+
+// RefreshedAt is when the assistants were last listed; zero if never, or
+// if they were invalidated.
+func (r *AssistantRegistry) RefreshedAt() time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.refreshedAt
+}
+
+// ensureFresh refreshes the cached assistants if they have expired.
+func (r *AssistantRegistry) ensureFresh(ctx context.Context) error {
+	r.mu.RLock()
+	stale := r.refreshedAt.IsZero() || time.Since(r.refreshedAt) > r.TTL
+	r.mu.RUnlock()
+	if stale {
+		return r.Refresh(ctx)
+	}
+	return nil
+}
+
+// List returns all the assistants, refreshing them if the cache has expired.
+func (r *AssistantRegistry) List(ctx context.Context) ([]openai.Assistant, error) {
+	if err := r.ensureFresh(ctx); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	assistants := make([]openai.Assistant, len(r.assistants))
+	copy(assistants, r.assistants)
+	return assistants, nil
+}
+
+// Duplicates returns the names shared by more than one assistant, with their IDs.
+func (r *AssistantRegistry) Duplicates(ctx context.Context) (map[string][]string, error) {
+	if err := r.ensureFresh(ctx); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	duplicates := make(map[string][]string)
+	for name, ids := range r.byName {
+		if len(ids) > 1 {
+			duplicates[name] = append([]string(nil), ids...)
+			sort.Strings(duplicates[name])
+		}
+	}
+	return duplicates, nil
+}
+
+// GetId returns the ID of the assistant with the given name.
+// If it is not in the cache, the assistants are listed again, in case it
+// was created since the last refresh; unless they were listed in the last
+// MissRefreshInterval.
+func (r *AssistantRegistry) GetId(ctx context.Context, name string) (string, error) {
+	if err := r.ensureFresh(ctx); err != nil {
+		return "", err
+	}
+	ids, err := r.lookup(name)
+	if errors.Is(err, ErrAssistantNotFound) && r.claimMissRefresh() {
+		if err = r.Refresh(ctx); err != nil {
+			return "", err
+		}
+		ids, err = r.lookup(name)
+	}
+	if err != nil {
+		return "", err
+	}
+	return ids[0], nil
+}
+
+// claimMissRefresh is true if the assistants can be listed again, for a name
+// which was not cached; the concurrent lookups, which missed too, will wait
+// for the next interval.
+func (r *AssistantRegistry) claimMissRefresh() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.refreshedAt) < r.MissRefreshInterval || time.Since(r.missedAt) < r.MissRefreshInterval {
+		return false
+	}
+	r.missedAt = time.Now()
+	return true
+}
+
+func (r *AssistantRegistry) lookup(name string) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ids := r.byName[name]
+	switch len(ids) {
+	case 0:
+		return nil, fmt.Errorf("%w: %s", ErrAssistantNotFound, name)
+	case 1:
+		return ids, nil
+	}
+	return nil, fmt.Errorf("%w: %s is the name of assistants %v", ErrDuplicateAssistant, name, ids)
+}
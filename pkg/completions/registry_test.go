@@ -0,0 +1,152 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package completions_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sashabaranov/go-openai"
+
+	"github.com/alertavert/gpt4-go/pkg/completions"
+)
+
+// fakeLister serves the assistants in pages of two.
+type fakeLister struct {
+	assistants []openai.Assistant
+	calls      int
+	err        error
+}
+
+func (f *fakeLister) ListAssistants(_ context.Context, _ *int, _ *string, after *string,
+	_ *string) (openai.AssistantsList, error) {
+	f.calls++
+	if f.err != nil {
+		return openai.AssistantsList{}, f.err
+	}
+	start := 0
+	if after != nil {
+		for i, a := range f.assistants {
+			if a.ID == *after {
+				start = i + 1
+			}
+		}
+	}
+	end := min(start+2, len(f.assistants))
+	page := openai.AssistantsList{Assistants: f.assistants[start:end], HasMore: end < len(f.assistants)}
+	if end > start {
+		page.LastID = &f.assistants[end-1].ID
+	}
+	return page, nil
+}
+
+func assistant(id, name string) openai.Assistant {
+	return openai.Assistant{ID: id, Name: &name}
+}
+
+var _ = Describe("Assistant Registry", func() {
+	var (
+		lister   *fakeLister
+		registry *completions.AssistantRegistry
+		ctx      = context.Background()
+	)
+
+	BeforeEach(func() {
+		lister = &fakeLister{assistants: []openai.Assistant{
+			assistant("asst_1", "go_developer"),
+			assistant("asst_2", "web_developer"),
+			assistant("asst_3", "architect"),
+			{ID: "asst_4"},
+			assistant("asst_5", "sre"),
+		}}
+		registry = completions.NewAssistantRegistry(lister, time.Hour)
+	})
+
+	It("should page through all the assistants", func() {
+		list, err := registry.List(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(list).To(HaveLen(5))
+		Expect(lister.calls).To(Equal(3))
+		id, err := registry.GetId(ctx, "sre")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(id).To(Equal("asst_5"))
+	})
+
+	It("should cache the assistants until they expire", func() {
+		for i := 0; i < 3; i++ {
+			_, err := registry.GetId(ctx, "go_developer")
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(lister.calls).To(Equal(3))
+
+		registry.TTL = time.Nanosecond
+		time.Sleep(time.Millisecond)
+		_, err := registry.GetId(ctx, "go_developer")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(lister.calls).To(Equal(6))
+	})
+
+	It("should list the assistants again after being invalidated", func() {
+		Expect(registry.Refresh(ctx)).To(Succeed())
+		Expect(registry.RefreshedAt()).NotTo(BeZero())
+		registry.Invalidate()
+		Expect(registry.RefreshedAt()).To(BeZero())
+		_, err := registry.List(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(lister.calls).To(Equal(6))
+	})
+
+	It("should look up new assistants which are not cached yet", func() {
+		registry.MissRefreshInterval = time.Millisecond
+		Expect(registry.Refresh(ctx)).To(Succeed())
+		lister.assistants = append(lister.assistants, assistant("asst_6", "tester"))
+		time.Sleep(2 * time.Millisecond)
+		id, err := registry.GetId(ctx, "tester")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(id).To(Equal("asst_6"))
+	})
+
+	It("should not list the assistants again for every unknown name", func() {
+		Expect(registry.Refresh(ctx)).To(Succeed())
+		Expect(lister.calls).To(Equal(3))
+		for i := 0; i < 5; i++ {
+			_, err := registry.GetId(ctx, "nobody")
+			Expect(err).To(MatchError(completions.ErrAssistantNotFound))
+		}
+		Expect(lister.calls).To(Equal(3))
+
+		// Once the interval has elapsed, only the first miss lists them again.
+		registry.MissRefreshInterval = 50 * time.Millisecond
+		time.Sleep(60 * time.Millisecond)
+		for i := 0; i < 5; i++ {
+			_, err := registry.GetId(ctx, "nobody")
+			Expect(err).To(MatchError(completions.ErrAssistantNotFound))
+		}
+		Expect(lister.calls).To(Equal(6))
+	})
+
+	It("should fail for unknown assistants", func() {
+		_, err := registry.GetId(ctx, "nobody")
+		Expect(err).To(MatchError(completions.ErrAssistantNotFound))
+	})
+
+	It("should detect duplicate names", func() {
+		lister.assistants = append(lister.assistants, assistant("asst_7", "architect"))
+		duplicates, err := registry.Duplicates(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(duplicates).To(Equal(map[string][]string{"architect": {"asst_3", "asst_7"}}))
+		_, err = registry.GetId(ctx, "architect")
+		Expect(err).To(MatchError(completions.ErrDuplicateAssistant))
+	})
+
+	It("should report listing errors", func() {
+		lister.err = errors.New("invalid API key")
+		_, err := registry.GetId(ctx, "go_developer")
+		Expect(err).To(MatchError(ContainSubstring("invalid API key")))
+	})
+})
@@ -0,0 +1,100 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package completions
+
+import (
+	"errors"
+
+	"github.com/alertavert/gpt4-go/pkg/api"
+	"github.com/alertavert/gpt4-go/pkg/preprocessors"
+)
+
+// CodeBlockResult describes a code block in the LLM's response, and what was
+// done with it.
+type CodeBlockResult = api.CodeBlockResult
+
+// PromptResponse is the LLM's response, as returned via the API, and the
+// files extracted from it.
+type PromptResponse struct {
+	api.BotResponse
+
+	// Snippets are the files extracted from the response, after applying the edits.
+	Snippets preprocessors.SourceCodeMap `json:"-"`
+}
+
+// newPromptResponse describes the code blocks found by the parser; `saveErr`
+// is the outcome of saving the parser's CodeMap, if `saved`.
+func newPromptResponse(botSays string, parser *preprocessors.Parser, editFailures []preprocessors.EditFailure,
+	store preprocessors.CodeStoreHandler, saved bool, saveErr error) *PromptResponse {
+	response := &PromptResponse{BotResponse: api.BotResponse{
+		Message:      botSays,
+		Text:         parser.Prose,
+		CodeBlocks:   make([]CodeBlockResult, 0, len(parser.Blocks)),
+		Commands:     parser.Commands,
+		EditFailures: editFailures,
+		Saved:        saved,
+	}}
+	if response.Commands == nil {
+		response.Commands = make([]string, 0)
+	}
+	// Only the paths rejected by the store were not saved.
+	rejected := make(map[string]string)
+	var rejectedErr *preprocessors.RejectedPathsError
+	if errors.As(saveErr, &rejectedErr) {
+		for _, r := range rejectedErr.Rejected {
+			rejected[r.Path] = r.Reason
+		}
+		saveErr = nil
+	} else if saveErr != nil {
+		// None was saved: each block reports why.
+		response.Saved = false
+	}
+	describe := func(block preprocessors.CodeBlock, path string) CodeBlockResult {
+		result := CodeBlockResult{Path: path, Language: block.Language, Format: block.Format}
+		content, found := parser.CodeMap[path]
+		switch {
+		case !found && block.Format != "":
+			result.Reason = "the edits could not be applied"
+		case !saved:
+			// Previewed only.
+		case rejected[path] != "":
+			result.Reason = rejected[path]
+		case saveErr != nil:
+			result.Reason = saveErr.Error()
+		default:
+			result.Saved = true
+			if locator, ok := store.(preprocessors.SnippetLocator); ok {
+				result.Location = locator.SnippetLocation(path)
+			}
+		}
+		if found {
+			result.Bytes = len(content)
+			original := preprocessors.SourceCodeMap{path: ""}
+			result.Changed = store.GetSourceCode(&original) != nil || original[path] != content
+		}
+		return result
+	}
+	for _, block := range parser.Blocks {
+		switch {
+		case !block.Attributed():
+			response.CodeBlocks = append(response.CodeBlocks, CodeBlockResult{
+				Path:     block.Path,
+				Language: block.Language,
+				Format:   block.Format,
+				Bytes:    len(block.Content),
+				Reason:   block.Reason,
+			})
+		case block.Format != "":
+			for _, fe := range parser.Edits {
+				if fe.Line == block.Line {
+					response.CodeBlocks = append(response.CodeBlocks, describe(block, fe.Path))
+				}
+			}
+		case parser.CodeMap[block.Path] != "":
+			response.CodeBlocks = append(response.CodeBlocks, describe(block, block.Path))
+		}
+	}
+	return response
+}
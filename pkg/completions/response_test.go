@@ -0,0 +1,117 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package completions_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alertavert/gpt4-go/pkg/api"
+	"github.com/alertavert/gpt4-go/pkg/completions"
+	"github.com/alertavert/gpt4-go/pkg/config"
+	"github.com/alertavert/gpt4-go/pkg/preprocessors"
+)
+
+const botSays = `Here is the fix:
+
+'''main.go
+package main
+'''
+
+'''util/util.go
+package util
+'''
+
+Then run:
+! go build ./...
+
+` + "```sh\n$ go test ./...\n```\n" + `
+'''../outside.go
+package oops
+'''
+`
+
+var _ = Describe("ProcessResponse", func() {
+	var (
+		majordomo *completions.Majordomo
+		store     *preprocessors.FilesystemStore
+	)
+
+	BeforeEach(func() {
+		cfg, err := config.LoadConfig(TestConfigLocation)
+		Expect(err).NotTo(HaveOccurred())
+		majordomo, err = completions.NewMajordomo(cfg)
+		Expect(err).NotTo(HaveOccurred())
+
+		src, err := os.MkdirTemp("", "src")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.WriteFile(filepath.Join(src, "main.go"), []byte("package main\n"), 0644)).To(Succeed())
+		dest, err := os.MkdirTemp("", "dest")
+		Expect(err).NotTo(HaveOccurred())
+		store = &preprocessors.FilesystemStore{SourceCodeDir: src, DestCodeDir: dest}
+	})
+	AfterEach(func() {
+		Expect(os.RemoveAll(store.SourceCodeDir)).To(Succeed())
+		Expect(os.RemoveAll(store.DestCodeDir)).To(Succeed())
+	})
+
+	It("separates the text, the code blocks and the commands", func() {
+		response, err := majordomo.ProcessResponse(botSays, &completions.PromptRequest{}, "run_1", store)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(response.Message).To(Equal(botSays))
+		Expect(response.Text).To(Equal("Here is the fix:\n\nThen run:"))
+		Expect(response.Commands).To(Equal([]string{"go build ./...", "go test ./..."}))
+		Expect(response.Saved).To(BeTrue())
+
+		Expect(response.CodeBlocks).To(HaveLen(3))
+		main := response.CodeBlocks[0]
+		Expect(main.Path).To(Equal("main.go"))
+		Expect(main.Saved).To(BeTrue())
+		Expect(main.Location).To(Equal(filepath.Join(store.DestCodeDir, "main.go")))
+		Expect(main.Bytes).To(Equal(len("package main\n")))
+		Expect(main.Changed).To(BeFalse())
+
+		util := response.CodeBlocks[1]
+		Expect(util.Saved).To(BeTrue())
+		Expect(util.Changed).To(BeTrue())
+		Expect(filepath.Join(store.DestCodeDir, "util/util.go")).To(BeARegularFile())
+
+		Expect(response.CodeBlocks[2].Saved).To(BeFalse())
+		Expect(response.CodeBlocks[2].Reason).To(Equal(preprocessors.ReasonInvalidPath))
+	})
+	It("does not save the snippets when previewing", func() {
+		save := false
+		response, err := majordomo.ProcessResponse(botSays, &completions.PromptRequest{PromptRequest: api.PromptRequest{Save: &save}}, "run_1", store)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(response.Saved).To(BeFalse())
+		Expect(response.CodeBlocks[1].Saved).To(BeFalse())
+		Expect(response.CodeBlocks[1].Location).To(BeEmpty())
+		Expect(response.CodeBlocks[1].Changed).To(BeTrue())
+		Expect(filepath.Join(store.DestCodeDir, "util/util.go")).NotTo(BeAnExistingFile())
+	})
+	It("only reports the snippets rejected by the store as not saved", func() {
+		// util/util.go cannot be saved under a file.
+		Expect(os.WriteFile(filepath.Join(store.DestCodeDir, "util"), nil, 0644)).To(Succeed())
+		response, err := majordomo.ProcessResponse(botSays, &completions.PromptRequest{}, "run_1", store)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(response.Saved).To(BeTrue())
+		Expect(response.CodeBlocks[0].Saved).To(BeTrue())
+		Expect(response.CodeBlocks[0].Reason).To(BeEmpty())
+		Expect(response.CodeBlocks[1].Saved).To(BeFalse())
+		Expect(response.CodeBlocks[1].Reason).To(Equal("not a directory"))
+	})
+	It("reports why the snippets were not saved, if the store fails", func() {
+		// util/util.go cannot be written over a directory.
+		Expect(os.MkdirAll(filepath.Join(store.DestCodeDir, "util", "util.go"), 0755)).To(Succeed())
+		response, err := majordomo.ProcessResponse(botSays, &completions.PromptRequest{}, "run_1", store)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(response.Saved).To(BeFalse())
+		Expect(response.CodeBlocks[1].Saved).To(BeFalse())
+		Expect(response.CodeBlocks[1].Reason).NotTo(BeEmpty())
+	})
+})
@@ -0,0 +1,233 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package completions
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sashabaranov/go-openai"
+
+	"github.com/alertavert/gpt4-go/pkg/api"
+	"github.com/alertavert/gpt4-go/pkg/config"
+)
+
+const (
+	DefaultSpeechModel  = openai.TTSModel1
+	DefaultSpeechVoice  = openai.VoiceAlloy
+	DefaultSpeechFormat = openai.SpeechResponseFormatMp3
+	// MaxSpeechInput is the longest text the TTS API converts in one request.
+	MaxSpeechInput = 4096
+	MinSpeechSpeed = 0.25
+	MaxSpeechSpeed = 4.0
+)
+
+// SpeechContentTypes are the MIME types of the audio formats.
+var SpeechContentTypes = map[string]string{
+	string(openai.SpeechResponseFormatMp3):  "audio/mpeg",
+	string(openai.SpeechResponseFormatOpus): "audio/ogg",
+	string(openai.SpeechResponseFormatAac):  "audio/aac",
+	string(openai.SpeechResponseFormatFlac): "audio/flac",
+	string(openai.SpeechResponseFormatWav):  "audio/wav",
+	string(openai.SpeechResponseFormatPcm):  "audio/pcm",
+}
+
+var speechVoices = map[string]bool{
+	string(openai.VoiceAlloy):   true,
+	string(openai.VoiceEcho):    true,
+	string(openai.VoiceFable):   true,
+	string(openai.VoiceOnyx):    true,
+	string(openai.VoiceNova):    true,
+	string(openai.VoiceShimmer): true,
+}
+
+var (
+	// ErrSpeechNotFound is returned when a cached audio file does not exist.
+	ErrSpeechNotFound = errors.New("speech not found")
+	// ErrInvalidSpeechRequest is returned when the SpeechRequest is not valid.
+	ErrInvalidSpeechRequest = errors.New("invalid speech request")
+)
+
+// SpeechRequest is the text to convert to audio; Voice, Format and Speed
+// default to the configured ones, if not set.
+type SpeechRequest = api.SpeechRequest
+
+// Speech is the audio converted from the text, kept in the cache.
+type Speech struct {
+	// File is the name of the audio file, in the cache.
+	File        string
+	Path        string
+	ContentType string
+	// Truncated is true if the text was too long, and only its beginning was converted.
+	Truncated bool
+}
+
+// withDefaults fills in the configured defaults, and validates the request.
+func withDefaults(r SpeechRequest, cfg config.SpeechConfig) (SpeechRequest, error) {
+	if strings.TrimSpace(r.Text) == "" {
+		return r, fmt.Errorf("%w: text field is required", ErrInvalidSpeechRequest)
+	}
+	if r.Voice == "" {
+		r.Voice = cfg.Voice
+	}
+	if r.Voice == "" {
+		r.Voice = string(DefaultSpeechVoice)
+	}
+	if r.Format == "" {
+		r.Format = cfg.Format
+	}
+	if r.Format == "" {
+		r.Format = string(DefaultSpeechFormat)
+	}
+	if r.Speed == 0 {
+		r.Speed = cfg.Speed
+	}
+	if r.Speed == 0 {
+		r.Speed = 1.0
+	}
+	if !speechVoices[r.Voice] {
+		return r, fmt.Errorf("%w: unknown voice %q", ErrInvalidSpeechRequest, r.Voice)
+	}
+	if _, found := SpeechContentTypes[r.Format]; !found {
+		return r, fmt.Errorf("%w: unknown audio format %q", ErrInvalidSpeechRequest, r.Format)
+	}
+	if r.Speed < MinSpeechSpeed || r.Speed > MaxSpeechSpeed {
+		return r, fmt.Errorf("%w: speed must be between %.2f and %.1f", ErrInvalidSpeechRequest,
+			MinSpeechSpeed, MaxSpeechSpeed)
+	}
+	return r, nil
+}
+
+var sentenceEnd = regexp.MustCompile(`[.!?]\s`)
+
+// truncateSpeech shortens the text to at most MaxSpeechInput bytes, at the
+// end of a sentence if possible.
+func truncateSpeech(text string) (string, bool) {
+	if len(text) <= MaxSpeechInput {
+		return text, false
+	}
+	text = text[:MaxSpeechInput]
+	if ends := sentenceEnd.FindAllStringIndex(text, -1); len(ends) > 0 {
+		return text[:ends[len(ends)-1][0]+1], true
+	}
+	return strings.ToValidUTF8(text, ""), true
+}
+
+// speechCacheDir is the configured directory for the audio files.
+func (m *Majordomo) speechCacheDir() string {
+	if m.Config.Speech.CacheDir == "" {
+		return config.DefaultSpeechCacheLocation
+	}
+	return os.ExpandEnv(m.Config.Speech.CacheDir)
+}
+
+func (m *Majordomo) speechModel() string {
+	if m.Config.Speech.Model == "" {
+		return string(DefaultSpeechModel)
+	}
+	return m.Config.Speech.Model
+}
+
+// SpeechFile returns the name of the file, in the cache, for the audio
+// converted from the request's text.
+func (m *Majordomo) SpeechFile(request SpeechRequest) (string, error) {
+	r, err := withDefaults(request, m.Config.Speech)
+	if err != nil {
+		return "", err
+	}
+	text, _ := truncateSpeech(r.Text)
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s\n%s\n%.2f\n%s", m.speechModel(), r.Voice, r.Speed, text)))
+	return hex.EncodeToString(hash[:16]) + "." + r.Format, nil
+}
+
+// GetSpeech returns the audio file, with the given name, from the cache.
+func (m *Majordomo) GetSpeech(file string) (*Speech, error) {
+	ext := strings.TrimPrefix(filepath.Ext(file), ".")
+	contentType, found := SpeechContentTypes[ext]
+	if !found || filepath.Base(file) != file {
+		return nil, ErrSpeechNotFound
+	}
+	path := filepath.Join(m.speechCacheDir(), file)
+	if _, err := os.Stat(path); err != nil {
+		return nil, ErrSpeechNotFound
+	}
+	return &Speech{File: file, Path: path, ContentType: contentType}, nil
+}
+
+// TextToSpeech converts the text to audio, unless it was already converted,
+// and returns the audio file in the cache.
+func (m *Majordomo) TextToSpeech(request SpeechRequest) (*Speech, error) {
+	r, err := withDefaults(request, m.Config.Speech)
+	if err != nil {
+		return nil, err
+	}
+	file, err := m.SpeechFile(r)
+	if err != nil {
+		return nil, err
+	}
+	text, truncated := truncateSpeech(r.Text)
+	if speech, err := m.GetSpeech(file); err == nil {
+		log.Debug().Str("file", file).Msg("speech found in cache")
+		speech.Truncated = truncated
+		return speech, nil
+	}
+	if m.Client == nil {
+		return nil, fmt.Errorf("OpenAI client not initialized")
+	}
+	if truncated {
+		log.Warn().
+			Int("length", len(r.Text)).
+			Int("converted", len(text)).
+			Msg("text too long, only its beginning is converted to speech")
+	}
+	resp, err := m.Client.CreateSpeech(context.Background(), openai.CreateSpeechRequest{
+		Model:          openai.SpeechModel(m.speechModel()),
+		Input:          text,
+		Voice:          openai.SpeechVoice(r.Voice),
+		ResponseFormat: openai.SpeechResponseFormat(r.Format),
+		Speed:          r.Speed,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error converting text to speech: %w", err)
+	}
+	defer resp.Close()
+
+	dir := m.speechCacheDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	// Written to a temporary file first, so that a partial file is never served.
+	tmp, err := os.CreateTemp(dir, ".speech-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = io.Copy(tmp, resp); err != nil {
+		_ = tmp.Close()
+		return nil, fmt.Errorf("error reading the speech: %w", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, file)
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return nil, err
+	}
+	log.Debug().
+		Str("file", file).
+		Str("voice", r.Voice).
+		Int("length", len(text)).
+		Msg("text converted to speech")
+	return &Speech{File: file, Path: path, ContentType: SpeechContentTypes[r.Format], Truncated: truncated}, nil
+}
@@ -0,0 +1,145 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package completions
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sashabaranov/go-openai"
+
+	"github.com/alertavert/gpt4-go/pkg/api"
+	"github.com/alertavert/gpt4-go/pkg/audio"
+)
+
+const (
+	// DefaultMaxAudioBytes is the largest file the transcription API accepts.
+	DefaultMaxAudioBytes = 25 << 20
+	// DefaultMaxAudioSeconds is the longest spoken command.
+	DefaultMaxAudioSeconds = 300
+)
+
+// TranscriptionOptions describe the recording, and how to transcribe it;
+// Language and Prompt default to the configured ones.
+type TranscriptionOptions = api.TranscriptionOptions
+
+// Segment is a part of the transcription, with its timestamps (in seconds).
+type Segment = api.Segment
+
+// Transcription is the text of a recording.
+type Transcription = api.Transcription
+
+func (m *Majordomo) maxAudioBytes() int64 {
+	if m.Config.Voice.MaxAudioBytes > 0 {
+		return m.Config.Voice.MaxAudioBytes
+	}
+	return DefaultMaxAudioBytes
+}
+
+func (m *Majordomo) maxAudioDuration() time.Duration {
+	seconds := m.Config.Voice.MaxAudioSeconds
+	if seconds <= 0 {
+		seconds = DefaultMaxAudioSeconds
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// ReadAudio reads the recording, enforcing the size and (where it can be
+// told from the header) duration limits, and detects its format, converting
+// it to MP3 if it cannot be transcribed as it is.
+func (m *Majordomo) ReadAudio(r io.Reader, opts TranscriptionOptions) ([]byte, audio.Format, bool, error) {
+	maxBytes := m.maxAudioBytes()
+	data, err := io.ReadAll(io.LimitReader(r, maxBytes+1))
+	if err != nil {
+		return nil, audio.Format{}, false, fmt.Errorf("error reading the audio: %w", err)
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, audio.Format{}, false, fmt.Errorf("%w: more than %d bytes", audio.ErrTooLarge, maxBytes)
+	}
+	format, err := audio.Detect(data, opts.Filename, opts.ContentType)
+	if err != nil {
+		return nil, format, false, err
+	}
+	if duration, ok := audio.WAVDuration(data); ok && duration > m.maxAudioDuration() {
+		return nil, format, false, fmt.Errorf("%w: %s, the limit is %s", audio.ErrTooLong,
+			duration.Round(time.Second), m.maxAudioDuration())
+	}
+	if format.Supported {
+		return data, format, false, nil
+	}
+	transcoder := &audio.Transcoder{Command: m.Config.Voice.TranscodeCommand}
+	data, err = transcoder.Transcode(context.Background(), data, format)
+	if err != nil {
+		return nil, format, false, err
+	}
+	return data, audio.MP3, true, nil
+}
+
+// Transcribe converts the recording to text, using Whisper.
+func (m *Majordomo) Transcribe(r io.Reader, opts TranscriptionOptions) (*Transcription, error) {
+	data, format, transcoded, err := m.ReadAudio(r, opts)
+	if err != nil {
+		return nil, err
+	}
+	if m.Client == nil {
+		return nil, fmt.Errorf("OpenAI client not initialized")
+	}
+	if opts.Language == "" {
+		opts.Language = m.Config.Voice.Language
+	}
+	if opts.Prompt == "" {
+		opts.Prompt = m.Config.Voice.Prompt
+	}
+	request := openai.AudioRequest{
+		Model: openai.Whisper1,
+		// The extension is how the API knows the format.
+		FilePath: "audio." + format.Extension,
+		Reader:   bytes.NewReader(data),
+		Prompt:   opts.Prompt,
+		Format:   openai.AudioResponseFormatVerboseJSON,
+	}
+	var resp openai.AudioResponse
+	if opts.Translate {
+		resp, err = m.Client.CreateTranslation(context.Background(), request)
+	} else {
+		request.Language = opts.Language
+		request.TimestampGranularities = []openai.TranscriptionTimestampGranularity{
+			openai.TranscriptionTimestampGranularitySegment,
+		}
+		resp, err = m.Client.CreateTranscription(context.Background(), request)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error converting audio to text: %v", err)
+	}
+	duration := time.Duration(resp.Duration * float64(time.Second))
+	if duration > m.maxAudioDuration() {
+		return nil, fmt.Errorf("%w: %s, the limit is %s", audio.ErrTooLong,
+			duration.Round(time.Second), m.maxAudioDuration())
+	}
+	transcription := &Transcription{
+		Text:       resp.Text,
+		Language:   resp.Language,
+		Duration:   resp.Duration,
+		Format:     format.Name,
+		Translated: opts.Translate,
+		Transcoded: transcoded,
+		Segments:   make([]Segment, 0, len(resp.Segments)),
+	}
+	for _, s := range resp.Segments {
+		transcription.Segments = append(transcription.Segments, Segment{Start: s.Start, End: s.End, Text: s.Text})
+	}
+	log.Debug().
+		Str("format", format.Name).
+		Int("size", len(data)).
+		Float64("duration", resp.Duration).
+		Str("language", resp.Language).
+		Bool("translated", opts.Translate).
+		Msg("audio transcribed")
+	return transcription, nil
+}
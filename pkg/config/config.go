@@ -9,6 +9,7 @@ import (
 	"gopkg.in/yaml.v3"
 	"os"
 	"path"
+	"time"
 )
 
 const LocationEnv = "MAJORDOMO_CONFIG"
@@ -16,14 +17,32 @@ const CodeLocationEnv = "MAJORDOMO_CODE"
 
 var DefaultConfigLocation = os.Getenv("HOME") + "/.majordomo/config.yaml"
 var DefaultCodeSnippetsLocation = os.Getenv("HOME") + "/.majordomo/code"
+var DefaultSpeechCacheLocation = os.Getenv("HOME") + "/.majordomo/speech"
 
 type Project struct {
+	// ID identifies the project, and does not change when it is renamed: the
+	// conversations and the code snippets stores are kept by ID.
+	// It is the project's name, if not set (as in the configurations written
+	// before IDs were introduced).
+	ID          string `yaml:"id,omitempty" json:"id"`
 	Name        string `yaml:"name" json:"name"`
 	Description string `yaml:"description,omitempty" json:"description,omitempty"`
 	Location    string `yaml:"location" json:"location"`
 	// User-configured location for the code snippets for this project.
 	// It is never overwritten by the system.
 	CodeSnippets string `yaml:"code_snippets,omitempty" json:"code_snippets,omitempty"`
+	// TestCommand is the command (and its arguments) used to run the project's
+	// tests, the packages to test are appended to it; `go test` if not set.
+	// It is run on the server, so it can only be set in the configuration
+	// file, never via the API.
+	TestCommand []string `yaml:"test_command,omitempty" json:"-"`
+
+	// Include and Exclude are globs (relative to the project's location) which
+	// restrict the files that can be sent to, or saved from, the LLM.
+	// If Include is empty, all files are included; secrets (`.env`, private keys)
+	// and the `.git` folder are always excluded.
+	Include []string `yaml:"include,omitempty" json:"include,omitempty"`
+	Exclude []string `yaml:"exclude,omitempty" json:"exclude,omitempty"`
 
 	// Resolved path for code snippets for the project.
 	// This is what the system uses, but is not written to the config file.
@@ -35,6 +54,183 @@ func (p Project) String() string {
 	return fmt.Sprintf("Project [Name: %s, Description: %s, Location: %s]", p.Name, p.Description, p.Location)
 }
 
+// SecretPattern is a named regular expression matching a secret.
+type SecretPattern struct {
+	Name  string `yaml:"name"`
+	Regex string `yaml:"regex"`
+}
+
+// SecretsConfig configures the detection and redaction of secrets in the
+// prompts sent to the LLM.
+type SecretsConfig struct {
+	// Disabled turns off the secrets scanner altogether.
+	Disabled bool `yaml:"disabled,omitempty"`
+
+	// EntropyThreshold is the minimum entropy (bits per character) for a
+	// string to be considered a secret; a sensible default is used if zero.
+	EntropyThreshold float64 `yaml:"entropy_threshold,omitempty"`
+
+	// Patterns are custom rules, in addition to the built-in ones.
+	Patterns []SecretPattern `yaml:"patterns,omitempty"`
+}
+
+// VoiceConfig configures the spoken commands sent to `/command`.
+type VoiceConfig struct {
+	// DefaultAssistant is the assistant which answers the spoken requests,
+	// unless a different one is chosen when starting a new conversation.
+	DefaultAssistant string `yaml:"default_assistant,omitempty"`
+
+	// DisableLLMFallback stops sending the requests which do not match any
+	// of the Commands to the LLM to classify: they are all sent to the
+	// assistant instead.
+	DisableLLMFallback bool `yaml:"disable_llm_fallback,omitempty"`
+
+	// Commands are, for each intent, the templates of the spoken commands
+	// (e.g., "open project {project}"); they are matched before the built-in ones.
+	Commands map[string][]string `yaml:"commands,omitempty"`
+
+	// MaxAudioBytes and MaxAudioSeconds limit the size and the duration of
+	// the recordings; respectively 25MB (the transcription API's limit) and
+	// 5 minutes if not set.
+	MaxAudioBytes   int64   `yaml:"max_audio_bytes,omitempty"`
+	MaxAudioSeconds float64 `yaml:"max_audio_seconds,omitempty"`
+
+	// Language (ISO-639-1, e.g. "en") and Prompt (e.g., the names of the
+	// projects) are hints to improve the accuracy of the transcription.
+	Language string `yaml:"language,omitempty"`
+	Prompt   string `yaml:"prompt,omitempty"`
+
+	// TranscodeCommand converts the recordings which cannot be transcribed
+	// as they are (e.g., AIFF) to MP3; `{input}` and `{output}` are replaced
+	// by the paths of the files.
+	TranscodeCommand []string `yaml:"transcode_command,omitempty"`
+}
+
+// SpeechConfig configures the conversion of the assistant's replies to audio.
+type SpeechConfig struct {
+	// Model is the text-to-speech model; `tts-1` if not set.
+	Model string `yaml:"model,omitempty"`
+
+	// Voice, Format and Speed are the defaults, if not specified in the
+	// request; respectively `alloy`, `mp3` and 1.0 if not set.
+	Voice  string  `yaml:"voice,omitempty"`
+	Format string  `yaml:"format,omitempty"`
+	Speed  float64 `yaml:"speed,omitempty"`
+
+	// CacheDir is where the audio files are kept, so that the same text is
+	// converted only once; `$HOME/.majordomo/speech` if not set.
+	CacheDir string `yaml:"cache_dir,omitempty"`
+}
+
+// OpenAIConfig configures the retries of the failed OpenAI API requests, and
+// the polling of the Runs.
+type OpenAIConfig struct {
+	// MaxRetries is how many times the requests failed with a 429 or 5xx
+	// status are retried; 3 if not set, and a negative value disables retries.
+	MaxRetries int `yaml:"max_retries,omitempty"`
+
+	// InitialBackoff is the delay before the first retry, doubled (with
+	// jitter) at each one, up to MaxBackoff; respectively 500ms and 30s if not
+	// set. Requests the API asks to retry after more than MaxBackoff fail.
+	InitialBackoff time.Duration `yaml:"initial_backoff,omitempty"`
+	MaxBackoff     time.Duration `yaml:"max_backoff,omitempty"`
+
+	// After BreakerThreshold consecutive failed requests, the API is
+	// considered down and requests fail immediately, for BreakerCooldown;
+	// respectively 5 and 30s if not set, and a negative threshold disables it.
+	BreakerThreshold int           `yaml:"breaker_threshold,omitempty"`
+	BreakerCooldown  time.Duration `yaml:"breaker_cooldown,omitempty"`
+
+	// PollInterval is the delay between checks of a Run's progress, growing
+	// by half at each check, up to MaxPollInterval; respectively 1s and 10s
+	// if not set.
+	PollInterval    time.Duration `yaml:"poll_interval,omitempty"`
+	MaxPollInterval time.Duration `yaml:"max_poll_interval,omitempty"`
+}
+
+// TracingConfig configures the export of the OpenTelemetry traces.
+type TracingConfig struct {
+	// Enabled turns on the export of the traces; the trace context of the
+	// incoming requests is propagated regardless.
+	Enabled bool `yaml:"enabled,omitempty"`
+
+	// Endpoint is the host:port of the OTLP/HTTP collector; `localhost:4318`
+	// if not set.
+	Endpoint string `yaml:"endpoint,omitempty"`
+
+	// Insecure sends the traces over plain HTTP (e.g., to a local collector).
+	Insecure bool `yaml:"insecure,omitempty"`
+
+	// ServiceName identifies the server in the traces; `majordomo` if not set.
+	ServiceName string `yaml:"service_name,omitempty"`
+
+	// SampleRatio is the fraction of the traces which are sampled, between 0
+	// and 1; all of them if not set.
+	SampleRatio float64 `yaml:"sample_ratio,omitempty"`
+}
+
+// AuditConfig configures the audit log of the prompts sent to the LLM.
+type AuditConfig struct {
+	// Location is the path of the JSONL audit log (environment variables,
+	// e.g. $HOME, are expanded); if not set, the interactions are not audited.
+	Location string `yaml:"location,omitempty"`
+
+	// MaxSizeMB is the size after which the log is rotated, and MaxFiles the
+	// number of rotated files kept; respectively 100 and 10 if not set.
+	MaxSizeMB int `yaml:"max_size_mb,omitempty"`
+	MaxFiles  int `yaml:"max_files,omitempty"`
+
+	// HashChain chains each entry to the previous one, with a SHA-256 hash,
+	// so that tampering with the log is evident.
+	HashChain bool `yaml:"hash_chain,omitempty"`
+}
+
+// CassetteConfig records the interactions with the OpenAI API, or replays
+// them, for the tests; see the cassette package.
+type CassetteConfig struct {
+	// Mode is either `record` or `replay`; if not set, the interactions are
+	// neither recorded nor replayed.
+	Mode string `yaml:"mode,omitempty"`
+
+	// Location is the path of the cassette file.
+	Location string `yaml:"location,omitempty"`
+}
+
+// TLSConfig enables HTTPS and, optionally, the authentication of the clients
+// with their certificates (mTLS).
+type TLSConfig struct {
+	// CertFile and KeyFile are the paths of the server's certificate and key,
+	// in PEM format; if not set, the server uses plain HTTP.
+	CertFile string `yaml:"cert_file,omitempty"`
+	KeyFile  string `yaml:"key_file,omitempty"`
+
+	// ClientCAFile is the path of the CA certificates (PEM) which signed the
+	// clients' certificates; if set, the clients must present one.
+	ClientCAFile string `yaml:"client_ca_file,omitempty"`
+}
+
+// ServerConfig configures the HTTP server.
+type ServerConfig struct {
+	// ReadHeaderTimeout, ReadTimeout, WriteTimeout and IdleTimeout bound the
+	// time spent on each connection; respectively 10s, 1m, 5m (prompts can
+	// take minutes to complete) and 2m if not set. Fixing the tests is not
+	// bound by WriteTimeout.
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout,omitempty"`
+	ReadTimeout       time.Duration `yaml:"read_timeout,omitempty"`
+	WriteTimeout      time.Duration `yaml:"write_timeout,omitempty"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout,omitempty"`
+
+	// MaxBodyBytes is the largest request body accepted; 32MB if not set
+	// (the transcription API accepts recordings of up to 25MB).
+	MaxBodyBytes int64 `yaml:"max_body_bytes,omitempty"`
+
+	// ShutdownTimeout is how long the in-flight requests, and the running
+	// prompt jobs, are waited for on shutdown; 1m if not set.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout,omitempty"`
+
+	TLS TLSConfig `yaml:"tls,omitempty"`
+}
+
 type Config struct {
 	// LoadedFrom is the path from which the Config was loaded.
 	LoadedFrom string `yaml:"-"`
@@ -50,9 +246,25 @@ type Config struct {
 	// TODO: not supported yet (see #18)
 	AssistantsLocation string `yaml:"assistants"`
 
+	// AssistantsTTL is how long the list of OpenAI Assistants is cached
+	// (e.g., "10m", the default if not set).
+	AssistantsTTL time.Duration `yaml:"assistants_ttl,omitempty"`
+
 	// ThreadsLocation is the path to the directory where the conversations are stored.
 	ThreadsLocation string `yaml:"threads_location"`
 
+	// JobsLocation is the path to the file where the prompt jobs are stored,
+	// so that they are resumed after a restart; if not set, they are only
+	// kept in memory.
+	JobsLocation string `yaml:"jobs_location,omitempty"`
+
+	// JobWorkers is the maximum number of prompt jobs run concurrently; 2 if not set.
+	JobWorkers int `yaml:"job_workers,omitempty"`
+
+	// JobRetention is how long the finished prompt jobs are kept; 24 hours
+	// if not set.
+	JobRetention time.Duration `yaml:"job_retention,omitempty"`
+
 	// CodeSnippetsDir is the name of the directory, inside each respective
 	// project's location, where the code snippets are stored.
 	CodeSnippetsDir string `yaml:"code_snippets"`
@@ -66,6 +278,36 @@ type Config struct {
 
 	// Projects is a list of projects that are configured in the system.
 	Projects []Project `yaml:"projects"`
+
+	// MaxFixIterations is the maximum number of times the tests are re-run,
+	// and the failures sent to the LLM, when fixing failing tests.
+	MaxFixIterations int `yaml:"max_fix_iterations,omitempty"`
+
+	// Secrets configures the redaction of secrets before prompts are sent to the LLM.
+	Secrets SecretsConfig `yaml:"secrets,omitempty"`
+
+	// Voice configures the spoken commands.
+	Voice VoiceConfig `yaml:"voice,omitempty"`
+
+	// Speech configures the text-to-speech conversion of the replies.
+	Speech SpeechConfig `yaml:"speech,omitempty"`
+
+	// OpenAI configures the retries, and the polling, of the OpenAI API.
+	OpenAI OpenAIConfig `yaml:"openai,omitempty"`
+
+	// Tracing configures the OpenTelemetry traces.
+	Tracing TracingConfig `yaml:"tracing,omitempty"`
+
+	// Audit configures the audit log of the interactions with the LLM.
+	Audit AuditConfig `yaml:"audit,omitempty"`
+
+	// Cassette records, or replays, the interactions with the OpenAI API;
+	// it can also be set with the MAJORDOMO_CASSETTE_MODE and
+	// MAJORDOMO_CASSETTE environment variables.
+	Cassette CassetteConfig `yaml:"cassette,omitempty"`
+
+	// Server configures the HTTP server.
+	Server ServerConfig `yaml:"server,omitempty"`
 }
 
 // Save writes the Config to a YAML file at the given filePath.
@@ -138,25 +380,53 @@ func LoadConfig(filepath string) (*Config, error) {
 		}
 	}
 
-	// Resolve the actual code snippets directory for each project.
-	for i, _ := range c.Projects {
+	// The IDs set explicitly take precedence over the defaulted ones, which
+	// are assigned once all the former are known.
+	for i := range c.Projects {
 		p := &c.Projects[i]
-		// By default, we use the global code snippets directory.
-		var cs = c.CodeSnippetsDir
-		if p.CodeSnippets != "" {
-			// If the project has a code snippets directory configured, we use that.
-			cs = p.CodeSnippets
-		}
-		if !path.IsAbs(cs) {
-			// If the path is not absolute, we assume it is relative to the project's location.
-			p.ResolvedCodeSnippetsDir = path.Join(p.Location, cs)
-		} else {
-			p.ResolvedCodeSnippetsDir = cs
+		if p.ID == "" {
+			p.ID = c.NewProjectID(p.Name)
 		}
+		c.ResolveProject(p)
 	}
 	return &c, nil
 }
 
+// ResolveProject sets the actual code snippets directory of the project.
+func (c *Config) ResolveProject(p *Project) {
+	// By default, we use the global code snippets directory.
+	var cs = c.CodeSnippetsDir
+	if p.CodeSnippets != "" {
+		// If the project has a code snippets directory configured, we use that.
+		cs = p.CodeSnippets
+	}
+	if !path.IsAbs(cs) {
+		// If the path is not absolute, we assume it is relative to the project's location.
+		p.ResolvedCodeSnippetsDir = path.Join(p.Location, cs)
+	} else {
+		p.ResolvedCodeSnippetsDir = cs
+	}
+}
+
+// NewProjectID returns the ID for a new project named `name`: the name
+// itself, unless another project (e.g., one since renamed) already uses it.
+func (c *Config) NewProjectID(name string) string {
+	id := name
+	for n := 2; c.GetProjectByID(id) != nil; n++ {
+		id = fmt.Sprintf("%s-%d", name, n)
+	}
+	return id
+}
+
+// ProjectID returns the ID of the project named `name` or, if there is no
+// such project, `name` itself.
+func (c *Config) ProjectID(name string) string {
+	if p := c.GetProject(name); p != nil && p.ID != "" {
+		return p.ID
+	}
+	return name
+}
+
 func (c *Config) GetProject(name string) *Project {
 	for _, p := range c.Projects {
 		if p.Name == name {
@@ -166,6 +436,16 @@ func (c *Config) GetProject(name string) *Project {
 	return nil
 }
 
+// GetProjectByID returns the project with the given ID, or nil if there is none.
+func (c *Config) GetProjectByID(id string) *Project {
+	for _, p := range c.Projects {
+		if p.ID == id {
+			return &p
+		}
+	}
+	return nil
+}
+
 func (c *Config) GetActiveProject() *Project {
 	return c.GetProject(c.ActiveProject)
 }
@@ -8,6 +8,7 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"os"
+	"time"
 
 	"github.com/alertavert/gpt4-go/pkg/config"
 )
@@ -32,6 +33,7 @@ var _ = Describe("Config", func() {
 				Expect(c.OpenAIApiKey).To(Equal("test-key"))
 				Expect(c.AssistantsLocation).To(HaveSuffix("test/assistants.yaml"))
 				Expect(c.CodeSnippetsDir).To(Equal(".majordomo"))
+				Expect(c.AssistantsTTL).To(Equal(5 * time.Minute))
 			})
 			It("should expand relative paths", func() {
 				c, err := config.LoadConfig(testConfigLocation)
@@ -56,6 +58,33 @@ var _ = Describe("Config", func() {
 				Expect(project2.Location).To(Equal("test/location-2"))
 				Expect(project2.Description).To(Equal("test-description-2"))
 			})
+			It("should default the projects' IDs to their names", func() {
+				c, err := config.LoadConfig(testConfigProjectsLocation)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(c.Projects[0].ID).To(Equal("test-project"))
+				Expect(c.ProjectID("test-project-2")).To(Equal("test-project-2"))
+				Expect(c.GetProjectByID("test-project-2").Name).To(Equal("test-project-2"))
+				// Both "test-project" and "test-project-2" are taken.
+				Expect(c.NewProjectID("test-project")).To(Equal("test-project-3"))
+			})
+			It("should keep the IDs of renamed projects", func() {
+				c := &config.Config{
+					CodeSnippetsDir: ".snippets",
+					Projects: []config.Project{
+						{Name: "reused", Location: "test/location"},
+						{ID: "reused", Name: "renamed", Location: "test/location-2"},
+					},
+				}
+				filepath := os.TempDir() + "/config-ids.yaml"
+				Expect(c.Save(filepath)).To(Succeed())
+				defer os.Remove(filepath)
+
+				loaded, err := config.LoadConfig(filepath)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(loaded.ProjectID("renamed")).To(Equal("reused"))
+				Expect(loaded.ProjectID("reused")).To(Equal("reused-2"))
+				Expect(loaded.ProjectID("missing")).To(Equal("missing"))
+			})
 
 		})
 	})
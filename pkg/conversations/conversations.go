@@ -4,8 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 	"sync"
 
+	"github.com/alertavert/gpt4-go/pkg/api"
 	"github.com/alertavert/gpt4-go/pkg/config"
 	"github.com/rs/zerolog/log"
 )
@@ -13,12 +15,7 @@ import (
 // Thread models simply the ID and name of the Thread.
 // The ID is used to retrieve the Thread from the OpenAI API; while the name is
 // used to display the Thread in the UI.
-type Thread struct {
-	ID          string `json:"id"`
-	Name        string `json:"name"`
-	Assistant   string `json:"assistant"`
-	Description string `json:"description"`
-}
+type Thread = api.Thread
 
 // ValidationError provides more context about what field failed validation
 type ValidationError struct {
@@ -30,7 +27,8 @@ func (e ValidationError) Error() string {
 	return fmt.Sprintf("validation failed for %s: %s", e.Field, e.Message)
 }
 
-func (thread Thread) Validate() error {
+// validate checks that the thread can be stored.
+func validate(thread Thread) error {
 	if thread.ID == "" && thread.Name == "" {
 		return ValidationError{
 			Field:   "ID/Name",
@@ -46,9 +44,18 @@ func (thread Thread) Validate() error {
 	return nil
 }
 
-// ThreadsMap is a map of Project names to their respective Threads.
+// ThreadsMap is a map of Project IDs to their respective Threads; the
+// threads of the deleted projects are kept under their ArchiveKey.
 type ThreadsMap map[string][]Thread
 
+// archivePrefix cannot be part of the projects' IDs, as they cannot contain a `/`.
+const archivePrefix = "archived/"
+
+// ArchiveKey is the key of the archived threads of the project.
+func ArchiveKey(projectId string) string {
+	return archivePrefix + projectId
+}
+
 // ThreadStore encapsulates behavior for managing and persisting conversations.
 type ThreadStore struct {
 	location   string
@@ -81,31 +88,92 @@ func NewThreadStore(cfg *config.Config) *ThreadStore {
 }
 
 // AddThread adds a new thread to the thread map and persists the map to storage.
-func (ts *ThreadStore) AddThread(projectName string, thread Thread) error {
-	if err := thread.Validate(); err != nil {
+func (ts *ThreadStore) AddThread(projectId string, thread Thread) error {
+	if err := validate(thread); err != nil {
 		log.Error().Err(err).Msg("Invalid thread data")
 		return err
 	}
 	ts.mu.Lock()
 	defer ts.mu.Unlock()
 	// TODO: do we need to check first if the key exists in the map?
-	ts.threadsMap[projectName] = append(ts.threadsMap[projectName], thread)
+	ts.threadsMap[projectId] = append(ts.threadsMap[projectId], thread)
+	return ts.save()
+}
+
+func (ts *ThreadStore) GetAllThreads(projectId string) []Thread {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return ts.threadsMap[projectId]
+}
+
+// Counts returns the number of threads of each project, by ID; the archived
+// threads are not counted.
+func (ts *ThreadStore) Counts() map[string]int {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	counts := make(map[string]int, len(ts.threadsMap))
+	for project, threads := range ts.threadsMap {
+		if strings.HasPrefix(project, archivePrefix) {
+			continue
+		}
+		counts[project] = len(threads)
+	}
+	return counts
+}
+
+// ArchiveProject moves all the threads of the project to its ArchiveKey,
+// and returns how many they were.
+func (ts *ThreadStore) ArchiveProject(projectId string) (int, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	threads, found := ts.threadsMap[projectId]
+	if !found {
+		return 0, nil
+	}
+	delete(ts.threadsMap, projectId)
+	if len(threads) > 0 {
+		key := ArchiveKey(projectId)
+		ts.threadsMap[key] = append(ts.threadsMap[key], threads...)
+	}
+	return len(threads), ts.save()
+}
+
+// ArchiveThreads adds the threads to the archived ones of the project.
+func (ts *ThreadStore) ArchiveThreads(projectId string, threads []Thread) error {
+	if len(threads) == 0 {
+		return nil
+	}
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	key := ArchiveKey(projectId)
+	ts.threadsMap[key] = append(ts.threadsMap[key], threads...)
 	return ts.save()
 }
 
-func (ts *ThreadStore) GetAllThreads(projectName string) []Thread {
+// ArchivedThreads returns the archived threads of the project.
+func (ts *ThreadStore) ArchivedThreads(projectId string) []Thread {
+	return ts.GetAllThreads(ArchiveKey(projectId))
+}
+
+// RemoveProject removes all the threads of the project, and returns them.
+func (ts *ThreadStore) RemoveProject(projectId string) ([]Thread, error) {
 	ts.mu.Lock()
 	defer ts.mu.Unlock()
-	return ts.threadsMap[projectName]
+	threads, found := ts.threadsMap[projectId]
+	if !found {
+		return nil, nil
+	}
+	delete(ts.threadsMap, projectId)
+	return threads, ts.save()
 }
 
 // GetThread retrieves a specific thread from a project by its ID.
 // Returns the thread and true if found, or an empty thread and false if not found.
-func (ts *ThreadStore) GetThread(projectName string, threadID string) (Thread, bool) {
+func (ts *ThreadStore) GetThread(projectId string, threadID string) (Thread, bool) {
 	ts.mu.Lock()
 	defer ts.mu.Unlock()
 
-	threads := ts.threadsMap[projectName]
+	threads := ts.threadsMap[projectId]
 	for _, thread := range threads {
 		if thread.ID == threadID {
 			return thread, true
@@ -116,15 +184,15 @@ func (ts *ThreadStore) GetThread(projectName string, threadID string) (Thread, b
 
 // RemoveThread removes a specific thread from a project.
 // Returns true if the thread was found and removed, false otherwise.
-func (ts *ThreadStore) RemoveThread(projectName string, threadID string) (bool, error) {
+func (ts *ThreadStore) RemoveThread(projectId string, threadID string) (bool, error) {
 	ts.mu.Lock()
 	defer ts.mu.Unlock()
 
-	threads := ts.threadsMap[projectName]
+	threads := ts.threadsMap[projectId]
 	for i, thread := range threads {
 		if thread.ID == threadID {
 			// Remove the thread by slicing
-			ts.threadsMap[projectName] = append(threads[:i], threads[i+1:]...)
+			ts.threadsMap[projectId] = append(threads[:i], threads[i+1:]...)
 			return true, ts.save()
 		}
 	}
@@ -158,20 +226,37 @@ func (ts *ThreadStore) load() error {
 }
 
 // save persists the current state of the conversations map to the disk.
+// The conversations are written to a temporary file first, and synced to
+// disk, so that a crash (or a kill) never leaves a truncated file behind.
 func (ts *ThreadStore) save() error {
-	file, err := os.Create(ts.location)
+	tmp := ts.location + ".tmp"
+	file, err := os.Create(tmp)
 	if err != nil {
 		log.Error().Err(err).
 			Str("location", ts.location).
 			Msg("Error while saving conversations")
 		return err
 	}
-	defer func() {
-		if err := file.Close(); err != nil {
-			log.Error().Err(err).
-				Str("location", ts.location).
-				Msg("Error while closing saved conversations file")
-		}
-	}()
-	return json.NewEncoder(file).Encode(ts.threadsMap)
+	err = json.NewEncoder(file).Encode(ts.threadsMap)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Error().Err(err).
+			Str("location", ts.location).
+			Msg("Error while writing saved conversations file")
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, ts.location)
+}
+
+// Flush persists the conversations to storage.
+func (ts *ThreadStore) Flush() error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return ts.save()
 }
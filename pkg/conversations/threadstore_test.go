@@ -158,4 +158,36 @@ var _ = Describe("ThreadStore", func() {
 			Expect(removed).To(BeFalse())
 		})
 	})
+
+	Describe("ArchiveProject and RemoveProject", func() {
+		It("should archive the threads of a project, and persist them", func() {
+			Expect(threadStore.AddThread(projectName, testThread)).To(Succeed())
+			Expect(threadStore.AddThread("OtherProject", testThread)).To(Succeed())
+
+			archived, err := threadStore.ArchiveProject(projectName)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(archived).To(Equal(1))
+			Expect(threadStore.GetAllThreads(projectName)).To(BeEmpty())
+			Expect(threadStore.ArchivedThreads(projectName)).To(ConsistOf(testThread))
+			Expect(threadStore.Counts()).To(Equal(map[string]int{"OtherProject": 1}))
+
+			reloaded := conversations.NewThreadStore(testConfig)
+			Expect(reloaded.ArchivedThreads(projectName)).To(ConsistOf(testThread))
+			Expect(reloaded.GetAllThreads(projectName)).To(BeEmpty())
+		})
+
+		It("should remove the threads of a project, and return them", func() {
+			Expect(threadStore.AddThread(projectName, testThread)).To(Succeed())
+
+			removed, err := threadStore.RemoveProject(projectName)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(removed).To(ConsistOf(testThread))
+			Expect(threadStore.GetAllThreads(projectName)).To(BeEmpty())
+			Expect(threadStore.ArchivedThreads(projectName)).To(BeEmpty())
+
+			removed, err = threadStore.RemoveProject(projectName)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(removed).To(BeEmpty())
+		})
+	})
 })
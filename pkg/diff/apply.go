@@ -0,0 +1,214 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// MaxFuzz is the maximum number of context lines which can be dropped from
+	// either end of an Edit, when it cannot be found with its full context.
+	MaxFuzz = 2
+	// MinSimilarity is the minimum fraction of lines which must match, for an
+	// Edit to be applied where the Search lines are only similar to the source.
+	MinSimilarity = 0.8
+)
+
+// Edit replaces the Search lines with the Replace ones.
+type Edit struct {
+	Search  []string
+	Replace []string
+	// Hint is the (0-based) line where Search is expected to be found in the
+	// source, or -1 if unknown.
+	Hint int
+	// LeadingContext and TrailingContext are the numbers of lines, at either
+	// end of both Search and Replace, which are unchanged context.
+	LeadingContext  int
+	TrailingContext int
+}
+
+// Failure reports an Edit which could not be applied.
+type Failure struct {
+	// Edit is the (0-based) index of the Edit.
+	Edit   int
+	Reason string
+}
+
+// Apply applies the edits, in order, to the source; the edits which cannot be
+// applied are skipped, and reported in the returned Failures.
+//
+// The Search lines are looked up, in order of preference: exactly; ignoring
+// leading and trailing whitespace; dropping up to MaxFuzz lines of context;
+// and, finally, looking for the (unique) most similar lines, if at least
+// MinSimilarity of them match.
+func Apply(source string, edits []Edit) (string, []Failure) {
+	lines := Lines(source)
+	var failures []Failure
+	// The lines added (or removed) by the edits applied so far shift the hints.
+	offset := 0
+	for i, e := range edits {
+		hint := e.Hint
+		if hint >= 0 {
+			hint += offset
+		}
+		pos, n, e, err := find(lines, e, hint)
+		if err != nil {
+			failures = append(failures, Failure{Edit: i, Reason: err.Error()})
+			continue
+		}
+		updated := make([]string, 0, len(lines)-n+len(e.Replace))
+		updated = append(updated, lines[:pos]...)
+		updated = append(updated, e.Replace...)
+		lines = append(updated, lines[pos+n:]...)
+		offset += len(e.Replace) - n
+	}
+	if len(lines) == 0 {
+		return "", failures
+	}
+	return strings.Join(lines, "\n") + "\n", failures
+}
+
+// find returns the position and number of the lines matching the edit's
+// Search, and the edit itself, with any context dropped to find the match.
+func find(lines []string, e Edit, hint int) (int, int, Edit, error) {
+	if len(e.Search) == 0 {
+		switch {
+		case len(lines) == 0:
+			return 0, 0, e, nil
+		case hint >= 0 && hint <= len(lines):
+			return hint, 0, e, nil
+		}
+		return 0, 0, e, fmt.Errorf("nothing to search for")
+	}
+	for _, equal := range []func(a, b string) bool{exactly, ignoringSpace} {
+		for fuzz := 0; fuzz <= MaxFuzz; fuzz++ {
+			fe, ok := dropContext(e, fuzz)
+			if !ok {
+				break
+			}
+			if pos := closest(matches(lines, fe.Search, equal), hint); pos >= 0 {
+				return pos, len(fe.Search), fe, nil
+			}
+		}
+	}
+	pos, err := mostSimilar(lines, e.Search, hint)
+	if err != nil {
+		return 0, 0, e, err
+	}
+	return pos, len(e.Search), e, nil
+}
+
+func exactly(a, b string) bool { return a == b }
+
+func ignoringSpace(a, b string) bool { return strings.TrimSpace(a) == strings.TrimSpace(b) }
+
+// dropContext removes up to `fuzz` context lines from either end of the edit;
+// it returns false if there isn't enough context to drop.
+func dropContext(e Edit, fuzz int) (Edit, bool) {
+	if fuzz == 0 {
+		return e, true
+	}
+	lead, trail := min(fuzz, e.LeadingContext), min(fuzz, e.TrailingContext)
+	// At least one line must remain, to anchor the edit.
+	if lead+trail == 0 || lead+trail >= len(e.Search) {
+		return e, false
+	}
+	e.Search = e.Search[lead : len(e.Search)-trail]
+	e.Replace = e.Replace[lead : len(e.Replace)-trail]
+	e.LeadingContext -= lead
+	e.TrailingContext -= trail
+	return e, true
+}
+
+// matches returns the positions where `search` is found in `lines`.
+func matches(lines, search []string, equal func(a, b string) bool) []int {
+	var found []int
+	for pos := 0; pos+len(search) <= len(lines); pos++ {
+		match := true
+		for j := range search {
+			if !equal(lines[pos+j], search[j]) {
+				match = false
+				break
+			}
+		}
+		if match {
+			found = append(found, pos)
+		}
+	}
+	return found
+}
+
+// closest returns the position closest to the hint or, if there is no hint,
+// the first one; -1 if there are none.
+func closest(positions []int, hint int) int {
+	if len(positions) == 0 {
+		return -1
+	}
+	if hint < 0 {
+		return positions[0]
+	}
+	best := positions[0]
+	for _, p := range positions[1:] {
+		if abs(p-hint) < abs(best-hint) {
+			best = p
+		}
+	}
+	return best
+}
+
+// mostSimilar finds the window of lines most similar to `search`, comparing
+// lines without their leading and trailing whitespace.
+func mostSimilar(lines, search []string, hint int) (int, error) {
+	normalize := func(ls []string) []string {
+		result := make([]string, len(ls))
+		for i, l := range ls {
+			result[i] = strings.TrimSpace(l)
+		}
+		return result
+	}
+	target := normalize(search)
+	normalized := normalize(lines)
+	var candidates []int
+	bestScore := 0.0
+	for pos := 0; pos+len(search) <= len(lines); pos++ {
+		score := similarity(normalized[pos:pos+len(search)], target)
+		switch {
+		case score > bestScore:
+			bestScore, candidates = score, []int{pos}
+		case score == bestScore && score > 0:
+			candidates = append(candidates, pos)
+		}
+	}
+	if bestScore < MinSimilarity {
+		return -1, fmt.Errorf("search lines not found")
+	}
+	if len(candidates) > 1 && hint < 0 {
+		return -1, fmt.Errorf("search lines are ambiguous: %d similar matches", len(candidates))
+	}
+	return closest(candidates, hint), nil
+}
+
+// similarity is the fraction of lines common to a and b.
+func similarity(a, b []string) float64 {
+	if len(a)+len(b) == 0 {
+		return 1
+	}
+	var equal int
+	for _, op := range Diff(a, b) {
+		if op.Kind == Equal {
+			equal++
+		}
+	}
+	return 2 * float64(equal) / float64(len(a)+len(b))
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
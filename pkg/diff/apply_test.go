@@ -0,0 +1,153 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package diff_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alertavert/gpt4-go/pkg/diff"
+)
+
+const source = `package main
+
+import "fmt"
+
+func main() {
+	fmt.Println("hello")
+}
+
+func helper() int {
+	return 42
+}
+`
+
+var _ = Describe("Apply", func() {
+	It("replaces the exact match", func() {
+		result, failures := diff.Apply(source, []diff.Edit{{
+			Search:  []string{"\treturn 42"},
+			Replace: []string{"\treturn 43"},
+			Hint:    -1,
+		}})
+		Expect(failures).To(BeEmpty())
+		Expect(result).To(ContainSubstring("return 43"))
+		Expect(result).NotTo(ContainSubstring("return 42"))
+	})
+	It("ignores differences in indentation", func() {
+		result, failures := diff.Apply(source, []diff.Edit{{
+			Search:  []string{"    fmt.Println(\"hello\")"},
+			Replace: []string{"\tfmt.Println(\"world\")"},
+			Hint:    -1,
+		}})
+		Expect(failures).To(BeEmpty())
+		Expect(result).To(ContainSubstring("\tfmt.Println(\"world\")\n"))
+	})
+	It("drops context which does not match", func() {
+		result, failures := diff.Apply(source, []diff.Edit{{
+			Search:          []string{"// not in the source", "func helper() int {", "\treturn 42", "}"},
+			Replace:         []string{"// not in the source", "func helper() int {", "\treturn 0", "}"},
+			Hint:            8,
+			LeadingContext:  2,
+			TrailingContext: 1,
+		}})
+		Expect(failures).To(BeEmpty())
+		Expect(result).To(ContainSubstring("\treturn 0\n"))
+		Expect(result).NotTo(ContainSubstring("not in the source"))
+	})
+	It("matches lines which are similar enough", func() {
+		result, failures := diff.Apply(source, []diff.Edit{{
+			Search:  []string{"func main() {", "\tfmt.Println(\"hello\")", "}", "", "func helper() int {", "\treturn 41", "}"},
+			Replace: []string{"func main() {}"},
+			Hint:    -1,
+		}})
+		Expect(failures).To(BeEmpty())
+		Expect(result).To(Equal("package main\n\nimport \"fmt\"\n\nfunc main() {}\n"))
+	})
+	It("reports the edits which cannot be applied, and applies the others", func() {
+		result, failures := diff.Apply(source, []diff.Edit{
+			{Search: []string{"func missing() {"}, Replace: []string{"func found() {"}, Hint: -1},
+			{Search: []string{"\treturn 42"}, Replace: []string{"\treturn 43"}, Hint: -1},
+		})
+		Expect(failures).To(HaveLen(1))
+		Expect(failures[0].Edit).To(Equal(0))
+		Expect(result).To(ContainSubstring("return 43"))
+	})
+	It("uses the hint to choose between identical matches", func() {
+		result, failures := diff.Apply("}\nfoo\n}\n", []diff.Edit{{
+			Search: []string{"}"}, Replace: []string{"end"}, Hint: 2,
+		}})
+		Expect(failures).To(BeEmpty())
+		Expect(result).To(Equal("}\nfoo\nend\n"))
+	})
+	It("creates a new file from an empty search", func() {
+		result, failures := diff.Apply("", []diff.Edit{{Replace: []string{"package main"}, Hint: -1}})
+		Expect(failures).To(BeEmpty())
+		Expect(result).To(Equal("package main\n"))
+	})
+})
+
+var _ = Describe("ParseSearchReplace", func() {
+	It("parses multiple blocks", func() {
+		edits, err := diff.ParseSearchReplace("<<<<<<< SEARCH\nold\n=======\nnew\nnewer\n>>>>>>> REPLACE\n" +
+			"some text\n<<<<<<< SEARCH\n=======\nappended\n>>>>>>> REPLACE\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(edits).To(HaveLen(2))
+		Expect(edits[0].Search).To(Equal([]string{"old"}))
+		Expect(edits[0].Replace).To(Equal([]string{"new", "newer"}))
+		Expect(edits[1].Search).To(BeEmpty())
+	})
+	It("rejects malformed blocks", func() {
+		_, err := diff.ParseSearchReplace("<<<<<<< SEARCH\nold\n>>>>>>> REPLACE\n")
+		Expect(err).To(HaveOccurred())
+		_, err = diff.ParseSearchReplace("<<<<<<< SEARCH\nold\n=======\nnew\n")
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("ParseUnified", func() {
+	It("parses the files and hunks", func() {
+		patches, err := diff.ParseUnified(`--- a/main.go
++++ b/main.go
+@@ -5,3 +5,3 @@ func main() {
+ func main() {
+-	fmt.Println("hello")
++	fmt.Println("world")
+ }
+
+--- /dev/null
++++ b/new.go
+@@ -0,0 +1 @@
++package main
+`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(patches).To(HaveLen(2))
+		Expect(patches[0].NewPath).To(Equal("main.go"))
+		Expect(patches[0].Edits).To(HaveLen(1))
+		hunk := patches[0].Edits[0]
+		Expect(hunk.Hint).To(Equal(4))
+		Expect(hunk.LeadingContext).To(Equal(1))
+		Expect(hunk.TrailingContext).To(Equal(1))
+		Expect(patches[1].OldPath).To(Equal(diff.DevNull))
+		Expect(patches[1].Edits[0].Search).To(BeEmpty())
+
+		result, failures := diff.Apply(source, patches[0].Edits)
+		Expect(failures).To(BeEmpty())
+		Expect(result).To(ContainSubstring("\tfmt.Println(\"world\")\n"))
+	})
+	It("parses diffs without file headers, or line numbers", func() {
+		patches, err := diff.ParseUnified("@@ ... @@\n-\treturn 42\n+\treturn 0\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(patches).To(HaveLen(1))
+		Expect(patches[0].NewPath).To(BeEmpty())
+		Expect(patches[0].Edits[0].Hint).To(Equal(-1))
+	})
+	It("recognizes the edit formats", func() {
+		Expect(diff.IsUnified("--- a/x\n+++ b/x\n")).To(BeTrue())
+		Expect(diff.IsUnified("@@ -1 +1 @@\n")).To(BeTrue())
+		Expect(diff.IsUnified("--- just a line\n")).To(BeFalse())
+		Expect(diff.IsSearchReplace("<<<<<<< SEARCH\n")).To(BeTrue())
+		Expect(diff.IsSearchReplace("package main\n")).To(BeFalse())
+	})
+})
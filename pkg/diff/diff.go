@@ -0,0 +1,185 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+// Package diff computes line-based differences between texts, and renders
+// them in the unified diff format.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultContext is the number of unchanged lines shown around each change.
+const DefaultContext = 3
+
+// OpKind is the kind of an edit operation.
+type OpKind int
+
+const (
+	Equal OpKind = iota
+	Delete
+	Insert
+)
+
+// Op is a single line of the edit script transforming `a` into `b`; AIndex and
+// BIndex are the (0-based) positions in `a` and `b` *before* the Op is applied.
+type Op struct {
+	Kind   OpKind
+	Line   string
+	AIndex int
+	BIndex int
+}
+
+// Lines splits the text into lines, without the trailing newlines.
+func Lines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// Diff computes the shortest edit script from `a` to `b`, using Myers' algorithm.
+func Diff(a, b []string) []Op {
+	n, m := len(a), len(b)
+	maxD := n + m
+	offset := maxD + 1
+	v := make([]int, 2*maxD+3)
+	var trace [][]int
+
+	for d := 0; d <= maxD; d++ {
+		trace = append(trace, append([]int{}, v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, a, b, offset)
+			}
+		}
+	}
+	// Unreachable: the edit script is at most n+m long.
+	return nil
+}
+
+func backtrack(trace [][]int, a, b []string, offset int) []Op {
+	var ops []Op
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			ops = append(ops, Op{Kind: Equal, Line: a[x-1], AIndex: x - 1, BIndex: y - 1})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				ops = append(ops, Op{Kind: Insert, Line: b[y-1], AIndex: x, BIndex: y - 1})
+			} else {
+				ops = append(ops, Op{Kind: Delete, Line: a[x-1], AIndex: x - 1, BIndex: y})
+			}
+		}
+		x, y = prevX, prevY
+	}
+	// The ops were collected backwards.
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// Unified renders the differences between the two texts in the unified diff
+// format, with `context` lines around each change; `fromName` and `toName` are
+// used in the header.
+// It returns an empty string if the texts are identical.
+func Unified(from, to, fromName, toName string, context int) string {
+	ops := Diff(Lines(from), Lines(to))
+	var sb strings.Builder
+	for _, h := range hunks(ops, context) {
+		if sb.Len() == 0 {
+			sb.WriteString(fmt.Sprintf("--- %s\n+++ %s\n", fromName, toName))
+		}
+		sb.WriteString(h.header())
+		for _, op := range h.ops {
+			switch op.Kind {
+			case Equal:
+				sb.WriteString(" ")
+			case Delete:
+				sb.WriteString("-")
+			case Insert:
+				sb.WriteString("+")
+			}
+			sb.WriteString(op.Line)
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
+
+// hunk is a group of changes, with their surrounding context.
+type hunk struct {
+	ops []Op
+}
+
+func (h hunk) header() string {
+	var aCount, bCount int
+	for _, op := range h.ops {
+		if op.Kind != Insert {
+			aCount++
+		}
+		if op.Kind != Delete {
+			bCount++
+		}
+	}
+	aStart, bStart := h.ops[0].AIndex, h.ops[0].BIndex
+	if aCount > 0 {
+		aStart++
+	}
+	if bCount > 0 {
+		bStart++
+	}
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@\n", aStart, aCount, bStart, bCount)
+}
+
+// hunks groups the changes, merging them when their contexts overlap.
+func hunks(ops []Op, context int) []hunk {
+	var result []hunk
+	start, end := -1, -1
+	for i, op := range ops {
+		if op.Kind == Equal {
+			continue
+		}
+		lo, hi := max(0, i-context), min(len(ops), i+context+1)
+		if start >= 0 && lo <= end {
+			end = hi
+			continue
+		}
+		if start >= 0 {
+			result = append(result, hunk{ops: ops[start:end]})
+		}
+		start, end = lo, hi
+	}
+	if start >= 0 {
+		result = append(result, hunk{ops: ops[start:end]})
+	}
+	return result
+}
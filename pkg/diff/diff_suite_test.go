@@ -0,0 +1,15 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+package diff_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Diff Suite")
+}
@@ -0,0 +1,89 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package diff_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alertavert/gpt4-go/pkg/diff"
+)
+
+// apply re-creates `b` from the edit script, to verify it is correct.
+func apply(ops []diff.Op) []string {
+	var result []string
+	for _, op := range ops {
+		if op.Kind != diff.Delete {
+			result = append(result, op.Line)
+		}
+	}
+	return result
+}
+
+var _ = Describe("Diff", func() {
+	It("returns only Equal ops for identical texts", func() {
+		a := diff.Lines("one\ntwo\nthree\n")
+		ops := diff.Diff(a, a)
+		Expect(ops).To(HaveLen(3))
+		for _, op := range ops {
+			Expect(op.Kind).To(Equal(diff.Equal))
+		}
+	})
+	It("produces a correct, minimal edit script", func() {
+		a := strings.Split("a b c a b b a", " ")
+		b := strings.Split("c b a b a c", " ")
+		ops := diff.Diff(a, b)
+		Expect(apply(ops)).To(Equal(b))
+		changes := 0
+		for _, op := range ops {
+			if op.Kind != diff.Equal {
+				changes++
+			}
+		}
+		Expect(changes).To(Equal(5))
+	})
+	It("handles empty inputs", func() {
+		Expect(diff.Diff(nil, nil)).To(BeEmpty())
+		Expect(apply(diff.Diff(nil, []string{"x", "y"}))).To(Equal([]string{"x", "y"}))
+		Expect(apply(diff.Diff([]string{"x"}, nil))).To(BeEmpty())
+	})
+})
+
+var _ = Describe("Unified", func() {
+	It("is empty for identical texts", func() {
+		Expect(diff.Unified("a\nb\n", "a\nb\n", "a", "b", 3)).To(BeEmpty())
+	})
+	It("renders the changes with their context", func() {
+		from := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n"
+		to := "1\n2\n3\n4\nfive\n6\n7\n8\n9\n10\n11\n12\n13\n14\n"
+		expected := `--- a/file.txt
++++ b/file.txt
+@@ -2,7 +2,7 @@
+ 2
+ 3
+ 4
+-5
++five
+ 6
+ 7
+ 8
+@@ -11,3 +11,4 @@
+ 11
+ 12
+ 13
++14
+`
+		Expect(diff.Unified(from, to, "a/file.txt", "b/file.txt", 3)).To(Equal(expected))
+	})
+	It("merges changes with overlapping contexts", func() {
+		from := "1\n2\n3\n4\n5\n"
+		to := "one\n2\n3\n4\nfive\n"
+		out := diff.Unified(from, to, "a", "b", 3)
+		Expect(strings.Count(out, "@@ -")).To(Equal(1))
+		Expect(out).To(ContainSubstring("@@ -1,5 +1,5 @@"))
+	})
+})
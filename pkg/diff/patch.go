@@ -0,0 +1,207 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package diff
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DevNull is the path used in unified diffs for files which are created, or deleted.
+const DevNull = "/dev/null"
+
+// FilePatch are the edits to a single file, parsed from a unified diff.
+type FilePatch struct {
+	// OldPath and NewPath are the paths from the `---` and `+++` headers,
+	// without the `a/` and `b/` prefixes; they are empty if the diff has
+	// no headers.
+	OldPath string
+	NewPath string
+	Edits   []Edit
+}
+
+var (
+	hunkHeaderRegex = regexp.MustCompile(`^@@ -(\d+)(?:,\d+)? \+\d+(?:,\d+)? @@`)
+	searchRegex     = regexp.MustCompile(`^<{5,9} ?SEARCH\s*$`)
+	dividerRegex    = regexp.MustCompile(`^={5,9}\s*$`)
+	replaceRegex    = regexp.MustCompile(`^>{5,9} ?REPLACE\s*$`)
+)
+
+// IsSearchReplace checks whether the text contains SEARCH/REPLACE blocks.
+func IsSearchReplace(text string) bool {
+	for _, line := range Lines(text) {
+		if searchRegex.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsUnified checks whether the text looks like a unified diff, with either
+// file headers or hunk headers.
+func IsUnified(text string) bool {
+	lines := Lines(text)
+	for i, line := range lines {
+		if strings.HasPrefix(line, "@@ ") {
+			return true
+		}
+		if strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ") {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseSearchReplace parses one or more blocks in the format:
+//
+//	<<<<<<< SEARCH
+//	lines to find
+//	=======
+//	lines to replace them with
+//	>>>>>>> REPLACE
+//
+// Text outside of the blocks is ignored.
+func ParseSearchReplace(text string) ([]Edit, error) {
+	const (
+		outside = iota
+		inSearch
+		inReplace
+	)
+	var edits []Edit
+	var current Edit
+	state := outside
+	for i, line := range Lines(text) {
+		switch {
+		case searchRegex.MatchString(line):
+			if state != outside {
+				return nil, fmt.Errorf("line %d: unexpected SEARCH marker", i+1)
+			}
+			current = Edit{Hint: -1, Search: []string{}, Replace: []string{}}
+			state = inSearch
+		case dividerRegex.MatchString(line) && state == inSearch:
+			state = inReplace
+		case replaceRegex.MatchString(line):
+			if state != inReplace {
+				return nil, fmt.Errorf("line %d: unexpected REPLACE marker", i+1)
+			}
+			edits = append(edits, current)
+			state = outside
+		case state == inSearch:
+			current.Search = append(current.Search, line)
+		case state == inReplace:
+			current.Replace = append(current.Replace, line)
+		}
+	}
+	if state != outside {
+		return nil, fmt.Errorf("unterminated SEARCH/REPLACE block")
+	}
+	return edits, nil
+}
+
+// ParseUnified parses a unified diff, for one or more files; the line counts
+// in the hunk headers are ignored, as LLMs rarely get them right.
+func ParseUnified(text string) ([]FilePatch, error) {
+	lines := Lines(text)
+	var patches []FilePatch
+	var patch *FilePatch
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			patches = append(patches, FilePatch{
+				OldPath: headerPath(line, "a/"),
+				NewPath: headerPath(lines[i+1], "b/"),
+			})
+			patch = &patches[len(patches)-1]
+			i++
+		case strings.HasPrefix(line, "@@"):
+			if patch == nil {
+				// A diff without file headers.
+				patches = append(patches, FilePatch{})
+				patch = &patches[len(patches)-1]
+			}
+			var edit Edit
+			edit, i = parseHunk(lines, i)
+			if len(edit.Search) == 0 && len(edit.Replace) == 0 {
+				return nil, fmt.Errorf("line %d: empty hunk", i+1)
+			}
+			patch.Edits = append(patch.Edits, edit)
+		}
+	}
+	if len(patches) == 0 {
+		return nil, fmt.Errorf("no hunks found in diff")
+	}
+	return patches, nil
+}
+
+// headerPath extracts the path from a `---` or `+++` header line.
+func headerPath(line, prefix string) string {
+	path := strings.TrimSpace(line[4:])
+	// Some tools append a timestamp, separated by a tab.
+	path, _, _ = strings.Cut(path, "\t")
+	if path == DevNull {
+		return path
+	}
+	return strings.TrimPrefix(path, prefix)
+}
+
+// parseHunk parses the hunk whose header is at `lines[start]`, and returns
+// the index of its last line.
+func parseHunk(lines []string, start int) (Edit, int) {
+	edit := Edit{Hint: -1, Search: []string{}, Replace: []string{}}
+	if m := hunkHeaderRegex.FindStringSubmatch(lines[start]); m != nil {
+		from, _ := strconv.Atoi(m[1])
+		edit.Hint = max(0, from-1)
+	}
+	changed := false
+	end := start
+	for i := start + 1; i < len(lines); i++ {
+		line := lines[i]
+		if strings.HasPrefix(line, "@@") ||
+			(strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ")) {
+			break
+		}
+		var kind byte = ' '
+		if line != "" {
+			kind, line = line[0], line[1:]
+		}
+		switch kind {
+		case ' ':
+			// Empty context lines often lose their leading space.
+			edit.Search = append(edit.Search, line)
+			edit.Replace = append(edit.Replace, line)
+			if changed {
+				edit.TrailingContext++
+			} else {
+				edit.LeadingContext++
+			}
+		case '-':
+			edit.Search = append(edit.Search, line)
+			changed, edit.TrailingContext = true, 0
+		case '+':
+			edit.Replace = append(edit.Replace, line)
+			changed, edit.TrailingContext = true, 0
+		case '\\':
+			// "\ No newline at end of file"
+		default:
+			return trimHunk(edit), end
+		}
+		end = i
+	}
+	return trimHunk(edit), end
+}
+
+// trimHunk drops the trailing empty context lines, which are usually just
+// the separation from the text following the diff.
+func trimHunk(e Edit) Edit {
+	for e.TrailingContext > 0 && e.Search[len(e.Search)-1] == "" {
+		e.Search = e.Search[:len(e.Search)-1]
+		e.Replace = e.Replace[:len(e.Replace)-1]
+		e.TrailingContext--
+	}
+	return e
+}
@@ -5,9 +5,12 @@
 package integration
 
 import (
+	"context"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"github.com/alertavert/gpt4-go/pkg/api"
 	"github.com/alertavert/gpt4-go/pkg/completions"
 )
 
@@ -22,19 +25,19 @@ var _ = Describe("Integration Tests: When querying OpenAI", func() {
 		It("should return a response for a valid prompt", func() {
 			prompt := "Please update this code:\n'''sample/main.go\n" +
 				"'''to also print the current date."
-			request := completions.PromptRequest{
+			request := completions.PromptRequest{PromptRequest: api.PromptRequest{
 				Assistant:  "go_developer",
 				ThreadId:   "",
 				ThreadName: "test-thread",
 				Prompt:     prompt,
-			}
+			}}
 			Eventually(func(g Gomega) {
 				// TODO: run this in a goroutine and check the response
 				// in the main thread.
 
-				response, err := ActiveBot.QueryBot(&request)
+				response, err := ActiveBot.QueryBot(context.Background(), &request)
 				g.Expect(err).NotTo(HaveOccurred())
-				g.Expect(response).NotTo(BeEmpty())
+				g.Expect(response.Message).NotTo(BeEmpty())
 				// TODO: check that the response contains the expected code.
 				// TODO: check that the snippet was saved to the correct location.
 				// TODO: once background processing is enabled, change the timeout
@@ -71,17 +74,17 @@ var _ = Describe("Integration Tests: When querying OpenAI", func() {
 
 		It("should automatically suggest a thread name when neither thread_id nor thread_name is provided", func() {
 			prompt := "What is the best way to handle errors in Go?"
-			request := completions.PromptRequest{
+			request := completions.PromptRequest{PromptRequest: api.PromptRequest{
 				Assistant:  "go_developer",
 				ThreadId:   "",
 				ThreadName: "", // Intentionally empty to trigger name suggestion
 				Prompt:     prompt,
-			}
+			}}
 
 			Eventually(func(g Gomega) {
-				response, err := ActiveBot.QueryBot(&request)
+				response, err := ActiveBot.QueryBot(context.Background(), &request)
 				g.Expect(err).NotTo(HaveOccurred())
-				g.Expect(response).NotTo(BeEmpty())
+				g.Expect(response.Message).NotTo(BeEmpty())
 
 				// The thread name should have been set automatically
 				g.Expect(request.ThreadName).NotTo(BeEmpty())
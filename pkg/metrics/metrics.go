@@ -0,0 +1,158 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+// Package metrics exposes the activity of the server, and of the LLM, in the
+// Prometheus exposition format.
+package metrics
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "majordomo"
+
+// Registry holds all the Majordomo metrics, and those of the Go runtime.
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+var (
+	HTTPRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests served, by route and status code.",
+	}, []string{"method", "route", "status"})
+
+	HTTPDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of the HTTP requests, by route.",
+		// Prompts can take minutes.
+		Buckets: []float64{.005, .025, .1, .5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+	}, []string{"method", "route"})
+
+	OpenAIRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "openai_requests_total",
+		Help:      "Requests to the OpenAI API (including their retries), by operation and status code.",
+	}, []string{"operation", "status"})
+
+	OpenAIDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "openai_request_duration_seconds",
+		Help:      "Latency of the requests to the OpenAI API (including their retries), by operation.",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"operation"})
+
+	Runs = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "runs_total",
+		Help:      "OpenAI Runs which reached a terminal status, by status.",
+	}, []string{"status"})
+
+	RunDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "run_duration_seconds",
+		Help:      "Time from the creation of the OpenAI Runs to their terminal status, by status.",
+		Buckets:   []float64{1, 2.5, 5, 10, 20, 30, 60, 120, 300, 600},
+	}, []string{"status"})
+
+	Tokens = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tokens_total",
+		Help:      "Tokens consumed by the completed Runs, by model, project and type (prompt or completion).",
+	}, []string{"model", "project", "type"})
+
+	SnippetsSaved = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "snippets_saved_total",
+		Help:      "Code snippet files saved from the LLM's responses, by project.",
+	}, []string{"project"})
+
+	Threads = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "threads",
+		Help:      "Conversation threads in the thread store, by project.",
+	}, []string{"project"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler serves the metrics, in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// GinMiddleware records the count and latency of the requests, by route
+// (e.g., `/projects/:project_name`, rather than the actual path).
+func GinMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		HTTPRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		HTTPDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Doer sends the HTTP requests; see retry.Doer.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// instrumentedDoer records the requests to the OpenAI API.
+type instrumentedDoer struct {
+	doer Doer
+}
+
+// InstrumentDoer records the count and latency of the requests sent by `doer`.
+func InstrumentDoer(doer Doer) Doer {
+	return &instrumentedDoer{doer: doer}
+}
+
+func (d *instrumentedDoer) Do(req *http.Request) (*http.Response, error) {
+	operation := Operation(req.Method, req.URL.Path)
+	start := time.Now()
+	resp, err := d.doer.Do(req)
+	OpenAIDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	OpenAIRequests.WithLabelValues(operation, status).Inc()
+	return resp, err
+}
+
+// openAIIds are the IDs of the OpenAI objects, e.g. `thread_abc123`.
+var openAIIds = regexp.MustCompile(`^(asst|thread|run|msg|step|file|vs|batch)_[A-Za-z0-9]+$`)
+
+// Operation names the API call, replacing the IDs in the path, so that the
+// number of label values stays bounded, e.g.: `POST /threads/:id/runs`.
+func Operation(method, path string) string {
+	segments := strings.Split(strings.TrimPrefix(path, "/v1"), "/")
+	for i, s := range segments {
+		if openAIIds.MatchString(s) {
+			segments[i] = ":id"
+		}
+	}
+	return method + " " + strings.Join(segments, "/")
+}
@@ -0,0 +1,63 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package metrics_test
+
+import (
+	"errors"
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/alertavert/gpt4-go/pkg/metrics"
+)
+
+type doerFunc func(*http.Request) (*http.Response, error)
+
+func (f doerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+var _ = Describe("Metrics", func() {
+	Context("naming the OpenAI operations", func() {
+		It("replaces the IDs in the path", func() {
+			Expect(metrics.Operation(http.MethodGet, "/v1/threads/thread_abc123/runs/run_XYZ789")).
+				To(Equal("GET /threads/:id/runs/:id"))
+			Expect(metrics.Operation(http.MethodPost, "/v1/threads/thread_abc123/messages")).
+				To(Equal("POST /threads/:id/messages"))
+			Expect(metrics.Operation(http.MethodPost, "/v1/audio/transcriptions")).
+				To(Equal("POST /audio/transcriptions"))
+		})
+	})
+
+	Context("instrumenting the OpenAI client", func() {
+		It("counts the requests by operation and status", func() {
+			doer := metrics.InstrumentDoer(doerFunc(func(*http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusTooManyRequests}, nil
+			}))
+			req, _ := http.NewRequest(http.MethodGet, "https://api.openai.com/v1/assistants", nil)
+			counter := metrics.OpenAIRequests.WithLabelValues("GET /assistants", "429")
+			before := testutil.ToFloat64(counter)
+
+			_, err := doer.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(testutil.ToFloat64(counter)).To(Equal(before + 1))
+		})
+
+		It("counts the failed requests as errors", func() {
+			doer := metrics.InstrumentDoer(doerFunc(func(*http.Request) (*http.Response, error) {
+				return nil, errors.New("connection refused")
+			}))
+			req, _ := http.NewRequest(http.MethodDelete, "https://api.openai.com/v1/threads/thread_1", nil)
+			counter := metrics.OpenAIRequests.WithLabelValues("DELETE /threads/:id", "error")
+			before := testutil.ToFloat64(counter)
+
+			_, err := doer.Do(req)
+			Expect(err).To(HaveOccurred())
+			Expect(testutil.ToFloat64(counter)).To(Equal(before + 1))
+		})
+	})
+})
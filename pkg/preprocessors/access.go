@@ -0,0 +1,247 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package preprocessors
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// DefaultExcludes are the globs that are always excluded from being read or
+// written, in addition to any configured for the project.
+var DefaultExcludes = []string{
+	".env",
+	".env.*",
+	".git",
+	".ssh",
+	"*.pem",
+	"*.key",
+	"*.p12",
+	"*.pfx",
+	"id_rsa*",
+	"id_dsa*",
+	"id_ecdsa*",
+	"id_ed25519*",
+	".netrc",
+	".npmrc",
+	".pypirc",
+}
+
+// RejectedPath is a file path that was refused access, and why.
+type RejectedPath struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// RejectedPathsError is returned when one or more file paths cannot be read
+// from (or written to) a CodeStoreHandler.
+type RejectedPathsError struct {
+	Rejected []RejectedPath
+}
+
+func (e *RejectedPathsError) Error() string {
+	var msgs []string
+	for _, r := range e.Rejected {
+		msgs = append(msgs, fmt.Sprintf("%s (%s)", r.Path, r.Reason))
+	}
+	return "access denied to: " + strings.Join(msgs, ", ")
+}
+
+func (e *RejectedPathsError) add(path, reason string) {
+	e.Rejected = append(e.Rejected, RejectedPath{Path: path, Reason: reason})
+}
+
+// AccessRules determine which files, relative to a project's root, can be
+// read and written by the CodeStoreHandler.
+//
+// Globs without a `/` are matched against every segment of the path (so that
+// `.git` excludes everything inside a `.git` folder, and `*.pem` all the PEM
+// files); globs containing a `/` are matched against the whole path, with `**`
+// matching any number of directories.
+type AccessRules struct {
+	include []glob
+	exclude []glob
+}
+
+// glob is a compiled glob pattern.
+type glob struct {
+	pattern string
+	re      *regexp.Regexp
+	// If true, the glob is matched against each segment of the path.
+	segment bool
+}
+
+// NewAccessRules creates the AccessRules from the given globs; the
+// DefaultExcludes are always added to the `exclude` ones.
+// If `include` is empty, all the (non-excluded) files are accessible.
+func NewAccessRules(include, exclude []string) (*AccessRules, error) {
+	rules := &AccessRules{}
+	for _, g := range include {
+		compiled, err := compileGlob(g)
+		if err != nil {
+			return nil, err
+		}
+		rules.include = append(rules.include, compiled)
+	}
+	for _, g := range append(append([]string{}, DefaultExcludes...), exclude...) {
+		compiled, err := compileGlob(g)
+		if err != nil {
+			return nil, err
+		}
+		rules.exclude = append(rules.exclude, compiled)
+	}
+	return rules, nil
+}
+
+// DefaultAccessRules only excludes the DefaultExcludes.
+func DefaultAccessRules() *AccessRules {
+	rules, _ := NewAccessRules(nil, nil)
+	return rules
+}
+
+// Check returns the reason why `relPath` cannot be accessed, or an empty
+// string if it can be.
+func (r *AccessRules) Check(relPath string) string {
+	p := strings.TrimPrefix(filepath.ToSlash(filepath.Clean(relPath)), "/")
+	for _, g := range r.exclude {
+		if g.matches(p) {
+			return fmt.Sprintf("excluded by rule %q", g.pattern)
+		}
+	}
+	if len(r.include) == 0 {
+		return ""
+	}
+	for _, g := range r.include {
+		if g.matches(p) {
+			return ""
+		}
+	}
+	return "not matched by any include rule"
+}
+
+// Excluded reports whether `relPath` matches any of the exclude rules: for a
+// directory, this means that none of its files can be accessed.
+func (r *AccessRules) Excluded(relPath string) bool {
+	p := strings.TrimPrefix(filepath.ToSlash(filepath.Clean(relPath)), "/")
+	for _, g := range r.exclude {
+		if g.matches(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches checks the whole path and, for segment globs, each of its segments.
+func (g glob) matches(path string) bool {
+	if g.re.MatchString(path) {
+		return true
+	}
+	if !g.segment {
+		return false
+	}
+	for _, seg := range strings.Split(path, "/") {
+		if g.re.MatchString(seg) {
+			return true
+		}
+	}
+	return false
+}
+
+// compileGlob converts a shell-style glob into an anchored regular expression.
+func compileGlob(pattern string) (glob, error) {
+	var sb strings.Builder
+	sb.WriteString("^")
+	g := strings.TrimPrefix(filepath.ToSlash(pattern), "/")
+	for i := 0; i < len(g); i++ {
+		switch c := g[i]; c {
+		case '*':
+			if i+1 < len(g) && g[i+1] == '*' {
+				i++
+				if i+1 < len(g) && g[i+1] == '/' {
+					// `**/` matches zero or more directories.
+					i++
+					sb.WriteString("(.*/)?")
+				} else {
+					sb.WriteString(".*")
+				}
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	re, err := regexp.Compile(sb.String())
+	if err != nil {
+		return glob{}, fmt.Errorf("invalid glob %q: %w", pattern, err)
+	}
+	return glob{pattern: pattern, re: re, segment: !strings.Contains(g, "/")}, nil
+}
+
+// ResolvePath returns the absolute location of `relPath` inside `root`,
+// guaranteeing that it does not escape it, either via `..` segments or by
+// following symbolic links.
+// If the path is rejected, the returned string is empty, and the reason is
+// returned as an error.
+func ResolvePath(root, relPath string) (string, error) {
+	if relPath == "" {
+		return "", fmt.Errorf("empty path")
+	}
+	for _, seg := range strings.Split(filepath.ToSlash(relPath), "/") {
+		if seg == ".." {
+			return "", fmt.Errorf("path contains '..' segments")
+		}
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	// Leading slashes are considered relative to the root.
+	target := filepath.Join(absRoot, relPath)
+	realRoot, err := resolveExisting(absRoot)
+	if err != nil {
+		return "", err
+	}
+	if fi, err := os.Lstat(target); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+		if _, err := filepath.EvalSymlinks(target); err != nil {
+			return "", fmt.Errorf("dangling symbolic link")
+		}
+	}
+	realTarget, err := resolveExisting(target)
+	if err != nil {
+		return "", err
+	}
+	if realTarget != realRoot && !strings.HasPrefix(realTarget, realRoot+string(filepath.Separator)) {
+		return "", fmt.Errorf("symbolic link resolves outside of %s", root)
+	}
+	return target, nil
+}
+
+// resolveExisting evaluates the symbolic links in the longest prefix of
+// `path` which exists on disk, and appends the (non-existent) remainder.
+func resolveExisting(path string) (string, error) {
+	var suffix string
+	for p := path; ; {
+		real, err := filepath.EvalSymlinks(p)
+		if err == nil {
+			return filepath.Join(real, suffix), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(p)
+		if parent == p {
+			return path, nil
+		}
+		suffix = filepath.Join(filepath.Base(p), suffix)
+		p = parent
+	}
+}
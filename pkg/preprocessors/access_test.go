@@ -0,0 +1,137 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package preprocessors_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alertavert/gpt4-go/pkg/preprocessors"
+)
+
+var _ = Describe("Access Rules", func() {
+	It("excludes secrets and VCS metadata by default", func() {
+		rules := preprocessors.DefaultAccessRules()
+		Expect(rules.Check("pkg/server/server.go")).To(BeEmpty())
+		Expect(rules.Check(".env")).NotTo(BeEmpty())
+		Expect(rules.Check("config/.env.local")).NotTo(BeEmpty())
+		Expect(rules.Check(".git/config")).NotTo(BeEmpty())
+		Expect(rules.Check("certs/server.key")).NotTo(BeEmpty())
+		Expect(rules.Check("home/.ssh/id_rsa.pub")).NotTo(BeEmpty())
+	})
+	It("only allows included files, if configured", func() {
+		rules, err := preprocessors.NewAccessRules([]string{"pkg/**/*.go", "*.md"}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rules.Check("pkg/server/server.go")).To(BeEmpty())
+		Expect(rules.Check("pkg/main.go")).To(BeEmpty())
+		Expect(rules.Check("docs/README.md")).To(BeEmpty())
+		Expect(rules.Check("cmd/main.go")).NotTo(BeEmpty())
+		Expect(rules.Check("pkg/server/.env")).NotTo(BeEmpty())
+	})
+	It("honors the project's exclude globs", func() {
+		rules, err := preprocessors.NewAccessRules(nil, []string{"vendor", "build/**"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rules.Check("vendor/github.com/foo/bar.go")).NotTo(BeEmpty())
+		Expect(rules.Check("build/bin/majordomo")).NotTo(BeEmpty())
+		Expect(rules.Check("pkg/build.go")).To(BeEmpty())
+	})
+})
+
+var _ = Describe("ResolvePath", func() {
+	var root string
+
+	BeforeEach(func() {
+		var err error
+		root, err = os.MkdirTemp("", "root")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.MkdirAll(filepath.Join(root, "pkg"), 0755)).To(Succeed())
+	})
+	AfterEach(func() {
+		_ = os.RemoveAll(root)
+	})
+	It("resolves paths inside the root", func() {
+		p, err := preprocessors.ResolvePath(root, "pkg/new/file.go")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(p).To(Equal(filepath.Join(root, "pkg/new/file.go")))
+		p, err = preprocessors.ResolvePath(root, "/pkg/file.go")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(p).To(Equal(filepath.Join(root, "pkg/file.go")))
+	})
+	It("rejects '..' segments", func() {
+		_, err := preprocessors.ResolvePath(root, "../../.ssh/id_rsa")
+		Expect(err).To(HaveOccurred())
+		_, err = preprocessors.ResolvePath(root, "pkg/../../etc/passwd")
+		Expect(err).To(HaveOccurred())
+	})
+	It("rejects symbolic links pointing outside the root", func() {
+		outside, err := os.MkdirTemp("", "outside")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(outside)
+		Expect(os.Symlink(outside, filepath.Join(root, "pkg/escape"))).To(Succeed())
+		Expect(os.Symlink(filepath.Join(outside, "missing"), filepath.Join(root, "dangling"))).To(Succeed())
+
+		_, err = preprocessors.ResolvePath(root, "pkg/escape/file.go")
+		Expect(err).To(HaveOccurred())
+		_, err = preprocessors.ResolvePath(root, "dangling")
+		Expect(err).To(HaveOccurred())
+	})
+	It("allows symbolic links inside the root", func() {
+		Expect(os.Symlink(filepath.Join(root, "pkg"), filepath.Join(root, "alias"))).To(Succeed())
+		_, err := preprocessors.ResolvePath(root, "alias/file.go")
+		Expect(err).NotTo(HaveOccurred())
+	})
+})
+
+var _ = Describe("FilesystemStore access control", func() {
+	var store *preprocessors.FilesystemStore
+
+	BeforeEach(func() {
+		src, dest, err := SetupTestFiles()
+		Expect(err).ShouldNot(HaveOccurred())
+		store = preprocessors.NewFilesystemStore(src, dest).(*preprocessors.FilesystemStore)
+	})
+	AfterEach(func() {
+		Cleanup(store)
+	})
+	It("refuses to read outside of the project", func() {
+		codeMap := preprocessors.SourceCodeMap{
+			"test1.go":          "",
+			"../../.ssh/id_rsa": "",
+			".env":              "",
+		}
+		err := store.GetSourceCode(&codeMap)
+		Expect(err).To(HaveOccurred())
+		var rejected *preprocessors.RejectedPathsError
+		Expect(errors.As(err, &rejected)).To(BeTrue())
+		Expect(rejected.Rejected).To(HaveLen(2))
+	})
+	It("only writes the allowed snippets", func() {
+		err := store.PutSourceCode(preprocessors.SourceCodeMap{
+			"pkg/ok.go":       "package pkg",
+			"../../escape.go": "package evil",
+			".git/hooks/post": "#!/bin/sh",
+		})
+		var rejected *preprocessors.RejectedPathsError
+		Expect(errors.As(err, &rejected)).To(BeTrue())
+		Expect(rejected.Rejected).To(HaveLen(2))
+		_, err = os.Stat(filepath.Join(store.DestCodeDir, "pkg/ok.go"))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = os.Stat(filepath.Join(store.DestCodeDir, "../../escape.go"))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+	It("enforces the project's include rules", func() {
+		rules, err := preprocessors.NewAccessRules([]string{"*.go"}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		store.Rules = rules
+		codeMap := preprocessors.SourceCodeMap{"test_config.yaml": ""}
+		Expect(store.GetSourceCode(&codeMap)).To(HaveOccurred())
+		codeMap = preprocessors.SourceCodeMap{"test1.go": ""}
+		Expect(store.GetSourceCode(&codeMap)).To(Succeed())
+	})
+})
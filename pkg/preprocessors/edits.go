@@ -0,0 +1,161 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package preprocessors
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/alertavert/gpt4-go/pkg/api"
+	"github.com/alertavert/gpt4-go/pkg/diff"
+)
+
+// FileEdit are the edits to a file, from a code block in a bot response
+// which uses one of the EditFormats.
+type FileEdit struct {
+	Path   string
+	Format string
+	// Line is the line of the bot response where the code block starts.
+	Line  int
+	Edits []diff.Edit
+	// NewFile is true if the file is created by the edits.
+	NewFile bool
+}
+
+// EditFailure reports an edit which could not be applied.
+type EditFailure = api.EditFailure
+
+// parseEdits parses the edits in the block, which uses one of the EditFormats.
+func parseEdits(block CodeBlock) ([]FileEdit, error) {
+	switch block.Format {
+	case FormatSearchReplace:
+		edits, err := diff.ParseSearchReplace(block.Content)
+		if err != nil {
+			return nil, err
+		}
+		return []FileEdit{{Path: block.Path, Format: block.Format, Line: block.Line, Edits: edits}}, nil
+	case FormatUnifiedDiff:
+		patches, err := diff.ParseUnified(block.Content)
+		if err != nil {
+			return nil, err
+		}
+		var result []FileEdit
+		for _, p := range patches {
+			path := p.NewPath
+			if path == "" {
+				path = block.Path
+			}
+			if path == "" {
+				return nil, fmt.Errorf(ReasonNoPath)
+			}
+			if path == diff.DevNull {
+				return nil, fmt.Errorf("deleting %s is not supported", p.OldPath)
+			}
+			if !IsValidFilePath(path) {
+				return nil, fmt.Errorf("%s: %s", ReasonInvalidPath, path)
+			}
+			result = append(result, FileEdit{
+				Path:    path,
+				Format:  block.Format,
+				Line:    block.Line,
+				Edits:   p.Edits,
+				NewFile: p.OldPath == diff.DevNull,
+			})
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("unknown edit format %q", block.Format)
+}
+
+// ApplyEdits applies the Edits parsed from the bot response to the current
+// version of each file: this is, in order of preference, the one in the
+// same response, the original in the project, or the latest snippet saved.
+// The resulting files are added to the CodeMap; the edits which could not be
+// applied are returned.
+func (p *Parser) ApplyEdits(store CodeStoreHandler) []EditFailure {
+	var failures []EditFailure
+	for _, fe := range p.Edits {
+		var bases []string
+		if content, found := p.CodeMap[fe.Path]; found {
+			bases = []string{content}
+		} else if fe.NewFile {
+			bases = []string{""}
+		} else {
+			original := SourceCodeMap{fe.Path: ""}
+			if err := store.GetSourceCode(&original); err == nil {
+				bases = append(bases, original[fe.Path])
+			}
+			if snippet, err := store.GetSnippet(fe.Path); err == nil {
+				bases = append(bases, snippet)
+			}
+		}
+		if len(bases) == 0 {
+			failures = append(failures, EditFailure{
+				Path:   fe.Path,
+				Format: fe.Format,
+				Line:   fe.Line,
+				Reason: "cannot find the file to edit",
+			})
+			continue
+		}
+		// The base to which most of the edits apply wins.
+		var result string
+		var failed []diff.Failure
+		for i, base := range bases {
+			r, f := diff.Apply(base, fe.Edits)
+			if i == 0 || len(f) < len(failed) {
+				result, failed = r, f
+			}
+			if len(f) == 0 {
+				break
+			}
+		}
+		for _, f := range failed {
+			failures = append(failures, EditFailure{
+				Path:   fe.Path,
+				Format: fe.Format,
+				Line:   fe.Line,
+				Hunk:   f.Edit + 1,
+				Search: strings.Join(fe.Edits[f.Edit].Search, "\n"),
+				Reason: f.Reason,
+			})
+		}
+		if len(failed) == len(fe.Edits) {
+			log.Warn().
+				Str("path", fe.Path).
+				Int("line", fe.Line).
+				Msg("none of the edits could be applied, file not saved")
+			continue
+		}
+		log.Debug().
+			Str("path", fe.Path).
+			Str("format", fe.Format).
+			Int("applied", len(fe.Edits)-len(failed)).
+			Int("failed", len(failed)).
+			Msg("edits applied")
+		p.CodeMap[fe.Path] = result
+	}
+	return failures
+}
+
+// RestoreEdits restores the secrets in the edits, before they are applied
+// to the original files.
+func (r *Redactions) RestoreEdits(edits []FileEdit) {
+	if r.Len() == 0 {
+		return
+	}
+	for _, fe := range edits {
+		for _, e := range fe.Edits {
+			for i := range e.Search {
+				e.Search[i] = r.Restore(e.Search[i])
+			}
+			for i := range e.Replace {
+				e.Replace[i] = r.Restore(e.Replace[i])
+			}
+		}
+	}
+}
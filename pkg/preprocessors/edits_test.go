@@ -0,0 +1,108 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package preprocessors_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alertavert/gpt4-go/pkg/config"
+	"github.com/alertavert/gpt4-go/pkg/preprocessors"
+)
+
+const original = `package main
+
+func main() {
+	println("hello")
+}
+`
+
+var _ = Describe("Edits", func() {
+	var (
+		store  *preprocessors.FilesystemStore
+		parser preprocessors.Parser
+	)
+
+	BeforeEach(func() {
+		src, err := os.MkdirTemp("", "src")
+		Expect(err).NotTo(HaveOccurred())
+		dest, err := os.MkdirTemp("", "dest")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.WriteFile(filepath.Join(src, "main.go"), []byte(original), 0644)).To(Succeed())
+		store = &preprocessors.FilesystemStore{SourceCodeDir: src, DestCodeDir: dest}
+		parser = preprocessors.Parser{CodeMap: make(preprocessors.SourceCodeMap)}
+	})
+	AfterEach(func() {
+		Cleanup(store)
+	})
+
+	It("applies SEARCH/REPLACE blocks to the original file", func() {
+		Expect(parser.ParseBotResponse("```go\n// file: main.go\n<<<<<<< SEARCH\n" +
+			"\tprintln(\"hello\")\n=======\n\tprintln(\"world\")\n>>>>>>> REPLACE\n```\n")).To(Succeed())
+		Expect(parser.Edits).To(HaveLen(1))
+		Expect(parser.CodeMap).To(BeEmpty())
+
+		Expect(parser.ApplyEdits(store)).To(BeEmpty())
+		Expect(parser.CodeMap["main.go"]).To(ContainSubstring("println(\"world\")"))
+		Expect(parser.CodeMap["main.go"]).To(HavePrefix("package main\n"))
+	})
+	It("applies unified diffs, including new files", func() {
+		Expect(parser.ParseBotResponse("```diff\n--- a/main.go\n+++ b/main.go\n@@ -3,3 +3,3 @@\n" +
+			" func main() {\n-\tprintln(\"hello\")\n+\tprintln(\"world\")\n }\n" +
+			"--- /dev/null\n+++ b/util/util.go\n@@ -0,0 +1 @@\n+package util\n```\n")).To(Succeed())
+		Expect(parser.Edits).To(HaveLen(2))
+
+		Expect(parser.ApplyEdits(store)).To(BeEmpty())
+		Expect(parser.CodeMap["main.go"]).To(ContainSubstring("println(\"world\")"))
+		Expect(parser.CodeMap["util/util.go"]).To(Equal("package util\n"))
+	})
+	It("falls back to the latest snippet", func() {
+		Expect(store.PutSourceCode(preprocessors.SourceCodeMap{"main.go": "package main\n\nfunc other() {}\n"})).
+			To(Succeed())
+		Expect(parser.ParseBotResponse("'''main.go\n<<<<<<< SEARCH\nfunc other() {}\n=======\n" +
+			"func other() int { return 1 }\n>>>>>>> REPLACE\n'''\n")).To(Succeed())
+
+		Expect(parser.ApplyEdits(store)).To(BeEmpty())
+		Expect(parser.CodeMap["main.go"]).To(ContainSubstring("func other() int"))
+	})
+	It("reports the edits which could not be applied", func() {
+		Expect(parser.ParseBotResponse("'''main.go\n<<<<<<< SEARCH\nfunc missing() {}\n=======\n\n>>>>>>> REPLACE\n" +
+			"<<<<<<< SEARCH\n\tprintln(\"hello\")\n=======\n\tprintln(\"world\")\n>>>>>>> REPLACE\n'''\n" +
+			"'''nowhere.go\n<<<<<<< SEARCH\nfoo\n=======\nbar\n>>>>>>> REPLACE\n'''\n")).To(Succeed())
+
+		failures := parser.ApplyEdits(store)
+		Expect(failures).To(HaveLen(2))
+		Expect(failures[0].Path).To(Equal("main.go"))
+		Expect(failures[0].Hunk).To(Equal(1))
+		Expect(failures[0].Search).To(Equal("func missing() {}"))
+		Expect(failures[1].Path).To(Equal("nowhere.go"))
+		// The edits which could be applied are kept.
+		Expect(parser.CodeMap["main.go"]).To(ContainSubstring("println(\"world\")"))
+		Expect(parser.CodeMap).NotTo(HaveKey("nowhere.go"))
+	})
+	It("reports malformed edits as unattributed", func() {
+		Expect(parser.ParseBotResponse("'''main.go\n<<<<<<< SEARCH\nfoo\n'''\n")).To(Succeed())
+		Expect(parser.Edits).To(BeEmpty())
+		Expect(parser.Unattributed).To(HaveLen(1))
+	})
+	It("restores the redacted secrets before applying the edits", func() {
+		Expect(os.WriteFile(filepath.Join(store.SourceCodeDir, "main.go"),
+			[]byte("const key = \"sk-proj-abcdefghijklmnopqrstuvwxyz0123\"\n"), 0644)).To(Succeed())
+		scanner, err := preprocessors.NewSecretScanner(config.SecretsConfig{})
+		Expect(err).NotTo(HaveOccurred())
+		redactions := preprocessors.NewRedactions()
+		redacted := scanner.Redact("const key = \"sk-proj-abcdefghijklmnopqrstuvwxyz0123\"", "main.go", redactions)
+
+		Expect(parser.ParseBotResponse("'''main.go\n<<<<<<< SEARCH\n" + redacted +
+			"\n=======\n// The key\n" + redacted + "\n>>>>>>> REPLACE\n'''\n")).To(Succeed())
+		redactions.RestoreEdits(parser.Edits)
+		Expect(parser.ApplyEdits(store)).To(BeEmpty())
+		Expect(parser.CodeMap["main.go"]).To(Equal(
+			"// The key\nconst key = \"sk-proj-abcdefghijklmnopqrstuvwxyz0123\"\n"))
+	})
+})
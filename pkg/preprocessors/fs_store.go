@@ -11,15 +11,69 @@ import (
 	"github.com/rs/zerolog/log"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 )
 
 const (
-	ErrorReadingCodeSnippet = "error while reading %s: %v"
+	ErrorReadingCodeSnippet = "error while reading %s: %w"
 )
 
-type ProjectsStoreMap = map[string]*CodeStoreHandler
+type ProjectsStoreMap = map[string]CodeStoreHandler
 
-var cache = make(ProjectsStoreMap)
+// StoreRegistry keeps the CodeStoreHandler of each project, so that the same
+// one is used by all the requests; it is safe for concurrent use.
+type StoreRegistry struct {
+	mu     sync.Mutex
+	stores ProjectsStoreMap
+}
+
+// NewStoreRegistry creates an empty StoreRegistry.
+func NewStoreRegistry() *StoreRegistry {
+	return &StoreRegistry{stores: make(ProjectsStoreMap)}
+}
+
+// storeKey is the project's ID, so that a renamed project keeps its store;
+// or its name, if the ID is not set.
+func storeKey(project *config.Project) string {
+	if project.ID != "" {
+		return project.ID
+	}
+	return project.Name
+}
+
+// Get returns the CodeStoreHandler for the project, creating it if necessary.
+func (r *StoreRegistry) Get(project *config.Project) CodeStoreHandler {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if store, found := r.stores[storeKey(project)]; found {
+		return store
+	}
+	rules, err := NewAccessRules(project.Include, project.Exclude)
+	if err != nil {
+		log.Err(err).
+			Str("project", project.Name).
+			Msg("invalid include/exclude rules, using defaults")
+		rules = DefaultAccessRules()
+	}
+	store := &FilesystemStore{
+		SourceCodeDir: project.Location,
+		DestCodeDir:   project.ResolvedCodeSnippetsDir,
+		Rules:         rules,
+		KeepHistory:   true,
+	}
+	r.stores[storeKey(project)] = store
+	return store
+}
+
+// Forget discards the CodeStoreHandler of the project with the given ID (or
+// name, if it has none), e.g. when the project's configuration changes; a new
+// one is created on next use.
+func (r *StoreRegistry) Forget(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.stores, id)
+}
 
 // FilesystemStore is a CodeStoreHandler that reads and writes code snippets from/to the filesystem
 type FilesystemStore struct {
@@ -27,22 +81,90 @@ type FilesystemStore struct {
 	SourceCodeDir string
 	// DestCodeDir is the directory where the code snippets are saved to
 	DestCodeDir string
+	// Rules restrict which files can be read or written; if nil, the
+	// DefaultAccessRules are used.
+	Rules *AccessRules
+	// KeepHistory, if true, keeps every version of the saved snippets in the
+	// HistoryDir folder of DestCodeDir.
+	KeepHistory bool
+
+	mu sync.Mutex
+}
+
+// resolve confines relPath to the root directory, and checks it against the access rules.
+func (fp *FilesystemStore) resolve(root, relPath string) (string, string) {
+	rules := fp.Rules
+	if rules == nil {
+		rules = DefaultAccessRules()
+	}
+	if reason := rules.Check(relPath); reason != "" {
+		return "", reason
+	}
+	absPath, err := ResolvePath(root, relPath)
+	if err != nil {
+		return "", err.Error()
+	}
+	return absPath, ""
+}
+
+// resolveSnippet is like resolve, for the snippets in DestCodeDir, where the
+// HistoryDir is reserved.
+func (fp *FilesystemStore) resolveSnippet(relPath string) (string, string) {
+	absPath, reason := fp.resolve(fp.DestCodeDir, relPath)
+	if reason == "" && strings.SplitN(cleanPath(relPath), "/", 2)[0] == HistoryDir {
+		reason = "reserved for the snippets history"
+	}
+	return absPath, reason
 }
 
 func (fp *FilesystemStore) GetSourceCode(codeMap *SourceCodeMap) error {
+	// We first check all the paths, so that all the rejected ones are reported.
+	var rejected RejectedPathsError
+	resolved := make(map[string]string)
 	for relPath := range *codeMap {
-		content, err := os.ReadFile(filepath.Join(fp.SourceCodeDir, relPath))
+		absPath, reason := fp.resolve(fp.SourceCodeDir, relPath)
+		if reason != "" {
+			rejected.add(relPath, reason)
+			continue
+		}
+		resolved[relPath] = absPath
+	}
+	if len(rejected.Rejected) > 0 {
+		log.Warn().
+			Str("source_dir", fp.SourceCodeDir).
+			Err(&rejected).
+			Msg("rejected reading files")
+		return &rejected
+	}
+	for relPath, absPath := range resolved {
+		content, err := os.ReadFile(absPath)
 		if err != nil {
-			return errors.New(fmt.Sprintf(ErrorReadingCodeSnippet, relPath, err))
+			return fmt.Errorf(ErrorReadingCodeSnippet, relPath, err)
 		}
 		(*codeMap)[relPath] = string(content)
 	}
 	return nil
 }
 
+// PutSourceCode saves the code snippets, skipping those whose paths are
+// rejected: these are reported in the returned RejectedPathsError.
 func (fp *FilesystemStore) PutSourceCode(codemap SourceCodeMap) error {
+	_, err := fp.PutVersionedSourceCode(codemap, VersionMeta{})
+	return err
+}
+
+func (fp *FilesystemStore) putSourceCode(codemap SourceCodeMap) error {
+	var rejected RejectedPathsError
 	for relPath, content := range codemap {
-		absPath := filepath.Join(fp.DestCodeDir, relPath)
+		absPath, reason := fp.resolveSnippet(relPath)
+		if reason != "" {
+			log.Warn().
+				Str("relative_path", relPath).
+				Str("reason", reason).
+				Msg("rejected saving code snippet")
+			rejected.add(relPath, reason)
+			continue
+		}
 		dir := filepath.Dir(absPath)
 		// Creates the directory if it doesn't exist
 		if _, err := os.Stat(dir); os.IsNotExist(err) {
@@ -64,6 +186,9 @@ func (fp *FilesystemStore) PutSourceCode(codemap SourceCodeMap) error {
 			Str("relative_path", relPath).
 			Msg("Code saved to file")
 	}
+	if len(rejected.Rejected) > 0 {
+		return &rejected
+	}
 	return nil
 }
 
@@ -75,12 +200,175 @@ func NewFilesystemStore(sourceDir, destDir string) CodeStoreHandler {
 	}
 }
 
-// GetCodeStoreHandler returns a CodeStoreHandler for the given project
-// Creating a new one if necessary.
-func GetCodeStoreHandler(project *config.Project) *CodeStoreHandler {
-	if cache[project.Name] == nil {
-		store := NewFilesystemStore(project.Location, project.ResolvedCodeSnippetsDir)
-		cache[project.Name] = &store
+// SnippetLocation returns the path of the file where the snippet is saved.
+func (fp *FilesystemStore) SnippetLocation(relPath string) string {
+	return filepath.Join(fp.DestCodeDir, cleanPath(relPath))
+}
+
+// ListSnippets walks DestCodeDir, skipping the HistoryDir.
+func (fp *FilesystemStore) ListSnippets() ([]SnippetInfo, error) {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	snippets := make([]SnippetInfo, 0)
+	err := filepath.WalkDir(fp.DestCodeDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) && path == fp.DestCodeDir {
+				// No snippets saved yet.
+				return filepath.SkipAll
+			}
+			return err
+		}
+		rel, err := filepath.Rel(fp.DestCodeDir, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if rel == HistoryDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		snippets = append(snippets, SnippetInfo{
+			Path:     filepath.ToSlash(rel),
+			Size:     info.Size(),
+			Modified: info.ModTime().UTC(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// WalkDir visits the files in lexical order, so they are already sorted.
+	return snippets, nil
+}
+
+// ListSources walks SourceCodeDir, skipping the files (and directories) which
+// cannot be read, and the snippets, if they are saved inside it.
+func (fp *FilesystemStore) ListSources(limit int) ([]string, bool, error) {
+	rules := fp.Rules
+	if rules == nil {
+		rules = DefaultAccessRules()
+	}
+	sources := make([]string, 0)
+	truncated := false
+	err := filepath.WalkDir(fp.SourceCodeDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(fp.SourceCodeDir, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if rel != "." && (rules.Excluded(rel) || path == fp.DestCodeDir) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || rules.Check(rel) != "" {
+			return nil
+		}
+		if limit > 0 && len(sources) == limit {
+			truncated = true
+			return filepath.SkipAll
+		}
+		sources = append(sources, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return sources, truncated, nil
+}
+
+// snippetPath returns the absolute path of the snippet, or a RejectedPathsError
+// if the path is not allowed.
+func (fp *FilesystemStore) snippetPath(relPath string) (string, error) {
+	absPath, reason := fp.resolveSnippet(relPath)
+	if reason != "" {
+		var rejected RejectedPathsError
+		rejected.add(relPath, reason)
+		return "", &rejected
+	}
+	return absPath, nil
+}
+
+func (fp *FilesystemStore) GetSnippet(relPath string) (string, error) {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	absPath, err := fp.snippetPath(relPath)
+	if err != nil {
+		return "", err
+	}
+	content, err := os.ReadFile(absPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("%w: %s", ErrSnippetNotFound, relPath)
+		}
+		return "", fmt.Errorf(ErrorReadingCodeSnippet, relPath, err)
+	}
+	return string(content), nil
+}
+
+// DeleteSnippet removes the snippet, and any of its parent directories left
+// empty; its versions are kept in the history.
+func (fp *FilesystemStore) DeleteSnippet(relPath string) error {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	absPath, err := fp.snippetPath(relPath)
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(absPath); err != nil || info.IsDir() {
+		return fmt.Errorf("%w: %s", ErrSnippetNotFound, relPath)
+	}
+	if err := os.Remove(absPath); err != nil {
+		return err
+	}
+	root, err := filepath.Abs(fp.DestCodeDir)
+	if err != nil {
+		return err
+	}
+	// Remove fails on non-empty directories, which stops the pruning.
+	for dir := filepath.Dir(absPath); strings.HasPrefix(dir, root+string(filepath.Separator)); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
 	}
-	return cache[project.Name]
+	log.Debug().
+		Str("path", absPath).
+		Msg("code snippet deleted")
+	return nil
+}
+
+// DeleteAllSnippets removes everything in DestCodeDir, except the HistoryDir.
+func (fp *FilesystemStore) DeleteAllSnippets() error {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	entries, err := os.ReadDir(fp.DestCodeDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	for _, e := range entries {
+		if e.Name() == HistoryDir {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(fp.DestCodeDir, e.Name())); err != nil {
+			return err
+		}
+	}
+	log.Debug().
+		Str("path", fp.DestCodeDir).
+		Msg("all code snippets deleted")
+	return nil
 }
@@ -0,0 +1,106 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package preprocessors_test
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alertavert/gpt4-go/pkg/config"
+	"github.com/alertavert/gpt4-go/pkg/preprocessors"
+)
+
+var _ = Describe("StoreRegistry", func() {
+	project := &config.Project{
+		Name:                    "registry-test",
+		Location:                "/tmp/registry-test",
+		ResolvedCodeSnippetsDir: "/tmp/registry-test/.majordomo",
+	}
+
+	It("should return the same store to concurrent requests", func() {
+		registry := preprocessors.NewStoreRegistry()
+		stores := make([]preprocessors.CodeStoreHandler, 10)
+		var wg sync.WaitGroup
+		for i := range stores {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				stores[i] = registry.Get(project)
+			}(i)
+		}
+		wg.Wait()
+		for _, store := range stores {
+			Expect(store).To(BeIdenticalTo(stores[0]))
+		}
+		fs := stores[0].(*preprocessors.FilesystemStore)
+		Expect(fs.SourceCodeDir).To(Equal(project.Location))
+		Expect(fs.DestCodeDir).To(Equal(project.ResolvedCodeSnippetsDir))
+	})
+
+	It("should create a new store once the project is forgotten", func() {
+		registry := preprocessors.NewStoreRegistry()
+		store := registry.Get(project)
+		registry.Forget(project.Name)
+		moved := *project
+		moved.Location = "/tmp/registry-test-moved"
+		other := registry.Get(&moved)
+		Expect(other).NotTo(BeIdenticalTo(store))
+		Expect(other.(*preprocessors.FilesystemStore).SourceCodeDir).To(Equal(moved.Location))
+	})
+
+	It("should keep the store of a renamed project, by its ID", func() {
+		registry := preprocessors.NewStoreRegistry()
+		withId := *project
+		withId.ID = "registry-id"
+		store := registry.Get(&withId)
+		renamed := withId
+		renamed.Name = "registry-renamed"
+		Expect(registry.Get(&renamed)).To(BeIdenticalTo(store))
+		registry.Forget(withId.ID)
+		Expect(registry.Get(&renamed)).NotTo(BeIdenticalTo(store))
+	})
+})
+
+var _ = Describe("Listing the source files", func() {
+	var root string
+
+	BeforeEach(func() {
+		var err error
+		root, err = os.MkdirTemp("", "sources")
+		Expect(err).NotTo(HaveOccurred())
+		for _, f := range []string{"main.go", "pkg/server/server.go", "pkg/server/.env",
+			".git/config", "vendor/lib/lib.go", ".majordomo/main.go", "README.md"} {
+			path := filepath.Join(root, f)
+			Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+			Expect(os.WriteFile(path, []byte("content"), 0644)).To(Succeed())
+		}
+	})
+	AfterEach(func() {
+		_ = os.RemoveAll(root)
+	})
+
+	It("skips the files which cannot be read, and the snippets", func() {
+		rules, err := preprocessors.NewAccessRules(nil, []string{"vendor"})
+		Expect(err).NotTo(HaveOccurred())
+		store := &preprocessors.FilesystemStore{
+			SourceCodeDir: root,
+			DestCodeDir:   filepath.Join(root, ".majordomo"),
+			Rules:         rules,
+		}
+		sources, truncated, err := store.ListSources(0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(truncated).To(BeFalse())
+		Expect(sources).To(Equal([]string{"README.md", "main.go", "pkg/server/server.go"}))
+
+		sources, truncated, err = store.ListSources(2)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(truncated).To(BeTrue())
+		Expect(sources).To(Equal([]string{"README.md", "main.go"}))
+	})
+})
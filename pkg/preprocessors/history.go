@@ -0,0 +1,285 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package preprocessors
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/alertavert/gpt4-go/pkg/api"
+)
+
+const (
+	// HistoryDir is the folder, inside the snippets' destination directory,
+	// where the previous versions of the snippets are kept.
+	HistoryDir = ".history"
+
+	historyIndex   = "index.jsonl"
+	historyObjects = "objects"
+)
+
+// ErrVersionNotFound is returned when a snippet version does not exist.
+var ErrVersionNotFound = errors.New("version not found")
+
+// VersionMeta identifies the conversation that generated a snippet.
+type VersionMeta struct {
+	ThreadId string
+	RunId    string
+}
+
+// SnippetVersion describes one saved version of a code snippet.
+type SnippetVersion = api.SnippetVersion
+
+// SnippetHistory lists all the versions of a snippet, the most recent
+// (current) one last.
+type SnippetHistory struct {
+	Path     string           `json:"path"`
+	Current  string           `json:"current"`
+	Versions []SnippetVersion `json:"versions"`
+}
+
+// A VersionedStore is a CodeStoreHandler which keeps every version of the
+// code snippets it saves.
+type VersionedStore interface {
+	CodeStoreHandler
+
+	// PutVersionedSourceCode stores the code snippets, recording a new version
+	// for each of them, associated with the given metadata.
+	PutVersionedSourceCode(codemap SourceCodeMap, meta VersionMeta) ([]SnippetVersion, error)
+
+	// History returns the versions of all the snippets, sorted by path.
+	History() ([]SnippetHistory, error)
+
+	// GetVersion returns the version with the given ID, and its content.
+	GetVersion(id string) (*SnippetVersion, string, error)
+
+	// RestoreVersion makes the given version the current one for its path.
+	RestoreVersion(id string) (*SnippetVersion, error)
+}
+
+// SaveSnippets stores the code snippets, recording their version if the
+// store supports it.
+func SaveSnippets(store CodeStoreHandler, codemap SourceCodeMap, meta VersionMeta) error {
+	if vs, ok := store.(VersionedStore); ok {
+		_, err := vs.PutVersionedSourceCode(codemap, meta)
+		return err
+	}
+	return store.PutSourceCode(codemap)
+}
+
+func contentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// versionId is unique even for identical snippets saved at the same time
+// under different paths.
+func versionId(ts time.Time, path, hash string) string {
+	return fmt.Sprintf("%d-%s", ts.UnixNano(), contentHash(path + ":" + hash)[:10])
+}
+
+func (fp *FilesystemStore) historyPath(elem ...string) string {
+	return filepath.Join(append([]string{fp.DestCodeDir, HistoryDir}, elem...)...)
+}
+
+// recordVersion saves the content in the history, and appends the version to
+// the index; it must be called with the lock held.
+func (fp *FilesystemStore) recordVersion(v *SnippetVersion, content string) error {
+	objects := fp.historyPath(historyObjects)
+	if err := os.MkdirAll(objects, 0755); err != nil {
+		return err
+	}
+	// Objects are content-addressed, so identical versions are stored only once.
+	object := filepath.Join(objects, v.Hash)
+	if _, err := os.Stat(object); os.IsNotExist(err) {
+		if err := os.WriteFile(object, []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+	index, err := os.OpenFile(fp.historyPath(historyIndex), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer index.Close()
+	return json.NewEncoder(index).Encode(v)
+}
+
+// readIndex returns all the versions, in the order in which they were saved.
+func (fp *FilesystemStore) readIndex() ([]SnippetVersion, error) {
+	file, err := os.Open(fp.historyPath(historyIndex))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+	var versions []SnippetVersion
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var v SnippetVersion
+		if err := json.Unmarshal(scanner.Bytes(), &v); err != nil {
+			log.Warn().Err(err).
+				Str("index", fp.historyPath(historyIndex)).
+				Msg("skipping malformed history entry")
+			continue
+		}
+		versions = append(versions, v)
+	}
+	return versions, scanner.Err()
+}
+
+// PutVersionedSourceCode saves the code snippets (see PutSourceCode) and, if
+// KeepHistory is set, records a new version for each.
+func (fp *FilesystemStore) PutVersionedSourceCode(codemap SourceCodeMap, meta VersionMeta) ([]SnippetVersion, error) {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	err := fp.putSourceCode(codemap)
+	var rejected *RejectedPathsError
+	if err != nil && !errors.As(err, &rejected) {
+		return nil, err
+	}
+	if !fp.KeepHistory {
+		return nil, err
+	}
+	skip := make(map[string]bool)
+	if rejected != nil {
+		for _, r := range rejected.Rejected {
+			skip[r.Path] = true
+		}
+	}
+	// Sorted, so that versions saved together have a predictable order.
+	paths := make([]string, 0, len(codemap))
+	for p := range codemap {
+		if !skip[p] {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+	now := time.Now().UTC()
+	var versions []SnippetVersion
+	for _, p := range paths {
+		content := codemap[p]
+		v := SnippetVersion{
+			Path:      cleanPath(p),
+			ThreadId:  meta.ThreadId,
+			RunId:     meta.RunId,
+			Timestamp: now,
+			Hash:      contentHash(content),
+			Size:      len(content),
+		}
+		v.ID = versionId(now, v.Path, v.Hash)
+		if err := fp.recordVersion(&v, content); err != nil {
+			return versions, fmt.Errorf("error recording version of %s: %w", p, err)
+		}
+		versions = append(versions, v)
+	}
+	log.Debug().
+		Int("versions", len(versions)).
+		Str("thread_id", meta.ThreadId).
+		Str("run_id", meta.RunId).
+		Msg("snippet versions recorded")
+	return versions, err
+}
+
+// History returns the versions of all the snippets, grouped by path.
+func (fp *FilesystemStore) History() ([]SnippetHistory, error) {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	versions, err := fp.readIndex()
+	if err != nil {
+		return nil, err
+	}
+	byPath := make(map[string]*SnippetHistory)
+	var paths []string
+	for _, v := range versions {
+		h, found := byPath[v.Path]
+		if !found {
+			h = &SnippetHistory{Path: v.Path}
+			byPath[v.Path] = h
+			paths = append(paths, v.Path)
+		}
+		h.Versions = append(h.Versions, v)
+		h.Current = v.ID
+	}
+	sort.Strings(paths)
+	result := make([]SnippetHistory, 0, len(paths))
+	for _, p := range paths {
+		result = append(result, *byPath[p])
+	}
+	return result, nil
+}
+
+// GetVersion returns the version with the given ID, and its content.
+func (fp *FilesystemStore) GetVersion(id string) (*SnippetVersion, string, error) {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	return fp.getVersion(id)
+}
+
+func (fp *FilesystemStore) getVersion(id string) (*SnippetVersion, string, error) {
+	versions, err := fp.readIndex()
+	if err != nil {
+		return nil, "", err
+	}
+	for i := range versions {
+		if versions[i].ID == id {
+			content, err := os.ReadFile(fp.historyPath(historyObjects, versions[i].Hash))
+			if err != nil {
+				return nil, "", fmt.Errorf("cannot read content of version %s: %w", id, err)
+			}
+			return &versions[i], string(content), nil
+		}
+	}
+	return nil, "", fmt.Errorf("%w: %s", ErrVersionNotFound, id)
+}
+
+// RestoreVersion overwrites the snippet with the content of the given version,
+// which is recorded as a new (current) version.
+func (fp *FilesystemStore) RestoreVersion(id string) (*SnippetVersion, error) {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	old, content, err := fp.getVersion(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := fp.putSourceCode(SourceCodeMap{old.Path: content}); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	v := SnippetVersion{
+		ID:           versionId(now, old.Path, old.Hash),
+		Path:         old.Path,
+		ThreadId:     old.ThreadId,
+		RunId:        old.RunId,
+		Timestamp:    now,
+		Hash:         old.Hash,
+		Size:         old.Size,
+		RestoredFrom: old.ID,
+	}
+	if err := fp.recordVersion(&v, content); err != nil {
+		return nil, err
+	}
+	log.Debug().
+		Str("path", v.Path).
+		Str("restored_from", id).
+		Msg("snippet version restored")
+	return &v, nil
+}
+
+func cleanPath(p string) string {
+	return strings.TrimPrefix(filepath.ToSlash(filepath.Clean(p)), "/")
+}
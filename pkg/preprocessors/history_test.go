@@ -0,0 +1,96 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package preprocessors_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alertavert/gpt4-go/pkg/preprocessors"
+)
+
+var _ = Describe("Snippets History", func() {
+	var store *preprocessors.FilesystemStore
+
+	BeforeEach(func() {
+		dest, err := os.MkdirTemp("", "dest")
+		Expect(err).NotTo(HaveOccurred())
+		store = &preprocessors.FilesystemStore{
+			SourceCodeDir: "/foo/bar",
+			DestCodeDir:   dest,
+			KeepHistory:   true,
+		}
+	})
+	AfterEach(func() {
+		_ = os.RemoveAll(store.DestCodeDir)
+	})
+
+	It("records a version for every snippet saved", func() {
+		versions, err := store.PutVersionedSourceCode(preprocessors.SourceCodeMap{
+			"main.go":     "package main",
+			"pkg/util.go": "package pkg",
+		}, preprocessors.VersionMeta{ThreadId: "thread_1", RunId: "run_1"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(versions).To(HaveLen(2))
+		Expect(versions[0].Path).To(Equal("main.go"))
+		Expect(versions[0].ThreadId).To(Equal("thread_1"))
+		Expect(versions[0].RunId).To(Equal("run_1"))
+		Expect(versions[0].Size).To(Equal(len("package main")))
+		Expect(versions[0].ID).NotTo(Equal(versions[1].ID))
+	})
+	It("keeps the previous versions when a snippet is overwritten", func() {
+		Expect(store.PutSourceCode(preprocessors.SourceCodeMap{"main.go": "v1"})).To(Succeed())
+		Expect(store.PutSourceCode(preprocessors.SourceCodeMap{"main.go": "v2"})).To(Succeed())
+
+		history, err := store.History()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(history).To(HaveLen(1))
+		Expect(history[0].Versions).To(HaveLen(2))
+		Expect(history[0].Current).To(Equal(history[0].Versions[1].ID))
+
+		_, content, err := store.GetVersion(history[0].Versions[0].ID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(content).To(Equal("v1"))
+	})
+	It("can restore an older version", func() {
+		Expect(store.PutSourceCode(preprocessors.SourceCodeMap{"main.go": "v1"})).To(Succeed())
+		Expect(store.PutSourceCode(preprocessors.SourceCodeMap{"main.go": "v2"})).To(Succeed())
+		history, _ := store.History()
+		first := history[0].Versions[0]
+
+		restored, err := store.RestoreVersion(first.ID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(restored.RestoredFrom).To(Equal(first.ID))
+		data, err := os.ReadFile(filepath.Join(store.DestCodeDir, "main.go"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(Equal("v1"))
+
+		history, _ = store.History()
+		Expect(history[0].Versions).To(HaveLen(3))
+		Expect(history[0].Current).To(Equal(restored.ID))
+	})
+	It("returns an error for unknown versions", func() {
+		_, _, err := store.GetVersion("no-such-version")
+		Expect(errors.Is(err, preprocessors.ErrVersionNotFound)).To(BeTrue())
+		_, err = store.RestoreVersion("no-such-version")
+		Expect(errors.Is(err, preprocessors.ErrVersionNotFound)).To(BeTrue())
+	})
+	It("does not allow snippets to overwrite the history", func() {
+		err := store.PutSourceCode(preprocessors.SourceCodeMap{".history/index.jsonl": "{}"})
+		var rejected *preprocessors.RejectedPathsError
+		Expect(errors.As(err, &rejected)).To(BeTrue())
+	})
+	It("does not record versions unless configured to", func() {
+		store.KeepHistory = false
+		Expect(store.PutSourceCode(preprocessors.SourceCodeMap{"main.go": "v1"})).To(Succeed())
+		history, err := store.History()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(history).To(BeEmpty())
+	})
+})
@@ -8,6 +8,11 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/alertavert/gpt4-go/pkg/api"
 )
 
 const (
@@ -17,9 +22,8 @@ const (
 	// 	inline commands (such as LOAD, SAVE, etc.)
 	// 	See #19
 
-	FilepathPattern    = `^/?([\w.-]+/?)+$`
-	CodeSnippetPattern = `'''([\w/.]+/?)\n([\s\S]+?)'''`
-	PromptCodePattern  = `'''([\w/.]+/?)\n'''`
+	FilepathPattern   = `^/?([\w.-]+/?)+$`
+	PromptCodePattern = `'''([\w/.-]+/?)\n'''`
 )
 
 // SourceCodeMap is a map of file paths to their contents
@@ -28,6 +32,21 @@ type SourceCodeMap = map[string]string
 // Parser parses code snippets into a prompt or from a bot response
 type Parser struct {
 	CodeMap SourceCodeMap
+	// Unattributed are the code blocks in the bot response which could not
+	// be saved, because their file path was missing or invalid.
+	Unattributed []CodeBlock
+	// Edits to the files, from the code blocks which use one of the
+	// EditFormats; see ApplyEdits.
+	Edits []FileEdit
+
+	// Blocks are all the code blocks in the bot response, in order, except
+	// for those containing only shell commands.
+	Blocks []CodeBlock
+	// Commands are the shell commands in the bot response: either on lines
+	// starting with `!`, or in shell code blocks without a file path.
+	Commands []string
+	// Prose is the bot response, without the code blocks and the commands.
+	Prose string
 }
 
 // A CodeStoreHandler interface abstracts the storage layer for the code
@@ -38,45 +57,177 @@ type CodeStoreHandler interface {
 
 	// PutSourceCode will store the code snippets, based on their file paths
 	PutSourceCode(codemap SourceCodeMap) error
+
+	// ListSnippets returns all the stored code snippets, sorted by path.
+	ListSnippets() ([]SnippetInfo, error)
+
+	// GetSnippet returns the content of the stored snippet.
+	GetSnippet(path string) (string, error)
+
+	// DeleteSnippet removes the stored snippet.
+	DeleteSnippet(path string) error
+
+	// DeleteAllSnippets removes all the stored snippets.
+	DeleteAllSnippets() error
+}
+
+// A SnippetLocator is a CodeStoreHandler which can tell where each snippet
+// is saved.
+type SnippetLocator interface {
+	SnippetLocation(path string) string
+}
+
+// A SourceLister is a CodeStoreHandler which can list the source files that
+// can be included in the prompts.
+type SourceLister interface {
+	// ListSources returns up to `limit` paths (all of them, if not positive),
+	// sorted, and whether there were more.
+	ListSources(limit int) ([]string, bool, error)
 }
 
+// ErrSnippetNotFound is returned when a code snippet does not exist in the store.
+var ErrSnippetNotFound = errors.New("snippet not found")
+
+// SnippetInfo describes a stored code snippet.
+type SnippetInfo = api.SnippetInfo
+
 var validPathPattern *regexp.Regexp
-var snippetRegex *regexp.Regexp
 var promptRegex *regexp.Regexp
 
 func init() {
 	validPathPattern = regexp.MustCompile(FilepathPattern)
-	snippetRegex = regexp.MustCompile(CodeSnippetPattern)
 	promptRegex = regexp.MustCompile(PromptCodePattern)
 }
 
+// IsValidFilePath checks that the path is well-formed, and does not contain
+// any `..` segments.
 func IsValidFilePath(path string) bool {
-	return validPathPattern.MatchString(path)
+	if !validPathPattern.MatchString(path) {
+		return false
+	}
+	for _, seg := range strings.Split(path, "/") {
+		if seg == ".." {
+			return false
+		}
+	}
+	return true
 }
 
 // ParseBotResponse parses a prompt or bot response and extracts code snippets
-// with their respective file paths.
+// with their respective file paths (see TokenizeBotResponse); the blocks
+// which cannot be attributed to a file are added to Unattributed, and those
+// with partial edits to Edits.
 func (p *Parser) ParseBotResponse(botSays string) error {
-	matches := snippetRegex.FindAllStringSubmatch(botSays, -1)
-	if len(matches) == 0 {
-		return nil
-	}
-
 	if p.CodeMap == nil {
 		p.CodeMap = make(map[string]string)
 	}
-	for _, match := range matches {
-		filePath := match[1]
-		if IsValidFilePath(filePath) {
-			content := match[2]
-			p.CodeMap[filePath] = content
-		} else {
-			return errors.New(fmt.Sprintf("invalid file path: %s", filePath))
+	blocks := TokenizeBotResponse(botSays)
+	for _, block := range blocks {
+		if isShellBlock(block) {
+			// See parseProse.
+			continue
 		}
+		p.parseBlock(&block)
+		p.Blocks = append(p.Blocks, block)
 	}
+	p.parseProse(botSays, blocks)
 	return nil
 }
 
+// parseBlock adds the block's content to the CodeMap, or its edits to Edits.
+func (p *Parser) parseBlock(block *CodeBlock) {
+	if !block.Attributed() {
+		// We still want to save the valid snippets, and not lose the response.
+		log.Warn().
+			Str("path", block.Path).
+			Int("line", block.Line).
+			Str("reason", block.Reason).
+			Msg("code block in bot response could not be attributed, skipped")
+		p.Unattributed = append(p.Unattributed, *block)
+		return
+	}
+	if block.Format != "" {
+		edits, err := parseEdits(*block)
+		if err != nil {
+			log.Warn().
+				Err(err).
+				Str("path", block.Path).
+				Int("line", block.Line).
+				Msg("malformed edits in bot response, skipped")
+			block.Reason = err.Error()
+			p.Unattributed = append(p.Unattributed, *block)
+			return
+		}
+		p.Edits = append(p.Edits, edits...)
+		return
+	}
+	if strings.TrimSpace(block.Content) == "" {
+		// The LLM echoed back an empty placeholder from the prompt.
+		return
+	}
+	p.CodeMap[block.Path] = block.Content
+}
+
+// shellLanguages are the languages of the code blocks which contain commands.
+var shellLanguages = map[string]bool{
+	"bash": true, "console": true, "sh": true, "shell": true, "zsh": true,
+}
+
+func isShellBlock(block CodeBlock) bool {
+	return block.Reason == ReasonNoPath && shellLanguages[strings.ToLower(block.Language)]
+}
+
+// commandRegex matches the lines with a shell command, prefixed by `!`.
+var commandRegex = regexp.MustCompile(`^\s*!\s*(\S.*?)\s*$`)
+
+// shellCommands returns the commands in a shell code block, skipping
+// comments and the prompts.
+func shellCommands(content string) []string {
+	var commands []string
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		line = strings.TrimSpace(strings.TrimPrefix(line, "$ "))
+		if m := commandRegex.FindStringSubmatch(line); m != nil {
+			line = m[1]
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		commands = append(commands, line)
+	}
+	return commands
+}
+
+// parseProse removes the code blocks from the response, and extracts the
+// commands, in order, from what remains and from the shell code blocks.
+func (p *Parser) parseProse(botSays string, blocks []CodeBlock) {
+	var prose []string
+	addText := func(text string) {
+		for _, line := range strings.Split(text, "\n") {
+			if m := commandRegex.FindStringSubmatch(line); m != nil {
+				p.Commands = append(p.Commands, m[1])
+				continue
+			}
+			line = strings.TrimRight(line, " \t")
+			// Collapses the blank lines left where the blocks were.
+			if line == "" && len(prose) > 0 && prose[len(prose)-1] == "" {
+				continue
+			}
+			prose = append(prose, line)
+		}
+	}
+	last := 0
+	for _, b := range blocks {
+		addText(botSays[last:b.Start])
+		if isShellBlock(b) {
+			p.Commands = append(p.Commands, shellCommands(b.Content)...)
+		}
+		last = b.End
+	}
+	addText(botSays[last:])
+	p.Prose = strings.TrimSpace(strings.Join(prose, "\n"))
+}
+
 // ParsePrompt finds all the code snippets in the prompt and extracts their paths
 // from the prompt to prepare the CodeMap to be populated by a CodeStoreHandler.
 func (p *Parser) ParsePrompt(prompt string) {
@@ -5,6 +5,7 @@
 package preprocessors_test
 
 import (
+	"errors"
 	"fmt"
 	"github.com/alertavert/gpt4-go/pkg/preprocessors"
 	. "github.com/onsi/ginkgo"
@@ -45,6 +46,8 @@ var _ = Describe("Parse BotResponse", func() {
 			Expect(preprocessors.IsValidFilePath("pkg/server/server.go")).To(BeTrue())
 			Expect(preprocessors.IsValidFilePath("/etc/config/cfg.yaml")).To(BeTrue())
 			Expect(preprocessors.IsValidFilePath("C:\\Windows\\Sucks\\cfg.yaml")).To(BeFalse())
+			Expect(preprocessors.IsValidFilePath("../../.ssh/id_rsa")).To(BeFalse())
+			Expect(preprocessors.IsValidFilePath("pkg/../../etc/passwd")).To(BeFalse())
 		})
 		It("should successfully extract the correct content to the source code map", func() {
 			Expect(parser.ParseBotResponse(response)).ShouldNot(HaveOccurred())
@@ -61,6 +64,13 @@ var _ = Describe("Parse BotResponse", func() {
 		})
 	})
 	Context("with malformed code snippets", func() {
+		It("should skip snippets whose path escapes the project", func() {
+			parser := preprocessors.Parser{CodeMap: make(preprocessors.SourceCodeMap)}
+			Expect(parser.ParseBotResponse("'''../../.bashrc\nrm -rf /\n'''\n'''main.go\npackage main\n'''")).
+				ShouldNot(HaveOccurred())
+			Expect(parser.CodeMap).Should(HaveLen(1))
+			Expect(parser.CodeMap).Should(HaveKey("main.go"))
+		})
 		It("should never match when file path is malformed", func() {
 			parser := preprocessors.Parser{CodeMap: make(preprocessors.SourceCodeMap)}
 			Expect(parser.ParseBotResponse("'''server\\prompt_handler.go\nsome text\n'''")).
@@ -72,6 +82,18 @@ var _ = Describe("Parse BotResponse", func() {
 			Expect(parser.ParseBotResponse("'''\nsome text\nand more text.\n'''")).
 				ShouldNot(HaveOccurred())
 			Expect(parser.CodeMap).Should(BeEmpty())
+			Expect(parser.Unattributed).Should(HaveLen(1))
+			Expect(parser.Unattributed[0].Reason).Should(Equal(preprocessors.ReasonNoPath))
+		})
+	})
+	Context("with Markdown code blocks", func() {
+		It("should extract the snippets, and ignore empty placeholders", func() {
+			parser := preprocessors.Parser{CodeMap: make(preprocessors.SourceCodeMap)}
+			Expect(parser.ParseBotResponse("```go\n// file: pkg/my-util.go\npackage pkg\n```\n'''main.go\n'''\n")).
+				ShouldNot(HaveOccurred())
+			Expect(parser.CodeMap).Should(HaveLen(1))
+			Expect(parser.CodeMap["pkg/my-util.go"]).To(Equal("package pkg\n"))
+			Expect(parser.Unattributed).Should(BeEmpty())
 		})
 	})
 })
@@ -232,4 +254,61 @@ var _ = Describe("FilesystemStore", func() {
 			Expect(err).ToNot(HaveOccurred())
 		})
 	})
+	Context("Managing the saved snippets", func() {
+		var store *preprocessors.FilesystemStore
+
+		BeforeEach(func() {
+			destDir, err := os.MkdirTemp("", "snippets")
+			Expect(err).ShouldNot(HaveOccurred())
+			store = &preprocessors.FilesystemStore{
+				SourceCodeDir: "/foo/bar",
+				DestCodeDir:   destDir,
+				KeepHistory:   true,
+			}
+			Expect(store.PutSourceCode(preprocessors.SourceCodeMap{
+				"main.go":          "package main",
+				"pkg/util/util.go": "package util",
+			})).To(Succeed())
+		})
+		AfterEach(func() {
+			Cleanup(store)
+		})
+
+		It("Should list the snippets, without the history", func() {
+			snippets, err := store.ListSnippets()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(snippets).To(HaveLen(2))
+			Expect(snippets[0].Path).To(Equal("main.go"))
+			Expect(snippets[0].Size).To(BeEquivalentTo(len("package main")))
+			Expect(snippets[0].Modified).ToNot(BeZero())
+			Expect(snippets[1].Path).To(Equal("pkg/util/util.go"))
+		})
+		It("Should return an empty list if nothing was saved", func() {
+			empty := preprocessors.NewFilesystemStore("/foo/bar", "/no/such/dir")
+			Expect(empty.ListSnippets()).To(BeEmpty())
+		})
+		It("Should read a snippet", func() {
+			Expect(store.GetSnippet("pkg/util/util.go")).To(Equal("package util"))
+			_, err := store.GetSnippet("missing.go")
+			Expect(errors.Is(err, preprocessors.ErrSnippetNotFound)).To(BeTrue())
+			_, err = store.GetSnippet(".history/index.jsonl")
+			var rejected *preprocessors.RejectedPathsError
+			Expect(errors.As(err, &rejected)).To(BeTrue())
+		})
+		It("Should delete a snippet, and its empty directories", func() {
+			Expect(store.DeleteSnippet("pkg/util/util.go")).To(Succeed())
+			_, err := os.Stat(filepath.Join(store.DestCodeDir, "pkg"))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+			Expect(store.ListSnippets()).To(HaveLen(1))
+			err = store.DeleteSnippet("pkg/util/util.go")
+			Expect(errors.Is(err, preprocessors.ErrSnippetNotFound)).To(BeTrue())
+		})
+		It("Should delete all the snippets, keeping their history", func() {
+			Expect(store.DeleteAllSnippets()).To(Succeed())
+			Expect(store.ListSnippets()).To(BeEmpty())
+			history, err := store.History()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(history).To(HaveLen(2))
+		})
+	})
 })
@@ -0,0 +1,221 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package preprocessors
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/alertavert/gpt4-go/pkg/api"
+	"github.com/alertavert/gpt4-go/pkg/config"
+)
+
+const (
+	// DefaultEntropyThreshold is the Shannon entropy (bits per character)
+	// above which a token is considered a secret.
+	DefaultEntropyThreshold = 4.5
+	// MinEntropyTokenLen is the minimum length of a token for it to be
+	// considered by the entropy check.
+	MinEntropyTokenLen = 32
+
+	// HighEntropyRule is the name reported for secrets found by the entropy check.
+	HighEntropyRule = "high_entropy_string"
+	// SecretGroup is the name of the (optional) capturing group in a rule's
+	// pattern: if present, only the group is redacted, instead of the whole match.
+	SecretGroup = "secret"
+)
+
+// DefaultSecretRules are always used by the SecretScanner, in addition to
+// any configured custom pattern.
+var DefaultSecretRules = []config.SecretPattern{
+	{Name: "private_key", Regex: `-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`},
+	{Name: "aws_access_key", Regex: `\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`},
+	{Name: "aws_secret_key", Regex: `(?i)aws_?secret_?(?:access_?)?key["']?\s*[:=]\s*["']?(?P<secret>[A-Za-z0-9/+=]{40})`},
+	{Name: "openai_key", Regex: `\bsk-(?:proj-|svcacct-|admin-)?[A-Za-z0-9_-]{20,}`},
+	{Name: "github_token", Regex: `\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})`},
+	{Name: "slack_token", Regex: `\bxox[abprs]-[A-Za-z0-9-]{10,}`},
+	{Name: "password", Regex: `(?i)(?:password|passwd|pwd|secret|api_?key|auth_?token)["']?\s*[:=]\s*["'](?P<secret>[^"'\s]{8,})["']`},
+}
+
+var entropyTokenRegex = regexp.MustCompile(`[A-Za-z0-9+/=_-]{32,}`)
+
+// secretRule is a compiled SecretPattern.
+type secretRule struct {
+	name  string
+	re    *regexp.Regexp
+	group int
+}
+
+// SecretScanner finds secrets in the text that is about to be sent to the LLM,
+// and replaces them with placeholders.
+type SecretScanner struct {
+	rules            []secretRule
+	entropyThreshold float64
+}
+
+// Redaction reports a secret that was replaced by a placeholder; the secret
+// itself is never reported.
+type Redaction = api.Redaction
+
+// Redactions keeps track of the placeholders used for the secrets redacted
+// in a prompt, so that they can be restored in the LLM's response.
+type Redactions struct {
+	Items   []Redaction `json:"items"`
+	secrets map[string]string
+	byValue map[string]string
+}
+
+// NewSecretScanner creates a scanner with the DefaultSecretRules plus the
+// configured custom patterns; it returns nil if the scanner is disabled.
+func NewSecretScanner(cfg config.SecretsConfig) (*SecretScanner, error) {
+	if cfg.Disabled {
+		return nil, nil
+	}
+	s := &SecretScanner{entropyThreshold: cfg.EntropyThreshold}
+	if s.entropyThreshold == 0 {
+		s.entropyThreshold = DefaultEntropyThreshold
+	}
+	for _, p := range append(append([]config.SecretPattern{}, DefaultSecretRules...), cfg.Patterns...) {
+		re, err := regexp.Compile(p.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret pattern %s: %w", p.Name, err)
+		}
+		s.rules = append(s.rules, secretRule{name: p.Name, re: re, group: re.SubexpIndex(SecretGroup)})
+	}
+	return s, nil
+}
+
+// NewRedactions creates an empty set of Redactions.
+func NewRedactions() *Redactions {
+	return &Redactions{
+		secrets: make(map[string]string),
+		byValue: make(map[string]string),
+	}
+}
+
+// Redact replaces all the secrets found in `text` with placeholders, which
+// are recorded in `redactions`; `source` identifies where the text came from
+// (e.g., the file path) for reporting purposes.
+func (s *SecretScanner) Redact(text, source string, redactions *Redactions) string {
+	if s == nil {
+		return text
+	}
+	for _, rule := range s.rules {
+		text = s.redactMatches(text, rule, source, redactions)
+	}
+	return entropyTokenRegex.ReplaceAllStringFunc(text, func(token string) string {
+		if strings.HasPrefix(token, placeholderPrefix) || !isHighEntropy(token, s.entropyThreshold) {
+			return token
+		}
+		return redactions.placeholder(HighEntropyRule, source, token)
+	})
+}
+
+func (s *SecretScanner) redactMatches(text string, rule secretRule, source string, redactions *Redactions) string {
+	matches := rule.re.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text
+	}
+	var sb strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if rule.group > 0 {
+			start, end = m[2*rule.group], m[2*rule.group+1]
+		}
+		// Secrets already redacted by a previous rule are skipped.
+		if start < 0 || start < last || strings.HasPrefix(text[start:end], placeholderPrefix) {
+			continue
+		}
+		sb.WriteString(text[last:start])
+		sb.WriteString(redactions.placeholder(rule.name, source, text[start:end]))
+		last = end
+	}
+	sb.WriteString(text[last:])
+	return sb.String()
+}
+
+const placeholderPrefix = "REDACTED_"
+
+// placeholder returns the placeholder for `secret`, reusing the same one if
+// the secret has already been redacted.
+func (r *Redactions) placeholder(rule, source, secret string) string {
+	if p, found := r.byValue[secret]; found {
+		return p
+	}
+	p := fmt.Sprintf("%s%s_%d", placeholderPrefix, strings.ToUpper(rule), len(r.Items)+1)
+	r.secrets[p] = secret
+	r.byValue[secret] = p
+	r.Items = append(r.Items, Redaction{Rule: rule, Source: source, Placeholder: p})
+	log.Info().
+		Str("rule", rule).
+		Str("source", source).
+		Str("placeholder", p).
+		Msg("secret redacted")
+	return p
+}
+
+// Len returns the number of secrets redacted.
+func (r *Redactions) Len() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.Items)
+}
+
+// Restore replaces any placeholder in `text` with the original secret.
+func (r *Redactions) Restore(text string) string {
+	if r.Len() == 0 || !strings.Contains(text, placeholderPrefix) {
+		return text
+	}
+	// Longer placeholders first, so that `_1` does not clobber `_10`.
+	placeholders := make([]string, 0, len(r.secrets))
+	for p := range r.secrets {
+		placeholders = append(placeholders, p)
+	}
+	sort.Slice(placeholders, func(i, j int) bool { return len(placeholders[i]) > len(placeholders[j]) })
+	for _, p := range placeholders {
+		text = strings.ReplaceAll(text, p, r.secrets[p])
+	}
+	return text
+}
+
+// RestoreAll restores the secrets in all the snippets of the map.
+func (r *Redactions) RestoreAll(codeMap SourceCodeMap) {
+	for path, content := range codeMap {
+		codeMap[path] = r.Restore(content)
+	}
+}
+
+// isHighEntropy checks whether a token looks like a random secret: it must
+// contain both letters and digits, and have a high Shannon entropy.
+func isHighEntropy(token string, threshold float64) bool {
+	if len(token) < MinEntropyTokenLen || !strings.ContainsAny(token, "0123456789") {
+		return false
+	}
+	var letters bool
+	freq := make(map[rune]float64)
+	for _, c := range token {
+		freq[c]++
+		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') {
+			letters = true
+		}
+	}
+	if !letters {
+		return false
+	}
+	var entropy float64
+	n := float64(len(token))
+	for _, count := range freq {
+		p := count / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy >= threshold
+}
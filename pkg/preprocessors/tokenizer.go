@@ -0,0 +1,283 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package preprocessors
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/alertavert/gpt4-go/pkg/diff"
+)
+
+const (
+	// TripleQuote delimits the code blocks in the prompts, and in the responses
+	// of the assistants which follow the instructions.
+	TripleQuote = "'''"
+
+	// Reasons for a CodeBlock not being attributed to a file.
+	ReasonNoPath       = "no file path"
+	ReasonInvalidPath  = "invalid file path"
+	ReasonUnterminated = "unterminated code block"
+
+	// Edit formats, for blocks which only contain the changes to a file.
+	FormatSearchReplace = "search_replace"
+	FormatUnifiedDiff   = "unified_diff"
+)
+
+// EditFormats are all the supported edit formats.
+var EditFormats = []string{FormatSearchReplace, FormatUnifiedDiff}
+
+// CodeBlock is a block of code found in a bot response, delimited either by
+// triple quotes or by Markdown fences.
+type CodeBlock struct {
+	// Path is the file the block belongs to, empty if it could not be determined.
+	Path     string `json:"path,omitempty"`
+	Language string `json:"language,omitempty"`
+	Content  string `json:"content"`
+	// Line is the (1-based) line of the response where the block starts.
+	Line int `json:"line"`
+	// Reason explains why the block was not attributed to a file, if it wasn't.
+	Reason string `json:"reason,omitempty"`
+	// Format is the edit format of the block, empty if it contains a whole file.
+	Format string `json:"format,omitempty"`
+
+	// Start and End are the offsets of the block in the response, including
+	// its delimiters.
+	Start int `json:"-"`
+	End   int `json:"-"`
+}
+
+// Attributed is true if the block can be saved to its Path.
+func (b CodeBlock) Attributed() bool {
+	return b.Reason == ""
+}
+
+// knownLanguages are the language tags which are not taken to be file names.
+var knownLanguages = map[string]bool{
+	"bash": true, "c": true, "c++": true, "conf": true, "console": true, "cpp": true,
+	"cs": true, "csharp": true, "css": true, "diff": true, "dockerfile": true,
+	"go": true, "golang": true, "graphql": true, "hcl": true, "html": true, "ini": true,
+	"java": true, "javascript": true, "js": true, "json": true, "jsx": true,
+	"kotlin": true, "lua": true, "makefile": true, "markdown": true, "md": true,
+	"patch": true, "perl": true, "php": true, "plaintext": true, "proto": true,
+	"protobuf": true, "py": true, "python": true, "r": true, "rb": true, "ruby": true,
+	"rust": true, "scala": true, "scss": true, "sh": true, "shell": true, "sql": true,
+	"swift": true, "terraform": true, "text": true, "toml": true, "ts": true,
+	"tsx": true, "txt": true, "typescript": true, "xml": true, "yaml": true,
+	"yml": true, "zsh": true,
+}
+
+// extensionlessFiles are recognized as file names in a Markdown fence's info
+// string, even though they have neither an extension nor a directory.
+var extensionlessFiles = map[string]bool{
+	"Dockerfile": true, "Gemfile": true, "Jenkinsfile": true, "LICENSE": true,
+	"Makefile": true, "Procfile": true, "Rakefile": true,
+}
+
+var (
+	// fenceRegex matches the opening (or closing) line of a Markdown fence.
+	fenceRegex = regexp.MustCompile("^( {0,3}|\t*)(`{3,}|~{3,})(.*)$")
+	// headerRegex matches a `path:` or `// file:` header, on the first line of a block.
+	headerRegex = regexp.MustCompile(
+		`(?i)^\s*(?://|#|--|;|/\*|<!--)?\s*(?:file|filename|path)\s*:\s*(\S+?)\s*(?:\*/|-->)?\s*$`)
+	// captionRegex matches a line preceding a block which only names its file,
+	// such as "**`pkg/main.go`**:" or "### File: pkg/main.go".
+	captionRegex = regexp.MustCompile(
+		"(?i)^\\s*(?:#+\\s*)?[*_]*(?:(?:file|filename|path)\\s*:\\s*)?[*_]*`?([\\w./-]+?)`?[*_]*:?[*_]*\\s*$")
+	// infoAttrRegex matches a `path=...` attribute in a fence's info string.
+	infoAttrRegex = regexp.MustCompile(`^(?:path|file|filename|title)=["']?([^"']+)["']?$`)
+)
+
+// TokenizeBotResponse finds all the code blocks in the response, and
+// attributes them to files using, in order:
+//   - the path after the opening triple quote, or in the info string of a
+//     Markdown fence (```go path, ```go:path, ```go title="path");
+//   - a `path:` or `// file:` header on the first line of the block, which is removed;
+//   - a caption line just before the block, naming only the file.
+func TokenizeBotResponse(text string) []CodeBlock {
+	t := &tokenizer{text: text}
+	var blocks []CodeBlock
+	var caption string
+	for !t.done() {
+		lineNo, start := t.line+1, t.pos
+		line := t.nextLine()
+		trimmed := strings.TrimLeft(line, " \t")
+		var block *CodeBlock
+		if strings.HasPrefix(trimmed, TripleQuote) {
+			block = t.tripleQuoteBlock(strings.TrimPrefix(trimmed, TripleQuote))
+		} else if m := fenceRegex.FindStringSubmatch(line); m != nil &&
+			!(m[2][0] == '`' && strings.Contains(m[3], "`")) {
+			block = t.fencedBlock(len(m[1]), m[2], m[3])
+		}
+		if block == nil {
+			if strings.TrimSpace(line) != "" {
+				caption = line
+			}
+			continue
+		}
+		block.Line, block.Start, block.End = lineNo, start, t.pos
+		block.Format = editFormat(*block)
+		if block.Reason == "" {
+			attribute(block, caption)
+		}
+		blocks = append(blocks, *block)
+		caption = ""
+	}
+	return blocks
+}
+
+// tokenizer scans the text line by line.
+type tokenizer struct {
+	text string
+	pos  int
+	line int
+}
+
+func (t *tokenizer) done() bool {
+	return t.pos >= len(t.text)
+}
+
+// nextLine returns the next line, without its newline.
+func (t *tokenizer) nextLine() string {
+	end := strings.IndexByte(t.text[t.pos:], '\n')
+	var line string
+	if end < 0 {
+		line, t.pos = t.text[t.pos:], len(t.text)
+	} else {
+		line, t.pos = t.text[t.pos:t.pos+end], t.pos+end+1
+	}
+	t.line++
+	return line
+}
+
+// tripleQuoteBlock reads the block up to the next triple quote, which may
+// also be at the end of the last line of code.
+func (t *tokenizer) tripleQuoteBlock(info string) *CodeBlock {
+	block := &CodeBlock{}
+	block.Language, block.Path = parseInfo(info, true)
+	end := strings.Index(t.text[t.pos:], TripleQuote)
+	if end < 0 {
+		block.Content = t.text[t.pos:]
+		block.Reason = ReasonUnterminated
+		t.pos = len(t.text)
+		return block
+	}
+	block.Content = t.text[t.pos : t.pos+end]
+	t.line += strings.Count(block.Content, "\n")
+	t.pos += end + len(TripleQuote)
+	// Whatever follows the closing quotes, on the same line, is ignored.
+	if !t.done() {
+		t.nextLine()
+	}
+	return block
+}
+
+// fencedBlock reads a Markdown fenced block; fences nested inside it are
+// recognized because they have an info string when opening, while the
+// closing ones don't.
+func (t *tokenizer) fencedBlock(indent int, fence, info string) *CodeBlock {
+	block := &CodeBlock{}
+	block.Language, block.Path = parseInfo(info, false)
+	var content strings.Builder
+	depth := 0
+	for !t.done() {
+		line := t.nextLine()
+		if m := fenceRegex.FindStringSubmatch(line); m != nil &&
+			m[2][0] == fence[0] && len(m[2]) >= len(fence) {
+			if strings.TrimSpace(m[3]) != "" {
+				depth++
+			} else if depth == 0 {
+				block.Content = content.String()
+				return block
+			} else {
+				depth--
+			}
+		}
+		// Content lines are un-indented as much as the opening fence was.
+		for i := 0; i < indent && len(line) > 0 && (line[0] == ' ' || line[0] == '\t'); i++ {
+			line = line[1:]
+		}
+		content.WriteString(line)
+		content.WriteString("\n")
+	}
+	block.Content = content.String()
+	block.Reason = ReasonUnterminated
+	return block
+}
+
+// parseInfo extracts the language and the file path from the text following
+// the opening delimiter; for triple-quote blocks any word which is not a
+// known language is taken to be the file path.
+func parseInfo(info string, tripleQuote bool) (language, path string) {
+	for i, token := range strings.Fields(info) {
+		if m := infoAttrRegex.FindStringSubmatch(token); m != nil {
+			path = m[1]
+			continue
+		}
+		if i == 0 {
+			if lang, p, found := strings.Cut(token, ":"); found && knownLanguages[strings.ToLower(lang)] {
+				language, path = lang, p
+				continue
+			}
+			if knownLanguages[strings.ToLower(token)] && !extensionlessFiles[token] {
+				language = token
+				continue
+			}
+		}
+		if path == "" && (tripleQuote || looksLikePath(token)) {
+			path = token
+		} else if i == 0 && language == "" {
+			language = token
+		}
+	}
+	return
+}
+
+func looksLikePath(token string) bool {
+	return strings.ContainsAny(token, "./") || extensionlessFiles[token]
+}
+
+// attribute determines the block's path, if not already known, from its
+// header or caption, and validates it.
+func attribute(block *CodeBlock, caption string) {
+	first, rest, _ := strings.Cut(block.Content, "\n")
+	if m := headerRegex.FindStringSubmatch(first); m != nil && (block.Path == "" || block.Path == m[1]) {
+		block.Path = m[1]
+		block.Content = rest
+	}
+	if block.Path == "" {
+		if m := captionRegex.FindStringSubmatch(caption); m != nil && looksLikePath(m[1]) {
+			block.Path = m[1]
+		}
+	}
+	switch {
+	case block.Path == "" && block.Format == FormatUnifiedDiff:
+		// The paths can be in the diff's headers.
+	case block.Path == "":
+		block.Reason = ReasonNoPath
+	case !IsValidFilePath(block.Path):
+		block.Reason = ReasonInvalidPath
+	}
+}
+
+// editFormat detects whether the block contains edits, instead of a whole file.
+func editFormat(block CodeBlock) string {
+	lang := strings.ToLower(block.Language)
+	switch {
+	case diff.IsSearchReplace(block.Content):
+		return FormatSearchReplace
+	case lang == "diff" || lang == "patch":
+		return FormatUnifiedDiff
+	}
+	// Otherwise, the block must start as a diff, so that whole files which
+	// happen to contain something that looks like a diff are not mistaken.
+	start := strings.TrimLeft(block.Content, "\n")
+	for _, prefix := range []string{"diff ", "--- ", "@@ "} {
+		if strings.HasPrefix(start, prefix) && diff.IsUnified(block.Content) {
+			return FormatUnifiedDiff
+		}
+	}
+	return ""
+}
@@ -0,0 +1,80 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package preprocessors_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alertavert/gpt4-go/pkg/preprocessors"
+)
+
+var _ = Describe("TokenizeBotResponse", func() {
+	It("finds triple-quote blocks", func() {
+		blocks := preprocessors.TokenizeBotResponse(
+			"Here it is:\n'''pkg/my-server/main.go\npackage main\n'''\nDone.")
+		Expect(blocks).To(HaveLen(1))
+		Expect(blocks[0].Path).To(Equal("pkg/my-server/main.go"))
+		Expect(blocks[0].Content).To(Equal("package main\n"))
+		Expect(blocks[0].Line).To(Equal(2))
+		Expect(blocks[0].Attributed()).To(BeTrue())
+	})
+	It("finds Markdown fences with the path in the info string", func() {
+		blocks := preprocessors.TokenizeBotResponse("```go pkg/main.go\npackage main\n```\n" +
+			"```go:pkg/util.go\npackage pkg\n```\n" +
+			"~~~yaml title=\"configs/app.yaml\"\nname: app\n~~~\n" +
+			"```Makefile\nall:\n```\n")
+		Expect(blocks).To(HaveLen(4))
+		Expect(blocks[0].Language).To(Equal("go"))
+		Expect(blocks[0].Path).To(Equal("pkg/main.go"))
+		Expect(blocks[0].Content).To(Equal("package main\n"))
+		Expect(blocks[1].Path).To(Equal("pkg/util.go"))
+		Expect(blocks[2].Language).To(Equal("yaml"))
+		Expect(blocks[2].Path).To(Equal("configs/app.yaml"))
+		Expect(blocks[3].Path).To(Equal("Makefile"))
+	})
+	It("uses the header on the first line of the block, and removes it", func() {
+		blocks := preprocessors.TokenizeBotResponse("```go\n// file: cmd/main.go\npackage main\n```\n" +
+			"```python\n# path: scripts/run.py\nprint()\n```\n" +
+			"'''\npath: docs/README.md\n# Title\n'''\n")
+		Expect(blocks).To(HaveLen(3))
+		Expect(blocks[0].Path).To(Equal("cmd/main.go"))
+		Expect(blocks[0].Content).To(Equal("package main\n"))
+		Expect(blocks[1].Path).To(Equal("scripts/run.py"))
+		Expect(blocks[1].Content).To(Equal("print()\n"))
+		Expect(blocks[2].Path).To(Equal("docs/README.md"))
+		Expect(blocks[2].Content).To(Equal("# Title\n"))
+	})
+	It("uses a caption naming the file just before the block", func() {
+		blocks := preprocessors.TokenizeBotResponse("**`pkg/main.go`**:\n\n```go\npackage main\n```\n" +
+			"Run it with:\n```sh\ngo run .\n```\n")
+		Expect(blocks).To(HaveLen(2))
+		Expect(blocks[0].Path).To(Equal("pkg/main.go"))
+		Expect(blocks[1].Attributed()).To(BeFalse())
+		Expect(blocks[1].Reason).To(Equal(preprocessors.ReasonNoPath))
+		Expect(blocks[1].Language).To(Equal("sh"))
+	})
+	It("keeps nested fences inside the block", func() {
+		readme := "# Usage\n\n```sh\nmake build\n```\n\nThat's it.\n"
+		blocks := preprocessors.TokenizeBotResponse("```markdown README.md\n" + readme + "```\n")
+		Expect(blocks).To(HaveLen(1))
+		Expect(blocks[0].Path).To(Equal("README.md"))
+		Expect(blocks[0].Content).To(Equal(readme))
+
+		blocks = preprocessors.TokenizeBotResponse("````md docs/usage.md\n```\nplain\n```\n````\n")
+		Expect(blocks).To(HaveLen(1))
+		Expect(blocks[0].Content).To(Equal("```\nplain\n```\n"))
+	})
+	It("reports blocks with invalid paths or without an end", func() {
+		blocks := preprocessors.TokenizeBotResponse("'''../../etc/passwd\nroot\n'''\n```go main.go\npackage main\n")
+		Expect(blocks).To(HaveLen(2))
+		Expect(blocks[0].Reason).To(Equal(preprocessors.ReasonInvalidPath))
+		Expect(blocks[1].Reason).To(Equal(preprocessors.ReasonUnterminated))
+		Expect(blocks[1].Line).To(Equal(4))
+	})
+	It("ignores inline code", func() {
+		Expect(preprocessors.TokenizeBotResponse("Use ```go run``` to run it.\n")).To(BeEmpty())
+	})
+})
@@ -0,0 +1,85 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+// Package retry makes the requests to the OpenAI API resilient to transient
+// failures: they are retried with exponential backoff, honouring the delays
+// requested by the API, and fail fast while the API is down.
+package retry
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Backoff computes the delays between successive attempts, growing by
+// Multiplier from Initial up to Max.
+type Backoff struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+	// Jitter randomizes each delay between half and the whole of it, so
+	// that clients do not retry all at the same time.
+	Jitter bool
+}
+
+// Delay returns the delay before the given attempt (the first being 0).
+func (b Backoff) Delay(attempt int) time.Duration {
+	delay := float64(b.Initial) * math.Pow(b.Multiplier, float64(attempt))
+	if b.Max > 0 && delay > float64(b.Max) {
+		delay = float64(b.Max)
+	}
+	if b.Jitter {
+		delay = delay/2 + rand.Float64()*delay/2
+	}
+	return time.Duration(delay)
+}
+
+// Sleep waits for the delay, or until the context is done.
+func Sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// RetryAfter returns the delay requested by the API before retrying, from
+// the `Retry-After` header (in seconds, or as a date) or, failing that, the
+// OpenAI rate-limit headers (e.g., `x-ratelimit-reset-requests: 6m0s`).
+func RetryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	if value := header.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second, true
+		}
+		if date, err := http.ParseTime(value); err == nil {
+			return max(date.Sub(now), 0), true
+		}
+	}
+	if value := header.Get("Retry-After-Ms"); value != "" {
+		if ms, err := strconv.ParseFloat(value, 64); err == nil && ms >= 0 {
+			return time.Duration(ms * float64(time.Millisecond)), true
+		}
+	}
+	var delay time.Duration
+	found := false
+	for _, limit := range []string{"Requests", "Tokens"} {
+		// Only the exhausted limits need to be waited for.
+		remaining := header.Get("X-Ratelimit-Remaining-" + limit)
+		if remaining != "" && remaining != "0" {
+			continue
+		}
+		if d, err := time.ParseDuration(header.Get("X-Ratelimit-Reset-" + limit)); err == nil {
+			delay = max(delay, d)
+			found = true
+		}
+	}
+	return delay, found
+}
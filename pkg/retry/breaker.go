@@ -0,0 +1,94 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package retry
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned, without sending the request, while the API is
+// considered down.
+var ErrCircuitOpen = errors.New("OpenAI API unavailable, too many consecutive failures")
+
+// CircuitBreaker stops sending requests after Threshold consecutive failures
+// (the circuit is open) for Cooldown; after that, a single request is let
+// through, and its outcome closes the circuit, or opens it again.
+// It is safe for concurrent use.
+type CircuitBreaker struct {
+	// Threshold is the number of consecutive failures which open the
+	// circuit; the breaker is disabled if not positive.
+	Threshold int
+	Cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// Allow returns ErrCircuitOpen if the request should not be sent.
+func (b *CircuitBreaker) Allow() error {
+	_, err := b.allow()
+	return err
+}
+
+// allow also returns whether the request is the one probing the API, after
+// the cooldown; its outcome must be recorded, or the probe released.
+func (b *CircuitBreaker) allow() (bool, error) {
+	if b == nil || b.Threshold <= 0 {
+		return false, nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.Threshold {
+		return false, nil
+	}
+	if time.Now().Before(b.openUntil) || b.probing {
+		return false, ErrCircuitOpen
+	}
+	b.probing = true
+	return true, nil
+}
+
+// Release lets another request probe the API, without recording the outcome
+// of the current probe (e.g., because it was cancelled).
+func (b *CircuitBreaker) Release() {
+	if b == nil || b.Threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// Record keeps track of the outcome of a request.
+func (b *CircuitBreaker) Record(success bool) {
+	if b == nil || b.Threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	if success {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.Threshold {
+		b.openUntil = time.Now().Add(b.Cooldown)
+	}
+}
+
+// Open is true while requests are not being sent.
+func (b *CircuitBreaker) Open() bool {
+	if b == nil || b.Threshold <= 0 {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.failures >= b.Threshold && time.Now().Before(b.openUntil)
+}
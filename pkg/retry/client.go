@@ -0,0 +1,174 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package retry
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/alertavert/gpt4-go/pkg/config"
+)
+
+const (
+	DefaultMaxRetries       = 3
+	DefaultInitialBackoff   = 500 * time.Millisecond
+	DefaultMaxBackoff       = 30 * time.Second
+	DefaultBreakerThreshold = 5
+	DefaultBreakerCooldown  = 30 * time.Second
+	DefaultPollInterval     = time.Second
+	DefaultMaxPollInterval  = 10 * time.Second
+)
+
+// Doer sends the HTTP requests; it is implemented by http.Client, and is the
+// openai.HTTPDoer used by the OpenAI client.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Client retries the requests which failed with a transient error.
+type Client struct {
+	Doer       Doer
+	MaxRetries int
+	Backoff    Backoff
+	Breaker    *CircuitBreaker
+}
+
+// NewClient wraps `doer` (http.DefaultClient if nil) with the retries, and
+// the circuit breaker, configured in `cfg`.
+func NewClient(doer Doer, cfg config.OpenAIConfig) *Client {
+	if doer == nil {
+		doer = http.DefaultClient
+	}
+	if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = DefaultMaxRetries
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = DefaultInitialBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = DefaultMaxBackoff
+	}
+	if cfg.BreakerThreshold == 0 {
+		cfg.BreakerThreshold = DefaultBreakerThreshold
+	}
+	if cfg.BreakerCooldown <= 0 {
+		cfg.BreakerCooldown = DefaultBreakerCooldown
+	}
+	return &Client{
+		Doer:       doer,
+		MaxRetries: max(cfg.MaxRetries, 0),
+		Backoff: Backoff{
+			Initial:    cfg.InitialBackoff,
+			Max:        cfg.MaxBackoff,
+			Multiplier: 2,
+			Jitter:     true,
+		},
+		Breaker: &CircuitBreaker{Threshold: cfg.BreakerThreshold, Cooldown: cfg.BreakerCooldown},
+	}
+}
+
+// PollBackoff returns the intervals between the checks of a Run's progress.
+func PollBackoff(cfg config.OpenAIConfig) Backoff {
+	b := Backoff{Initial: cfg.PollInterval, Max: cfg.MaxPollInterval, Multiplier: 1.5}
+	if b.Initial <= 0 {
+		b.Initial = DefaultPollInterval
+	}
+	if b.Max <= 0 {
+		b.Max = DefaultMaxPollInterval
+	}
+	return b
+}
+
+// Retryable is true for the status codes of the transient failures.
+func Retryable(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// Do sends the request, retrying it if it fails with a Retryable status or,
+// for the idempotent requests, with a network error.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	probe, err := c.Breaker.allow()
+	if err != nil {
+		return nil, err
+	}
+	ctx := req.Context()
+	// The body of the request can only be sent again if it can be re-created.
+	replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				if probe {
+					c.Breaker.Release()
+				}
+				return nil, err
+			}
+			req.Body = body
+		}
+		resp, err := c.Doer.Do(req)
+		if ctx.Err() == nil {
+			// Rate limiting is not a sign that the API is down.
+			c.Breaker.Record(err == nil && resp.StatusCode < 500)
+		} else if probe {
+			// Cancelled, which says nothing about the API: another request will probe it.
+			c.Breaker.Release()
+		}
+
+		var delay time.Duration
+		switch {
+		case err != nil:
+			if ctx.Err() != nil || (req.Method != http.MethodGet && req.Method != http.MethodHead) {
+				return nil, err
+			}
+			delay = c.Backoff.Delay(attempt)
+		case !Retryable(resp.StatusCode):
+			return resp, nil
+		default:
+			delay = c.Backoff.Delay(attempt)
+			if after, ok := RetryAfter(resp.Header, time.Now()); ok {
+				if c.Backoff.Max > 0 && after > c.Backoff.Max {
+					// Not worth waiting for: the caller gets the error.
+					return resp, nil
+				}
+				delay = max(delay, after)
+			}
+		}
+		if attempt >= c.MaxRetries || !replayable {
+			return resp, err
+		}
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+		log.Warn().
+			Err(err).
+			Int("status", statusCode(resp)).
+			Str("url", req.URL.Path).
+			Int("attempt", attempt+1).
+			Dur("delay", delay).
+			Msg("OpenAI request failed, retrying")
+		if err := Sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+		if probe, err = c.Breaker.allow(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func statusCode(resp *http.Response) int {
+	if resp == nil {
+		return 0
+	}
+	return resp.StatusCode
+}
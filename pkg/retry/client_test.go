@@ -0,0 +1,201 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package retry_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alertavert/gpt4-go/pkg/config"
+	"github.com/alertavert/gpt4-go/pkg/retry"
+)
+
+// doerFunc adapts a function to the retry.Doer interface.
+type doerFunc func(*http.Request) (*http.Response, error)
+
+func (f doerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+var _ = Describe("Retries", func() {
+	Context("computing the delays", func() {
+		It("should grow exponentially, up to the maximum", func() {
+			b := retry.Backoff{Initial: time.Second, Max: 5 * time.Second, Multiplier: 2}
+			Expect(b.Delay(0)).To(Equal(time.Second))
+			Expect(b.Delay(2)).To(Equal(4 * time.Second))
+			Expect(b.Delay(3)).To(Equal(5 * time.Second))
+		})
+		It("should add jitter", func() {
+			b := retry.Backoff{Initial: time.Second, Multiplier: 2, Jitter: true}
+			for i := 0; i < 10; i++ {
+				Expect(b.Delay(1)).To(BeNumerically("~", 1500*time.Millisecond, 500*time.Millisecond))
+			}
+		})
+		It("should honour the delays requested by the API", func() {
+			now := time.Now()
+			retryAfter := func(h http.Header) time.Duration {
+				d, ok := retry.RetryAfter(h, now)
+				Expect(ok).To(BeTrue())
+				return d
+			}
+			h := http.Header{}
+			h.Set("Retry-After", "7")
+			Expect(retryAfter(h)).To(Equal(7 * time.Second))
+			h.Set("Retry-After", now.Add(time.Minute).UTC().Format(http.TimeFormat))
+			Expect(retryAfter(h)).To(BeNumerically("~", time.Minute, time.Second))
+
+			h = http.Header{}
+			h.Set("X-Ratelimit-Remaining-Requests", "10")
+			h.Set("X-Ratelimit-Reset-Requests", "1s")
+			h.Set("X-Ratelimit-Remaining-Tokens", "0")
+			h.Set("X-Ratelimit-Reset-Tokens", "6m0s")
+			Expect(retryAfter(h)).To(Equal(6 * time.Minute))
+
+			_, ok := retry.RetryAfter(http.Header{}, now)
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Context("sending the requests", func() {
+		var (
+			calls    atomic.Int32
+			statuses []int
+			headers  http.Header
+			bodies   []string
+			server   *httptest.Server
+			client   *retry.Client
+		)
+
+		BeforeEach(func() {
+			calls.Store(0)
+			statuses = nil
+			headers = http.Header{}
+			bodies = nil
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(calls.Add(1))
+				body, _ := io.ReadAll(r.Body)
+				bodies = append(bodies, string(body))
+				status := http.StatusOK
+				if n <= len(statuses) {
+					status = statuses[n-1]
+				}
+				for k, v := range headers {
+					w.Header()[k] = v
+				}
+				w.WriteHeader(status)
+			}))
+			client = retry.NewClient(server.Client(), config.OpenAIConfig{
+				InitialBackoff:   time.Millisecond,
+				MaxBackoff:       50 * time.Millisecond,
+				BreakerThreshold: 3,
+				BreakerCooldown:  time.Hour,
+			})
+		})
+		AfterEach(func() {
+			server.Close()
+		})
+
+		post := func() (*http.Response, error) {
+			req, _ := http.NewRequest("POST", server.URL+"/v1/threads/runs", strings.NewReader(`{"a":1}`))
+			return client.Do(req)
+		}
+
+		It("should retry rate-limited and failed requests, resending the body", func() {
+			statuses = []int{http.StatusTooManyRequests, http.StatusBadGateway}
+			resp, err := post()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(calls.Load()).To(BeEquivalentTo(3))
+			Expect(bodies).To(Equal([]string{`{"a":1}`, `{"a":1}`, `{"a":1}`}))
+		})
+
+		It("should give up after the maximum number of retries", func() {
+			statuses = []int{500, 500, 500, 500, 500}
+			client.Breaker = nil
+			resp, err := post()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusInternalServerError))
+			Expect(calls.Load()).To(BeEquivalentTo(retry.DefaultMaxRetries + 1))
+		})
+
+		It("should not retry the other errors", func() {
+			statuses = []int{http.StatusUnauthorized}
+			resp, err := post()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+			Expect(calls.Load()).To(BeEquivalentTo(1))
+		})
+
+		It("should not wait longer than the maximum backoff", func() {
+			statuses = []int{http.StatusTooManyRequests}
+			headers.Set("Retry-After", "60")
+			resp, err := post()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusTooManyRequests))
+			Expect(calls.Load()).To(BeEquivalentTo(1))
+		})
+
+		It("should fail fast once the API is down", func() {
+			statuses = []int{503, 503, 503, 503}
+			_, err := post()
+			Expect(err).To(MatchError(retry.ErrCircuitOpen))
+			Expect(calls.Load()).To(BeEquivalentTo(3))
+			Expect(client.Breaker.Open()).To(BeTrue())
+
+			_, err = post()
+			Expect(err).To(MatchError(retry.ErrCircuitOpen))
+			Expect(calls.Load()).To(BeEquivalentTo(3))
+		})
+
+		It("should let another request probe the API, if the probe is cancelled", func() {
+			client.Breaker = &retry.CircuitBreaker{Threshold: 1, Cooldown: time.Millisecond}
+			client.Breaker.Record(false)
+			time.Sleep(2 * time.Millisecond)
+
+			// The probe is cancelled while in flight.
+			ctx, cancel := context.WithCancel(context.Background())
+			client.Doer = doerFunc(func(req *http.Request) (*http.Response, error) {
+				cancel()
+				return nil, req.Context().Err()
+			})
+			req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/v1/assistants", nil)
+			_, err := client.Do(req)
+			Expect(err).To(MatchError(context.Canceled))
+
+			client.Doer = server.Client()
+			req, _ = http.NewRequest("GET", server.URL+"/v1/assistants", nil)
+			resp, err := client.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(client.Breaker.Open()).To(BeFalse())
+		})
+	})
+
+	Context("the circuit breaker", func() {
+		It("should let a request through after the cooldown", func() {
+			b := &retry.CircuitBreaker{Threshold: 2, Cooldown: 10 * time.Millisecond}
+			b.Record(false)
+			Expect(b.Allow()).To(Succeed())
+			b.Record(false)
+			Expect(b.Allow()).To(MatchError(retry.ErrCircuitOpen))
+
+			time.Sleep(20 * time.Millisecond)
+			Expect(b.Allow()).To(Succeed())
+			// Only one request at a time probes the API.
+			Expect(b.Allow()).To(MatchError(retry.ErrCircuitOpen))
+			b.Record(true)
+			Expect(b.Open()).To(BeFalse())
+			Expect(b.Allow()).To(Succeed())
+		})
+	})
+})
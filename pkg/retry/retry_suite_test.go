@@ -0,0 +1,21 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+package retry_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rs/zerolog"
+	"testing"
+)
+
+func TestRetry(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Retry Suite")
+}
+
+var _ = BeforeSuite(func() {
+	// Silence the logs
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+})
@@ -2,19 +2,47 @@ package server
 
 import (
 	"context"
+	"github.com/alertavert/gpt4-go/pkg/api"
 	"github.com/alertavert/gpt4-go/pkg/completions"
 	"github.com/gin-gonic/gin"
 	"net/http"
 )
 
-// projectsGetHandler handles the GET request for the '/projects' endpoint.
+// assistantsGetHandler handles the GET request for the '/assistants' endpoint.
 func assistantsGetHandler(s *completions.Majordomo) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		list, err := s.Client.ListAssistants(context.Background(), nil, nil, nil, nil)
+		list, err := s.Assistants.List(context.Background())
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			abortWithError(c, http.StatusInternalServerError, err.Error())
 			return
 		}
-		c.JSON(http.StatusOK, list.Assistants)
+		c.JSON(http.StatusOK, list)
+	}
+}
+
+// assistantsRefreshHandler handles the POST request for the
+// '/assistants/refresh' endpoint, listing the assistants again.
+func assistantsRefreshHandler(s *completions.Majordomo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := context.Background()
+		if err := s.Assistants.Refresh(ctx); err != nil {
+			abortWithError(c, http.StatusBadGateway, err.Error())
+			return
+		}
+		list, err := s.Assistants.List(ctx)
+		if err != nil {
+			abortWithError(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+		duplicates, err := s.Assistants.Duplicates(ctx)
+		if err != nil {
+			abortWithError(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, api.RefreshResponse{
+			Assistants:  len(list),
+			Duplicates:  duplicates,
+			RefreshedAt: s.Assistants.RefreshedAt(),
+		})
 	}
 }
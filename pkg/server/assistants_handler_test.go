@@ -0,0 +1,88 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sashabaranov/go-openai"
+
+	"github.com/alertavert/gpt4-go/pkg/completions"
+	"github.com/alertavert/gpt4-go/pkg/config"
+	"github.com/alertavert/gpt4-go/pkg/server"
+)
+
+// assistantsLister returns the assistants in a single page.
+type assistantsLister []openai.Assistant
+
+func (l *assistantsLister) ListAssistants(context.Context, *int, *string, *string,
+	*string) (openai.AssistantsList, error) {
+	return openai.AssistantsList{Assistants: *l}, nil
+}
+
+var _ = Describe("Assistants Handler", func() {
+	var (
+		router *gin.Engine
+		lister *assistantsLister
+	)
+
+	BeforeEach(func() {
+		cfgLoc, err := MkTempConfigFile(TestConfigLocation)
+		Expect(err).NotTo(HaveOccurred())
+		cfg, err := config.LoadConfig(cfgLoc)
+		Expect(err).NotTo(HaveOccurred())
+		assistant, err := completions.NewMajordomo(cfg)
+		Expect(err).NotTo(HaveOccurred())
+		name := "go_developer"
+		lister = &assistantsLister{{ID: "asst_1", Name: &name}}
+		assistant.Assistants = completions.NewAssistantRegistry(lister, 0)
+
+		gin.SetMode(gin.TestMode)
+		router = gin.New()
+		server.SetupTestRoutes(router, assistant)
+	})
+
+	do := func(method, url string) (*httptest.ResponseRecorder, []byte) {
+		req, _ := http.NewRequest(method, url, nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp, resp.Body.Bytes()
+	}
+
+	It("should list the cached assistants", func() {
+		resp, body := do("GET", "/api/v1/assistants")
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		var list []openai.Assistant
+		Expect(json.Unmarshal(body, &list)).To(Succeed())
+		Expect(list).To(HaveLen(1))
+		Expect(list[0].ID).To(Equal("asst_1"))
+
+		name := "go_developer"
+		*lister = append(*lister, openai.Assistant{ID: "asst_2", Name: &name})
+		_, body = do("GET", "/api/v1/assistants")
+		Expect(json.Unmarshal(body, &list)).To(Succeed())
+		Expect(list).To(HaveLen(1))
+	})
+
+	It("should refresh the assistants, reporting duplicate names", func() {
+		_, _ = do("GET", "/api/v1/assistants")
+		name := "go_developer"
+		*lister = append(*lister, openai.Assistant{ID: "asst_2", Name: &name})
+		resp, body := do("POST", "/api/v1/assistants/refresh")
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		var result map[string]interface{}
+		Expect(json.Unmarshal(body, &result)).To(Succeed())
+		Expect(result["assistants"]).To(BeEquivalentTo(2))
+		Expect(result["duplicates"]).To(HaveKeyWithValue("go_developer",
+			ConsistOf("asst_1", "asst_2")))
+		Expect(result).To(HaveKey("refreshed_at"))
+	})
+})
@@ -5,39 +5,98 @@
 package server
 
 import (
+	"errors"
+	"github.com/alertavert/gpt4-go/pkg/api"
+	"github.com/alertavert/gpt4-go/pkg/audio"
 	"github.com/alertavert/gpt4-go/pkg/completions"
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
 	"net/http"
 )
 
+// audioErrorStatus is the HTTP status for the errors in transcribing the audio.
+func audioErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, audio.ErrTooLarge), errors.Is(err, audio.ErrTooLong):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, audio.ErrUnsupported):
+		return http.StatusUnsupportedMediaType
+	}
+	return http.StatusInternalServerError
+}
+
+// audioHandler transcribes the spoken command POSTed as the `audio` file
+// and carries it out; a typed command can be sent as the `text` field instead.
 func audioHandler(m *completions.Majordomo) func(c *gin.Context) {
 	return func(c *gin.Context) {
-		file, header, err := c.Request.FormFile("audio")
-		if err != nil {
-			log.Err(err).Msg("error getting audio content POSTed to /command")
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
+		text := c.PostForm("text")
+		var transcription *completions.Transcription
+		if text == "" {
+			file, header, err := c.Request.FormFile("audio")
+			if err != nil {
+				log.Err(err).Msg("error getting audio content POSTed to /command")
+				status := http.StatusBadRequest
+				if bodyTooLarge(err) {
+					status = http.StatusRequestEntityTooLarge
+				}
+				abortWithError(c, status, err.Error())
+				return
+			}
+			defer file.Close()
 
-		// The name of the file is accessible as header.Filename
-		log.Debug().
-			Str("filename", header.Filename).
-			Str("Content-Type", header.Header.Get("Content-Type")).
-			Int("size", int(header.Size)).Msg("received audio file")
+			// The name of the file is accessible as header.Filename
+			log.Debug().
+				Str("filename", header.Filename).
+				Str("Content-Type", header.Header.Get("Content-Type")).
+				Int("size", int(header.Size)).Msg("received audio file")
 
-		text, err := m.SpeechToText(file)
+			transcription, err = m.Transcribe(file, completions.TranscriptionOptions{
+				Filename:    header.Filename,
+				ContentType: header.Header.Get("Content-Type"),
+				Language:    c.PostForm("language"),
+				Prompt:      c.PostForm("prompt"),
+				Translate:   c.PostForm("translate") == "true",
+			})
+			if err != nil {
+				log.Err(err).Msg("error converting audio to text")
+				abortWithError(c, audioErrorStatus(err), err.Error())
+				return
+			}
+			text = transcription.Text
+			log.Debug().
+				Str("text", text).
+				Msg("converted audio to text")
+		}
+
+		result, err := m.ExecuteCommand(c.Request.Context(), text)
 		if err != nil {
-			log.Err(err).Msg("error converting audio to text")
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			log.Err(err).Str("text", text).Msg("error executing command")
+			status := http.StatusInternalServerError
+			var commandErr *completions.CommandError
+			if errors.As(err, &commandErr) {
+				status = http.StatusUnprocessableEntity
+			}
+			abortWithDetails(c, status, err.Error(), map[string]any{"transcript": text})
 			return
 		}
-		log.Debug().
-			Str("text", text).
-			Msg("converted audio to text")
-		c.JSON(http.StatusOK, gin.H{
-			"response": "success",
-			"message":  text,
+		c.JSON(http.StatusOK, api.CommandResponse{
+			Message:       result.Message,
+			Transcript:    result.Transcript,
+			Intent:        result.Intent,
+			ActiveProject: result.ActiveProject,
+			Assistant:     result.Assistant,
+			ThreadId:      result.ThreadId,
+			ThreadName:    result.ThreadName,
+			Result:        botResponse(result.Response),
+			Transcription: transcription,
 		})
 	}
 }
+
+// botResponse is the LLM's response to the command, if it was a prompt.
+func botResponse(response *completions.PromptResponse) *api.BotResponse {
+	if response == nil {
+		return nil
+	}
+	return &response.BotResponse
+}
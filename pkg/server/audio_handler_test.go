@@ -0,0 +1,116 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package server_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alertavert/gpt4-go/pkg/completions"
+	"github.com/alertavert/gpt4-go/pkg/config"
+	"github.com/alertavert/gpt4-go/pkg/server"
+)
+
+var _ = Describe("Command Handler", func() {
+	var (
+		router *gin.Engine
+		tmpDir string
+	)
+
+	BeforeEach(func() {
+		cfgLoc, err := MkTempConfigFile(TestConfigLocation)
+		Expect(err).NotTo(HaveOccurred())
+		cfg, err := config.LoadConfig(cfgLoc)
+		Expect(err).NotTo(HaveOccurred())
+		tmpDir, err = os.MkdirTemp("", "command-test-")
+		Expect(err).NotTo(HaveOccurred())
+		cfg.ThreadsLocation = filepath.Join(tmpDir, "threads.json")
+		cfg.Voice.DisableLLMFallback = true
+		cfg.Voice.MaxAudioBytes = 1024
+
+		assistant, err := completions.NewMajordomo(cfg)
+		Expect(err).NotTo(HaveOccurred())
+		gin.SetMode(gin.TestMode)
+		router = gin.New()
+		server.SetupTestRoutes(router, assistant)
+	})
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	command := func(text string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		form := url.Values{"text": {text}}
+		req, _ := http.NewRequest("POST", "/api/v1/command", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		var body map[string]interface{}
+		Expect(json.Unmarshal(resp.Body.Bytes(), &body)).To(Succeed())
+		return resp, body
+	}
+
+	It("should carry out a typed command", func() {
+		resp, body := command("Switch to project actual.")
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		Expect(body["active_project"]).To(Equal("actual"))
+		Expect(body["transcript"]).To(Equal("Switch to project actual."))
+		Expect(body["intent"]).To(HaveKeyWithValue("name", completions.IntentSwitchProject))
+	})
+
+	It("should return 422 if the command cannot be carried out", func() {
+		resp, body := command("switch to project nowhere")
+		Expect(resp.Code).To(Equal(http.StatusUnprocessableEntity))
+		Expect(body["error"]).To(HaveKeyWithValue("code", "unprocessable"))
+		Expect(body["error"]).To(HaveKeyWithValue("message", ContainSubstring("nowhere")))
+		Expect(body["error"]).To(HaveKeyWithValue("details", HaveKeyWithValue("transcript", "switch to project nowhere")))
+	})
+
+	It("should return 400 without audio or text", func() {
+		req, _ := http.NewRequest("POST", "/api/v1/command", nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		Expect(resp.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	upload := func(filename string, data []byte) (*httptest.ResponseRecorder, map[string]interface{}) {
+		var buf bytes.Buffer
+		w := multipart.NewWriter(&buf)
+		part, err := w.CreateFormFile("audio", filename)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = part.Write(data)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(w.Close()).To(Succeed())
+		req, _ := http.NewRequest("POST", "/api/v1/command", &buf)
+		req.Header.Set("Content-Type", w.FormDataContentType())
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		var body map[string]interface{}
+		Expect(json.Unmarshal(resp.Body.Bytes(), &body)).To(Succeed())
+		return resp, body
+	}
+
+	It("should return 413 for recordings above the size limit", func() {
+		resp, body := upload("command.webm", append([]byte{0x1A, 0x45, 0xDF, 0xA3}, make([]byte, 2048)...))
+		Expect(resp.Code).To(Equal(http.StatusRequestEntityTooLarge))
+		Expect(body["error"]).To(HaveKeyWithValue("message", ContainSubstring("audio too large")))
+	})
+
+	It("should return 415 for unknown formats", func() {
+		resp, body := upload("notes.txt", []byte("not a recording"))
+		Expect(resp.Code).To(Equal(http.StatusUnsupportedMediaType))
+		Expect(body["error"]).To(HaveKeyWithValue("message", ContainSubstring("unsupported audio format")))
+	})
+})
@@ -0,0 +1,275 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+
+	"github.com/alertavert/gpt4-go/pkg/api"
+	"github.com/alertavert/gpt4-go/pkg/completions"
+	"github.com/alertavert/gpt4-go/pkg/preprocessors"
+	"github.com/alertavert/gpt4-go/pkg/retry"
+)
+
+const (
+	// chatWriteTimeout bounds the time to send an event to the client.
+	chatWriteTimeout = 10 * time.Second
+	// chatEventsBuffer is how many events are queued, while sending them to a
+	// slow client; the progress events are dropped when the queue is full.
+	chatEventsBuffer = 64
+)
+
+// The upgrader rejects the cross-origin requests, as browsers do not apply
+// CORS to WebSockets.
+var upgrader = websocket.Upgrader{}
+
+// chatSessions tracks the open WebSocket connections: they are hijacked from
+// the HTTP server, whose Shutdown does not wait for them.
+type chatSessions struct {
+	mu      sync.Mutex
+	conns   map[*websocket.Conn]struct{}
+	closing bool
+	wg      sync.WaitGroup
+}
+
+func newChatSessions() *chatSessions {
+	return &chatSessions{conns: make(map[*websocket.Conn]struct{})}
+}
+
+// add tracks the connection, until `done` is called; it fails once closing.
+func (cs *chatSessions) add(conn *websocket.Conn) (done func(), ok bool) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if cs.closing {
+		return nil, false
+	}
+	cs.conns[conn] = struct{}{}
+	cs.wg.Add(1)
+	return func() {
+		cs.mu.Lock()
+		delete(cs.conns, conn)
+		cs.mu.Unlock()
+		cs.wg.Done()
+	}, true
+}
+
+// close closes the connections (cancelling their running prompts), and waits
+// for their handlers to return, until `ctx` is done.
+func (cs *chatSessions) close(ctx context.Context) error {
+	cs.mu.Lock()
+	cs.closing = true
+	message := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	for conn := range cs.conns {
+		_ = conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second))
+		_ = conn.Close()
+	}
+	cs.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		cs.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// chatStatus is the HTTP status matching the errors of the ChatSession.
+func chatStatus(err error) int {
+	var rejected *preprocessors.RejectedPathsError
+	switch {
+	case errors.As(err, &rejected):
+		return http.StatusForbidden
+	case errors.Is(err, completions.ErrPromptRunning), errors.Is(err, completions.ErrNoPromptRunning):
+		return http.StatusConflict
+	case errors.Is(err, completions.ErrProjectNotFound), errors.Is(err, completions.ErrThreadNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, retry.ErrCircuitOpen):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadRequest
+}
+
+// chatError is the error sent to the client, in the `error` events.
+func chatError(err error) *api.Error {
+	var details map[string]any
+	var rejected *preprocessors.RejectedPathsError
+	if errors.As(err, &rejected) {
+		details = map[string]any{"rejected": rejected.Rejected}
+	}
+	return &api.Error{Code: api.CodeForStatus(chatStatus(err)), Message: err.Error(), Details: details}
+}
+
+// chatHandler opens an interactive session over a WebSocket, bound to the
+// project, assistant and Thread in the query; the client sends ChatMessages,
+// and receives ChatEvents (see api.ChatMessage), and can send another prompt
+// once the `response` (or `error`) to the previous one is received.
+func chatHandler(m *completions.Majordomo, sessions *chatSessions) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, err := completions.NewChatSession(m, m, completions.ChatState{
+			Project:    c.Query("project"),
+			Assistant:  c.Query("assistant"),
+			ThreadId:   c.Query("thread_id"),
+			ThreadName: c.Query("thread_name"),
+		}, c.GetHeader(UserHeader))
+		if err != nil {
+			abortWithError(c, chatStatus(err), err.Error())
+			return
+		}
+		conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			// The upgrader has already responded.
+			log.Warn().Err(err).Msg("cannot open the chat session")
+			return
+		}
+		defer conn.Close()
+		done, ok := sessions.add(conn)
+		if !ok {
+			_ = conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"),
+				time.Now().Add(time.Second))
+			return
+		}
+		defer done()
+		conn.SetReadLimit(orDefaultSize(m.Config.Server.MaxBodyBytes, DefaultMaxBodyBytes))
+
+		// The events are sent by a single writer, so that a slow client does
+		// not block the prompts reporting their progress.
+		events := make(chan api.ChatEvent, chatEventsBuffer)
+		written := make(chan struct{})
+		go func() {
+			defer close(written)
+			var failed bool
+			for event := range events {
+				if failed {
+					// Drained, so that the senders are not blocked.
+					continue
+				}
+				_ = conn.SetWriteDeadline(time.Now().Add(chatWriteTimeout))
+				if err := conn.WriteJSON(event); err != nil {
+					log.Debug().Err(err).Str("type", event.Type).Msg("cannot send the chat event")
+					failed = true
+				}
+			}
+		}()
+		// Runs once all the senders are done.
+		defer func() {
+			close(events)
+			<-written
+		}()
+		send := func(event api.ChatEvent) {
+			events <- event
+		}
+		// sendProgress never blocks: the progress is dropped if the client
+		// cannot keep up, while the outcome of the prompt is always sent.
+		sendProgress := func(event api.ChatEvent) {
+			select {
+			case events <- event:
+			default:
+				log.Debug().Str("id", event.ID).Msg("chat client too slow, progress dropped")
+			}
+		}
+		sendError := func(id string, err error) {
+			send(api.ChatEvent{Type: api.EventError, ID: id, Error: chatError(err)})
+		}
+		sendSession := func(id string) {
+			state := session.State()
+			send(api.ChatEvent{Type: api.EventSession, ID: id, Session: &state})
+		}
+
+		var prompts sync.WaitGroup
+		defer prompts.Wait()
+		// Cancels the prompt running, once the client is gone.
+		defer session.Close()
+		sendSession("")
+		log.Debug().Str("project", session.State().Project).Msg("chat session opened")
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure,
+					websocket.CloseGoingAway, websocket.CloseNoStatusReceived) {
+					log.Debug().Err(err).Msg("chat session closed unexpectedly")
+				}
+				return
+			}
+			var message api.ChatMessage
+			if err := json.Unmarshal(data, &message); err != nil {
+				sendError("", fmt.Errorf("invalid message: %w", err))
+				continue
+			}
+			switch message.Type {
+			case api.ChatPrompt:
+				prompts.Add(1)
+				go func(message api.ChatMessage) {
+					defer prompts.Done()
+					threadId := session.State().ThreadId
+					ctx := completions.WithProgress(context.Background(), func(p completions.Progress) {
+						sendProgress(api.ChatEvent{Type: api.EventProgress, ID: message.ID, Progress: &p})
+					})
+					response, err := session.Prompt(ctx, message.Prompt, message.Save)
+					if session.State().ThreadId != threadId {
+						sendSession(message.ID)
+					}
+					switch {
+					case errors.Is(err, completions.ErrPromptCancelled):
+						send(api.ChatEvent{Type: api.EventCancelled, ID: message.ID})
+					case err != nil:
+						sendError(message.ID, err)
+					default:
+						send(api.ChatEvent{Type: api.EventResponse, ID: message.ID, Response: &response.BotResponse})
+					}
+				}(message)
+			case api.ChatParse:
+				prompt, err := session.Parse(message.Prompt)
+				if err != nil {
+					sendError(message.ID, err)
+					continue
+				}
+				redactions := []preprocessors.Redaction{}
+				if prompt.Redactions != nil {
+					redactions = append(redactions, prompt.Redactions.Items...)
+				}
+				send(api.ChatEvent{Type: api.EventParsed, ID: message.ID, Parsed: &api.ParseResponse{
+					Message:    prompt.Prompt,
+					Redactions: redactions,
+				}})
+			case api.ChatCancel:
+				// The `cancelled` event is sent once the prompt has stopped.
+				if err := session.Cancel(); err != nil {
+					sendError(message.ID, err)
+				}
+			case api.ChatAssistant:
+				if err := session.SetAssistant(message.Assistant); err != nil {
+					sendError(message.ID, err)
+					continue
+				}
+				sendSession(message.ID)
+			default:
+				sendError(message.ID, fmt.Errorf("unknown message type: %q", message.Type))
+			}
+		}
+	}
+}
+
+func orDefaultSize(n, def int64) int64 {
+	if n <= 0 {
+		return def
+	}
+	return n
+}
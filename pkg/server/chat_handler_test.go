@@ -0,0 +1,155 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alertavert/gpt4-go/pkg/api"
+	"github.com/alertavert/gpt4-go/pkg/cassette"
+	"github.com/alertavert/gpt4-go/pkg/completions"
+	"github.com/alertavert/gpt4-go/pkg/config"
+	"github.com/alertavert/gpt4-go/pkg/server"
+)
+
+var _ = Describe("Chat sessions", func() {
+	var (
+		cfg    *config.Config
+		svr    *httptest.Server
+		conns  []*websocket.Conn
+		tmpDir string
+	)
+
+	BeforeEach(func() {
+		cfgLoc, err := MkTempConfigFile(TestConfigLocation)
+		Expect(err).NotTo(HaveOccurred())
+		cfg, err = config.LoadConfig(cfgLoc)
+		Expect(err).NotTo(HaveOccurred())
+		tmpDir, err = os.MkdirTemp("", "chat-test-")
+		Expect(err).NotTo(HaveOccurred())
+		cfg.ThreadsLocation = filepath.Join(tmpDir, "threads.json")
+		cfg.OpenAI.PollInterval = time.Millisecond
+		// The prompt is answered by the recorded interactions.
+		cfg.Cassette = config.CassetteConfig{
+			Mode:     string(cassette.ModeReplay),
+			Location: "../../testdata/cassettes/query_bot.yaml",
+		}
+	})
+	JustBeforeEach(func() {
+		assistant, err := completions.NewMajordomo(cfg)
+		Expect(err).NotTo(HaveOccurred())
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		server.SetupTestRoutes(router, assistant)
+		svr = httptest.NewServer(router)
+	})
+	AfterEach(func() {
+		for _, conn := range conns {
+			_ = conn.Close()
+		}
+		conns = nil
+		svr.Close()
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	dial := func(query url.Values) (*websocket.Conn, *http.Response, error) {
+		u := "ws" + strings.TrimPrefix(svr.URL, "http") + api.Prefix + "/ws?" + query.Encode()
+		return websocket.DefaultDialer.Dial(u, nil)
+	}
+	open := func(query url.Values) *websocket.Conn {
+		conn, _, err := dial(query)
+		Expect(err).NotTo(HaveOccurred())
+		conns = append(conns, conn)
+		return conn
+	}
+	receive := func(conn *websocket.Conn) api.ChatEvent {
+		var event api.ChatEvent
+		Expect(conn.SetReadDeadline(time.Now().Add(5 * time.Second))).To(Succeed())
+		Expect(conn.ReadJSON(&event)).To(Succeed())
+		return event
+	}
+
+	It("rejects the sessions without an assistant, or on unknown projects", func() {
+		_, resp, err := dial(url.Values{"project": {"actual"}})
+		Expect(err).To(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+		_, resp, err = dial(url.Values{"project": {"none"}, "assistant": {"go_developer"}})
+		Expect(err).To(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+	})
+
+	It("sends the prompts on a new Thread, reporting their progress", func() {
+		conn := open(url.Values{"project": {"actual"}, "assistant": {"go_developer"},
+			"thread_name": {"Greeting"}})
+		event := receive(conn)
+		Expect(event.Type).To(Equal(api.EventSession))
+		Expect(*event.Session).To(Equal(completions.ChatState{
+			Project: "actual", Assistant: "go_developer", ThreadName: "Greeting"}))
+
+		save := false
+		Expect(conn.WriteJSON(api.ChatMessage{Type: api.ChatPrompt, ID: "1",
+			Prompt: "Please greet the user by name:\n'''sample/main.go\n'''", Save: &save})).To(Succeed())
+		var stages []completions.ProgressStage
+		for event = receive(conn); event.Type == api.EventProgress; event = receive(conn) {
+			Expect(event.ID).To(Equal("1"))
+			stages = append(stages, event.Progress.Stage)
+			if event.Progress.Stage == completions.ProgressOutput {
+				Expect(event.Progress.Text).NotTo(BeEmpty())
+			}
+		}
+		Expect(stages[0]).To(Equal(completions.ProgressThread))
+		Expect(stages).To(ContainElement(completions.ProgressRun))
+		Expect(stages[len(stages)-1]).To(Equal(completions.ProgressOutput))
+
+		Expect(event.Type).To(Equal(api.EventSession))
+		Expect(event.Session.ThreadId).To(Equal("thread_Gr33t1ng"))
+		event = receive(conn)
+		Expect(event.Type).To(Equal(api.EventResponse))
+		Expect(event.ID).To(Equal("1"))
+		Expect(event.Response.Saved).To(BeFalse())
+	})
+
+	It("parses the prompts, switches the assistant, and reports the errors", func() {
+		conn := open(url.Values{"project": {"actual"}, "assistant": {"go_developer"}})
+		Expect(receive(conn).Type).To(Equal(api.EventSession))
+
+		Expect(conn.WriteJSON(api.ChatMessage{Type: api.ChatParse, ID: "p", Prompt: "Hello"})).To(Succeed())
+		event := receive(conn)
+		Expect(event.Type).To(Equal(api.EventParsed))
+		Expect(event.ID).To(Equal("p"))
+		Expect(event.Parsed.Message).To(ContainSubstring("Hello"))
+
+		Expect(conn.WriteJSON(api.ChatMessage{Type: api.ChatAssistant, Assistant: "web_developer"})).To(Succeed())
+		event = receive(conn)
+		Expect(event.Type).To(Equal(api.EventSession))
+		Expect(event.Session.Assistant).To(Equal("web_developer"))
+
+		Expect(conn.WriteJSON(api.ChatMessage{Type: api.ChatCancel, ID: "c"})).To(Succeed())
+		event = receive(conn)
+		Expect(event.Type).To(Equal(api.EventError))
+		Expect(event.ID).To(Equal("c"))
+		Expect(event.Error.Code).To(Equal(api.CodeConflict))
+
+		Expect(conn.WriteMessage(websocket.TextMessage, []byte("not json"))).To(Succeed())
+		event = receive(conn)
+		Expect(event.Type).To(Equal(api.EventError))
+		Expect(event.Error.Code).To(Equal(api.CodeInvalidRequest))
+
+		Expect(conn.WriteJSON(api.ChatMessage{Type: "dance"})).To(Succeed())
+		event = receive(conn)
+		Expect(event.Error.Message).To(ContainSubstring(`unknown message type: "dance"`))
+	})
+})
@@ -3,8 +3,12 @@
 package server
 
 import (
+	"errors"
+	"github.com/alertavert/gpt4-go/pkg/api"
 	"github.com/alertavert/gpt4-go/pkg/completions"
+	"github.com/alertavert/gpt4-go/pkg/retry"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,16 +20,39 @@ func threadGetByIdHandler(assistant *completions.Majordomo) gin.HandlerFunc {
 		threadId := c.Param("thread_id")
 
 		if projectName == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "project query parameter is required"})
+			abortWithError(c, http.StatusBadRequest, "project query parameter is required")
 			return
 		}
 
-		thread, found := assistant.Threads.GetThread(projectName, threadId)
+		thread, found := assistant.Threads.GetThread(assistant.ProjectID(projectName), threadId)
 		if !found {
-			c.JSON(http.StatusNotFound, gin.H{"error": "thread not found"})
+			abortWithError(c, http.StatusNotFound, "thread not found")
 			return
 		}
 
 		c.JSON(http.StatusOK, thread)
 	}
 }
+
+// threadMessagesHandler returns the messages of a thread, as retrieved from OpenAI.
+func threadMessagesHandler(m *completions.Majordomo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		threadId := c.Param("thread_id")
+		if _, found := m.Threads.GetThread(m.ProjectID(c.Query("project")), threadId); !found {
+			abortWithError(c, http.StatusNotFound, "thread not found")
+			return
+		}
+		// Validated against the OpenAPI document.
+		limit, _ := strconv.Atoi(c.Query("limit"))
+		messages, err := m.ThreadMessages(c.Request.Context(), threadId, limit)
+		if err != nil {
+			status := http.StatusBadGateway
+			if errors.Is(err, retry.ErrCircuitOpen) {
+				status = http.StatusServiceUnavailable
+			}
+			abortWithError(c, status, err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, api.ThreadMessagesResponse{ThreadId: threadId, Messages: messages})
+	}
+}
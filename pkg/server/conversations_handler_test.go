@@ -3,10 +3,13 @@
 package server_test
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 
+	"github.com/alertavert/gpt4-go/pkg/api"
+	"github.com/alertavert/gpt4-go/pkg/cassette"
 	"github.com/alertavert/gpt4-go/pkg/completions"
 	"github.com/alertavert/gpt4-go/pkg/config"
 	"github.com/alertavert/gpt4-go/pkg/conversations"
@@ -57,7 +60,7 @@ var _ = Describe("/conversations endpoint", func() {
 		Context("With valid parameters", func() {
 			It("should return the specific thread", func() {
 				req, _ := http.NewRequest("GET",
-					fmt.Sprintf("/conversations/%s?project=%s", testThread.ID, projectName),
+					fmt.Sprintf("/api/v1/conversations/%s?project=%s", testThread.ID, projectName),
 					nil)
 				resp := httptest.NewRecorder()
 
@@ -72,18 +75,18 @@ var _ = Describe("/conversations endpoint", func() {
 		Context("With invalid parameters", func() {
 			It("should return 400 when project parameter is missing", func() {
 				req, _ := http.NewRequest("GET",
-					fmt.Sprintf("/conversations/%s", testThread.ID),
+					fmt.Sprintf("/api/v1/conversations/%s", testThread.ID),
 					nil)
 				resp := httptest.NewRecorder()
 
 				router.ServeHTTP(resp, req)
 				Expect(resp.Code).To(Equal(http.StatusBadRequest))
-				Expect(resp.Body.String()).To(ContainSubstring("project query parameter is required"))
+				Expect(resp.Body.String()).To(ContainSubstring(`invalid query parameter \"project\"`))
 			})
 
 			It("should return 404 when thread doesn't exist", func() {
 				req, _ := http.NewRequest("GET",
-					fmt.Sprintf("/conversations/nonexistent?project=%s", projectName),
+					fmt.Sprintf("/api/v1/conversations/nonexistent?project=%s", projectName),
 					nil)
 				resp := httptest.NewRecorder()
 
@@ -93,4 +96,39 @@ var _ = Describe("/conversations endpoint", func() {
 			})
 		})
 	})
+
+	Describe("GET /conversations/:thread_id/messages", func() {
+		It("should return the messages, replayed from the OpenAI API", func() {
+			cfg.Cassette = config.CassetteConfig{
+				Mode:     string(cassette.ModeReplay),
+				Location: "../../testdata/cassettes/thread_messages.yaml",
+			}
+			replaying, err := completions.NewMajordomo(cfg)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(replaying.Threads.AddThread("actual", conversations.Thread{
+				ID: "thread_Gr33t1ng", Name: "Greeting", Assistant: "go_developer"})).To(Succeed())
+			router = gin.New()
+			server.SetupTestRoutes(router, replaying)
+
+			req, _ := http.NewRequest("GET", "/api/v1/conversations/thread_Gr33t1ng/messages?project=actual", nil)
+			resp := httptest.NewRecorder()
+			router.ServeHTTP(resp, req)
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			var response api.ThreadMessagesResponse
+			Expect(json.NewDecoder(resp.Body).Decode(&response)).To(Succeed())
+			Expect(response.ThreadId).To(Equal("thread_Gr33t1ng"))
+			Expect(response.Messages).To(HaveLen(2))
+			Expect(response.Messages[0].Role).To(Equal("user"))
+			Expect(response.Messages[1].Text).To(Equal("This greets the user by name."))
+		})
+
+		It("should return 404 when the thread is not in the project", func() {
+			req, _ := http.NewRequest("GET",
+				fmt.Sprintf("/api/v1/conversations/%s/messages?project=actual", testThread.ID), nil)
+			resp := httptest.NewRecorder()
+
+			router.ServeHTTP(resp, req)
+			Expect(resp.Code).To(Equal(http.StatusNotFound))
+		})
+	})
 })
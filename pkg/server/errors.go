@@ -0,0 +1,34 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/alertavert/gpt4-go/pkg/api"
+)
+
+// abortWithError writes the error response, in the api.ErrorResponse envelope.
+func abortWithError(c *gin.Context, status int, message string) {
+	abortWithDetails(c, status, message, nil)
+}
+
+// abortWithDetails is like abortWithError, with details specific to the error.
+func abortWithDetails(c *gin.Context, status int, message string, details map[string]any) {
+	c.AbortWithStatusJSON(status, api.ErrorResponse{Error: &api.Error{
+		Code:    api.CodeForStatus(status),
+		Message: message,
+		Details: details,
+	}})
+}
+
+// noRouteHandler reports the requests to unknown routes.
+func noRouteHandler(c *gin.Context) {
+	abortWithError(c, http.StatusNotFound,
+		fmt.Sprintf("no route for %s %s", c.Request.Method, c.Request.URL.Path))
+}
@@ -0,0 +1,55 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	"github.com/alertavert/gpt4-go/pkg/completions"
+)
+
+// fixTestsHandler handles the POST request for the '/projects/:project_name/fix-tests' endpoint.
+// It runs the project's tests and iteratively asks the assistant to fix them,
+// returning a report of every iteration.
+// The loop can run for longer than the server's write timeout, which does not
+// apply to it: it is bounded by the number of iterations, and the tests' timeout.
+func fixTestsHandler(m *completions.Majordomo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		projectName := c.Param("project_name")
+		if m.GetProject(projectName) == nil {
+			abortWithError(c, http.StatusNotFound, fmt.Sprintf("project '%s' not found", projectName))
+			return
+		}
+		var requestBody completions.FixTestsRequest
+		if err := c.ShouldBindJSON(&requestBody); err != nil {
+			abortWithError(c, http.StatusBadRequest, "Invalid request body: "+err.Error())
+			return
+		}
+		if err := requestBody.Validate(); err != nil {
+			abortWithError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+			log.Debug().Err(err).Msg("Cannot clear the write deadline, the report may be cut off")
+		}
+		log.Debug().
+			Str("project", projectName).
+			Strs("packages", requestBody.Packages).
+			Str("assistant", requestBody.Assistant).
+			Msg("Fixing tests")
+		report, err := m.FixTests(c.Request.Context(), projectName, &requestBody)
+		if err != nil {
+			log.Err(err).Str("project", projectName).Msg("Error fixing tests")
+			abortWithError(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, report)
+	}
+}
@@ -0,0 +1,86 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alertavert/gpt4-go/pkg/completions"
+	"github.com/alertavert/gpt4-go/pkg/config"
+	"github.com/alertavert/gpt4-go/pkg/server"
+)
+
+var _ = Describe("Fix Tests Handler", func() {
+	var router *gin.Engine
+
+	BeforeEach(func() {
+		cfgLoc, err := MkTempConfigFile(TestConfigLocation)
+		Expect(err).NotTo(HaveOccurred())
+		cfg, err := config.LoadConfig(cfgLoc)
+		Expect(err).NotTo(HaveOccurred())
+		assistant, err := completions.NewMajordomo(cfg)
+		Expect(err).NotTo(HaveOccurred())
+
+		gin.SetMode(gin.TestMode)
+		router = gin.New()
+		server.SetupTestRoutes(router, assistant)
+	})
+
+	Describe("POST /projects/:project_name/fix-tests", func() {
+		It("should return 404 for an unknown project", func() {
+			req, _ := http.NewRequest("POST", "/api/v1/projects/nonexistent/fix-tests",
+				strings.NewReader(`{"assistant": "go_developer"}`))
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+
+			router.ServeHTTP(resp, req)
+			Expect(resp.Code).To(Equal(http.StatusNotFound))
+		})
+		It("should return 400 if the assistant is missing", func() {
+			req, _ := http.NewRequest("POST", "/api/v1/projects/actual/fix-tests",
+				strings.NewReader(`{"packages": ["./..."]}`))
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+
+			router.ServeHTTP(resp, req)
+			Expect(resp.Code).To(Equal(http.StatusBadRequest))
+			Expect(resp.Body.String()).To(ContainSubstring(`property \"assistant\" is missing`))
+		})
+		It("should return 400 for a negative number of iterations", func() {
+			req, _ := http.NewRequest("POST", "/api/v1/projects/actual/fix-tests",
+				strings.NewReader(`{"assistant": "go_developer", "max_iterations": -1}`))
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+
+			router.ServeHTTP(resp, req)
+			Expect(resp.Code).To(Equal(http.StatusBadRequest))
+		})
+		It("should return 400 for a flag in place of a package", func() {
+			req, _ := http.NewRequest("POST", "/api/v1/projects/actual/fix-tests",
+				strings.NewReader(`{"assistant": "go_developer", "packages": ["-exec=/tmp/x", "./..."]}`))
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+
+			router.ServeHTTP(resp, req)
+			Expect(resp.Code).To(Equal(http.StatusBadRequest))
+		})
+		It("should report success without querying the LLM if the tests pass", func() {
+			req, _ := http.NewRequest("POST", "/api/v1/projects/actual/fix-tests",
+				strings.NewReader(`{"assistant": "go_developer", "packages": ["./pkg/..."]}`))
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+
+			router.ServeHTTP(resp, req)
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			Expect(resp.Body.String()).To(ContainSubstring(`"passed":true`))
+		})
+	})
+})
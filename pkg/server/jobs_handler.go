@@ -0,0 +1,83 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	"github.com/alertavert/gpt4-go/pkg/api"
+	"github.com/alertavert/gpt4-go/pkg/completions"
+)
+
+// jobsPostHandler queues the prompt POSTed to `/jobs`, to be run in the
+// background on the request's project (or the currently active one), and
+// returns the job immediately.
+func jobsPostHandler(m *completions.Majordomo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request completions.PromptRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			abortWithError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := request.Validate(); err != nil {
+			abortWithError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		requestUser(c, &request)
+		_, project, err := m.ProjectStore(request.Project)
+		if err != nil {
+			abortWithError(c, http.StatusNotFound, err.Error())
+			return
+		}
+		job, err := m.Jobs.Submit(project, request)
+		if err != nil {
+			log.Err(err).Msg("error queueing the prompt job")
+			abortWithError(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.Header("Location", api.Prefix+"/jobs/"+job.ID)
+		c.JSON(http.StatusAccepted, job)
+	}
+}
+
+// jobsGetHandler returns all the jobs, oldest first.
+func jobsGetHandler(m *completions.Majordomo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, m.Jobs.List())
+	}
+}
+
+// jobGetHandler returns the status of the job and, once completed, the response.
+func jobGetHandler(m *completions.Majordomo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		job, found := m.Jobs.Get(c.Param("job_id"))
+		if !found {
+			abortWithError(c, http.StatusNotFound, "job not found")
+			return
+		}
+		c.JSON(http.StatusOK, job)
+	}
+}
+
+// jobDeleteHandler cancels the job, if it has not finished yet.
+func jobDeleteHandler(m *completions.Majordomo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		job, err := m.Jobs.Cancel(c.Param("job_id"))
+		switch {
+		case errors.Is(err, completions.ErrJobNotFound):
+			abortWithError(c, http.StatusNotFound, err.Error())
+		case errors.Is(err, completions.ErrJobFinished):
+			abortWithError(c, http.StatusConflict, err.Error())
+		case err != nil:
+			abortWithError(c, http.StatusInternalServerError, err.Error())
+		default:
+			c.JSON(http.StatusOK, job)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alertavert/gpt4-go/pkg/api"
+	"github.com/alertavert/gpt4-go/pkg/completions"
+	"github.com/alertavert/gpt4-go/pkg/config"
+	"github.com/alertavert/gpt4-go/pkg/server"
+)
+
+// blockedRunner never completes the prompts, until they are cancelled.
+type blockedRunner struct{}
+
+func (blockedRunner) StartPrompt(context.Context, *completions.PromptRequest, string) (string, error) {
+	return "run_1", nil
+}
+
+func (blockedRunner) FinishPrompt(ctx context.Context, _ *completions.PromptRequest, _,
+	_ string) (*completions.PromptResponse, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (blockedRunner) CancelRun(string, string) error {
+	return nil
+}
+
+var _ = Describe("Jobs Handler", func() {
+	var (
+		router    *gin.Engine
+		assistant *completions.Majordomo
+	)
+
+	BeforeEach(func() {
+		cfgLoc, err := MkTempConfigFile(TestConfigLocation)
+		Expect(err).NotTo(HaveOccurred())
+		cfg, err := config.LoadConfig(cfgLoc)
+		Expect(err).NotTo(HaveOccurred())
+		assistant, err = completions.NewMajordomo(cfg)
+		Expect(err).NotTo(HaveOccurred())
+		assistant.Jobs, err = completions.NewJobQueue(blockedRunner{}, "", 1)
+		Expect(err).NotTo(HaveOccurred())
+
+		gin.SetMode(gin.TestMode)
+		router = gin.New()
+		server.SetupTestRoutes(router, assistant)
+	})
+
+	do := func(method, url string, body interface{}) (*httptest.ResponseRecorder, *completions.Job) {
+		var data []byte
+		if body != nil {
+			data, _ = json.Marshal(body)
+		}
+		req, _ := http.NewRequest(method, url, bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		var job completions.Job
+		_ = json.Unmarshal(resp.Body.Bytes(), &job)
+		return resp, &job
+	}
+
+	It("should queue, report and cancel the jobs", func() {
+		resp, job := do("POST", "/api/v1/jobs", completions.PromptRequest{PromptRequest: api.PromptRequest{Assistant: "go_developer", Prompt: "Hi"}})
+		Expect(resp.Code).To(Equal(http.StatusAccepted))
+		Expect(resp.Header().Get("Location")).To(Equal("/api/v1/jobs/" + job.ID))
+		Expect(job.Project).To(Equal(assistant.Config.ActiveProject))
+
+		Eventually(func() completions.JobStatus {
+			_, j := do("GET", "/api/v1/jobs/"+job.ID, nil)
+			return j.Status
+		}).Should(Equal(completions.JobRunning))
+
+		resp, job = do("DELETE", "/api/v1/jobs/"+job.ID, nil)
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		Expect(job.Status).To(Equal(completions.JobCancelled))
+		resp, _ = do("DELETE", "/api/v1/jobs/"+job.ID, nil)
+		Expect(resp.Code).To(Equal(http.StatusConflict))
+		assistant.Jobs.Wait()
+
+		resp, _ = do("GET", "/api/v1/jobs", nil)
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		var jobs []completions.Job
+		Expect(json.Unmarshal(resp.Body.Bytes(), &jobs)).To(Succeed())
+		Expect(jobs).To(HaveLen(1))
+	})
+
+	It("should reject invalid prompts", func() {
+		resp, _ := do("POST", "/api/v1/jobs", completions.PromptRequest{PromptRequest: api.PromptRequest{Assistant: "go_developer"}})
+		Expect(resp.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("should return 404 for unknown jobs", func() {
+		resp, _ := do("GET", "/api/v1/jobs/job_none", nil)
+		Expect(resp.Code).To(Equal(http.StatusNotFound))
+		resp, _ = do("DELETE", "/api/v1/jobs/job_none", nil)
+		Expect(resp.Code).To(Equal(http.StatusNotFound))
+	})
+})
@@ -0,0 +1,31 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package server
+
+import (
+	"github.com/alertavert/gpt4-go/pkg/completions"
+	"github.com/alertavert/gpt4-go/pkg/metrics"
+	"github.com/gin-gonic/gin"
+)
+
+// metricsHandler serves the metrics in the Prometheus exposition format;
+// the size of the thread store is sampled at every scrape, and labelled
+// with the current names of the projects.
+func metricsHandler(m *completions.Majordomo) gin.HandlerFunc {
+	handler := metrics.Handler()
+	return func(c *gin.Context) {
+		metrics.Threads.Reset()
+		if m.Threads != nil {
+			for id, count := range m.Threads.Counts() {
+				project := id
+				if p := m.GetProjectByID(id); p != nil {
+					project = p.Name
+				}
+				metrics.Threads.WithLabelValues(project).Set(float64(count))
+			}
+		}
+		handler.ServeHTTP(c.Writer, c.Request)
+	}
+}
@@ -0,0 +1,92 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alertavert/gpt4-go/pkg/completions"
+	"github.com/alertavert/gpt4-go/pkg/config"
+	"github.com/alertavert/gpt4-go/pkg/conversations"
+	"github.com/alertavert/gpt4-go/pkg/server"
+)
+
+var _ = Describe("Metrics Handler", func() {
+	var (
+		router    *gin.Engine
+		assistant *completions.Majordomo
+		tmpDir    string
+	)
+
+	BeforeEach(func() {
+		cfgLoc, err := MkTempConfigFile(TestConfigLocation)
+		Expect(err).NotTo(HaveOccurred())
+		cfg, err := config.LoadConfig(cfgLoc)
+		Expect(err).NotTo(HaveOccurred())
+		tmpDir, err = os.MkdirTemp("", "metrics-test-")
+		Expect(err).NotTo(HaveOccurred())
+		cfg.ThreadsLocation = filepath.Join(tmpDir, "threads.json")
+		assistant, err = completions.NewMajordomo(cfg)
+		Expect(err).NotTo(HaveOccurred())
+
+		gin.SetMode(gin.TestMode)
+		router = gin.New()
+		server.SetupTestRoutes(router, assistant)
+	})
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	scrape := func() string {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/metrics", nil)
+		router.ServeHTTP(w, req)
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Header().Get("Content-Type")).To(ContainSubstring("text/plain"))
+		return w.Body.String()
+	}
+
+	It("counts the requests by route", func() {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/health", nil)
+		router.ServeHTTP(w, req)
+		Expect(w.Code).To(Equal(http.StatusOK))
+
+		body := scrape()
+		Expect(body).To(ContainSubstring(
+			`majordomo_http_requests_total{method="GET",route="/health",status="200"}`))
+		Expect(body).To(ContainSubstring(
+			`majordomo_http_request_duration_seconds_count{method="GET",route="/health"}`))
+	})
+
+	It("labels the requests by route, not by path", func() {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/jobs/job_missing", nil)
+		router.ServeHTTP(w, req)
+		Expect(w.Code).To(Equal(http.StatusNotFound))
+
+		body := scrape()
+		Expect(body).To(ContainSubstring(
+			`majordomo_http_requests_total{method="GET",route="/api/v1/jobs/:job_id",status="404"}`))
+		Expect(body).NotTo(ContainSubstring("job_missing"))
+	})
+
+	It("reports the number of threads per project", func() {
+		Expect(assistant.Threads.AddThread("metrics-project", conversations.Thread{
+			ID:        "thread_metrics",
+			Name:      "Metrics",
+			Assistant: "test-assistant",
+		})).To(Succeed())
+
+		Expect(scrape()).To(ContainSubstring(`majordomo_threads{project="metrics-project"} 1`))
+	})
+})
@@ -0,0 +1,159 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package server
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/gin-gonic/gin"
+
+	"github.com/alertavert/gpt4-go/pkg/api"
+)
+
+//go:embed openapi.yaml
+var openAPIDocument []byte
+
+var loadSpec = sync.OnceValues(func() (*openapi3.T, error) {
+	loader := openapi3.NewLoader()
+	spec, err := loader.LoadFromData(openAPIDocument)
+	if err != nil {
+		return nil, fmt.Errorf("error loading the OpenAPI document: %w", err)
+	}
+	if err = spec.Validate(loader.Context); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+	return spec, nil
+})
+
+// Spec returns the OpenAPI document of the API, which is validated when first loaded.
+func Spec() (*openapi3.T, error) {
+	return loadSpec()
+}
+
+// mustSpec returns the OpenAPI document; it is embedded, and checked by the
+// tests, so that failing to load it is a bug.
+func mustSpec() *openapi3.T {
+	spec, err := Spec()
+	if err != nil {
+		panic(err)
+	}
+	return spec
+}
+
+// ginParams are the parameters in gin's routes, e.g. `:project_name` or `*path`.
+var ginParams = regexp.MustCompile(`[:*]([^/]+)`)
+
+// SpecPath is the path, in the OpenAPI document, of gin's route, e.g.
+// `/projects/{project_name}` for `/api/v1/projects/:project_name`.
+func SpecPath(route string) string {
+	return ginParams.ReplaceAllString(strings.TrimPrefix(route, api.Prefix), "{$1}")
+}
+
+// openAPIHandler serves the OpenAPI document, as JSON.
+func openAPIHandler(spec *openapi3.T) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, spec)
+	}
+}
+
+// validateRequest rejects the requests which do not match the OpenAPI
+// document (their parameters, and their JSON body); the operation is that
+// of the route matched by gin.
+func validateRequest(spec *openapi3.T) gin.HandlerFunc {
+	options := &openapi3filter.Options{
+		// The requests are not changed.
+		SkipSettingDefaults: true,
+	}
+	options.WithCustomSchemaErrorFunc(func(err *openapi3.SchemaError) string {
+		return fmt.Sprintf("/%s: %s", strings.Join(err.JSONPointer(), "/"), err.Reason)
+	})
+	// The audio files are validated by the handler, while reading them, rather
+	// than buffering them in memory.
+	multipart := *options
+	multipart.ExcludeRequestBody = true
+
+	return func(c *gin.Context) {
+		path := SpecPath(c.FullPath())
+		pathItem := spec.Paths.Value(path)
+		if pathItem == nil {
+			c.Next()
+			return
+		}
+		operation := pathItem.GetOperation(c.Request.Method)
+		if operation == nil {
+			c.Next()
+			return
+		}
+		params := make(map[string]string, len(c.Params))
+		for _, p := range c.Params {
+			// The catch-all parameters start with a slash.
+			params[p.Key] = strings.TrimPrefix(p.Value, "/")
+		}
+		input := &openapi3filter.RequestValidationInput{
+			Request:    c.Request,
+			PathParams: params,
+			Route: &routers.Route{
+				Spec:      spec,
+				Path:      path,
+				PathItem:  pathItem,
+				Method:    c.Request.Method,
+				Operation: operation,
+			},
+			Options: options,
+		}
+		if mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type")); mediaType == "multipart/form-data" {
+			input.Options = &multipart
+		}
+		if err := openapi3filter.ValidateRequest(c.Request.Context(), input); err != nil {
+			status := http.StatusBadRequest
+			if bodyTooLarge(err) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			abortWithError(c, status, validationMessage(err))
+			return
+		}
+		c.Next()
+	}
+}
+
+// validationMessage describes what is wrong with the request, without the
+// details of the schema.
+func validationMessage(err error) string {
+	var requestErr *openapi3filter.RequestError
+	if !errors.As(err, &requestErr) {
+		return err.Error()
+	}
+	var what string
+	switch {
+	case requestErr.Parameter != nil:
+		what = fmt.Sprintf("invalid %s parameter %q", requestErr.Parameter.In, requestErr.Parameter.Name)
+	case requestErr.RequestBody != nil:
+		what = "invalid request body"
+	default:
+		return requestErr.Error()
+	}
+	reason := requestErr.Reason
+	if requestErr.Err != nil {
+		if cause := requestErr.Err.Error(); reason == "" || reason == cause {
+			reason = cause
+		} else {
+			reason += ": " + cause
+		}
+	}
+	if reason == "" {
+		return what
+	}
+	return what + ": " + reason
+}
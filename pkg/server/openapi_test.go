@@ -0,0 +1,158 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/gin-gonic/gin"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alertavert/gpt4-go/pkg/api"
+	"github.com/alertavert/gpt4-go/pkg/completions"
+	"github.com/alertavert/gpt4-go/pkg/config"
+	"github.com/alertavert/gpt4-go/pkg/server"
+)
+
+var _ = Describe("OpenAPI", func() {
+	var (
+		router *gin.Engine
+		spec   *openapi3.T
+		tmpDir string
+	)
+
+	BeforeEach(func() {
+		var err error
+		spec, err = server.Spec()
+		Expect(err).NotTo(HaveOccurred())
+
+		cfgLoc, err := MkTempConfigFile(TestConfigLocation)
+		Expect(err).NotTo(HaveOccurred())
+		cfg, err := config.LoadConfig(cfgLoc)
+		Expect(err).NotTo(HaveOccurred())
+		tmpDir, err = os.MkdirTemp("", "openapi-test-")
+		Expect(err).NotTo(HaveOccurred())
+		cfg.ThreadsLocation = filepath.Join(tmpDir, "threads.json")
+		cfg.JobsLocation = filepath.Join(tmpDir, "jobs.json")
+		assistant, err := completions.NewMajordomo(cfg)
+		Expect(err).NotTo(HaveOccurred())
+
+		gin.SetMode(gin.TestMode)
+		router = gin.New()
+		server.SetupTestRoutes(router, assistant)
+	})
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	// specPath is the path of the route in the document, where the routes
+	// outside the API are documented with their own server.
+	// The routes outside of the API (e.g., /health) keep their paths.
+	specPath := server.SpecPath
+
+	// do sends the request, and checks that the response is the one documented
+	// for the operation at `path`.
+	do := func(method, path, target, body string) *httptest.ResponseRecorder {
+		var reader io.Reader
+		if body != "" {
+			reader = strings.NewReader(body)
+		}
+		req := httptest.NewRequest(method, target, reader)
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		pathItem := spec.Paths.Value(path)
+		Expect(pathItem).NotTo(BeNil(), "no path %s in the document", path)
+		err := openapi3filter.ValidateResponse(context.Background(), &openapi3filter.ResponseValidationInput{
+			RequestValidationInput: &openapi3filter.RequestValidationInput{
+				Request: req,
+				Route: &routers.Route{Spec: spec, Path: path, PathItem: pathItem, Method: method,
+					Operation: pathItem.GetOperation(method)},
+			},
+			Status:  resp.Code,
+			Header:  resp.Header(),
+			Body:    io.NopCloser(strings.NewReader(resp.Body.String())),
+			Options: &openapi3filter.Options{IncludeResponseStatus: true},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		return resp
+	}
+
+	It("documents all the routes, and only those", func() {
+		routed := make(map[string]bool)
+		for _, route := range router.Routes() {
+			path := specPath(route.Path)
+			routed[route.Method+" "+path] = true
+			pathItem := spec.Paths.Value(path)
+			Expect(pathItem).NotTo(BeNil(), "%s %s is not documented", route.Method, route.Path)
+			Expect(pathItem.GetOperation(route.Method)).NotTo(BeNil(),
+				"%s %s is not documented", route.Method, route.Path)
+		}
+		for path, pathItem := range spec.Paths.Map() {
+			for method := range pathItem.Operations() {
+				Expect(routed).To(HaveKey(method+" "+path), "%s %s is not routed", method, path)
+			}
+		}
+	})
+
+	It("serves the document", func() {
+		resp := do(http.MethodGet, "/openapi.json", api.Prefix+"/openapi.json", "")
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		var served openapi3.T
+		Expect(json.Unmarshal(resp.Body.Bytes(), &served)).To(Succeed())
+		Expect(served.OpenAPI).To(Equal(spec.OpenAPI))
+		Expect(served.Paths.Value("/prompt")).NotTo(BeNil())
+	})
+
+	It("returns the documented responses", func() {
+		Expect(do(http.MethodGet, "/health", "/health", "").Code).To(Equal(http.StatusOK))
+		Expect(do(http.MethodGet, "/projects", api.Prefix+"/projects", "").Code).To(Equal(http.StatusOK))
+		Expect(do(http.MethodGet, "/projects/{project_name}",
+			api.Prefix+"/projects/nonexistent", "").Code).To(Equal(http.StatusNotFound))
+		Expect(do(http.MethodGet, "/jobs", api.Prefix+"/jobs", "").Code).To(Equal(http.StatusOK))
+		Expect(do(http.MethodGet, "/jobs/{job_id}", api.Prefix+"/jobs/job_none", "").Code).To(Equal(http.StatusNotFound))
+		Expect(do(http.MethodPost, "/parse", api.Prefix+"/parse",
+			`{"assistant": "default", "prompt": "Hello"}`).Code).To(Equal(http.StatusOK))
+	})
+
+	It("rejects the requests which do not match the document", func() {
+		resp := do(http.MethodPost, "/prompt", api.Prefix+"/prompt", `{"prompt": "Hello", "save": "yes"}`)
+		Expect(resp.Code).To(Equal(http.StatusBadRequest))
+		var body api.ErrorResponse
+		Expect(json.Unmarshal(resp.Body.Bytes(), &body)).To(Succeed())
+		Expect(body.Error.Code).To(Equal(api.CodeInvalidRequest))
+		Expect(body.Error.Message).To(HavePrefix("invalid request body"))
+
+		resp = do(http.MethodGet, "/projects/{project_name}/snippets/{path}",
+			api.Prefix+"/projects/nonexistent/snippets/main.go?diff=maybe", "")
+		Expect(resp.Code).To(Equal(http.StatusBadRequest))
+		Expect(json.Unmarshal(resp.Body.Bytes(), &body)).To(Succeed())
+		Expect(body.Error.Message).To(ContainSubstring(`invalid query parameter "diff"`))
+	})
+
+	It("reports the unknown routes in the error envelope", func() {
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/prompt", nil))
+		Expect(resp.Code).To(Equal(http.StatusNotFound))
+		var body api.ErrorResponse
+		Expect(json.Unmarshal(resp.Body.Bytes(), &body)).To(Succeed())
+		Expect(body.Error.Code).To(Equal(api.CodeNotFound))
+		Expect(body.Error.Message).To(Equal("no route for GET /prompt"))
+	})
+})
@@ -5,8 +5,11 @@
 package server
 
 import (
+	"errors"
 	"fmt"
+	"github.com/alertavert/gpt4-go/pkg/api"
 	"github.com/alertavert/gpt4-go/pkg/completions"
+	"github.com/alertavert/gpt4-go/pkg/preprocessors"
 	"github.com/gin-gonic/gin"
 	"net/http"
 )
@@ -17,32 +20,38 @@ func parsePromptHandler(m *completions.Majordomo) func(c *gin.Context) {
 	return func(c *gin.Context) {
 		var requestBody completions.PromptRequest
 		if err := c.ShouldBindJSON(&requestBody); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"response": "error",
-				"message":  "Invalid JSON format: " + err.Error(),
-			})
+			abortWithError(c, http.StatusBadRequest, "Invalid JSON format: "+err.Error())
 			return
 		}
 
 		// Validate required fields
 		if err := requestBody.Validate(); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"response": "error",
-				"message":  fmt.Sprintf("Request has missing fields: %s", err.Error()),
-			})
+			abortWithError(c, http.StatusBadRequest,
+				fmt.Sprintf("Request has missing fields: %s", err.Error()))
 			return
 		}
 		if err := m.PreparePrompt(&requestBody); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"response": "error",
-				"message":  err.Error(),
-			})
+			var rejected *preprocessors.RejectedPathsError
+			if errors.As(err, &rejected) {
+				abortWithDetails(c, http.StatusForbidden, err.Error(),
+					map[string]any{"rejected": rejected.Rejected})
+				return
+			}
+			status := http.StatusInternalServerError
+			if errors.Is(err, completions.ErrProjectNotFound) {
+				status = http.StatusNotFound
+			}
+			abortWithError(c, status, err.Error())
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{
-			"response": "success",
-			"message":  requestBody.Prompt,
+		redactions := []preprocessors.Redaction{}
+		if requestBody.Redactions != nil {
+			redactions = append(redactions, requestBody.Redactions.Items...)
+		}
+		c.JSON(http.StatusOK, api.ParseResponse{
+			Message:    requestBody.Prompt,
+			Redactions: redactions,
 		})
 	}
 }
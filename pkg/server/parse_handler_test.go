@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 
+	"github.com/alertavert/gpt4-go/pkg/api"
 	"github.com/alertavert/gpt4-go/pkg/completions"
 	"github.com/alertavert/gpt4-go/pkg/config"
 	"github.com/alertavert/gpt4-go/pkg/server"
@@ -41,13 +42,13 @@ var _ = Describe("Parse Handler", func() {
 	Describe("POST /parse", func() {
 		Context("with valid request body", func() {
 			It("should successfully parse a simple prompt", func() {
-				promptReq := completions.PromptRequest{
+				promptReq := completions.PromptRequest{PromptRequest: api.PromptRequest{
 					Prompt:    "Write a simple hello world program",
 					Assistant: "default",
 					ThreadName: "test-thread",
-				}
+				}}
 				body, _ := json.Marshal(promptReq)
-				req, _ := http.NewRequest("POST", "/parse", bytes.NewBuffer(body))
+				req, _ := http.NewRequest("POST", "/api/v1/parse", bytes.NewBuffer(body))
 				req.Header.Set("Content-Type", "application/json")
 				resp := httptest.NewRecorder()
 
@@ -56,18 +57,81 @@ var _ = Describe("Parse Handler", func() {
 				Expect(resp.Code).To(Equal(http.StatusOK))
 				var response map[string]interface{}
 				Expect(json.Unmarshal(resp.Body.Bytes(), &response)).ShouldNot(HaveOccurred())
-				Expect(response["response"]).To(Equal("success"))
+				Expect(response).NotTo(HaveKey("error"))
 				Expect(response["message"]).To(Equal(promptReq.Prompt))
 			})
 
 			It("should successfully parse a prompt with code references", func() {
-				promptReq := completions.PromptRequest{
+				promptReq := completions.PromptRequest{PromptRequest: api.PromptRequest{
 					Prompt:    "Update the code in `main.go`",
 					Assistant: "default",
 					ThreadName: "test-thread",
-				}
+				}}
+				body, _ := json.Marshal(promptReq)
+				req, _ := http.NewRequest("POST", "/api/v1/parse", bytes.NewBuffer(body))
+				req.Header.Set("Content-Type", "application/json")
+				resp := httptest.NewRecorder()
+
+				router.ServeHTTP(resp, req)
+
+				Expect(resp.Code).To(Equal(http.StatusOK))
+				var response map[string]interface{}
+				Expect(json.Unmarshal(resp.Body.Bytes(), &response)).ShouldNot(HaveOccurred())
+				Expect(response).NotTo(HaveKey("error"))
+				Expect(response["redactions"]).To(BeEmpty())
+			})
+		})
+		Context("with a prompt containing secrets", func() {
+			It("should redact them and report the redactions", func() {
+				promptReq := completions.PromptRequest{PromptRequest: api.PromptRequest{
+					Prompt:     "Why is my key sk-proj-abcdefghijklmnopqrstuvwxyz0123 not working?",
+					Assistant:  "default",
+					ThreadName: "test-thread",
+				}}
+				body, _ := json.Marshal(promptReq)
+				req, _ := http.NewRequest("POST", "/api/v1/parse", bytes.NewBuffer(body))
+				req.Header.Set("Content-Type", "application/json")
+				resp := httptest.NewRecorder()
+
+				router.ServeHTTP(resp, req)
+
+				Expect(resp.Code).To(Equal(http.StatusOK))
+				var response map[string]interface{}
+				Expect(json.Unmarshal(resp.Body.Bytes(), &response)).ShouldNot(HaveOccurred())
+				Expect(response["message"]).NotTo(ContainSubstring("sk-proj-"))
+				Expect(response["redactions"]).To(HaveLen(1))
+			})
+		})
+		Context("with a prompt referencing files outside the project", func() {
+			It("should return 403 and the rejected paths", func() {
+				promptReq := completions.PromptRequest{PromptRequest: api.PromptRequest{
+					Prompt:     "Please review:\n'''../../.ssh/id_rsa\n'''\n",
+					Assistant:  "default",
+					ThreadName: "test-thread",
+				}}
 				body, _ := json.Marshal(promptReq)
-				req, _ := http.NewRequest("POST", "/parse", bytes.NewBuffer(body))
+				req, _ := http.NewRequest("POST", "/api/v1/parse", bytes.NewBuffer(body))
+				req.Header.Set("Content-Type", "application/json")
+				resp := httptest.NewRecorder()
+
+				router.ServeHTTP(resp, req)
+
+				Expect(resp.Code).To(Equal(http.StatusForbidden))
+				var response map[string]interface{}
+				Expect(json.Unmarshal(resp.Body.Bytes(), &response)).ShouldNot(HaveOccurred())
+				Expect(response["error"]).To(HaveKeyWithValue("code", "forbidden"))
+				Expect(response["error"]).To(HaveKeyWithValue("details", HaveKeyWithValue("rejected", HaveLen(1))))
+			})
+		})
+		Context("with a project in the request", func() {
+			It("should read the files from that project", func() {
+				promptReq := completions.PromptRequest{PromptRequest: api.PromptRequest{
+					Prompt:    "Please review:\n'''sample/main.go\n'''\n",
+					Assistant: "default",
+					Project:   "actual",
+				}}
+				body, _ := json.Marshal(promptReq)
+				req, _ := http.NewRequest("POST", "/api/v1/parse", bytes.NewBuffer(body))
 				req.Header.Set("Content-Type", "application/json")
 				resp := httptest.NewRecorder()
 
@@ -76,7 +140,23 @@ var _ = Describe("Parse Handler", func() {
 				Expect(resp.Code).To(Equal(http.StatusOK))
 				var response map[string]interface{}
 				Expect(json.Unmarshal(resp.Body.Bytes(), &response)).ShouldNot(HaveOccurred())
-				Expect(response["response"]).To(Equal("success"))
+				Expect(response["message"]).To(ContainSubstring("package main"))
+				Expect(assistant.ActiveProject()).To(Equal("test-project"))
+			})
+			It("should return 404 for unknown projects", func() {
+				promptReq := completions.PromptRequest{PromptRequest: api.PromptRequest{
+					Prompt:    "Write a simple hello world program",
+					Assistant: "default",
+					Project:   "nowhere",
+				}}
+				body, _ := json.Marshal(promptReq)
+				req, _ := http.NewRequest("POST", "/api/v1/parse", bytes.NewBuffer(body))
+				req.Header.Set("Content-Type", "application/json")
+				resp := httptest.NewRecorder()
+
+				router.ServeHTTP(resp, req)
+
+				Expect(resp.Code).To(Equal(http.StatusNotFound))
 			})
 		})
 		Context("with invalid request body", func() {
@@ -85,7 +165,7 @@ var _ = Describe("Parse Handler", func() {
 					"invalid_field": "some value",
 				}
 				body, _ := json.Marshal(promptReq)
-				req, _ := http.NewRequest("POST", "/parse", bytes.NewBuffer(body))
+				req, _ := http.NewRequest("POST", "/api/v1/parse", bytes.NewBuffer(body))
 				req.Header.Set("Content-Type", "application/json")
 				resp := httptest.NewRecorder()
 
@@ -94,12 +174,12 @@ var _ = Describe("Parse Handler", func() {
 				Expect(resp.Code).To(Equal(http.StatusBadRequest))
 				var response map[string]interface{}
 				Ω(json.Unmarshal(resp.Body.Bytes(), &response)).ToNot(HaveOccurred())
-				Expect(response["response"]).To(Equal("error"))
+				Expect(response["error"]).To(HaveKeyWithValue("code", "invalid_request"))
 			})
 
 			It("should return 400 for malformed JSON", func() {
 				malformedJSON := `{"prompt": "test", project": "test"}`
-				req, _ := http.NewRequest("POST", "/parse", bytes.NewBuffer([]byte(malformedJSON)))
+				req, _ := http.NewRequest("POST", "/api/v1/parse", bytes.NewBuffer([]byte(malformedJSON)))
 				req.Header.Set("Content-Type", "application/json")
 				resp := httptest.NewRecorder()
 
@@ -108,11 +188,11 @@ var _ = Describe("Parse Handler", func() {
 				Expect(resp.Code).To(Equal(http.StatusBadRequest))
 				var response map[string]interface{}
 				json.Unmarshal(resp.Body.Bytes(), &response)
-				Expect(response["response"]).To(Equal("error"))
+				Expect(response["error"]).To(HaveKeyWithValue("code", "invalid_request"))
 			})
 
 			It("should return 400 for empty request body", func() {
-				req, _ := http.NewRequest("POST", "/parse", nil)
+				req, _ := http.NewRequest("POST", "/api/v1/parse", nil)
 				req.Header.Set("Content-Type", "application/json")
 				resp := httptest.NewRecorder()
 
@@ -121,7 +201,7 @@ var _ = Describe("Parse Handler", func() {
 				Expect(resp.Code).To(Equal(http.StatusBadRequest))
 				var response map[string]interface{}
 				json.Unmarshal(resp.Body.Bytes(), &response)
-				Expect(response["response"]).To(Equal("error"))
+				Expect(response["error"]).To(HaveKeyWithValue("code", "invalid_request"))
 			})
 		})
 	})
@@ -1,9 +1,13 @@
 package server
 
 import (
+	"errors"
 	"fmt"
+	"github.com/alertavert/gpt4-go/pkg/api"
 	"github.com/alertavert/gpt4-go/pkg/conversations"
+	"github.com/alertavert/gpt4-go/pkg/preprocessors"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -13,45 +17,57 @@ import (
 	"github.com/alertavert/gpt4-go/pkg/config"
 )
 
-type ProjectResponse struct {
-	ActiveProject string           `json:"active_project"`
-	Projects      []config.Project `json:"projects"`
-}
-
 // projectsGetHandler handles the GET request for the '/projects' endpoint.
-func projectsGetHandler(cfg *config.Config) gin.HandlerFunc {
+func projectsGetHandler(m *completions.Majordomo) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		response := ProjectResponse{
-			ActiveProject: cfg.ActiveProject,
-			Projects:      cfg.Projects,
+		projects, active := m.Projects()
+		response := api.ProjectsResponse{
+			ActiveProject: active,
+			Projects:      make([]api.Project, 0, len(projects)),
+		}
+		for _, project := range projects {
+			response.Projects = append(response.Projects, toAPIProject(project))
 		}
 		c.JSON(http.StatusOK, response)
 	}
 }
 
 // projectDetailsGetHandler handles the GET request for the '/projects/:project_name' endpoint.
-func projectDetailsGetHandler(cfg *config.Config) gin.HandlerFunc {
+func projectDetailsGetHandler(m *completions.Majordomo) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		projectName := c.Param("project_name")
-		project := cfg.GetProject(projectName)
+		project := m.GetProject(projectName)
 		if project == nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+			abortWithError(c, http.StatusNotFound, "Project not found")
 			return
 		}
-		c.JSON(http.StatusOK, project)
+		c.JSON(http.StatusOK, toAPIProject(*project))
 	}
 }
 
-// Helper function to update project fields if the provided data is non-empty.
-func updateProjectIfNotEmpty(original *config.Project, updates config.Project) {
-	if updates.Name != "" && isProjectNameValid(updates.Name) {
-		original.Name = updates.Name
+// toAPIProject is the project, as returned by the API.
+func toAPIProject(project config.Project) api.Project {
+	return api.Project{
+		ID:           project.ID,
+		Name:         project.Name,
+		Description:  project.Description,
+		Location:     project.Location,
+		CodeSnippets: project.CodeSnippets,
+		Include:      project.Include,
+		Exclude:      project.Exclude,
 	}
-	if updates.Location != "" {
-		original.Location = updates.Location
-	}
-	if updates.Description != "" {
-		original.Description = updates.Description
+}
+
+// fromAPIProject is the project sent to the API; the ID is ignored, and the
+// test command can only be set in the configuration file.
+func fromAPIProject(project api.Project) config.Project {
+	return config.Project{
+		Name:         project.Name,
+		Description:  project.Description,
+		Location:     project.Location,
+		CodeSnippets: project.CodeSnippets,
+		Include:      project.Include,
+		Exclude:      project.Exclude,
 	}
 }
 
@@ -60,83 +76,64 @@ func isProjectNameValid(name string) bool {
 	return len(name) > 0 && !strings.ContainsAny(name, " /?%#*<>|\\")
 }
 
-// Helper function to check if project name already exists
-func isProjectNameExists(name string, projects []config.Project) bool {
-	for _, project := range projects {
-		if project.Name == name {
-			return true
-		}
-	}
-	return false
-}
-
 func updateActiveProject(assistant *completions.Majordomo) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var newActiveProject struct {
-			ActiveProject string `json:"active_project"`
-		}
+		var newActiveProject api.ActiveProjectRequest
 		if err := c.BindJSON(&newActiveProject); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			abortWithError(c, http.StatusBadRequest, "Invalid request body")
 			return
 		}
 		if !isProjectNameValid(newActiveProject.ActiveProject) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project name"})
+			abortWithError(c, http.StatusBadRequest, "Invalid project name")
 			return
 		}
 		if err := assistant.SetActiveProject(newActiveProject.ActiveProject); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			abortWithError(c, http.StatusBadRequest, err.Error())
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"message": "Active project updated"})
+		c.JSON(http.StatusOK, api.MessageResponse{Message: "Active project updated"})
 	}
 }
 
 // projectPutHandler handles the PUT request for the '/projects/:project_name' endpoint.
-func projectPutHandler(cfg *config.Config) gin.HandlerFunc {
+// The project keeps its ID when renamed, so its threads and snippets follow it.
+func projectPutHandler(m *completions.Majordomo) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		projectName := c.Param("project_name")
 
-		var updatedProject config.Project
+		var updatedProject api.Project
 		if err := c.BindJSON(&updatedProject); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			abortWithError(c, http.StatusBadRequest, "Invalid request body")
 			return
 		}
 		// Check if the projectName is valid.
 		if !isProjectNameValid(updatedProject.Name) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project name"})
-			return
-		}
-
-		projectIndex := -1
-		for i, project := range cfg.Projects {
-			if project.Name == projectName {
-				projectIndex = i
-				break
-			}
-		}
-
-		if projectIndex == -1 {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+			abortWithError(c, http.StatusBadRequest, "Invalid project name")
 			return
 		}
 
 		// Update only the fields that have been provided in the request body.
-		updateProjectIfNotEmpty(&cfg.Projects[projectIndex], updatedProject)
-
-		if err := cfg.Save(""); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update project"})
-			return
+		project, err := m.UpdateProject(projectName, fromAPIProject(updatedProject))
+		switch {
+		case errors.Is(err, completions.ErrProjectNotFound):
+			abortWithError(c, http.StatusNotFound, "Project not found")
+		case errors.Is(err, completions.ErrProjectExists):
+			abortWithError(c, http.StatusConflict, "Project already exists")
+		case err != nil:
+			log.Error().Err(err).Str("project_name", projectName).Msg("Failed to update project")
+			abortWithError(c, http.StatusInternalServerError, "Failed to update project")
+		default:
+			c.JSON(http.StatusOK, toAPIProject(*project))
 		}
-		c.JSON(http.StatusOK, cfg.Projects[projectIndex])
 	}
 }
 
 // projectPostHandler handles the POST request for the '/projects' endpoint.
-func projectPostHandler(cfg *config.Config) gin.HandlerFunc {
+func projectPostHandler(m *completions.Majordomo) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var newProject config.Project
+		var newProject api.Project
 		if err := c.BindJSON(&newProject); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			abortWithError(c, http.StatusBadRequest, "Invalid request body")
 			return
 		}
 		log.Debug().
@@ -148,50 +145,54 @@ func projectPostHandler(cfg *config.Config) gin.HandlerFunc {
 			log.Error().
 				Str("project_name", newProject.Name).
 				Msg("Invalid project name")
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Project name contains invalid characters"})
+			abortWithError(c, http.StatusBadRequest, "Project name contains invalid characters")
 			return
 		}
-		if isProjectNameExists(newProject.Name, cfg.Projects) {
+		project, err := m.AddProject(fromAPIProject(newProject))
+		switch {
+		case errors.Is(err, completions.ErrProjectExists):
 			log.Error().
 				Str("project_name", newProject.Name).
 				Msg("Project already exists")
-			c.JSON(http.StatusConflict, gin.H{"error": "Project already exists"})
-			return
-		}
-
-		cfg.Projects = append(cfg.Projects, newProject)
-		if err := cfg.Save(""); err != nil {
+			abortWithError(c, http.StatusConflict, "Project already exists")
+		case err != nil:
 			errMsg := fmt.Sprintf("Failed to save new project: %s", err)
 			log.Error().Err(err).Msg(errMsg)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": errMsg})
-			return
+			abortWithError(c, http.StatusInternalServerError, errMsg)
+		default:
+			c.JSON(http.StatusCreated, toAPIProject(*project))
 		}
-		c.JSON(http.StatusCreated, newProject)
 	}
 }
 
 // projectDeleteHandler handles the DELETE request for the '/projects/:project_name' endpoint.
-func projectDeleteHandler(cfg *config.Config) gin.HandlerFunc {
+// The project's threads are archived, unless `threads=purge`, and its code
+// snippets are kept, unless `snippets=purge`.
+func projectDeleteHandler(m *completions.Majordomo) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		projectName := c.Param("project_name")
-		projectIndex := -1
-		for i, project := range cfg.Projects {
-			if project.Name == projectName {
-				projectIndex = i
-				break
-			}
-		}
-		if projectIndex == -1 {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
-			return
-		}
-		cfg.Projects = append(cfg.Projects[:projectIndex], cfg.Projects[projectIndex+1:]...)
-		if err := cfg.Save(""); err != nil {
+		// Validated against the OpenAPI document.
+		opts := completions.DeleteProjectOptions{
+			Threads:       completions.ThreadsDisposal(c.Query("threads")),
+			PurgeSnippets: c.Query("snippets") == "purge",
+		}
+		deletion, err := m.DeleteProject(c.Request.Context(), projectName, opts)
+		switch {
+		case errors.Is(err, completions.ErrProjectNotFound):
+			abortWithError(c, http.StatusNotFound, "Project not found")
+		case errors.Is(err, completions.ErrInvalidDisposal):
+			abortWithError(c, http.StatusBadRequest, err.Error())
+		case errors.Is(err, completions.ErrSharedSnippets):
+			abortWithError(c, http.StatusConflict, err.Error())
+		case err != nil:
 			errMsg := fmt.Sprintf("Failed to delete project: %s", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": errMsg})
-			return
+			abortWithError(c, http.StatusInternalServerError, errMsg)
+		default:
+			c.JSON(http.StatusOK, api.ProjectDeletionResponse{
+				Message:         "Project deleted",
+				ProjectDeletion: *deletion,
+			})
 		}
-		c.JSON(http.StatusOK, gin.H{"message": "Project deleted"})
 	}
 }
 
@@ -199,18 +200,53 @@ func getConversationsForProjectHandler(m *completions.Majordomo) gin.HandlerFunc
 	return func(c *gin.Context) {
 		projectName := c.Param("project_name")
 		// Check if project exists
-		project := m.Config.GetProject(projectName)
+		project := m.GetProject(projectName)
 		if project == nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("project '%s' not found", projectName)})
+			abortWithError(c, http.StatusNotFound, fmt.Sprintf("project '%s' not found", projectName))
 			return
 		}
-		threads := m.Threads.GetAllThreads(projectName)
+		threads := m.Threads.GetAllThreads(m.ProjectID(projectName))
 		if threads == nil {
 			threads = []conversations.Thread{}
 		}
-		c.JSON(http.StatusOK, gin.H{
-			"project": projectName,
-			"threads": threads,
+		c.JSON(http.StatusOK, api.ConversationsResponse{
+			Project: projectName,
+			Threads: threads,
+		})
+	}
+}
+
+// defaultFilesLimit is the most files listed by projectFilesHandler, unless
+// the request sets a `limit`.
+const defaultFilesLimit = 1000
+
+// projectFilesHandler lists the project's files which can be included in the
+// prompts, e.g. to pick them in the UI.
+func projectFilesHandler(m *completions.Majordomo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		store := storeForProject(m, c)
+		if store == nil {
+			return
+		}
+		lister, ok := store.(preprocessors.SourceLister)
+		if !ok {
+			abortWithError(c, http.StatusNotImplemented, "the code store cannot list the source files")
+			return
+		}
+		limit := defaultFilesLimit
+		if l := c.Query("limit"); l != "" {
+			// Validated against the OpenAPI document.
+			limit, _ = strconv.Atoi(l)
+		}
+		files, truncated, err := lister.ListSources(limit)
+		if err != nil {
+			storeErrorResponse(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, api.FilesResponse{
+			Project:   c.Param("project_name"),
+			Files:     files,
+			Truncated: truncated,
 		})
 	}
 }
@@ -2,6 +2,7 @@ package server_test
 
 import (
 	"encoding/json"
+	"github.com/alertavert/gpt4-go/pkg/api"
 	"github.com/alertavert/gpt4-go/pkg/completions"
 	"github.com/alertavert/gpt4-go/pkg/config"
 	"github.com/alertavert/gpt4-go/pkg/conversations"
@@ -50,7 +51,7 @@ var _ = Describe("/projects endpoint", func() {
 
 	Describe("GET /projects", func() {
 		It("should return all the projects", func() {
-			req, _ := http.NewRequest("GET", "/projects", nil)
+			req, _ := http.NewRequest("GET", "/api/v1/projects", nil)
 			resp := httptest.NewRecorder()
 
 			router.ServeHTTP(resp, req)
@@ -58,7 +59,7 @@ var _ = Describe("/projects endpoint", func() {
 		})
 
 		It("should return the names of projects", func() {
-			req, _ := http.NewRequest("GET", "/projects", nil)
+			req, _ := http.NewRequest("GET", "/api/v1/projects", nil)
 			resp := httptest.NewRecorder()
 
 			router.ServeHTTP(resp, req)
@@ -73,7 +74,7 @@ var _ = Describe("/projects endpoint", func() {
 		Context("With a valid project name", func() {
 			It("should return project details", func() {
 				project := cfg.Projects[0]
-				req, _ := http.NewRequest("GET", "/projects/"+project.Name, nil)
+				req, _ := http.NewRequest("GET", "/api/v1/projects/"+project.Name, nil)
 				resp := httptest.NewRecorder()
 
 				router.ServeHTTP(resp, req)
@@ -86,7 +87,7 @@ var _ = Describe("/projects endpoint", func() {
 
 		Context("With an invalid project name", func() {
 			It("should return a 404 error", func() {
-				req, _ := http.NewRequest("GET", "/projects/nonexistent", nil)
+				req, _ := http.NewRequest("GET", "/api/v1/projects/nonexistent", nil)
 				resp := httptest.NewRecorder()
 
 				router.ServeHTTP(resp, req)
@@ -99,7 +100,7 @@ var _ = Describe("/projects endpoint", func() {
 		Context("With valid project data", func() {
 			It("should create a new project", func() {
 				newProjectJson := `{"name":"new-project","description":"A new Project","location":"/some/path"}`
-				req, _ := http.NewRequest("POST", "/projects", strings.NewReader(newProjectJson))
+				req, _ := http.NewRequest("POST", "/api/v1/projects", strings.NewReader(newProjectJson))
 				req.Header.Set("Content-Type", "application/json")
 				resp := httptest.NewRecorder()
 
@@ -113,12 +114,22 @@ var _ = Describe("/projects endpoint", func() {
 				Expect(project.Description).To(Equal("A new Project"))
 				Expect(project.Location).To(Equal("/some/path"))
 			})
+			It("should ignore the test command", func() {
+				newProjectJson := `{"name":"new-project","location":"/some/path","test_command":["/tmp/x"]}`
+				req, _ := http.NewRequest("POST", "/api/v1/projects", strings.NewReader(newProjectJson))
+				req.Header.Set("Content-Type", "application/json")
+				resp := httptest.NewRecorder()
+
+				router.ServeHTTP(resp, req)
+				Expect(resp.Code).To(Equal(http.StatusCreated))
+				Expect(assistant.GetProject("new-project").TestCommand).To(BeEmpty())
+			})
 		})
 
 		Context("With invalid project data", func() {
 			It("should return a 400 error", func() {
 				newProjectJson := `{"Name": "", "Description": "A new Project", "Location":"/some/path" }`
-				req, _ := http.NewRequest("POST", "/projects", strings.NewReader(newProjectJson))
+				req, _ := http.NewRequest("POST", "/api/v1/projects", strings.NewReader(newProjectJson))
 				req.Header.Set("Content-Type", "application/json")
 				resp := httptest.NewRecorder()
 
@@ -131,7 +142,7 @@ var _ = Describe("/projects endpoint", func() {
 			It("should return a 409 conflict error", func() {
 				existingProjectName := cfg.Projects[0].Name
 				newProjectJson := `{"name":"` + existingProjectName + `","description":"Duplicate Project","location":"/some/other/path"}`
-				req, _ := http.NewRequest("POST", "/projects", strings.NewReader(newProjectJson))
+				req, _ := http.NewRequest("POST", "/api/v1/projects", strings.NewReader(newProjectJson))
 				req.Header.Set("Content-Type", "application/json")
 				resp := httptest.NewRecorder()
 
@@ -146,7 +157,7 @@ var _ = Describe("/projects endpoint", func() {
 			It("should update an existing project", func() {
 				project := cfg.Projects[0]
 				updateProjectJson := `{"name":"updated-name","description":"Updated Description","location":"/updated/path"}`
-				req, _ := http.NewRequest("PUT", "/projects/"+project.Name, strings.NewReader(updateProjectJson))
+				req, _ := http.NewRequest("PUT", "/api/v1/projects/"+project.Name, strings.NewReader(updateProjectJson))
 				req.Header.Set("Content-Type", "application/json")
 				resp := httptest.NewRecorder()
 
@@ -161,11 +172,50 @@ var _ = Describe("/projects endpoint", func() {
 			})
 		})
 
+		Context("When renaming the project", func() {
+			It("should keep its ID, and its conversations", func() {
+				project := cfg.Projects[0]
+				thread := conversations.Thread{ID: "thread-renamed", Name: "Before the rename", Assistant: "default"}
+				Expect(assistant.Threads.AddThread(project.ID, thread)).To(Succeed())
+
+				// The ID, as returned by GET, is ignored.
+				updateProjectJson := `{"id":"ignored","name":"renamed-project"}`
+				req, _ := http.NewRequest("PUT", "/api/v1/projects/"+project.Name, strings.NewReader(updateProjectJson))
+				req.Header.Set("Content-Type", "application/json")
+				resp := httptest.NewRecorder()
+				router.ServeHTTP(resp, req)
+				Expect(resp.Code).To(Equal(http.StatusOK))
+
+				var updated config.Project
+				Expect(json.NewDecoder(resp.Body).Decode(&updated)).To(Succeed())
+				Expect(updated.ID).To(Equal(project.ID))
+				Expect(updated.Location).To(Equal(project.Location))
+				Expect(assistant.ActiveProject()).To(Equal("renamed-project"))
+
+				req, _ = http.NewRequest("GET", "/api/v1/projects/renamed-project/conversations", nil)
+				resp = httptest.NewRecorder()
+				router.ServeHTTP(resp, req)
+				Expect(resp.Code).To(Equal(http.StatusOK))
+				var response api.ConversationsResponse
+				Expect(json.NewDecoder(resp.Body).Decode(&response)).To(Succeed())
+				Expect(response.Threads).To(Equal([]conversations.Thread{thread}))
+			})
+			It("should return a 409 error, if the name is taken", func() {
+				updateProjectJson := `{"name":"` + cfg.Projects[1].Name + `"}`
+				req, _ := http.NewRequest("PUT", "/api/v1/projects/"+cfg.Projects[0].Name, strings.NewReader(updateProjectJson))
+				req.Header.Set("Content-Type", "application/json")
+				resp := httptest.NewRecorder()
+
+				router.ServeHTTP(resp, req)
+				Expect(resp.Code).To(Equal(http.StatusConflict))
+			})
+		})
+
 		Context("With invalid update data", func() {
 			It("should return a 400 error", func() {
 				project := cfg.Projects[0]
 				updateProjectJson := `{"name":""}`
-				req, _ := http.NewRequest("PUT", "/projects/"+project.Name, strings.NewReader(updateProjectJson))
+				req, _ := http.NewRequest("PUT", "/api/v1/projects/"+project.Name, strings.NewReader(updateProjectJson))
 				req.Header.Set("Content-Type", "application/json")
 				resp := httptest.NewRecorder()
 
@@ -177,7 +227,7 @@ var _ = Describe("/projects endpoint", func() {
 		Context("With an invalid project name", func() {
 			It("should return a 404 error", func() {
 				updateProjectJson := `{"name":"updated-name","description":"Updated Description","location":"/updated/path"}`
-				req, _ := http.NewRequest("PUT", "/projects/nonexistent", strings.NewReader(updateProjectJson))
+				req, _ := http.NewRequest("PUT", "/api/v1/projects/nonexistent", strings.NewReader(updateProjectJson))
 				req.Header.Set("Content-Type", "application/json")
 				resp := httptest.NewRecorder()
 
@@ -191,7 +241,7 @@ var _ = Describe("/projects endpoint", func() {
 		Context("With a valid project name", func() {
 			It("should delete the project", func() {
 				projectNameToDelete := cfg.Projects[0].Name
-				req, _ := http.NewRequest("DELETE", "/projects/"+projectNameToDelete, nil)
+				req, _ := http.NewRequest("DELETE", "/api/v1/projects/"+projectNameToDelete, nil)
 				resp := httptest.NewRecorder()
 
 				initialProjectCount := len(cfg.Projects)
@@ -204,11 +254,70 @@ var _ = Describe("/projects endpoint", func() {
 				Expect(project).To(BeNil())
 				Expect(len(cfg.Projects)).To(Equal(initialProjectCount - 1))
 			})
+			It("should archive the conversations, and change the active project", func() {
+				project := cfg.Projects[0]
+				Expect(cfg.ActiveProject).To(Equal(project.Name))
+				thread := conversations.Thread{ID: "thread-archived", Name: "Archived", Assistant: "default"}
+				Expect(assistant.Threads.AddThread(project.ID, thread)).To(Succeed())
+
+				req, _ := http.NewRequest("DELETE", "/api/v1/projects/"+project.Name+"?threads=archive", nil)
+				resp := httptest.NewRecorder()
+				router.ServeHTTP(resp, req)
+				Expect(resp.Code).To(Equal(http.StatusOK))
+
+				var response api.ProjectDeletionResponse
+				Expect(json.NewDecoder(resp.Body).Decode(&response)).To(Succeed())
+				Expect(response.ID).To(Equal(project.ID))
+				Expect(response.ThreadsArchived).To(Equal(1))
+				Expect(response.ThreadsPurged).To(BeZero())
+				Expect(response.SnippetsPurged).To(BeFalse())
+				Expect(response.ActiveProject).To(Equal(cfg.Projects[0].Name))
+				Expect(assistant.ActiveProject()).To(Equal(response.ActiveProject))
+
+				Expect(assistant.Threads.GetAllThreads(project.ID)).To(BeEmpty())
+				Expect(assistant.Threads.ArchivedThreads(project.ID)).To(Equal([]conversations.Thread{thread}))
+			})
+			It("should purge the code snippets, if requested", func() {
+				project := cfg.GetProject("actual")
+				snippets := project.ResolvedCodeSnippetsDir
+				Expect(os.MkdirAll(snippets, 0755)).To(Succeed())
+				defer os.RemoveAll(snippets)
+
+				req, _ := http.NewRequest("DELETE", "/api/v1/projects/actual?snippets=purge", nil)
+				resp := httptest.NewRecorder()
+				router.ServeHTTP(resp, req)
+				Expect(resp.Code).To(Equal(http.StatusOK))
+				var response api.ProjectDeletionResponse
+				Expect(json.NewDecoder(resp.Body).Decode(&response)).To(Succeed())
+				Expect(response.SnippetsPurged).To(BeTrue())
+				Expect(snippets).NotTo(BeADirectory())
+			})
+			It("should not purge the code snippets shared with other projects", func() {
+				shared, err := os.MkdirTemp("", "shared-snippets-")
+				Expect(err).NotTo(HaveOccurred())
+				defer os.RemoveAll(shared)
+				for i := range cfg.Projects {
+					cfg.Projects[i].ResolvedCodeSnippetsDir = shared
+				}
+
+				req, _ := http.NewRequest("DELETE", "/api/v1/projects/actual?snippets=purge", nil)
+				resp := httptest.NewRecorder()
+				router.ServeHTTP(resp, req)
+				Expect(resp.Code).To(Equal(http.StatusConflict))
+				Expect(shared).To(BeADirectory())
+				Expect(cfg.GetProject("actual")).NotTo(BeNil())
+			})
+			It("should reject an invalid disposal of the threads", func() {
+				req, _ := http.NewRequest("DELETE", "/api/v1/projects/"+cfg.Projects[0].Name+"?threads=forget", nil)
+				resp := httptest.NewRecorder()
+				router.ServeHTTP(resp, req)
+				Expect(resp.Code).To(Equal(http.StatusBadRequest))
+			})
 		})
 
 		Context("With an invalid project name", func() {
 			It("should return a 404 error", func() {
-				req, _ := http.NewRequest("DELETE", "/projects/nonexistent", nil)
+				req, _ := http.NewRequest("DELETE", "/api/v1/projects/nonexistent", nil)
 				resp := httptest.NewRecorder()
 
 				router.ServeHTTP(resp, req)
@@ -221,7 +330,7 @@ var _ = Describe("/projects endpoint", func() {
 		Context("with an existing project", func() {
 			It("should return empty threads list when no conversations exist", func() {
 				project := cfg.Projects[0]
-				req, _ := http.NewRequest("GET", "/projects/"+project.Name+"/conversations", nil)
+				req, _ := http.NewRequest("GET", "/api/v1/projects/"+project.Name+"/conversations", nil)
 				resp := httptest.NewRecorder()
 
 				router.ServeHTTP(resp, req)
@@ -247,7 +356,7 @@ var _ = Describe("/projects endpoint", func() {
 				err := assistant.Threads.AddThread(project.Name, thread)
 				Expect(err).NotTo(HaveOccurred())
 
-				req, _ := http.NewRequest("GET", "/projects/"+project.Name+"/conversations", nil)
+				req, _ := http.NewRequest("GET", "/api/v1/projects/"+project.Name+"/conversations", nil)
 				resp := httptest.NewRecorder()
 
 				router.ServeHTTP(resp, req)
@@ -271,7 +380,7 @@ var _ = Describe("/projects endpoint", func() {
 
 		Context("with a non-existent project", func() {
 			It("should return 404 error", func() {
-				req, _ := http.NewRequest("GET", "/projects/nonexistent/conversations", nil)
+				req, _ := http.NewRequest("GET", "/api/v1/projects/nonexistent/conversations", nil)
 				resp := httptest.NewRecorder()
 
 				router.ServeHTTP(resp, req)
@@ -280,8 +389,44 @@ var _ = Describe("/projects endpoint", func() {
 				var response map[string]interface{}
 				err := json.NewDecoder(resp.Body).Decode(&response)
 				Expect(err).NotTo(HaveOccurred())
-				Expect(response["error"]).To(Equal("project 'nonexistent' not found"))
+				Expect(response["error"]).To(HaveKeyWithValue("message", "project 'nonexistent' not found"))
 			})
 		})
 	})
+
+	Describe("GET /projects/:project_name/files", func() {
+		It("should list the files which can be included in the prompts", func() {
+			req, _ := http.NewRequest("GET", "/api/v1/projects/actual/files", nil)
+			resp := httptest.NewRecorder()
+
+			router.ServeHTTP(resp, req)
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			var response api.FilesResponse
+			Expect(json.NewDecoder(resp.Body).Decode(&response)).To(Succeed())
+			Expect(response).To(Equal(api.FilesResponse{
+				Project: "actual",
+				Files:   []string{"go.mod", "pkg/simple.go", "sample/main.go"},
+			}))
+		})
+
+		It("should only list up to `limit` files", func() {
+			req, _ := http.NewRequest("GET", "/api/v1/projects/actual/files?limit=1", nil)
+			resp := httptest.NewRecorder()
+
+			router.ServeHTTP(resp, req)
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			var response api.FilesResponse
+			Expect(json.NewDecoder(resp.Body).Decode(&response)).To(Succeed())
+			Expect(response.Files).To(Equal([]string{"go.mod"}))
+			Expect(response.Truncated).To(BeTrue())
+		})
+
+		It("should return 404 for a non-existent project", func() {
+			req, _ := http.NewRequest("GET", "/api/v1/projects/nonexistent/files", nil)
+			resp := httptest.NewRecorder()
+
+			router.ServeHTTP(resp, req)
+			Expect(resp.Code).To(Equal(http.StatusNotFound))
+		})
+	})
 })
@@ -5,46 +5,60 @@
 package server
 
 import (
+	"errors"
+	"github.com/alertavert/gpt4-go/pkg/api"
 	"github.com/alertavert/gpt4-go/pkg/completions"
+	"github.com/alertavert/gpt4-go/pkg/retry"
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
 	"net/http"
 )
 
+// UserHeader names the user sending the prompt (e.g., set by an
+// authenticating proxy), recorded in the audit log; it takes precedence over
+// the `user` in the request body.
+const UserHeader = api.UserHeader
+
+func requestUser(c *gin.Context, prompt *completions.PromptRequest) {
+	if user := c.GetHeader(UserHeader); user != "" {
+		prompt.User = user
+	}
+}
+
 func promptHandler(m *completions.Majordomo) func(c *gin.Context) {
 	return func(c *gin.Context) {
 		var requestBody completions.PromptRequest
 		err := c.ShouldBindJSON(&requestBody)
 		if err != nil {
 			log.Error().Err(err).Msg("Cannot parse request body")
-			c.JSON(http.StatusBadRequest, gin.H{
-				"status":  "error",
-				"message": err.Error(),
-			})
+			abortWithError(c, http.StatusBadRequest, err.Error())
 			return
 		}
 		if err := requestBody.Validate(); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"status":  "error",
-				"message": err.Error(),
-			})
+			abortWithError(c, http.StatusBadRequest, err.Error())
 			return
 		}
+		requestUser(c, &requestBody)
 		lm := log.Debug().
-			Str("assistant_name", requestBody.Assistant)
+			Str("assistant_name", requestBody.Assistant).
+			Str("project", requestBody.Project)
 		var hasThreadId bool = false
 		if requestBody.ThreadId != "" {
 			hasThreadId = true
 			lm.Str("thread_id", requestBody.ThreadId)
 		}
 		lm.Msg("Sending prompt to LLM")
-		botResponse, err := m.QueryBot(&requestBody)
+		botResponse, err := m.QueryBot(c.Request.Context(), &requestBody)
 		if err != nil {
 			log.Error().Err(err).Msg("Error querying bot")
-			c.JSON(http.StatusBadRequest, gin.H{
-				"status":  "error",
-				"message": err.Error(),
-			})
+			status := http.StatusBadRequest
+			switch {
+			case errors.Is(err, retry.ErrCircuitOpen):
+				status = http.StatusServiceUnavailable
+			case errors.Is(err, completions.ErrProjectNotFound):
+				status = http.StatusNotFound
+			}
+			abortWithError(c, status, err.Error())
 			return
 		}
 		if !hasThreadId && requestBody.ThreadId != "" {
@@ -52,11 +66,21 @@ func promptHandler(m *completions.Majordomo) func(c *gin.Context) {
 				Str("thread_id", requestBody.ThreadId).
 				Msg("New thread created")
 		}
-		c.JSON(http.StatusOK, gin.H{
-			"status":     "success",
-			"message":    botResponse,
-			"thread_id":  requestBody.ThreadId,
-			"thread_name": requestBody.ThreadName,
-		})
+		response := api.PromptResponse{
+			BotResponse: botResponse.BotResponse,
+			ThreadId:    requestBody.ThreadId,
+			ThreadName:  requestBody.ThreadName,
+		}
+		if requestBody.Speak && botResponse.Text != "" {
+			// The response is still useful without the audio.
+			speech, err := m.TextToSpeech(completions.SpeechRequest{Text: botResponse.Text})
+			if err != nil {
+				log.Warn().Err(err).Msg("Cannot convert the response to speech")
+				response.SpeechError = err.Error()
+			} else {
+				response.AudioURL = speechURL(speech)
+			}
+		}
+		c.JSON(http.StatusOK, response)
 	}
 }
@@ -50,7 +50,7 @@ var _ = Describe("Prompt Handler", func() {
 					"assistant": "default",
 				}
 				body, _ := json.Marshal(promptReq)
-				req, _ := http.NewRequest("POST", "/prompt", bytes.NewBuffer(body))
+				req, _ := http.NewRequest("POST", "/api/v1/prompt", bytes.NewBuffer(body))
 				req.Header.Set("Content-Type", "application/json")
 				resp := httptest.NewRecorder()
 
@@ -59,7 +59,7 @@ var _ = Describe("Prompt Handler", func() {
 				Expect(resp.Code).To(Equal(http.StatusBadRequest))
 				var response map[string]interface{}
 				Expect(json.Unmarshal(resp.Body.Bytes(), &response)).ShouldNot(HaveOccurred())
-				Expect(response["status"]).To(Equal("error"))
+				Expect(response["error"]).To(HaveKeyWithValue("code", "invalid_request"))
 			})
 
 			It("should return 400 for missing assistant", func() {
@@ -67,7 +67,7 @@ var _ = Describe("Prompt Handler", func() {
 					"prompt": "Test prompt",
 				}
 				body, _ := json.Marshal(promptReq)
-				req, _ := http.NewRequest("POST", "/prompt", bytes.NewBuffer(body))
+				req, _ := http.NewRequest("POST", "/api/v1/prompt", bytes.NewBuffer(body))
 				req.Header.Set("Content-Type", "application/json")
 				resp := httptest.NewRecorder()
 
@@ -76,12 +76,12 @@ var _ = Describe("Prompt Handler", func() {
 				Expect(resp.Code).To(Equal(http.StatusBadRequest))
 				var response map[string]interface{}
 				Expect(json.Unmarshal(resp.Body.Bytes(), &response)).ShouldNot(HaveOccurred())
-				Expect(response["status"]).To(Equal("error"))
+				Expect(response["error"]).To(HaveKeyWithValue("code", "invalid_request"))
 			})
 
 			It("should return 400 for malformed JSON", func() {
 				malformedJSON := `{"prompt": "test", assistant": "default"}`
-				req, _ := http.NewRequest("POST", "/prompt", bytes.NewBuffer([]byte(malformedJSON)))
+				req, _ := http.NewRequest("POST", "/api/v1/prompt", bytes.NewBuffer([]byte(malformedJSON)))
 				req.Header.Set("Content-Type", "application/json")
 				resp := httptest.NewRecorder()
 
@@ -90,11 +90,11 @@ var _ = Describe("Prompt Handler", func() {
 				Expect(resp.Code).To(Equal(http.StatusBadRequest))
 				var response map[string]interface{}
 				Expect(json.Unmarshal(resp.Body.Bytes(), &response)).ShouldNot(HaveOccurred())
-				Expect(response["status"]).To(Equal("error"))
+				Expect(response["error"]).To(HaveKeyWithValue("code", "invalid_request"))
 			})
 
 			It("should return 400 for empty request body", func() {
-				req, _ := http.NewRequest("POST", "/prompt", nil)
+				req, _ := http.NewRequest("POST", "/api/v1/prompt", nil)
 				req.Header.Set("Content-Type", "application/json")
 				resp := httptest.NewRecorder()
 
@@ -103,7 +103,7 @@ var _ = Describe("Prompt Handler", func() {
 				Expect(resp.Code).To(Equal(http.StatusBadRequest))
 				var response map[string]interface{}
 				Expect(json.Unmarshal(resp.Body.Bytes(), &response)).ShouldNot(HaveOccurred())
-				Expect(response["status"]).To(Equal("error"))
+				Expect(response["error"]).To(HaveKeyWithValue("code", "invalid_request"))
 			})
 
 			// Note: We no longer require either thread_id or thread_name to be present
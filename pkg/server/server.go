@@ -5,16 +5,45 @@
 package server
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/alertavert/gpt4-go/pkg/api"
 	"github.com/alertavert/gpt4-go/pkg/completions"
+	"github.com/alertavert/gpt4-go/pkg/config"
+	"github.com/alertavert/gpt4-go/pkg/metrics"
+	"github.com/alertavert/gpt4-go/pkg/tracing"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	"net/http"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+)
+
+// Defaults of the HTTP server, if not configured.
+const (
+	DefaultReadHeaderTimeout = 10 * time.Second
+	DefaultReadTimeout       = time.Minute
+	// Prompts can take minutes to complete.
+	DefaultWriteTimeout    = 5 * time.Minute
+	DefaultIdleTimeout     = 2 * time.Minute
+	DefaultMaxBodyBytes    = 32 << 20
+	DefaultShutdownTimeout = time.Minute
 )
 
 type Server struct {
 	addr      string
 	router    *gin.Engine
 	assistant *completions.Majordomo
+	// chats are the WebSocket sessions open.
+	chats *chatSessions
 }
 
 var server *Server
@@ -34,37 +63,209 @@ func (s *Server) SetDebugMode() {
 	gin.SetMode(gin.DebugMode)
 }
 
-func (s *Server) Run() error {
-	return http.ListenAndServe(s.addr, s.router)
+// Run resumes the prompt jobs which had not finished, and serves the requests
+// (over HTTPS, if configured) until `ctx` is done; then, it stops accepting
+// new requests and waits for the in-flight ones, and for the running prompt
+// jobs, up to the configured shutdown timeout.
+// The chat sessions are closed, cancelling their running prompts.
+func (s *Server) Run(ctx context.Context) error {
+	cfg := s.assistant.Config.Server
+	srv, err := newHTTPServer(cfg, s.router)
+	if err != nil {
+		return err
+	}
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	s.assistant.Jobs.Resume()
+	served := make(chan error, 1)
+	go func() {
+		if srv.TLSConfig != nil {
+			served <- srv.ServeTLS(ln, cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		} else {
+			served <- srv.Serve(ln)
+		}
+	}()
+	select {
+	case err = <-served:
+		return err
+	case <-ctx.Done():
+	}
+
+	timeout := cfg.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = DefaultShutdownTimeout
+	}
+	log.Info().
+		Dur("timeout", timeout).
+		Msg("Shutting down, waiting for the in-flight requests and prompt jobs")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// The prompt jobs keep running in the background, while the in-flight
+	// requests complete; the audit log is closed only after both.
+	srvErr := srv.Shutdown(shutdownCtx)
+	if srvErr != nil {
+		// Deadline expired: the requests still in flight are dropped.
+		_ = srv.Close()
+	}
+	chatsErr := s.chats.close(shutdownCtx)
+	jobsErr := s.assistant.Shutdown(shutdownCtx)
+	if err = <-served; errors.Is(err, http.ErrServerClosed) {
+		err = nil
+	}
+	return errors.Join(err, srvErr, chatsErr, jobsErr)
+}
+
+// newHTTPServer configures the timeouts and, if enabled, TLS.
+func newHTTPServer(cfg config.ServerConfig, handler http.Handler) (*http.Server, error) {
+	srv := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: orDefault(cfg.ReadHeaderTimeout, DefaultReadHeaderTimeout),
+		ReadTimeout:       orDefault(cfg.ReadTimeout, DefaultReadTimeout),
+		WriteTimeout:      orDefault(cfg.WriteTimeout, DefaultWriteTimeout),
+		IdleTimeout:       orDefault(cfg.IdleTimeout, DefaultIdleTimeout),
+	}
+	tlsConfig, err := newTLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+	srv.TLSConfig = tlsConfig
+	return srv, nil
+}
+
+// newTLSConfig returns nil if TLS is not configured; the certificate and key
+// are loaded by the server.
+func newTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	if cfg.CertFile == "" && cfg.KeyFile == "" {
+		if cfg.ClientCAFile != "" {
+			return nil, fmt.Errorf("client_ca_file requires cert_file and key_file")
+		}
+		return nil, nil
+	}
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, fmt.Errorf("both cert_file and key_file must be set, to enable TLS")
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading the client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+func orDefault(d, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+	return d
+}
+
+// limitBody rejects the requests whose body is larger than `maxBytes`.
+func limitBody(maxBytes int64) gin.HandlerFunc {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBodyBytes
+	}
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > maxBytes {
+			abortWithError(c, http.StatusRequestEntityTooLarge,
+				fmt.Sprintf("request body larger than %d bytes", maxBytes))
+			return
+		}
+		// The length may not be known in advance (e.g., chunked bodies).
+		if c.Request.Body != nil {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+		}
+		c.Next()
+	}
+}
+
+// bodyTooLarge is true if reading the body failed because of limitBody.
+func bodyTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr)
 }
 
 func (s *Server) setupHandlers() {
 	r := s.router
+	// A span for each request, continuing the trace of the caller, if any.
+	r.Use(otelgin.Middleware(tracing.ServiceName(s.assistant.Config.Tracing),
+		otelgin.WithFilter(func(req *http.Request) bool {
+			return req.URL.Path != "/health" && req.URL.Path != "/metrics" &&
+				!strings.HasPrefix(req.URL.Path, UIPrefix+"/")
+		})))
+	r.Use(metrics.GinMiddleware())
+	r.Use(limitBody(s.assistant.Config.Server.MaxBodyBytes))
 	// Health check
 	r.GET("/health", func(context *gin.Context) {
 		context.JSON(http.StatusOK, gin.H{"status": "ok"})
 	})
+	r.GET("/metrics", metricsHandler(s.assistant))
+
+	// The web UI, embedded in the binary
+	r.GET(UIPrefix+"/*path", uiHandler())
+
+	r.NoRoute(noRouteHandler)
+
+	// All the other routes are versioned, and validated against the OpenAPI document.
+	spec := mustSpec()
+	v1 := r.Group(api.Prefix, validateRequest(spec))
+	v1.GET("/openapi.json", openAPIHandler(spec))
 
 	// Prompt-related routes
-	r.POST("/command", audioHandler(s.assistant))
-	r.POST("/parse", parsePromptHandler(s.assistant))
-	r.POST("/prompt", promptHandler(s.assistant))
+	v1.POST("/command", audioHandler(s.assistant))
+	v1.POST("/parse", parsePromptHandler(s.assistant))
+	v1.POST("/prompt", promptHandler(s.assistant))
+	v1.POST("/speak", speakHandler(s.assistant))
+	v1.GET("/speech/:file", speechGetHandler(s.assistant))
+
+	// Prompt jobs, run in the background
+	v1.POST("/jobs", jobsPostHandler(s.assistant))
+	v1.GET("/jobs", jobsGetHandler(s.assistant))
+	v1.GET("/jobs/:job_id", jobGetHandler(s.assistant))
+	v1.DELETE("/jobs/:job_id", jobDeleteHandler(s.assistant))
 
 	// Projects routes
-	cfg := s.assistant.Config
-	r.GET("/projects", projectsGetHandler(cfg))
-	r.GET("/projects/:project_name", projectDetailsGetHandler(cfg))
-	r.GET("/projects/:project_name/conversations", getConversationsForProjectHandler(s.assistant))
-	r.POST("/projects", projectPostHandler(cfg))
-	r.PUT("/projects", updateActiveProject(s.assistant))
-	r.PUT("/projects/:project_name", projectPutHandler(cfg))
-	r.DELETE("/projects/:project_name", projectDeleteHandler(cfg))
+	v1.GET("/projects", projectsGetHandler(s.assistant))
+	v1.GET("/projects/:project_name", projectDetailsGetHandler(s.assistant))
+	v1.GET("/projects/:project_name/conversations", getConversationsForProjectHandler(s.assistant))
+	v1.POST("/projects", projectPostHandler(s.assistant))
+	v1.PUT("/projects", updateActiveProject(s.assistant))
+	v1.PUT("/projects/:project_name", projectPutHandler(s.assistant))
+	v1.DELETE("/projects/:project_name", projectDeleteHandler(s.assistant))
+	v1.GET("/projects/:project_name/files", projectFilesHandler(s.assistant))
+	v1.POST("/projects/:project_name/fix-tests", fixTestsHandler(s.assistant))
+
+	// Snippets routes
+	v1.GET("/projects/:project_name/snippets", snippetsGetHandler(s.assistant))
+	v1.DELETE("/projects/:project_name/snippets", snippetsDeleteHandler(s.assistant))
+	v1.GET("/projects/:project_name/snippets.zip", snippetsZipHandler(s.assistant))
+	v1.GET("/projects/:project_name/snippets/*path", snippetGetHandler(s.assistant))
+	v1.DELETE("/projects/:project_name/snippets/*path", snippetDeleteHandler(s.assistant))
+	v1.GET("/projects/:project_name/versions/:version_id", versionGetHandler(s.assistant))
+	v1.GET("/projects/:project_name/versions/:version_id/diff", versionDiffHandler(s.assistant))
+	v1.POST("/projects/:project_name/versions/:version_id/restore", versionRestoreHandler(s.assistant))
 
 	// Assistants routes
-	r.GET("/assistants", assistantsGetHandler(s.assistant))
+	v1.GET("/assistants", assistantsGetHandler(s.assistant))
+	v1.POST("/assistants/refresh", assistantsRefreshHandler(s.assistant))
 
 	// Conversations routes
-	r.GET("/conversations/:thread_id", threadGetByIdHandler(s.assistant))
+	v1.GET("/conversations/:thread_id", threadGetByIdHandler(s.assistant))
+	v1.GET("/conversations/:thread_id/messages", threadMessagesHandler(s.assistant))
+
+	// Interactive chat sessions, over a WebSocket
+	s.chats = newChatSessions()
+	v1.GET("/ws", chatHandler(s.assistant, s.chats))
 }
 
 // SetupTestRoutes is a helper function to set up the routes for testing.
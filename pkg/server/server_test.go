@@ -0,0 +1,247 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alertavert/gpt4-go/pkg/api"
+	"github.com/alertavert/gpt4-go/pkg/completions"
+	"github.com/alertavert/gpt4-go/pkg/config"
+	"github.com/alertavert/gpt4-go/pkg/server"
+)
+
+// issue creates a certificate for `name`, signed by `parent` (self-signed if
+// nil), and writes it (and its key) in PEM format to `dir`.
+func issue(dir, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey,
+	isCA bool) (*x509.Certificate, *ecdsa.PrivateKey, tls.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	Expect(err).NotTo(HaveOccurred())
+	cert, err := x509.ParseCertificate(der)
+	Expect(err).NotTo(HaveOccurred())
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	Expect(err).NotTo(HaveOccurred())
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	Expect(os.WriteFile(filepath.Join(dir, name+".pem"), certPEM, 0600)).To(Succeed())
+	Expect(os.WriteFile(filepath.Join(dir, name+"-key.pem"), keyPEM, 0600)).To(Succeed())
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	Expect(err).NotTo(HaveOccurred())
+	return cert, key, pair
+}
+
+var _ = Describe("Server", func() {
+	var (
+		cfg    *config.Config
+		tmpDir string
+	)
+
+	BeforeEach(func() {
+		cfgLoc, err := MkTempConfigFile(TestConfigLocation)
+		Expect(err).NotTo(HaveOccurred())
+		cfg, err = config.LoadConfig(cfgLoc)
+		Expect(err).NotTo(HaveOccurred())
+		tmpDir, err = os.MkdirTemp("", "server-test-")
+		Expect(err).NotTo(HaveOccurred())
+		cfg.ThreadsLocation = filepath.Join(tmpDir, "threads.json")
+		cfg.JobsLocation = filepath.Join(tmpDir, "jobs.json")
+		gin.SetMode(gin.TestMode)
+	})
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	// run starts the server on a free port, and returns its address, and the
+	// channel on which Run returns, once `ctx` is done.
+	run := func(ctx context.Context) (string, chan error) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		addr := ln.Addr().String()
+		Expect(ln.Close()).To(Succeed())
+
+		assistant, err := completions.NewMajordomo(cfg)
+		Expect(err).NotTo(HaveOccurred())
+		svr := server.NewServer(addr, assistant)
+		done := make(chan error, 1)
+		go func() { done <- svr.Run(ctx) }()
+		return addr, done
+	}
+
+	It("rejects the requests larger than the limit", func() {
+		cfg.Server.MaxBodyBytes = 1024
+		assistant, err := completions.NewMajordomo(cfg)
+		Expect(err).NotTo(HaveOccurred())
+		router := gin.New()
+		server.SetupTestRoutes(router, assistant)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/prompt", bytes.NewReader(make([]byte, 2048)))
+		router.ServeHTTP(w, req)
+		Expect(w.Code).To(Equal(http.StatusRequestEntityTooLarge))
+		Expect(w.Body.String()).To(ContainSubstring(`"error"`))
+	})
+
+	It("stops gracefully, when the context is done", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		addr, done := run(ctx)
+		Eventually(func() int {
+			resp, err := http.Get("http://" + addr + "/health")
+			if err != nil {
+				return 0
+			}
+			_ = resp.Body.Close()
+			return resp.StatusCode
+		}).Should(Equal(http.StatusOK))
+
+		cancel()
+		Eventually(done, 5*time.Second).Should(Receive(BeNil()))
+		_, err := http.Get("http://" + addr + "/health")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("does not cut off fixing the tests, which can outlast the write timeout", func() {
+		cfg.Server.WriteTimeout = 200 * time.Millisecond
+		// The tests pass, after the write timeout.
+		for i := range cfg.Projects {
+			if cfg.Projects[i].Name == "actual" {
+				cfg.Projects[i].TestCommand = []string{"sh", "-c", "sleep 1"}
+			}
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		addr, _ := run(ctx)
+		Eventually(func() error {
+			conn, err := net.Dial("tcp", addr)
+			if err == nil {
+				_ = conn.Close()
+			}
+			return err
+		}).Should(Succeed())
+
+		resp, err := http.Post("http://"+addr+"/api/v1/projects/actual/fix-tests", "application/json",
+			strings.NewReader(`{"assistant": "go_developer"}`))
+		Expect(err).NotTo(HaveOccurred())
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		var report api.FixTestsReport
+		Expect(json.NewDecoder(resp.Body).Decode(&report)).To(Succeed())
+		Expect(report.Passed).To(BeTrue())
+	})
+
+	It("resumes the prompt jobs when run, not when created", func() {
+		queued := []completions.Job{{
+			ID:        "job_Qu3u3d",
+			Status:    completions.JobQueued,
+			Project:   "actual",
+			Request:   api.PromptRequest{Assistant: "go_developer", Prompt: "Hello"},
+			CreatedAt: time.Now().UTC(),
+		}}
+		data, err := json.Marshal(queued)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.WriteFile(cfg.JobsLocation, data, 0600)).To(Succeed())
+		cfg.Server.ShutdownTimeout = 100 * time.Millisecond
+
+		assistant, err := completions.NewMajordomo(cfg)
+		Expect(err).NotTo(HaveOccurred())
+		status := func() completions.JobStatus {
+			job, found := assistant.Jobs.Get("job_Qu3u3d")
+			Expect(found).To(BeTrue())
+			return job.Status
+		}
+		Consistently(status, 100*time.Millisecond).Should(Equal(completions.JobQueued))
+
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		addr := ln.Addr().String()
+		Expect(ln.Close()).To(Succeed())
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		done := make(chan error, 1)
+		go func() { done <- server.NewServer(addr, assistant).Run(ctx) }()
+		Eventually(status).ShouldNot(Equal(completions.JobQueued))
+
+		cancel()
+		Eventually(done, 5*time.Second).Should(Receive())
+	})
+
+	It("requires the clients' certificates, if the client CA is configured", func() {
+		ca, caKey, _ := issue(tmpDir, "ca", nil, nil, true)
+		_, _, _ = issue(tmpDir, "server", ca, caKey, false)
+		_, _, clientPair := issue(tmpDir, "client", ca, caKey, false)
+		cfg.Server.TLS = config.TLSConfig{
+			CertFile:     filepath.Join(tmpDir, "server.pem"),
+			KeyFile:      filepath.Join(tmpDir, "server-key.pem"),
+			ClientCAFile: filepath.Join(tmpDir, "ca.pem"),
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		addr, done := run(ctx)
+
+		roots := x509.NewCertPool()
+		roots.AddCert(ca)
+		get := func(certs ...tls.Certificate) (int, error) {
+			client := &http.Client{Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs},
+			}}
+			resp, err := client.Get("https://" + addr + "/health")
+			if err != nil {
+				return 0, err
+			}
+			_ = resp.Body.Close()
+			return resp.StatusCode, nil
+		}
+		Eventually(func() (int, error) { return get(clientPair) }).Should(Equal(http.StatusOK))
+		_, err := get()
+		Expect(err).To(HaveOccurred())
+
+		cancel()
+		Eventually(done, 5*time.Second).Should(Receive(BeNil()))
+	})
+
+	It("fails to start, if only the certificate is configured", func() {
+		cfg.Server.TLS = config.TLSConfig{CertFile: filepath.Join(tmpDir, "server.pem")}
+		_, done := run(context.Background())
+		Eventually(done).Should(Receive(MatchError(ContainSubstring("key_file"))))
+	})
+})
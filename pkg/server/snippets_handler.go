@@ -0,0 +1,308 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package server
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/alertavert/gpt4-go/pkg/api"
+	"github.com/alertavert/gpt4-go/pkg/completions"
+	"github.com/alertavert/gpt4-go/pkg/diff"
+	"github.com/alertavert/gpt4-go/pkg/preprocessors"
+)
+
+// storeForProject returns the CodeStoreHandler for the project in the
+// request's path, or writes an error response and returns nil.
+func storeForProject(m *completions.Majordomo, c *gin.Context) preprocessors.CodeStoreHandler {
+	projectName := c.Param("project_name")
+	project := m.GetProject(projectName)
+	if project == nil {
+		abortWithError(c, http.StatusNotFound, fmt.Sprintf("project '%s' not found", projectName))
+		return nil
+	}
+	return m.Stores.Get(project)
+}
+
+// versionedStoreForProject is like storeForProject, for the stores which
+// support versioning.
+func versionedStoreForProject(m *completions.Majordomo, c *gin.Context) preprocessors.VersionedStore {
+	store := storeForProject(m, c)
+	if store == nil {
+		return nil
+	}
+	versioned, ok := store.(preprocessors.VersionedStore)
+	if !ok {
+		abortWithError(c, http.StatusNotImplemented, "the code store does not support versioning")
+		return nil
+	}
+	return versioned
+}
+
+// storeErrorResponse maps the errors from the CodeStoreHandler to HTTP responses.
+func storeErrorResponse(c *gin.Context, err error) {
+	var rejected *preprocessors.RejectedPathsError
+	switch {
+	case errors.As(err, &rejected):
+		abortWithDetails(c, http.StatusForbidden, err.Error(), map[string]any{"rejected": rejected.Rejected})
+	case errors.Is(err, preprocessors.ErrSnippetNotFound), errors.Is(err, preprocessors.ErrVersionNotFound):
+		abortWithError(c, http.StatusNotFound, err.Error())
+	default:
+		abortWithError(c, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// snippetsGetHandler handles the GET request for the '/projects/:project_name/snippets'
+// endpoint, returning all the stored snippets with their versions.
+func snippetsGetHandler(m *completions.Majordomo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		store := storeForProject(m, c)
+		if store == nil {
+			return
+		}
+		snippets, err := store.ListSnippets()
+		if err != nil {
+			storeErrorResponse(c, err)
+			return
+		}
+		versions := make(map[string]preprocessors.SnippetHistory)
+		if versioned, ok := store.(preprocessors.VersionedStore); ok {
+			history, err := versioned.History()
+			if err != nil {
+				storeErrorResponse(c, err)
+				return
+			}
+			for _, h := range history {
+				versions[h.Path] = h
+			}
+		}
+		entries := make([]api.Snippet, 0, len(snippets))
+		for _, s := range snippets {
+			h := versions[s.Path]
+			entries = append(entries, api.Snippet{SnippetInfo: s, Current: h.Current, Versions: h.Versions})
+		}
+		c.JSON(http.StatusOK, api.SnippetsResponse{
+			Project:  c.Param("project_name"),
+			Snippets: entries,
+		})
+	}
+}
+
+// snippetPathParam returns the snippet's path from the '*path' parameter, or
+// writes an error response and returns an empty string.
+func snippetPathParam(c *gin.Context) string {
+	path := strings.TrimPrefix(c.Param("path"), "/")
+	if path == "" {
+		abortWithError(c, http.StatusBadRequest, "missing snippet path")
+	}
+	return path
+}
+
+// snippetGetHandler handles the GET request for the
+// '/projects/:project_name/snippets/*path' endpoint; if the `diff` query
+// parameter is true, the snippet is also compared against the original file
+// in the project's location.
+func snippetGetHandler(m *completions.Majordomo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		store := storeForProject(m, c)
+		if store == nil {
+			return
+		}
+		path := snippetPathParam(c)
+		if path == "" {
+			return
+		}
+		content, err := store.GetSnippet(path)
+		if err != nil {
+			storeErrorResponse(c, err)
+			return
+		}
+		response := api.SnippetResponse{
+			Path:    path,
+			Content: content,
+		}
+		if c.Query("diff") == "true" {
+			// A new file is compared against an empty one.
+			original := preprocessors.SourceCodeMap{path: ""}
+			if err := store.GetSourceCode(&original); err != nil && !errors.Is(err, os.ErrNotExist) {
+				storeErrorResponse(c, err)
+				return
+			}
+			response.Diff = diff.Unified(original[path], content,
+				"a/"+path, "b/"+path, diff.DefaultContext)
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// snippetDeleteHandler handles the DELETE request for the
+// '/projects/:project_name/snippets/*path' endpoint.
+func snippetDeleteHandler(m *completions.Majordomo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		store := storeForProject(m, c)
+		if store == nil {
+			return
+		}
+		path := snippetPathParam(c)
+		if path == "" {
+			return
+		}
+		if err := store.DeleteSnippet(path); err != nil {
+			storeErrorResponse(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// snippetsDeleteHandler handles the DELETE request for the
+// '/projects/:project_name/snippets' endpoint, removing all the snippets.
+func snippetsDeleteHandler(m *completions.Majordomo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		store := storeForProject(m, c)
+		if store == nil {
+			return
+		}
+		if err := store.DeleteAllSnippets(); err != nil {
+			storeErrorResponse(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// snippetsZipHandler handles the GET request for the
+// '/projects/:project_name/snippets.zip' endpoint, returning all the snippets
+// in a zip archive.
+func snippetsZipHandler(m *completions.Majordomo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		store := storeForProject(m, c)
+		if store == nil {
+			return
+		}
+		snippets, err := store.ListSnippets()
+		if err != nil {
+			storeErrorResponse(c, err)
+			return
+		}
+		// The snippets are all read before writing the response, so that
+		// errors can still be reported.
+		contents := make([]string, len(snippets))
+		for i, s := range snippets {
+			if contents[i], err = store.GetSnippet(s.Path); err != nil {
+				storeErrorResponse(c, err)
+				return
+			}
+		}
+		c.Header("Content-Disposition",
+			fmt.Sprintf("attachment; filename=%q", c.Param("project_name")+"-snippets.zip"))
+		c.Header("Content-Type", "application/zip")
+		c.Status(http.StatusOK)
+		archive := zip.NewWriter(c.Writer)
+		for i, s := range snippets {
+			w, err := archive.CreateHeader(&zip.FileHeader{
+				Name:     s.Path,
+				Method:   zip.Deflate,
+				Modified: s.Modified,
+			})
+			if err == nil {
+				_, err = w.Write([]byte(contents[i]))
+			}
+			if err != nil {
+				_ = c.Error(err)
+				return
+			}
+		}
+		if err := archive.Close(); err != nil {
+			_ = c.Error(err)
+		}
+	}
+}
+
+// versionGetHandler handles the GET request for the
+// '/projects/:project_name/versions/:version_id' endpoint.
+func versionGetHandler(m *completions.Majordomo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		store := versionedStoreForProject(m, c)
+		if store == nil {
+			return
+		}
+		version, content, err := store.GetVersion(c.Param("version_id"))
+		if err != nil {
+			storeErrorResponse(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, api.VersionResponse{
+			Version: version,
+			Content: content,
+		})
+	}
+}
+
+// versionDiffHandler handles the GET request for the
+// '/projects/:project_name/versions/:version_id/diff' endpoint: the version is
+// compared against the one in the `against` query parameter or, if missing,
+// against the current version of the same snippet.
+func versionDiffHandler(m *completions.Majordomo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		store := versionedStoreForProject(m, c)
+		if store == nil {
+			return
+		}
+		from, fromContent, err := store.GetVersion(c.Param("version_id"))
+		if err != nil {
+			storeErrorResponse(c, err)
+			return
+		}
+		againstId := c.Query("against")
+		if againstId == "" {
+			history, err := store.History()
+			if err != nil {
+				storeErrorResponse(c, err)
+				return
+			}
+			for _, h := range history {
+				if h.Path == from.Path {
+					againstId = h.Current
+				}
+			}
+		}
+		to, toContent, err := store.GetVersion(againstId)
+		if err != nil {
+			storeErrorResponse(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, api.VersionDiffResponse{
+			From: from,
+			To:   to,
+			Diff: diff.Unified(fromContent, toContent,
+				fmt.Sprintf("%s@%s", from.Path, from.ID),
+				fmt.Sprintf("%s@%s", to.Path, to.ID), diff.DefaultContext),
+		})
+	}
+}
+
+// versionRestoreHandler handles the POST request for the
+// '/projects/:project_name/versions/:version_id/restore' endpoint.
+func versionRestoreHandler(m *completions.Majordomo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		store := versionedStoreForProject(m, c)
+		if store == nil {
+			return
+		}
+		version, err := store.RestoreVersion(c.Param("version_id"))
+		if err != nil {
+			storeErrorResponse(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, version)
+	}
+}
@@ -0,0 +1,172 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package server_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alertavert/gpt4-go/pkg/completions"
+	"github.com/alertavert/gpt4-go/pkg/config"
+	"github.com/alertavert/gpt4-go/pkg/preprocessors"
+	"github.com/alertavert/gpt4-go/pkg/server"
+)
+
+var _ = Describe("Snippets Handler", func() {
+	var (
+		router   *gin.Engine
+		project  *config.Project
+		versions []preprocessors.SnippetVersion
+		tmpDir   string
+	)
+
+	BeforeEach(func() {
+		cfgLoc, err := MkTempConfigFile(TestConfigLocation)
+		Expect(err).NotTo(HaveOccurred())
+		cfg, err := config.LoadConfig(cfgLoc)
+		Expect(err).NotTo(HaveOccurred())
+
+		tmpDir, err = os.MkdirTemp("", "snippets-test-")
+		Expect(err).NotTo(HaveOccurred())
+		cfg.Projects = append(cfg.Projects, config.Project{
+			Name:                    "snippets",
+			Location:                tmpDir,
+			ResolvedCodeSnippetsDir: filepath.Join(tmpDir, ".majordomo"),
+		})
+		project = cfg.GetProject("snippets")
+		assistant, err := completions.NewMajordomo(cfg)
+		Expect(err).NotTo(HaveOccurred())
+		store := assistant.Stores.Get(project).(preprocessors.VersionedStore)
+		versions, err = store.PutVersionedSourceCode(preprocessors.SourceCodeMap{"main.go": "package main\n"},
+			preprocessors.VersionMeta{ThreadId: "thread_1", RunId: "run_1"})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = store.PutVersionedSourceCode(preprocessors.SourceCodeMap{"main.go": "package foo\n"},
+			preprocessors.VersionMeta{ThreadId: "thread_1", RunId: "run_2"})
+		Expect(err).NotTo(HaveOccurred())
+
+		gin.SetMode(gin.TestMode)
+		router = gin.New()
+		server.SetupTestRoutes(router, assistant)
+	})
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	get := func(url string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		req, _ := http.NewRequest("GET", url, nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		var body map[string]interface{}
+		Expect(json.Unmarshal(resp.Body.Bytes(), &body)).To(Succeed())
+		return resp, body
+	}
+
+	It("should return 404 for an unknown project", func() {
+		resp, _ := get("/api/v1/projects/nonexistent/snippets")
+		Expect(resp.Code).To(Equal(http.StatusNotFound))
+	})
+	It("should list the snippets, with their versions", func() {
+		resp, body := get("/api/v1/projects/" + project.Name + "/snippets")
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		Expect(body["snippets"]).To(HaveLen(1))
+		snippet := body["snippets"].([]interface{})[0].(map[string]interface{})
+		Expect(snippet["path"]).To(Equal("main.go"))
+		Expect(snippet["size"]).To(BeEquivalentTo(len("package foo\n")))
+		Expect(snippet["modified"]).NotTo(BeEmpty())
+		Expect(snippet["versions"]).To(HaveLen(2))
+	})
+	It("should return a snippet's content", func() {
+		resp, body := get("/api/v1/projects/" + project.Name + "/snippets/main.go")
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		Expect(body["content"]).To(Equal("package foo\n"))
+		Expect(body).NotTo(HaveKey("diff"))
+	})
+	It("should diff a snippet against the original", func() {
+		Expect(os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte("package main\n"), 0644)).To(Succeed())
+		resp, body := get("/api/v1/projects/" + project.Name + "/snippets/main.go?diff=true")
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		Expect(body["diff"]).To(ContainSubstring("-package main\n+package foo\n"))
+	})
+	It("should diff a new snippet against an empty file", func() {
+		resp, body := get("/api/v1/projects/" + project.Name + "/snippets/main.go?diff=true")
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		Expect(body["diff"]).To(ContainSubstring("+package foo\n"))
+	})
+	It("should return 404 for a missing snippet", func() {
+		resp, _ := get("/api/v1/projects/" + project.Name + "/snippets/missing.go")
+		Expect(resp.Code).To(Equal(http.StatusNotFound))
+	})
+	It("should not allow access to the history", func() {
+		resp, _ := get("/api/v1/projects/" + project.Name + "/snippets/.history/index.jsonl")
+		Expect(resp.Code).To(Equal(http.StatusForbidden))
+	})
+	It("should delete a snippet", func() {
+		req, _ := http.NewRequest("DELETE", "/api/v1/projects/"+project.Name+"/snippets/main.go", nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		Expect(resp.Code).To(Equal(http.StatusNoContent))
+		_, body := get("/api/v1/projects/" + project.Name + "/snippets")
+		Expect(body["snippets"]).To(BeEmpty())
+	})
+	It("should delete all the snippets", func() {
+		req, _ := http.NewRequest("DELETE", "/api/v1/projects/"+project.Name+"/snippets", nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		Expect(resp.Code).To(Equal(http.StatusNoContent))
+		_, body := get("/api/v1/projects/" + project.Name + "/snippets")
+		Expect(body["snippets"]).To(BeEmpty())
+	})
+	It("should download all the snippets as a zip archive", func() {
+		req, _ := http.NewRequest("GET", "/api/v1/projects/"+project.Name+"/snippets.zip", nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		Expect(resp.Header().Get("Content-Type")).To(Equal("application/zip"))
+
+		archive, err := zip.NewReader(bytes.NewReader(resp.Body.Bytes()), int64(resp.Body.Len()))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(archive.File).To(HaveLen(1))
+		Expect(archive.File[0].Name).To(Equal("main.go"))
+		f, err := archive.File[0].Open()
+		Expect(err).NotTo(HaveOccurred())
+		content, err := io.ReadAll(f)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(Equal("package foo\n"))
+	})
+	It("should return a version's content", func() {
+		resp, body := get("/api/v1/projects/" + project.Name + "/versions/" + versions[0].ID)
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		Expect(body["content"]).To(Equal("package main\n"))
+	})
+	It("should return 404 for an unknown version", func() {
+		resp, _ := get("/api/v1/projects/" + project.Name + "/versions/no-such-version")
+		Expect(resp.Code).To(Equal(http.StatusNotFound))
+	})
+	It("should diff a version against the current one", func() {
+		resp, body := get("/api/v1/projects/" + project.Name + "/versions/" + versions[0].ID + "/diff")
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		Expect(body["diff"]).To(ContainSubstring("-package main\n+package foo\n"))
+	})
+	It("should restore an older version", func() {
+		req, _ := http.NewRequest("POST", "/api/v1/projects/"+project.Name+"/versions/"+versions[0].ID+"/restore", nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		Expect(resp.Code).To(Equal(http.StatusOK))
+
+		data, err := os.ReadFile(filepath.Join(project.ResolvedCodeSnippetsDir, "main.go"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(Equal("package main\n"))
+	})
+})
@@ -0,0 +1,66 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	"github.com/alertavert/gpt4-go/pkg/api"
+	"github.com/alertavert/gpt4-go/pkg/completions"
+)
+
+// speechURL is where the cached audio file is served from.
+func speechURL(speech *completions.Speech) string {
+	return api.Prefix + "/speech/" + speech.File
+}
+
+// speakHandler converts the text POSTed to `/speak` to audio, and returns it;
+// with `?url=true`, the URL of the cached audio file is returned instead.
+func speakHandler(m *completions.Majordomo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request completions.SpeechRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			abortWithError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		speech, err := m.TextToSpeech(request)
+		if err != nil {
+			log.Err(err).Msg("error converting text to speech")
+			status := http.StatusInternalServerError
+			if errors.Is(err, completions.ErrInvalidSpeechRequest) {
+				status = http.StatusBadRequest
+			}
+			abortWithError(c, status, err.Error())
+			return
+		}
+		if c.Query("url") == "true" {
+			c.JSON(http.StatusOK, api.SpeechResponse{
+				URL:         speechURL(speech),
+				ContentType: speech.ContentType,
+				Truncated:   speech.Truncated,
+			})
+			return
+		}
+		c.Header("Content-Type", speech.ContentType)
+		c.File(speech.Path)
+	}
+}
+
+// speechGetHandler serves the audio files cached by `/speak`.
+func speechGetHandler(m *completions.Majordomo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		speech, err := m.GetSpeech(c.Param("file"))
+		if err != nil {
+			abortWithError(c, http.StatusNotFound, err.Error())
+			return
+		}
+		c.Header("Content-Type", speech.ContentType)
+		c.File(speech.Path)
+	}
+}
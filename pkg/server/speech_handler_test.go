@@ -0,0 +1,102 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package server_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alertavert/gpt4-go/pkg/completions"
+	"github.com/alertavert/gpt4-go/pkg/config"
+	"github.com/alertavert/gpt4-go/pkg/server"
+)
+
+var _ = Describe("Speech Handler", func() {
+	var (
+		router   *gin.Engine
+		cacheDir string
+		file     string
+	)
+
+	BeforeEach(func() {
+		cfgLoc, err := MkTempConfigFile(TestConfigLocation)
+		Expect(err).NotTo(HaveOccurred())
+		cfg, err := config.LoadConfig(cfgLoc)
+		Expect(err).NotTo(HaveOccurred())
+		cacheDir, err = os.MkdirTemp("", "speech-test-")
+		Expect(err).NotTo(HaveOccurred())
+		cfg.Speech.CacheDir = cacheDir
+		cfg.Speech.Voice = "nova"
+
+		assistant, err := completions.NewMajordomo(cfg)
+		Expect(err).NotTo(HaveOccurred())
+		// The audio is already in the cache, so the TTS API is never called.
+		file, err = assistant.SpeechFile(completions.SpeechRequest{Text: "All tests pass."})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.WriteFile(filepath.Join(cacheDir, file), []byte("ID3 audio"), 0644)).To(Succeed())
+
+		gin.SetMode(gin.TestMode)
+		router = gin.New()
+		server.SetupTestRoutes(router, assistant)
+	})
+	AfterEach(func() {
+		Expect(os.RemoveAll(cacheDir)).To(Succeed())
+	})
+
+	speak := func(url string, request completions.SpeechRequest) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(request)
+		req, _ := http.NewRequest("POST", url, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	It("should return the audio", func() {
+		resp := speak("/api/v1/speak", completions.SpeechRequest{Text: "All tests pass.", Voice: "nova", Format: "mp3"})
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		Expect(resp.Header().Get("Content-Type")).To(Equal("audio/mpeg"))
+		Expect(resp.Body.String()).To(Equal("ID3 audio"))
+	})
+
+	It("should return the URL of the cached audio", func() {
+		resp := speak("/api/v1/speak?url=true", completions.SpeechRequest{Text: "All tests pass."})
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		var body map[string]interface{}
+		Expect(json.Unmarshal(resp.Body.Bytes(), &body)).To(Succeed())
+		Expect(body["url"]).To(Equal("/api/v1/speech/" + file))
+
+		req, _ := http.NewRequest("GET", "/api/v1/speech/"+file, nil)
+		resp = httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		Expect(resp.Body.String()).To(Equal("ID3 audio"))
+	})
+
+	It("should reject invalid requests", func() {
+		Expect(speak("/api/v1/speak", completions.SpeechRequest{}).Code).To(Equal(http.StatusBadRequest))
+		Expect(speak("/api/v1/speak", completions.SpeechRequest{Text: "Hi", Voice: "yoda"}).Code).
+			To(Equal(http.StatusBadRequest))
+		Expect(speak("/api/v1/speak", completions.SpeechRequest{Text: "Hi", Speed: 5}).Code).
+			To(Equal(http.StatusBadRequest))
+	})
+
+	It("should return 404 for unknown audio files", func() {
+		for _, url := range []string{"/api/v1/speech/missing.mp3", "/api/v1/speech/" + file + ".txt"} {
+			req, _ := http.NewRequest("GET", url, nil)
+			resp := httptest.NewRecorder()
+			router.ServeHTTP(resp, req)
+			Expect(resp.Code).To(Equal(http.StatusNotFound))
+		}
+	})
+})
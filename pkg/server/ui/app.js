@@ -0,0 +1,521 @@
+// Copyright (c) 2025 AlertAvert.com. All rights reserved.
+//
+// The Majordomo web UI: it only uses the /api/v1 routes, and the /api/v1/ws
+// WebSocket for the chat sessions.
+'use strict';
+
+const API = '/api/v1';
+
+const state = {
+  project: '',
+  assistant: '',
+  threadId: '',
+  threadName: '',
+  ws: null,
+  // The id of the prompt running, if any.
+  running: '',
+  nextId: 1,
+  recorder: null,
+};
+
+const $ = (id) => document.getElementById(id);
+
+// el creates an element, with its text and CSS class.
+function el(tag, text, className) {
+  const e = document.createElement(tag);
+  if (text !== undefined) e.textContent = text;
+  if (className) e.className = className;
+  return e;
+}
+
+// api calls the REST API, and returns the JSON body; the errors are thrown
+// with the message of the error envelope.
+async function api(method, path, body) {
+  const options = { method, headers: {} };
+  if (body instanceof FormData) {
+    options.body = body;
+  } else if (body !== undefined) {
+    options.headers['Content-Type'] = 'application/json';
+    options.body = JSON.stringify(body);
+  }
+  const resp = await fetch(API + path, options);
+  const data = await resp.json().catch(() => ({}));
+  if (!resp.ok) {
+    throw new Error((data.error && data.error.message) || resp.statusText);
+  }
+  return data;
+}
+
+const enc = encodeURIComponent;
+const projectPath = (suffix) => `/projects/${enc(state.project)}${suffix || ''}`;
+const snippetPath = (path) => projectPath('/snippets/' + path.split('/').map(enc).join('/'));
+
+function setStatus(text) {
+  $('status').textContent = text;
+}
+
+function setProgress(text) {
+  $('progress').textContent = text || '';
+}
+
+function showError(err) {
+  addMessage('error', err.message || String(err));
+}
+
+// Messages
+
+function addMessage(kind, text) {
+  const div = el('div', undefined, 'message ' + kind);
+  renderText(div, text);
+  $('messages').appendChild(div);
+  div.scrollIntoView({ block: 'end' });
+  return div;
+}
+
+// renderText shows the code blocks (fenced with ``` or, in the prompts,
+// with ''') in <pre> elements.
+function renderText(parent, text) {
+  const parts = (text || '').split(/^(?:```|''')/m);
+  parts.forEach((part, i) => {
+    if (i % 2 === 1) {
+      parent.appendChild(el('pre', part.replace(/\n$/, '')));
+    } else if (part.trim() !== '') {
+      parent.appendChild(document.createTextNode(part));
+    }
+  });
+}
+
+function renderResponse(div, response) {
+  div.textContent = '';
+  renderText(div, response.text);
+  if (response.code_blocks && response.code_blocks.length > 0) {
+    const blocks = el('ul', undefined, 'blocks');
+    for (const block of response.code_blocks) {
+      let status = block.saved ? 'saved' : (response.saved ? 'not saved' : 'preview');
+      if (block.reason) status += ': ' + block.reason;
+      if (!block.changed) status += ', unchanged';
+      const li = el('li', `${block.path || block.language || 'code'} (${status})`);
+      if (block.saved && block.path) {
+        const view = el('button', 'diff', 'small');
+        view.onclick = () => showSnippet(block.path);
+        li.appendChild(document.createTextNode(' '));
+        li.appendChild(view);
+      }
+      blocks.appendChild(li);
+    }
+    div.appendChild(blocks);
+  }
+  if (response.commands && response.commands.length > 0) {
+    div.appendChild(el('div', 'Commands:', 'blocks'));
+    div.appendChild(el('pre', response.commands.join('\n')));
+  }
+  const full = el('details');
+  full.appendChild(el('summary', 'Full response'));
+  full.appendChild(el('pre', response.message));
+  div.appendChild(full);
+}
+
+// Projects & assistants
+
+async function loadProjects() {
+  const data = await api('GET', '/projects');
+  const select = $('project');
+  select.textContent = '';
+  for (const p of data.projects) {
+    select.appendChild(el('option', p.name));
+  }
+  select.value = data.active_project;
+  await selectProject(data.active_project);
+}
+
+async function loadAssistants() {
+  const assistants = await api('GET', '/assistants');
+  const select = $('assistant');
+  select.textContent = '';
+  for (const a of assistants) {
+    if (a.name) select.appendChild(el('option', a.name));
+  }
+  state.assistant = select.value;
+}
+
+async function selectProject(name) {
+  state.project = name;
+  newConversation('');
+  await Promise.all([loadThreads(), loadFiles(), loadSnippets()]);
+}
+
+async function loadFiles() {
+  const data = await api('GET', projectPath('/files'));
+  const list = $('files');
+  list.textContent = '';
+  for (const f of data.files) {
+    const option = el('option');
+    option.value = f;
+    list.appendChild(option);
+  }
+}
+
+// Conversations
+
+async function loadThreads() {
+  const data = await api('GET', projectPath('/conversations'));
+  const list = $('threads');
+  list.textContent = '';
+  for (const thread of data.threads) {
+    const li = el('li', thread.name || thread.id);
+    li.title = thread.description || thread.id;
+    li.appendChild(el('div', thread.assistant, 'meta'));
+    if (thread.id === state.threadId) li.classList.add('selected');
+    li.onclick = () => openThread(thread).catch(showError);
+    list.appendChild(li);
+  }
+}
+
+function setTitle() {
+  $('thread-title').textContent = state.threadName || (state.threadId ? state.threadId : 'New conversation');
+}
+
+function newConversation(name) {
+  state.threadId = '';
+  state.threadName = name;
+  $('messages').textContent = '';
+  setTitle();
+  connect();
+}
+
+async function openThread(thread) {
+  state.threadId = thread.id;
+  state.threadName = thread.name;
+  if (thread.assistant) {
+    $('assistant').value = thread.assistant;
+    state.assistant = $('assistant').value || state.assistant;
+  }
+  setTitle();
+  connect();
+  await loadThreads();
+  const messages = $('messages');
+  messages.textContent = '';
+  setProgress('Loading the conversation...');
+  try {
+    const data = await api('GET', `/conversations/${enc(thread.id)}/messages?project=${enc(state.project)}`);
+    for (const m of data.messages) {
+      addMessage(m.role === 'user' ? 'user' : 'assistant', m.text);
+    }
+  } finally {
+    setProgress('');
+  }
+}
+
+// Chat session
+
+function connect() {
+  if (state.ws) {
+    state.ws.onclose = null;
+    state.ws.close();
+  }
+  setRunning('');
+  if (!state.project || !state.assistant) return;
+  const query = new URLSearchParams({ project: state.project, assistant: state.assistant });
+  if (state.threadId) {
+    query.set('thread_id', state.threadId);
+  } else if (state.threadName) {
+    query.set('thread_name', state.threadName);
+  }
+  const scheme = location.protocol === 'https:' ? 'wss:' : 'ws:';
+  const ws = new WebSocket(`${scheme}//${location.host}${API}/ws?${query}`);
+  ws.onopen = () => setStatus('Connected');
+  ws.onclose = () => {
+    setStatus('Disconnected');
+    setRunning('');
+    state.ws = null;
+  };
+  ws.onmessage = (msg) => handleEvent(JSON.parse(msg.data));
+  state.ws = ws;
+}
+
+// send sends the message on the session, reconnecting if necessary.
+function send(message) {
+  if (!state.ws) connect();
+  const ws = state.ws;
+  if (!ws) {
+    showError(new Error('Select a project and an assistant'));
+    return false;
+  }
+  const payload = JSON.stringify(message);
+  if (ws.readyState === WebSocket.OPEN) {
+    ws.send(payload);
+  } else {
+    ws.addEventListener('open', () => ws.send(payload), { once: true });
+  }
+  return true;
+}
+
+function setRunning(id) {
+  state.running = id;
+  $('send').disabled = !!id;
+  $('cancel').disabled = !id;
+  if (!id) setProgress('');
+}
+
+// pending is the message showing the output of the prompt running.
+let pending = null;
+
+function handleEvent(event) {
+  switch (event.type) {
+    case 'session': {
+      const created = !state.threadId && event.session.thread_id;
+      state.threadId = event.session.thread_id || '';
+      state.threadName = event.session.thread_name || '';
+      state.assistant = event.session.assistant;
+      setTitle();
+      if (created) loadThreads().catch(showError);
+      break;
+    }
+    case 'progress': {
+      const p = event.progress;
+      switch (p.stage) {
+        case 'thread':
+          setProgress(`Thread: ${p.thread_name || p.thread_id}`);
+          break;
+        case 'run':
+          setProgress(`Run ${p.run_id}: ${p.run_status}`);
+          break;
+        case 'output':
+          setProgress('Processing the response...');
+          pending = addMessage('assistant', p.text);
+          break;
+        case 'snippet_saved':
+          setProgress(`Saved ${p.path}`);
+          break;
+      }
+      break;
+    }
+    case 'response':
+      renderResponse(pending || addMessage('assistant', ''), event.response);
+      pending = null;
+      setRunning('');
+      if (event.response.saved) loadSnippets().catch(showError);
+      break;
+    case 'parsed': {
+      const div = addMessage('info', event.parsed.message);
+      const redactions = event.parsed.redactions || [];
+      if (redactions.length > 0) {
+        div.appendChild(el('div', `${redactions.length} secret(s) redacted`, 'blocks'));
+      }
+      break;
+    }
+    case 'cancelled':
+      addMessage('info', 'Cancelled');
+      pending = null;
+      setRunning('');
+      break;
+    case 'error':
+      addMessage('error', event.error.message);
+      if (event.id && event.id === state.running) {
+        pending = null;
+        setRunning('');
+      }
+      break;
+  }
+}
+
+function sendPrompt() {
+  const text = $('prompt').value;
+  if (!text.trim() || state.running) return;
+  const id = String(state.nextId++);
+  if (send({ type: 'prompt', id, prompt: text, save: $('save').checked })) {
+    addMessage('user', text);
+    $('prompt').value = '';
+    setRunning(id);
+    setProgress('Sending...');
+  }
+}
+
+function addFile() {
+  const path = $('file').value.trim();
+  if (!path) return;
+  const prompt = $('prompt');
+  const prefix = prompt.value && !prompt.value.endsWith('\n') ? '\n' : '';
+  prompt.value += `${prefix}'''${path}\n'''\n`;
+  $('file').value = '';
+  prompt.focus();
+}
+
+// Snippets
+
+async function loadSnippets() {
+  const data = await api('GET', projectPath('/snippets'));
+  const list = $('snippets');
+  list.textContent = '';
+  for (const s of data.snippets) {
+    const li = el('li', s.path);
+    li.appendChild(el('div', `${(s.versions || []).length} version(s), ${new Date(s.modified).toLocaleString()}`,
+      'meta'));
+    li.onclick = () => showSnippet(s.path, s.versions || [], s.current).catch(showError);
+    list.appendChild(li);
+  }
+}
+
+function renderDiff(text) {
+  const pre = $('viewer-content');
+  pre.textContent = '';
+  if (!text) {
+    pre.textContent = 'No changes.';
+    return;
+  }
+  for (const line of text.split('\n')) {
+    let className;
+    if (line.startsWith('@@')) className = 'hunk';
+    else if (line.startsWith('+') && !line.startsWith('+++')) className = 'add';
+    else if (line.startsWith('-') && !line.startsWith('---')) className = 'del';
+    pre.appendChild(el('div', line || ' ', className));
+  }
+}
+
+// showSnippet shows the diff of the snippet against the project's file and,
+// if selected, of its previous versions against the current one.
+async function showSnippet(path, versions, current) {
+  if (versions === undefined) {
+    const data = await api('GET', projectPath('/snippets'));
+    const s = data.snippets.find((s) => s.path === path) || {};
+    versions = s.versions || [];
+    current = s.current;
+  }
+  $('viewer').hidden = false;
+  $('viewer-title').textContent = path;
+  const select = $('versions');
+  select.textContent = '';
+  const option = el('option', 'Changes to the project');
+  option.value = '';
+  select.appendChild(option);
+  for (const v of versions.slice().reverse()) {
+    if (v.id === current) continue;
+    const o = el('option', `Since ${new Date(v.timestamp).toLocaleString()}`);
+    o.value = v.id;
+    select.appendChild(o);
+  }
+  select.onchange = async () => {
+    try {
+      if (select.value) {
+        renderDiff((await api('GET', projectPath(`/versions/${enc(select.value)}/diff`))).diff);
+      } else {
+        renderDiff((await api('GET', snippetPath(path) + '?diff=true')).diff);
+      }
+    } catch (err) {
+      showError(err);
+    }
+  };
+  await select.onchange();
+}
+
+// Voice commands
+
+async function toggleRecording() {
+  const button = $('record');
+  if (state.recorder) {
+    state.recorder.stop();
+    return;
+  }
+  const stream = await navigator.mediaDevices.getUserMedia({ audio: true });
+  const recorder = new MediaRecorder(stream);
+  const chunks = [];
+  recorder.ondataavailable = (e) => chunks.push(e.data);
+  recorder.onstop = async () => {
+    stream.getTracks().forEach((t) => t.stop());
+    state.recorder = null;
+    button.classList.remove('recording');
+    const type = recorder.mimeType || 'audio/webm';
+    const extension = type.includes('ogg') ? 'ogg' : (type.includes('mp4') ? 'mp4' : 'webm');
+    const form = new FormData();
+    form.append('audio', new Blob(chunks, { type }), `command.${extension}`);
+    setProgress('Transcribing...');
+    try {
+      await runCommand(await api('POST', '/command', form));
+    } catch (err) {
+      showError(err);
+    } finally {
+      setProgress('');
+    }
+  };
+  state.recorder = recorder;
+  button.classList.add('recording');
+  recorder.start();
+}
+
+// runCommand shows the outcome of the spoken command, which may have
+// switched the project or the conversation.
+async function runCommand(result) {
+  addMessage('user', result.transcript);
+  if (result.response) {
+    renderResponse(addMessage('assistant', ''), result.response);
+  } else if (result.message) {
+    addMessage('info', result.message);
+  }
+  if (result.active_project && result.active_project !== state.project) {
+    $('project').value = result.active_project;
+    await selectProject(result.active_project);
+  }
+  if (result.thread_id && result.thread_id !== state.threadId) {
+    await openThread({ id: result.thread_id, name: result.thread_name, assistant: result.assistant });
+  } else if (result.assistant && result.assistant !== state.assistant) {
+    $('assistant').value = result.assistant;
+    send({ type: 'assistant', assistant: result.assistant });
+  }
+}
+
+// Wiring
+
+function init() {
+  $('project').onchange = async (e) => {
+    try {
+      await api('PUT', '/projects', { active_project: e.target.value });
+      await selectProject(e.target.value);
+    } catch (err) {
+      showError(err);
+    }
+  };
+  $('assistant').onchange = (e) => {
+    state.assistant = e.target.value;
+    if (state.ws) send({ type: 'assistant', id: '', assistant: state.assistant });
+    else connect();
+  };
+  $('new-thread').onsubmit = (e) => {
+    e.preventDefault();
+    newConversation($('thread-name').value.trim());
+    $('thread-name').value = '';
+    loadThreads().catch(showError);
+  };
+  $('prompt-form').onsubmit = (e) => {
+    e.preventDefault();
+    sendPrompt();
+  };
+  $('prompt').onkeydown = (e) => {
+    if (e.key === 'Enter' && (e.ctrlKey || e.metaKey)) {
+      e.preventDefault();
+      sendPrompt();
+    }
+  };
+  $('parse').onclick = () => {
+    const text = $('prompt').value;
+    if (text.trim()) send({ type: 'parse', id: String(state.nextId++), prompt: text });
+  };
+  $('cancel').onclick = () => send({ type: 'cancel', id: state.running });
+  $('add-file').onclick = addFile;
+  $('file').onkeydown = (e) => {
+    if (e.key === 'Enter') {
+      e.preventDefault();
+      addFile();
+    }
+  };
+  $('refresh-snippets').onclick = () => loadSnippets().catch(showError);
+  $('close-viewer').onclick = () => { $('viewer').hidden = true; };
+  $('record').onclick = () => toggleRecording().catch(showError);
+  if (!window.MediaRecorder) $('record').hidden = true;
+
+  // The assistants are needed to open the chat session on the project.
+  loadAssistants()
+    .then(loadProjects)
+    .catch(showError);
+}
+
+init();
@@ -0,0 +1,43 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package server
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UIPrefix is where the web UI is served.
+const UIPrefix = "/ui"
+
+// The web UI is a static single-page app, which only uses the API.
+//
+//go:embed ui
+var uiFiles embed.FS
+
+// uiHandler serves the web UI embedded in the binary, at UIPrefix/*path.
+func uiHandler() gin.HandlerFunc {
+	assets, err := fs.Sub(uiFiles, "ui")
+	if err != nil {
+		// The directory is embedded, so this cannot happen.
+		panic(err)
+	}
+	fileServer := http.StripPrefix(UIPrefix, http.FileServer(http.FS(assets)))
+	return func(c *gin.Context) {
+		name := strings.TrimPrefix(c.Param("path"), "/")
+		if name == "" {
+			name = "index.html"
+		}
+		if _, err := fs.Stat(assets, name); err != nil {
+			noRouteHandler(c)
+			return
+		}
+		fileServer.ServeHTTP(c.Writer, c.Request)
+	}
+}
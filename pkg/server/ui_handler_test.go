@@ -0,0 +1,67 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alertavert/gpt4-go/pkg/completions"
+	"github.com/alertavert/gpt4-go/pkg/config"
+	"github.com/alertavert/gpt4-go/pkg/server"
+)
+
+var _ = Describe("The web UI", func() {
+	var router *gin.Engine
+
+	BeforeEach(func() {
+		cfgLoc, err := MkTempConfigFile(TestConfigLocation)
+		Expect(err).NotTo(HaveOccurred())
+		cfg, err := config.LoadConfig(cfgLoc)
+		Expect(err).NotTo(HaveOccurred())
+		assistant, err := completions.NewMajordomo(cfg)
+		Expect(err).NotTo(HaveOccurred())
+		gin.SetMode(gin.TestMode)
+		router = gin.New()
+		server.SetupTestRoutes(router, assistant)
+	})
+
+	get := func(target string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodGet, target, nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	It("serves the page, and its assets", func() {
+		resp := get("/ui/")
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		Expect(resp.Header().Get("Content-Type")).To(HavePrefix("text/html"))
+		Expect(resp.Body.String()).To(ContainSubstring(`<script src="app.js">`))
+
+		resp = get("/ui/app.js")
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		Expect(resp.Header().Get("Content-Type")).To(ContainSubstring("javascript"))
+		Expect(resp.Body.String()).To(ContainSubstring("const API = '/api/v1'"))
+
+		Expect(get("/ui/style.css").Code).To(Equal(http.StatusOK))
+	})
+
+	It("redirects to the page", func() {
+		resp := get("/ui")
+		Expect(resp.Code).To(Equal(http.StatusMovedPermanently))
+		Expect(resp.Header().Get("Location")).To(Equal("/ui/"))
+	})
+
+	It("reports the missing assets in the error envelope", func() {
+		resp := get("/ui/missing.js")
+		Expect(resp.Code).To(Equal(http.StatusNotFound))
+		Expect(resp.Body.String()).To(ContainSubstring(`"code":"not_found"`))
+	})
+})
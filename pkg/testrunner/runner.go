@@ -0,0 +1,195 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package testrunner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/alertavert/gpt4-go/pkg/api"
+)
+
+const (
+	// DefaultTimeout is the maximum time a single test run is allowed to take.
+	DefaultTimeout = 5 * time.Minute
+	// MaxOutputLen caps the amount of test output we keep (and send to the LLM).
+	MaxOutputLen = 16 * 1024
+)
+
+// DefaultCommand is used when the project does not configure its own test command.
+var DefaultCommand = []string{"go", "test"}
+
+var (
+	// Matches `--- FAIL: TestName (0.01s)` lines emitted by `go test`.
+	failedTestRegex = regexp.MustCompile(`(?m)^\s*--- FAIL: (\S+)`)
+	// Matches file references such as `parser_test.go:42:` or `./pkg/foo/bar.go:12:3:`.
+	fileRefRegex = regexp.MustCompile(`([\w./-]+\.go):\d+`)
+)
+
+// Runner executes a test command in a directory.
+type Runner struct {
+	// Command is the test command and its arguments; the packages are appended to it.
+	Command []string
+	// Dir is the working directory for the command.
+	Dir string
+	// Timeout for each test run; DefaultTimeout if zero.
+	Timeout time.Duration
+}
+
+// Result is the outcome of a test run.
+type Result = api.TestResult
+
+// NewRunner creates a Runner for `dir`, using DefaultCommand if `command` is empty.
+func NewRunner(dir string, command []string) *Runner {
+	if len(command) == 0 {
+		command = DefaultCommand
+	}
+	return &Runner{
+		Command: command,
+		Dir:     dir,
+		Timeout: DefaultTimeout,
+	}
+}
+
+// Run executes the tests for the given packages, and returns their outcome;
+// the command is killed if `ctx` is cancelled.
+// A non-nil error is only returned if the command could not be run at all:
+// failing tests are reported via Result.Passed.
+func (r *Runner) Run(ctx context.Context, packages []string) (*Result, error) {
+	timeout := r.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	args := append(append([]string{}, r.Command[1:]...), packages...)
+	cmd := exec.CommandContext(ctx, r.Command[0], args...)
+	cmd.Dir = r.Dir
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	start := time.Now()
+	err := cmd.Run()
+	result := &Result{
+		Output:   truncate(out.String(), MaxOutputLen),
+		Duration: time.Since(start),
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("tests timed out after %v", timeout)
+	}
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("tests interrupted: %w", ctx.Err())
+	}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("error running %s: %w", strings.Join(r.Command, " "), err)
+		}
+		result.FailedTests = FailedTests(out.String())
+	} else {
+		result.Passed = true
+	}
+	log.Debug().
+		Strs("command", r.Command).
+		Strs("packages", packages).
+		Bool("passed", result.Passed).
+		Int("failed", len(result.FailedTests)).
+		Dur("duration", result.Duration).
+		Msg("tests run")
+	return result, nil
+}
+
+// FailedTests extracts the names of the failed tests from the `go test` output.
+func FailedTests(output string) []string {
+	var tests []string
+	for _, m := range failedTestRegex.FindAllStringSubmatch(output, -1) {
+		tests = append(tests, m[1])
+	}
+	return tests
+}
+
+// RelevantFiles returns the paths, relative to `root`, of the Go source files
+// that are relevant to fix the failures in `output`: those explicitly referenced
+// in the output, plus all the files in the same packages.
+// Only files inside the directories of `packages` are considered; at most
+// `maxFiles` are returned.
+func RelevantFiles(root string, packages []string, output string, maxFiles int) []string {
+	referenced := make(map[string]bool)
+	for _, m := range fileRefRegex.FindAllStringSubmatch(output, -1) {
+		referenced[filepath.Base(m[1])] = true
+	}
+	dirs := make(map[string]bool)
+	var candidates []string
+	for _, pkg := range packages {
+		dir, recursive := packageDir(pkg)
+		_ = filepath.Walk(filepath.Join(root, dir), func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			if info.IsDir() {
+				if path != filepath.Join(root, dir) && (!recursive || skipDirs[info.Name()]) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if strings.HasSuffix(path, ".go") {
+				rel, _ := filepath.Rel(root, path)
+				candidates = append(candidates, rel)
+				if referenced[info.Name()] {
+					dirs[filepath.Dir(rel)] = true
+				}
+			}
+			return nil
+		})
+	}
+	var files []string
+	for _, c := range candidates {
+		// If nothing could be matched, we send everything we have (up to maxFiles).
+		if len(dirs) == 0 || dirs[filepath.Dir(c)] {
+			files = append(files, c)
+		}
+	}
+	// Files referenced in the output come first, so they are never left out.
+	sort.SliceStable(files, func(i, j int) bool {
+		return referenced[filepath.Base(files[i])] && !referenced[filepath.Base(files[j])]
+	})
+	if maxFiles > 0 && len(files) > maxFiles {
+		files = files[:maxFiles]
+	}
+	return files
+}
+
+// packageDir converts a package pattern (e.g. `./pkg/...`) into a relative
+// directory, and whether it should be walked recursively.
+func packageDir(pkg string) (string, bool) {
+	recursive := strings.HasSuffix(pkg, "/...") || pkg == "..."
+	dir := strings.TrimSuffix(strings.TrimSuffix(pkg, "..."), "/")
+	dir = strings.TrimPrefix(dir, "./")
+	if dir == "" || dir == "." {
+		return ".", recursive
+	}
+	return dir, recursive
+}
+
+func truncate(s string, maxLen int) string {
+	if len(s) <= maxLen {
+		return s
+	}
+	// Failures are usually reported at the end, so we keep the tail.
+	return "[...truncated...]\n" + s[len(s)-maxLen:]
+}
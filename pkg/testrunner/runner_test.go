@@ -0,0 +1,120 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package testrunner_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alertavert/gpt4-go/pkg/testrunner"
+)
+
+const (
+	sampleProject = "../../testdata/actual"
+	failingOutput = `--- FAIL: TestSimple (0.00s)
+    simple_test.go:12: expected 5, got 4
+FAIL
+FAIL	sample/pkg	0.003s
+`
+	failingTest = `package pkg
+
+import "testing"
+
+func TestSimple(t *testing.T) {
+	if err := Simple("test"); err == nil {
+		t.Errorf("expected an error")
+	}
+}
+`
+)
+
+var _ = Describe("Worktree", func() {
+	It("copies the project sources in a scratch directory", func() {
+		wt, err := testrunner.NewWorktree(sampleProject)
+		Expect(err).NotTo(HaveOccurred())
+		defer func() { Expect(wt.Remove()).To(Succeed()) }()
+
+		Expect(wt.Dir).NotTo(Equal(wt.Source))
+		original, err := os.ReadFile(filepath.Join(sampleProject, "pkg/simple.go"))
+		Expect(err).NotTo(HaveOccurred())
+		copied, err := wt.ReadFile("pkg/simple.go")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(copied).To(Equal(string(original)))
+	})
+	It("skips the excluded directories", func() {
+		wt, err := testrunner.NewWorktree(sampleProject, filepath.Join(sampleProject, "sample"))
+		Expect(err).NotTo(HaveOccurred())
+		defer func() { Expect(wt.Remove()).To(Succeed()) }()
+
+		_, err = os.Stat(filepath.Join(wt.Dir, "sample"))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+		_, err = os.Stat(filepath.Join(wt.Dir, "pkg/simple.go"))
+		Expect(err).NotTo(HaveOccurred())
+	})
+	It("fails for a non-existent project", func() {
+		_, err := testrunner.NewWorktree("/no/such/project")
+		Expect(err).To(HaveOccurred())
+	})
+	It("refuses to remove directories it did not create", func() {
+		wt := testrunner.Worktree{Dir: os.TempDir()}
+		Expect(wt.Remove()).NotTo(Succeed())
+	})
+})
+
+var _ = Describe("Runner", func() {
+	It("extracts the names of the failed tests", func() {
+		Expect(testrunner.FailedTests(failingOutput)).To(ConsistOf("TestSimple"))
+		Expect(testrunner.FailedTests("ok  \tsample/pkg\t0.002s")).To(BeEmpty())
+	})
+	It("finds the files relevant to the failures", func() {
+		wt, err := testrunner.NewWorktree(sampleProject)
+		Expect(err).NotTo(HaveOccurred())
+		defer func() { Expect(wt.Remove()).To(Succeed()) }()
+		Expect(os.WriteFile(filepath.Join(wt.Dir, "pkg/simple_test.go"), []byte(failingTest), 0644)).
+			To(Succeed())
+
+		files := testrunner.RelevantFiles(wt.Dir, []string{"./..."}, failingOutput, 10)
+		Expect(files).To(ConsistOf("pkg/simple_test.go", "pkg/simple.go"))
+		Expect(files[0]).To(Equal("pkg/simple_test.go"))
+		Expect(testrunner.RelevantFiles(wt.Dir, []string{"./..."}, failingOutput, 1)).To(HaveLen(1))
+	})
+	It("reports passing and failing tests", func() {
+		wt, err := testrunner.NewWorktree(sampleProject)
+		Expect(err).NotTo(HaveOccurred())
+		defer func() { Expect(wt.Remove()).To(Succeed()) }()
+
+		runner := testrunner.NewRunner(wt.Dir, nil)
+		result, err := runner.Run(context.Background(), []string{"./pkg/..."})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Passed).To(BeTrue())
+
+		Expect(os.WriteFile(filepath.Join(wt.Dir, "pkg/simple_test.go"), []byte(failingTest), 0644)).
+			To(Succeed())
+		result, err = runner.Run(context.Background(), []string{"./pkg/..."})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Passed).To(BeFalse())
+		Expect(result.FailedTests).To(ConsistOf("TestSimple"))
+		Expect(result.Output).To(ContainSubstring("expected an error"))
+	})
+	It("returns an error if the command cannot be run", func() {
+		runner := testrunner.NewRunner(sampleProject, []string{"/no/such/command"})
+		_, err := runner.Run(context.Background(), nil)
+		Expect(err).To(HaveOccurred())
+	})
+	It("kills the command if the context is cancelled", func() {
+		runner := testrunner.NewRunner(sampleProject, []string{"sleep", "60"})
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(100*time.Millisecond, cancel)
+		start := time.Now()
+		_, err := runner.Run(ctx, nil)
+		Expect(err).To(MatchError(context.Canceled))
+		Expect(time.Since(start)).To(BeNumerically("<", 10*time.Second))
+	})
+})
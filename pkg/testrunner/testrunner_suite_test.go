@@ -0,0 +1,17 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+package testrunner_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rs/zerolog"
+	"testing"
+)
+
+func TestTestRunner(t *testing.T) {
+	RegisterFailHandler(Fail)
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	RunSpecs(t, "Test Runner Suite")
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package testrunner
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Worktree is a scratch copy of a project's source tree, where the tests can
+// be run and the code returned by the LLM can be applied without touching
+// the original sources.
+type Worktree struct {
+	// Source is the directory the Worktree was copied from.
+	Source string
+	// Dir is the location of the scratch copy.
+	Dir string
+}
+
+// skipDirs are never copied into a Worktree.
+var skipDirs = map[string]bool{
+	".git":         true,
+	".idea":        true,
+	"node_modules": true,
+}
+
+// NewWorktree copies the contents of `source` into a newly created temporary
+// directory; any of the `exclude` directories (absolute paths, typically the
+// project's code snippets folder) are skipped, as well as VCS metadata.
+func NewWorktree(source string, exclude ...string) (*Worktree, error) {
+	source, err := filepath.Abs(source)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, fmt.Errorf("cannot access project source %s: %w", source, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("project source %s is not a directory", source)
+	}
+	dir, err := os.MkdirTemp("", "majordomo-worktree-")
+	if err != nil {
+		return nil, err
+	}
+	excluded := make(map[string]bool)
+	for _, e := range exclude {
+		if abs, err := filepath.Abs(e); err == nil {
+			excluded[abs] = true
+		}
+	}
+	err = filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+		destPath := filepath.Join(dir, relPath)
+		if info.IsDir() {
+			if path != source && (skipDirs[info.Name()] || excluded[path]) {
+				return filepath.SkipDir
+			}
+			return os.MkdirAll(destPath, info.Mode().Perm()|0700)
+		}
+		if !info.Mode().IsRegular() {
+			// Symlinks, sockets and the like are not needed to run tests.
+			return nil
+		}
+		return copyFile(path, destPath, info.Mode().Perm())
+	})
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, fmt.Errorf("error copying %s to worktree: %w", source, err)
+	}
+	log.Debug().
+		Str("source", source).
+		Str("worktree", dir).
+		Msg("worktree created")
+	return &Worktree{Source: source, Dir: dir}, nil
+}
+
+// Remove deletes the scratch copy from disk.
+func (w *Worktree) Remove() error {
+	if w.Dir == "" || !strings.HasPrefix(filepath.Base(w.Dir), "majordomo-worktree-") {
+		return fmt.Errorf("refusing to remove %q, not a worktree", w.Dir)
+	}
+	return os.RemoveAll(w.Dir)
+}
+
+// ReadFile returns the contents of the file at `relPath` in the Worktree.
+func (w *Worktree) ReadFile(relPath string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(w.Dir, relPath))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func copyFile(src, dest string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,127 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+// Package tracing sets up the OpenTelemetry traces, and exports them to an
+// OTLP collector.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/alertavert/gpt4-go/pkg/config"
+	"github.com/alertavert/gpt4-go/pkg/metrics"
+)
+
+const (
+	// DefaultEndpoint is where the traces are sent, if not configured.
+	DefaultEndpoint = "localhost:4318"
+	// DefaultServiceName identifies the server in the traces, if not configured.
+	DefaultServiceName = "majordomo"
+
+	instrumentation = "github.com/alertavert/gpt4-go"
+)
+
+// Attribute keys of the Majordomo spans.
+const (
+	Project      = attribute.Key("majordomo.project")
+	Assistant    = attribute.Key("majordomo.assistant")
+	ThreadId     = attribute.Key("majordomo.thread_id")
+	RunId        = attribute.Key("majordomo.run_id")
+	RunStatus    = attribute.Key("majordomo.run_status")
+	PromptTokens = attribute.Key("majordomo.tokens.prompt")
+	ReplyTokens  = attribute.Key("majordomo.tokens.completion")
+)
+
+// Setup installs the W3C trace context (and baggage) propagator and, if
+// enabled, a tracer provider exporting the spans to the OTLP collector.
+// The returned function flushes the pending spans, and must be called on exit.
+func Setup(ctx context.Context, cfg config.TracingConfig, release string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+	if !cfg.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+	options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpoint)}
+	if cfg.Insecure {
+		options = append(options, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating the OTLP exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(ServiceName(cfg)),
+		semconv.ServiceVersion(release),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("error creating the tracing resource: %w", err)
+	}
+
+	sampler := sdktrace.AlwaysSample()
+	if cfg.SampleRatio > 0 && cfg.SampleRatio < 1 {
+		sampler = sdktrace.TraceIDRatioBased(cfg.SampleRatio)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sampler)),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// ServiceName identifies the server in the traces.
+func ServiceName(cfg config.TracingConfig) string {
+	if cfg.ServiceName == "" {
+		return DefaultServiceName
+	}
+	return cfg.ServiceName
+}
+
+// Start creates a span, child of the one in `ctx` (if any); it is a no-op
+// unless tracing was set up.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentation).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records the error, if any, and ends the span; it is meant to be
+// deferred, with a pointer to the function's named error result.
+func End(span trace.Span, err *error) {
+	if err != nil && *err != nil {
+		span.RecordError(*err)
+		span.SetStatus(codes.Error, (*err).Error())
+	}
+	span.End()
+}
+
+// HTTPClient creates a client which traces the requests sent via `transport`,
+// naming the spans after the OpenAI API operation, e.g.:
+// `OpenAI POST /threads/:id/runs`.
+func HTTPClient(transport http.RoundTripper) *http.Client {
+	return &http.Client{
+		Transport: otelhttp.NewTransport(transport,
+			otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+				return "OpenAI " + metrics.Operation(r.Method, r.URL.Path)
+			})),
+	}
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright (c) 2025 AlertAvert.com. All rights reserved.
+ */
+
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/alertavert/gpt4-go/pkg/config"
+	"github.com/alertavert/gpt4-go/pkg/tracing"
+)
+
+var _ = Describe("Tracing", func() {
+	var (
+		recorder *tracetest.SpanRecorder
+		previous trace.TracerProvider
+	)
+
+	BeforeEach(func() {
+		previous = otel.GetTracerProvider()
+		recorder = tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	})
+	AfterEach(func() {
+		otel.SetTracerProvider(previous)
+	})
+
+	It("propagates the W3C trace context, even if the export is disabled", func() {
+		shutdown, err := tracing.Setup(context.Background(), config.TracingConfig{}, "test")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(shutdown(context.Background())).To(Succeed())
+
+		header := http.Header{}
+		header.Set("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+		ctx := otel.GetTextMapPropagator().Extract(context.Background(), propagation.HeaderCarrier(header))
+		Expect(trace.SpanContextFromContext(ctx).TraceID().String()).
+			To(Equal("0af7651916cd43dd8448eb211c80319c"))
+	})
+
+	It("creates child spans with the attributes", func() {
+		ctx, parent := tracing.Start(context.Background(), "QueryBot", tracing.Project.String("majordomo"))
+		_, child := tracing.Start(ctx, "StartRun", tracing.RunId.String("run_1"))
+		child.End()
+		parent.End()
+
+		spans := recorder.Ended()
+		Expect(spans).To(HaveLen(2))
+		Expect(spans[0].Name()).To(Equal("StartRun"))
+		Expect(spans[0].Parent().SpanID()).To(Equal(spans[1].SpanContext().SpanID()))
+		Expect(spans[0].Attributes()).To(ContainElement(tracing.RunId.String("run_1")))
+		Expect(spans[1].Attributes()).To(ContainElement(tracing.Project.String("majordomo")))
+	})
+
+	It("records the errors", func() {
+		failing := func() (err error) {
+			_, span := tracing.Start(context.Background(), "WaitRun")
+			defer tracing.End(span, &err)
+			return errors.New("run failed")
+		}
+		Expect(failing()).To(HaveOccurred())
+
+		spans := recorder.Ended()
+		Expect(spans).To(HaveLen(1))
+		Expect(spans[0].Status().Code).To(Equal(codes.Error))
+		Expect(spans[0].Status().Description).To(Equal("run failed"))
+	})
+
+	It("names the spans of the OpenAI calls after the operation", func() {
+		client := tracing.HTTPClient(http.DefaultTransport)
+		req, _ := http.NewRequest(http.MethodGet, "http://127.0.0.1:0/v1/threads/thread_abc/runs/run_xyz", nil)
+		_, err := client.Do(req)
+		Expect(err).To(HaveOccurred())
+
+		spans := recorder.Ended()
+		Expect(spans).To(HaveLen(1))
+		Expect(spans[0].Name()).To(Equal("OpenAI GET /threads/:id/runs/:id"))
+	})
+})
@@ -8,7 +8,7 @@ import streamlit as st
 from constants import SERVER, PORT
 from utils import get_logger
 
-BASE_URL = f"http://{SERVER}:{PORT}"
+BASE_URL = f"http://{SERVER}:{PORT}/api/v1"
 LOG = get_logger()
 
 
@@ -21,6 +21,16 @@ class ResponseError:
     message: str
 
 
+def error_message(response: requests.Response) -> str:
+    """
+    Extracts the message from the server's error envelope, falling back to the raw body.
+    """
+    try:
+        return response.json()["error"]["message"]
+    except (ValueError, KeyError, TypeError):
+        return response.text
+
+
 @dataclass
 class Project:
     name: str
@@ -75,7 +85,7 @@ def get_assistants() -> Union[List[Assistant], ResponseError]:
     except requests.RequestException as e:
         return ResponseError(title="Connection Error", message=str(e))
     if response.status_code != 200:
-        return ResponseError(title="API Error", message=response.text)
+        return ResponseError(title="API Error", message=error_message(response))
     try:
         data = response.json()
         assistants = [Assistant.from_dict(item) for item in data]
@@ -92,7 +102,7 @@ def get_projects() -> Union[Tuple[str, List[Project]], ResponseError]:
     except requests.RequestException as e:
         return ResponseError(title="Connection Error", message=str(e))
     if response.status_code != 200:
-        return ResponseError(title="API Error", message=response.text)
+        return ResponseError(title="API Error", message=error_message(response))
     try:
         data = response.json()
         LOG.info("Data: %s", data)
@@ -111,7 +121,7 @@ def get_conversations(project_id: str) -> Union[List[Conversation], ResponseErro
     except requests.RequestException as e:
         return ResponseError(title="Connection Error", message=str(e))
     if response.status_code != 200:
-        return ResponseError(title="API Error", message=response.text)
+        return ResponseError(title="API Error", message=error_message(response))
     try:
         data = response.json()
         conversations = [Conversation.from_dict(item) for item in data.get("conversations", [])]
@@ -141,11 +151,8 @@ def ask_assistant(prompt: str, assistant: str, thread_id: Union[str, None] = Non
     except requests.RequestException as e:
         return ResponseError(title="Connection Error", message=str(e))
     if response.status_code != 200:
-        return ResponseError(title="API Error", message=response.text)
+        return ResponseError(title="API Error", message=error_message(response))
     try:
-        resp = response.json()
-        if resp.get("status") != "success":
-            return ResponseError(title="Assistant Error", message=resp.get("message"))
-        return resp
+        return response.json()
     except Exception as e:
         return ResponseError(title="Decoding Error", message=str(e))